@@ -0,0 +1,158 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Append атомарно добавляет элементы в конец среза, хранящегося по ключу,
+// и возвращает новую длину среза. Отсутствующий ключ считается пустым срезом
+// и записывается бессрочным; время жизни существующей записи сохраняется.
+// Без элементов значение не изменяется, а возвращается текущая длина.
+//
+// In-memory хранилище (NewMemory) добавляет элементы под блокировкой на запись,
+// Redis (NewRedis) с JSONCodec без WithMetadata - Lua-скриптом на сервере,
+// NewShardedRedis и NewRedisWithFallback - в хранилище, отвечающем за ключ.
+// Остальные хранилища и Redis с другим кодеком выполняют чтение и запись
+// в Transact: ключ записывается с ttl 0, поэтому его время жизни не сохраняется.
+//
+// Если значение в Redis не является JSON-массивом, возвращается ErrWrongType.
+func Append[E any](ctx context.Context, s Storage[[]E], key string, elems ...E) (int, error) {
+	switch st := s.(type) {
+	case *memoryStorage[[]E]:
+		return memoryAppend(st, key, elems)
+	case *redisStorage[[]E]:
+		if _, ok := st.opts.codec.(JSONCodec); ok && !st.opts.metadata {
+			return redisAppend(ctx, st, key, elems)
+		}
+	case *shardedStorage[[]E]:
+		key = st.opts.normalizeKey(key)
+		return Append(ctx, st.shard(key), key, elems...)
+	case *fallbackStorage[[]E]:
+		return Append(ctx, st.active(), key, elems...)
+	}
+	return txAppend(ctx, s, key, elems)
+}
+
+// memoryAppend добавляет элементы под блокировкой itemMu на запись.
+// Новый срез всегда размещается заново, поэтому срезы, возвращенные
+// ранее из Get, не изменяются.
+func memoryAppend[E any](s *memoryStorage[[]E], key string, elems []E) (int, error) {
+	key, origKey, err := s.opts.resolveKey(key)
+	if err != nil {
+		return 0, err
+	}
+	s.hot.record(key)
+
+	s.itemMu.Lock()         // Блокируем на запись
+	defer s.itemMu.Unlock() // Гарантируем разблокировку
+
+	var current []E
+	var expiration int64
+	if item, found := s.items[key]; found && !item.isExpired() {
+		current, expiration = item.value, item.expiration
+	}
+	if len(elems) == 0 {
+		return len(current), nil
+	}
+
+	value := append(slices.Clip(current), elems...)
+	if err := s.opts.checkValueSize(value); err != nil {
+		return 0, err
+	}
+	s.items[key] = s.newItem(key, origKey, value, expiration)
+	s.track(key, value)
+	s.stats.sets.Add(1)
+	return len(value), nil
+}
+
+// appendScript дописывает JSON-массив новых элементов к JSON-массиву ключа
+// с сохранением времени жизни (SET KEEPTTL, Redis 6.0+). Прежнее значение
+// разбирается только для проверки и подсчета длины, а результат склеивается
+// из исходных строк, поэтому числа и строки не переформатируются cjson.
+// KEYS[1] - ключ; ARGV[1] - непустой JSON-массив новых элементов;
+// ARGV[2] - количество новых элементов; ARGV[3] - максимальный размер значения (0 - без ограничения).
+// Возвращает новую длину, -1, если значение не JSON-массив, или -2, если результат слишком велик.
+var appendScript = redis.NewScript(`
+local cur = redis.call('GET', KEYS[1])
+local n = tonumber(ARGV[2])
+local value = ARGV[1]
+if cur and cur ~= 'null' then
+	if not string.find(cur, '^%s*%[') then
+		return -1
+	end
+	local ok, old = pcall(cjson.decode, cur)
+	if not ok or type(old) ~= 'table' then
+		return -1
+	end
+	if #old > 0 then
+		value = string.match(cur, '^(.*)%]%s*$') .. ',' .. string.sub(ARGV[1], 2)
+	end
+	n = n + #old
+end
+local limit = tonumber(ARGV[3])
+if limit > 0 and #value > limit then
+	return -2
+end
+redis.call('SET', KEYS[1], value, 'KEEPTTL')
+return n
+`)
+
+// redisAppend дописывает элементы скриптом appendScript.
+// Без элементов возвращает длину, прочитанную командой GET.
+func redisAppend[E any](ctx context.Context, s *redisStorage[[]E], key string, elems []E) (int, error) {
+	if len(elems) == 0 {
+		current, _, err := s.Get(ctx, key)
+		return len(current), err
+	}
+
+	key, err := s.checkKey(key)
+	if err != nil {
+		return 0, err
+	}
+	s.hot.record(key)
+
+	data, err := s.opts.marshalValue(elems)
+	if err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 1*time.Second)
+	defer cancel()
+
+	n, err := appendScript.Run(ctx, s.client, []string{key}, data, len(elems), s.opts.maxValueBytes).Int()
+	if err != nil {
+		return 0, wrapRedisErr("append", err)
+	}
+	switch n {
+	case -1:
+		return 0, fmt.Errorf("%w: value is not a JSON array", ErrWrongType)
+	case -2:
+		return 0, fmt.Errorf("%w: limit %d", ErrValueTooLarge, s.opts.maxValueBytes)
+	}
+	s.invalidate(key)
+	return n, nil
+}
+
+// txAppend читает и перезаписывает срез в транзакции хранилища.
+func txAppend[E any](ctx context.Context, s Storage[[]E], key string, elems []E) (int, error) {
+	var n int
+	err := s.Transact(ctx, func(tx Tx[[]E]) error {
+		current, _, err := tx.Get(key)
+		if err != nil {
+			return err
+		}
+		if n = len(current) + len(elems); len(elems) == 0 {
+			return nil
+		}
+		return tx.Set(key, append(slices.Clip(current), elems...), 0)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
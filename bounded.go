@@ -0,0 +1,66 @@
+package storage
+
+import "github.com/redis/go-redis/v9"
+
+// EnqueueReason - результат TryEnqueue.
+type EnqueueReason int
+
+const (
+	ReasonOK     EnqueueReason = iota // Элемент добавлен
+	ReasonFull                        // Очередь заполнена: в ней уже maxLen элементов
+	ReasonClosed                      // Хранилище закрыто
+	ReasonError                       // Ошибка хранилища или значения (возвращается вместе с ней)
+)
+
+// String возвращает имя причины (например, "full").
+func (r EnqueueReason) String() string {
+	switch r {
+	case ReasonOK:
+		return "ok"
+	case ReasonFull:
+		return "full"
+	case ReasonClosed:
+		return "closed"
+	case ReasonError:
+		return "error"
+	default:
+		return "unknown"
+	}
+}
+
+// Скрипты ограниченных очередей выполняются через redis.Script: Run отправляет
+// EVALSHA с закэшированным SHA1 тела скрипта и при ответе NOSCRIPT (например, после
+// перезапуска Redis или SCRIPT FLUSH) повторяет вызов через EVAL, заново загружая скрипт.
+// Проверка длины и добавление выполняются атомарно, поэтому конкурентные
+// производители не могут превысить ограничение.
+
+// boundedEnqueueScript добавляет элемент в конец очереди, только если ее длина меньше лимита.
+// Возвращает новую длину очереди, -1, если очередь заполнена, или тег очереди при несовпадении.
+// KEYS[1] - очередь; KEYS[2] - тег очереди (см. queueTagCheckLua);
+// ARGV[1] - тег элемента; ARGV[2] - элемент; ARGV[3] - максимальная длина.
+var boundedEnqueueScript = redis.NewScript(queueTagCheckLua + `
+if redis.call('LLEN', KEYS[1]) >= tonumber(ARGV[3]) then
+	return -1
+end
+local n = redis.call('RPUSH', KEYS[1], ARGV[2])
+` + queueTagSetLua + `
+return n
+`)
+
+// cappedEnqueueScript добавляет элемент в конец очереди и обрезает ее
+// до последних maxLen элементов. Возвращает длину очереди до обрезки
+// (значение больше maxLen означает, что начало очереди отброшено)
+// или тег очереди при несовпадении. Обрезанная очередь помечается разобранной.
+// KEYS[1] - очередь; KEYS[2] - тег очереди (см. queueTagCheckLua);
+// KEYS[3] - метка разобранной очереди (см. queueConsumedKey);
+// ARGV[1] - тег элемента; ARGV[2] - элемент; ARGV[3] - максимальная длина.
+var cappedEnqueueScript = redis.NewScript(queueTagCheckLua + `
+local n = redis.call('RPUSH', KEYS[1], ARGV[2])
+local limit = tonumber(ARGV[3])
+if n > limit then
+	redis.call('LTRIM', KEYS[1], -limit, -1)
+	redis.call('SET', KEYS[3], 1)
+end
+` + queueTagSetLua + `
+return n
+`)
@@ -0,0 +1,64 @@
+package storage
+
+import "strings"
+
+// Capability - набор возможностей хранилища в виде битовой маски.
+// Позволяет во время выполнения узнать, что поддерживает конкретная
+// реализация Storage[T], и выбрать запасной вариант заранее:
+//
+//	if store.Capabilities().Has(storage.CapPersistence) {
+//		// данные переживут перезапуск процесса
+//	}
+//
+// Значения констант стабильны: новые возможности добавляются только в конец.
+type Capability uint64
+
+const (
+	CapTTL           Capability = 1 << iota // Время жизни записей (Set с ttl, GetEx)
+	CapQueues                               // Очереди (Enqueue, Dequeue и т.д.)
+	CapPipeline                             // Пакетное выполнение команд за один сетевой вызов или захват блокировок
+	CapPatternDelete                        // Удаление по шаблону (DeletePattern)
+	CapScan                                 // Постраничный обход ключей (ScanPage)
+	CapMetadata                             // Метаданные записей (GetWithMeta возвращает время создания и изменения)
+	CapHotKeys                              // Отслеживание популярных ключей (HotKeyReporter)
+	CapStats                                // Счетчики операций (MemoryStatsProvider)
+	CapClientCache                          // Кэш на стороне клиента с инвалидацией сервером
+	CapPersistence                          // Данные хранятся вне процесса и переживают его перезапуск
+	CapShared                               // Данные доступны нескольким процессам одновременно
+	CapTransactions                         // Атомарные транзакции с несколькими ключами (Transact)
+)
+
+// capabilityNames содержит имена возможностей в порядке битов.
+var capabilityNames = []string{
+	"ttl",
+	"queues",
+	"pipeline",
+	"pattern-delete",
+	"scan",
+	"metadata",
+	"hot-keys",
+	"stats",
+	"client-cache",
+	"persistence",
+	"shared",
+	"transactions",
+}
+
+// Has проверяет, что набор содержит все возможности из want.
+func (c Capability) Has(want Capability) bool {
+	return c&want == want
+}
+
+// String возвращает имена возможностей через "|" (например, "ttl|queues").
+func (c Capability) String() string {
+	var names []string
+	for i, name := range capabilityNames {
+		if c&(1<<i) != 0 {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, "|")
+}
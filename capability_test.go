@@ -0,0 +1,166 @@
+package storage_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/alfzs/go-storage"
+	"github.com/stretchr/testify/require"
+)
+
+// requireCapabilities проверяет, что хранилище действительно поддерживает
+// каждую возможность, которую возвращает его Capabilities.
+// open создает новое хранилище с теми же параметрами поверх тех же данных,
+// want - возможности, которые хранилище с этими параметрами должно заявлять.
+func requireCapabilities(t *testing.T, want storage.Capability, open func(t *testing.T) storage.Storage[string]) {
+	ctx := context.Background()
+	s := open(t)
+	defer s.Close()
+
+	caps := s.Capabilities()
+	require.True(t, caps.Has(want), "claims %s, want %s", caps, want)
+	for c := storage.CapTTL; c <= storage.CapTransactions; c <<= 1 {
+		if !caps.Has(c) {
+			continue
+		}
+		t.Run(c.String(), func(t *testing.T) {
+			switch c {
+			case storage.CapTTL:
+				require.NoError(t, s.Set(ctx, "caps:ttl", "v", time.Minute))
+				_, ttl, found, err := s.GetWithTTL(ctx, "caps:ttl")
+				require.NoError(t, err)
+				require.True(t, found)
+				require.Positive(t, ttl)
+				_, found, err = s.GetEx(ctx, "caps:ttl", 0)
+				require.NoError(t, err)
+				require.True(t, found)
+
+			case storage.CapQueues:
+				require.NoError(t, s.Enqueue(ctx, "caps:queue", "job"))
+				value, found, err := s.Dequeue(ctx, "caps:queue")
+				require.NoError(t, err)
+				require.True(t, found)
+				require.Equal(t, "job", value)
+
+			case storage.CapPipeline:
+				pipe := s.Pipeline()
+				pipe.Set("caps:pipe", "v", 0)
+				pipe.Get("caps:pipe")
+				results, err := pipe.Exec(ctx)
+				require.NoError(t, err)
+				require.Len(t, results, 2)
+				require.True(t, results[1].Found)
+
+			case storage.CapPatternDelete:
+				require.NoError(t, s.Set(ctx, "caps:pattern:1", "v", 0))
+				n, err := s.DeletePattern(ctx, "caps:pattern:*")
+				require.NoError(t, err)
+				require.Equal(t, int64(1), n)
+
+			case storage.CapScan:
+				require.NoError(t, s.Set(ctx, "caps:scan", "v", 0))
+				var keys []string
+				for cursor := uint64(0); ; {
+					page, next, err := s.ScanPage(ctx, cursor, "caps:scan", 100)
+					require.NoError(t, err)
+					keys = append(keys, page...)
+					if cursor = next; cursor == 0 {
+						break
+					}
+				}
+				require.True(t, slices.Contains(keys, "caps:scan"))
+
+			case storage.CapMetadata:
+				require.NoError(t, s.Set(ctx, "caps:meta", "v", 0))
+				_, meta, found, err := s.GetWithMeta(ctx, "caps:meta")
+				require.NoError(t, err)
+				require.True(t, found)
+				require.False(t, meta.CreatedAt.IsZero())
+
+			case storage.CapHotKeys:
+				_, _, err := s.Get(ctx, "caps:hot")
+				require.NoError(t, err)
+				hr, ok := s.(storage.HotKeyReporter)
+				require.True(t, ok)
+				require.NotEmpty(t, hr.HotKeys(10))
+
+			case storage.CapStats:
+				require.NoError(t, s.Set(ctx, "caps:stats", "v", 0))
+				sp, ok := s.(storage.MemoryStatsProvider)
+				require.True(t, ok)
+				require.Positive(t, sp.Stats().Sets)
+
+			case storage.CapClientCache:
+				require.NoError(t, s.Set(ctx, "caps:cached", "old", 0))
+				_, _, err := s.Get(ctx, "caps:cached") // Значение попадает в кэш
+				require.NoError(t, err)
+				other := open(t)
+				defer other.Close()
+				require.NoError(t, other.Set(ctx, "caps:cached", "new", 0))
+				require.Eventually(t, func() bool {
+					value, _, err := s.Get(ctx, "caps:cached")
+					return err == nil && value == "new"
+				}, time.Second, 10*time.Millisecond)
+
+			case storage.CapPersistence:
+				writer := open(t)
+				require.NoError(t, writer.Set(ctx, "caps:persist", "v", 0))
+				require.NoError(t, writer.Close())
+				value, found, err := s.Get(ctx, "caps:persist")
+				require.NoError(t, err)
+				require.True(t, found)
+				require.Equal(t, "v", value)
+
+			case storage.CapShared:
+				other := open(t)
+				defer other.Close()
+				require.NoError(t, other.Set(ctx, "caps:shared", "v", 0))
+				value, found, err := s.Get(ctx, "caps:shared")
+				require.NoError(t, err)
+				require.True(t, found)
+				require.Equal(t, "v", value)
+
+			case storage.CapTransactions:
+				err := s.Transact(ctx, func(tx storage.Tx[string]) error {
+					if err := tx.Set("caps:tx:1", "a", 0); err != nil {
+						return err
+					}
+					return tx.Set("caps:tx:2", "b", 0)
+				})
+				require.NoError(t, err)
+				value, found, err := s.Get(ctx, "caps:tx:2")
+				require.NoError(t, err)
+				require.True(t, found)
+				require.Equal(t, "b", value)
+
+			default:
+				t.Fatalf("capability %s is not checked", c)
+			}
+		})
+	}
+	_, _ = s.DeletePattern(ctx, "caps:*")
+}
+
+func TestMemoryStorage_CapabilitiesSupported(t *testing.T) {
+	want := storage.CapTTL | storage.CapQueues | storage.CapPipeline | storage.CapPatternDelete | storage.CapScan |
+		storage.CapMetadata | storage.CapHotKeys | storage.CapStats | storage.CapTransactions
+	requireCapabilities(t, want, func(t *testing.T) storage.Storage[string] {
+		s, err := storage.NewMemory[string](time.Hour, storage.WithMetadata(true), storage.WithHotKeyTracking(10))
+		require.NoError(t, err)
+		return s
+	})
+}
+
+func TestRedisStorage_CapabilitiesSupported(t *testing.T) {
+	want := storage.CapTTL | storage.CapQueues | storage.CapPipeline | storage.CapPatternDelete | storage.CapScan |
+		storage.CapMetadata | storage.CapHotKeys | storage.CapClientCache | storage.CapPersistence |
+		storage.CapShared | storage.CapTransactions
+	requireCapabilities(t, want, func(t *testing.T) storage.Storage[string] {
+		cfg := storage.RedisConfig{Addr: "localhost:6379", ClientCacheTTL: time.Minute}
+		s, err := storage.NewRedis[string](cfg, storage.WithMetadata(true), storage.WithHotKeyTracking(10))
+		require.NoError(t, err)
+		return s
+	})
+}
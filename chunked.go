@@ -0,0 +1,273 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ChunkedStore реализуется хранилищем Redis для значений, которые не помещаются
+// в один ключ. Значение разбивается на пронумерованные ключи-части, список которых
+// хранится в манифесте. Части и манифест хранятся под зарезервированным префиксом "c:"
+// и не возвращаются обходами ключей (ScanPage, GetByPrefix, CountPattern, DeletePattern,
+// Query, Copy). Без WithNamespaces этот префикс общий с обычными ключами, поэтому
+// не используйте обычные ключи, начинающиеся с "c:": Get по ним прочитает манифест или часть.
+// Хранилище можно привести к этому интерфейсу:
+//
+//	if cs, ok := store.(storage.ChunkedStore); ok {
+//		err := cs.SetChunked(ctx, "report", file, 512<<10, time.Hour)
+//	}
+type ChunkedStore interface {
+	// SetChunked читает r до конца и сохраняет данные частями по chunkSize байт
+	// ctx - контекст для управления временем выполнения
+	// key - ключ значения
+	// r - источник данных
+	// chunkSize - размер части в байтах
+	// ttl - время жизни частей и манифеста (0 - бессрочно)
+	// Предыдущее значение ключа заменяется только после записи всех частей,
+	// поэтому при ошибке чтения r или записи в Redis остается прежнее значение
+	SetChunked(ctx context.Context, key string, r io.Reader, chunkSize int, ttl time.Duration) error
+
+	// GetChunked возвращает поток, собирающий значение из частей по мере чтения
+	// ctx - контекст чтения частей (используется и во время чтения потока)
+	// key - ключ значения
+	// Возвращает:
+	//   - поток данных (закрывать обязательно)
+	//   - флаг наличия значения
+	//   - ошибку в случае неудачи
+	// Если значение перезаписано или удалено во время чтения, Read вернет ErrChunkMissing
+	GetChunked(ctx context.Context, key string) (io.ReadCloser, bool, error)
+
+	// DeleteChunked удаляет манифест и все части значения
+	// Возвращает ошибку в случае неудачи; отсутствие значения ошибкой не считается
+	DeleteChunked(ctx context.Context, key string) error
+}
+
+// ErrChunkMissing возвращается при чтении потока GetChunked, если часть значения
+// удалена, истекла или заменена новой записью во время чтения.
+var ErrChunkMissing = errors.New("storage: chunk missing")
+
+// ErrInvalidChunkSize возвращается SetChunked при неположительном размере части.
+var ErrInvalidChunkSize = errors.New("storage: chunk size must be positive")
+
+// chunkManifest описывает значение, сохраненное частями.
+// Каждая запись SetChunked пишет части под новым поколением Gen,
+// поэтому части прежнего значения не смешиваются с новыми.
+type chunkManifest struct {
+	Gen    string `json:"gen"`    // Поколение частей
+	Chunks int    `json:"chunks"` // Количество частей
+	Size   int64  `json:"size"`   // Общий размер в байтах
+}
+
+// chunkManifestKey возвращает имя манифеста в Redis.
+// key - имя ключа в Redis (см. checkKey).
+func (s *redisStorage[T]) chunkManifestKey(key string) string {
+	return chunkNamespace + key
+}
+
+// chunkKey возвращает имя части с номером i поколения gen в Redis.
+func (s *redisStorage[T]) chunkKey(key, gen string, i int) string {
+	return chunkNamespace + key + ":" + gen + ":" + strconv.Itoa(i)
+}
+
+// SetChunked записывает части по одной командой SET, затем заменяет манифест
+// и удаляет части прежнего значения.
+func (s *redisStorage[T]) SetChunked(ctx context.Context, key string, r io.Reader, chunkSize int, ttl time.Duration) error {
+	if chunkSize <= 0 {
+		return ErrInvalidChunkSize
+	}
+	if s.opts.maxValueBytes > 0 && chunkSize > s.opts.maxValueBytes {
+		return fmt.Errorf("%w: chunk size %d exceeds %d bytes", ErrValueTooLarge, chunkSize, s.opts.maxValueBytes)
+	}
+	key, err := s.checkKey(key)
+	if err != nil {
+		return err
+	}
+
+	var genBytes [8]byte
+	_, _ = rand.Read(genBytes[:])
+	m := chunkManifest{Gen: hex.EncodeToString(genBytes[:])}
+
+	// Части без манифеста удаляются, если запись не завершилась
+	cleanup := func() { s.deleteChunks(key, m) }
+
+	buf := make([]byte, chunkSize)
+	for {
+		n, readErr := io.ReadFull(r, buf)
+		if n > 0 {
+			if err := s.setChunk(ctx, s.chunkKey(key, m.Gen, m.Chunks), buf[:n], ttl); err != nil {
+				cleanup()
+				return err
+			}
+			m.Chunks++
+			m.Size += int64(n)
+		}
+		if errors.Is(readErr, io.EOF) || errors.Is(readErr, io.ErrUnexpectedEOF) {
+			break
+		}
+		if readErr != nil {
+			cleanup()
+			return fmt.Errorf("chunked read failed: %w", readErr)
+		}
+	}
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		cleanup()
+		return fmt.Errorf("manifest encode failed: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, 1*time.Second)
+	defer cancel()
+
+	args := redis.SetArgs{Get: true, TTL: ttl}
+	old, err := s.client.SetArgs(ctx, s.chunkManifestKey(key), data, args).Result()
+	if err != nil && err != redis.Nil {
+		cleanup()
+		return wrapRedisErr("set manifest", err)
+	}
+
+	var prev chunkManifest
+	if err == nil && json.Unmarshal([]byte(old), &prev) == nil && prev.Gen != m.Gen {
+		s.deleteChunks(key, prev)
+	}
+	return nil
+}
+
+// setChunk сохраняет одну часть значения.
+func (s *redisStorage[T]) setChunk(ctx context.Context, chunkKey string, data []byte, ttl time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, 1*time.Second)
+	defer cancel()
+
+	if err := s.client.Set(ctx, chunkKey, data, ttl).Err(); err != nil {
+		return wrapRedisErr("set chunk", err)
+	}
+	return nil
+}
+
+// deleteChunks удаляет части значения по манифесту, не возвращая ошибку:
+// оставшиеся части не видны без манифеста и истекают вместе с TTL значения.
+func (s *redisStorage[T]) deleteChunks(key string, m chunkManifest) {
+	if m.Chunks == 0 {
+		return
+	}
+	keys := make([]string, m.Chunks)
+	for i := range keys {
+		keys[i] = s.chunkKey(key, m.Gen, i)
+	}
+	_, _ = s.deleteKeys(context.Background(), keys) // По одному ключу: в Redis Cluster части лежат в разных слотах
+}
+
+// GetChunked читает манифест и возвращает поток, запрашивающий части по одной.
+func (s *redisStorage[T]) GetChunked(ctx context.Context, key string) (io.ReadCloser, bool, error) {
+	key, err := s.checkKey(key)
+	if err != nil {
+		return nil, false, err
+	}
+	m, found, err := s.chunkManifest(ctx, key)
+	if err != nil || !found {
+		return nil, false, err
+	}
+	return &chunkReader[T]{ctx: ctx, s: s, key: key, m: m}, true, nil
+}
+
+// chunkManifest читает манифест значения.
+func (s *redisStorage[T]) chunkManifest(ctx context.Context, key string) (chunkManifest, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 1*time.Second)
+	defer cancel()
+
+	var m chunkManifest
+	data, err := s.client.Get(ctx, s.chunkManifestKey(key)).Bytes()
+	if err == redis.Nil {
+		return m, false, nil
+	}
+	if err != nil {
+		return m, false, wrapRedisErr("get manifest", err)
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return m, false, fmt.Errorf("manifest decode failed: %w", err)
+	}
+	return m, true, nil
+}
+
+// DeleteChunked удаляет манифест командой GETDEL, затем части.
+func (s *redisStorage[T]) DeleteChunked(ctx context.Context, key string) error {
+	key, err := s.checkKey(key)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 1*time.Second)
+	defer cancel()
+
+	data, err := s.client.GetDel(ctx, s.chunkManifestKey(key)).Bytes()
+	if err == redis.Nil {
+		return nil
+	}
+	if err != nil {
+		return wrapRedisErr("getdel manifest", err)
+	}
+
+	var m chunkManifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return fmt.Errorf("manifest decode failed: %w", err)
+	}
+	s.deleteChunks(key, m)
+	return nil
+}
+
+// chunkReader собирает значение из частей по мере чтения.
+type chunkReader[T any] struct {
+	ctx  context.Context
+	s    *redisStorage[T]
+	key  string        // Имя ключа в Redis
+	m    chunkManifest // Манифест на момент открытия потока
+	next int           // Номер следующей части
+	buf  []byte        // Непрочитанный остаток текущей части
+	err  error         // Ошибка, возвращаемая последующими вызовами Read
+}
+
+// Read возвращает данные текущей части, запрашивая следующую, когда текущая прочитана.
+func (r *chunkReader[T]) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		if r.next == r.m.Chunks {
+			return 0, io.EOF
+		}
+		r.buf, r.err = r.fetch(r.next)
+		r.next++
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+// fetch получает часть с номером i.
+func (r *chunkReader[T]) fetch(i int) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(r.ctx, 1*time.Second)
+	defer cancel()
+
+	data, err := r.s.client.Get(ctx, r.s.chunkKey(r.key, r.m.Gen, i)).Bytes()
+	if err == redis.Nil {
+		return nil, fmt.Errorf("%w: part %d of %d", ErrChunkMissing, i, r.m.Chunks)
+	}
+	if err != nil {
+		return nil, wrapRedisErr("get chunk", err)
+	}
+	return data, nil
+}
+
+// Close завершает чтение: последующие вызовы Read возвращают io.ErrClosedPipe.
+func (r *chunkReader[T]) Close() error {
+	r.buf, r.err = nil, io.ErrClosedPipe
+	return nil
+}
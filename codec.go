@@ -0,0 +1,334 @@
+package storage
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// Codec определяет способ сериализации значений для хранения.
+// Реализации должны быть безопасны для использования из разных горутин.
+type Codec interface {
+	// Marshal сериализует значение в байты
+	Marshal(v any) ([]byte, error)
+
+	// Unmarshal десериализует байты в значение по указателю v
+	Unmarshal(data []byte, v any) error
+}
+
+// JSONCodec сериализует значения в JSON с помощью encoding/json.
+// Используется по умолчанию.
+//
+// Вывод канонический: ключи map сортируются, поля структур записываются
+// в порядке объявления, поэтому равные значения всегда дают одинаковые байты.
+// На этом основаны операции, сравнивающие сериализованные значения
+// (например, поиск или удаление элемента по значению).
+//
+// Особенности работы со временем:
+//   - time.Duration сохраняется как целое число наносекунд и восстанавливается без потерь;
+//   - time.Time сохраняется в формате RFC 3339 с наносекундами и смещением часового пояса,
+//     поэтому момент времени и смещение восстанавливаются точно, включая нулевое значение.
+//
+// При этом теряются показания монотонных часов и имя зоны (time.Location заменяется
+// фиксированной зоной с тем же смещением). Поэтому восстановленное время нужно
+// сравнивать через Time.Equal, а не оператором == или reflect.DeepEqual.
+type JSONCodec struct{}
+
+// Marshal сериализует значение в JSON.
+func (JSONCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Unmarshal десериализует JSON в значение по указателю v.
+func (JSONCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+// MarshalerFunc сериализует значение зарегистрированного в TypeCodec типа.
+// v имеет ровно зарегистрированный тип.
+type MarshalerFunc func(v any) ([]byte, error)
+
+// UnmarshalerFunc десериализует данные в значение по указателю v
+// на зарегистрированный в TypeCodec тип.
+type UnmarshalerFunc func(data []byte, v any) error
+
+// TypeCodec - кодек с собственными функциями сериализации для отдельных типов.
+// Для значения зарегистрированного типа используются его функции, для остальных -
+// кодек по умолчанию (JSONCodec, если не задан). Функции применяются только
+// ко всему значению: поля структур и элементы срезов сериализуются кодеком по умолчанию.
+//
+// Формат данных не помечается, поэтому записи, сохраненные до регистрации типа,
+// нельзя прочитать после нее, и наоборот. Функции должны давать одинаковые байты
+// для равных значений, как JSONCodec, иначе операции, сравнивающие сериализованные
+// значения, перестанут находить совпадения.
+//
+// Типы регистрируются до начала работы с хранилищем; методы безопасны
+// для использования из разных горутин.
+type TypeCodec struct {
+	fallback Codec
+	mu       sync.RWMutex
+	funcs    map[reflect.Type]typeFuncs
+}
+
+// typeFuncs - функции сериализации одного типа.
+type typeFuncs struct {
+	marshal   MarshalerFunc
+	unmarshal UnmarshalerFunc
+}
+
+// NewTypeCodec создает кодек с регистрацией типов поверх fallback (nil - JSONCodec).
+func NewTypeCodec(fallback Codec) *TypeCodec {
+	if fallback == nil {
+		fallback = JSONCodec{}
+	}
+	return &TypeCodec{fallback: fallback, funcs: make(map[reflect.Type]typeFuncs)}
+}
+
+// Register задает функции сериализации для типа t, заменяя ранее заданные.
+func (c *TypeCodec) Register(t reflect.Type, marshal MarshalerFunc, unmarshal UnmarshalerFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.funcs[t] = typeFuncs{marshal: marshal, unmarshal: unmarshal}
+}
+
+// RegisterType задает для типа V типизированные функции сериализации.
+func RegisterType[V any](c *TypeCodec, marshal func(V) ([]byte, error), unmarshal func([]byte, *V) error) {
+	c.Register(reflect.TypeFor[V](),
+		func(v any) ([]byte, error) { return marshal(v.(V)) },
+		func(data []byte, v any) error { return unmarshal(data, v.(*V)) },
+	)
+}
+
+// lookup возвращает функции типа t, если он зарегистрирован.
+func (c *TypeCodec) lookup(t reflect.Type) (typeFuncs, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	f, ok := c.funcs[t]
+	return f, ok
+}
+
+// Marshal сериализует значение функцией его типа или кодеком по умолчанию.
+func (c *TypeCodec) Marshal(v any) ([]byte, error) {
+	if f, ok := c.lookup(reflect.TypeOf(v)); ok {
+		return f.marshal(v)
+	}
+	return c.fallback.Marshal(v)
+}
+
+// Unmarshal десериализует данные функцией типа, на который указывает v,
+// или кодеком по умолчанию.
+func (c *TypeCodec) Unmarshal(data []byte, v any) error {
+	if t := reflect.TypeOf(v); t != nil && t.Kind() == reflect.Pointer {
+		if f, ok := c.lookup(t.Elem()); ok {
+			return f.unmarshal(data, v)
+		}
+	}
+	return c.fallback.Unmarshal(data, v)
+}
+
+// Serializable - значение с собственной двоичной сериализацией, которое BinaryCodec
+// сериализует без JSON. Для типа T методы MarshalBinary должен иметь T,
+// а UnmarshalBinary - *T (обычно с получателем-указателем).
+type Serializable interface {
+	encoding.BinaryMarshaler
+	encoding.BinaryUnmarshaler
+}
+
+// BinaryCodec сериализует значения типов, реализующих Serializable, их методами
+// MarshalBinary и UnmarshalBinary, минуя рефлексию encoding/json. Значения остальных
+// типов сериализуются кодеком Fallback (nil - JSONCodec). Проверка типа выполняется
+// один раз для каждого типа и кэшируется.
+//
+// Кодек не используется по умолчанию: он меняет формат хранения типов, которые уже
+// реализуют эти интерфейсы (например, time.Time), и не может прочитать их записи,
+// сохраненные JSONCodec.
+type BinaryCodec struct {
+	Fallback Codec
+}
+
+var (
+	binaryMarshalerType   = reflect.TypeFor[encoding.BinaryMarshaler]()
+	binaryUnmarshalerType = reflect.TypeFor[encoding.BinaryUnmarshaler]()
+
+	binaryTypes sync.Map // reflect.Type -> bool: сериализуется ли тип методами Serializable
+)
+
+// isBinaryType проверяет, что значения типа t сериализуются MarshalBinary
+// и восстанавливаются UnmarshalBinary по указателю *t.
+func isBinaryType(t reflect.Type) bool {
+	if t == nil {
+		return false
+	}
+	if ok, found := binaryTypes.Load(t); found {
+		return ok.(bool)
+	}
+	ok := t.Implements(binaryMarshalerType) && reflect.PointerTo(t).Implements(binaryUnmarshalerType)
+	binaryTypes.Store(t, ok)
+	return ok
+}
+
+// fallback возвращает кодек для остальных типов.
+func (c BinaryCodec) fallback() Codec {
+	if c.Fallback == nil {
+		return JSONCodec{}
+	}
+	return c.Fallback
+}
+
+// Marshal сериализует значение методом MarshalBinary или кодеком Fallback.
+func (c BinaryCodec) Marshal(v any) ([]byte, error) {
+	if m, ok := v.(encoding.BinaryMarshaler); ok && isBinaryType(reflect.TypeOf(v)) {
+		return m.MarshalBinary()
+	}
+	return c.fallback().Marshal(v)
+}
+
+// Unmarshal десериализует данные методом UnmarshalBinary или кодеком Fallback.
+// v должен быть указателем.
+func (c BinaryCodec) Unmarshal(data []byte, v any) error {
+	if u, ok := v.(encoding.BinaryUnmarshaler); ok && isBinaryType(reflect.TypeOf(v).Elem()) {
+		return u.UnmarshalBinary(data)
+	}
+	return c.fallback().Unmarshal(data, v)
+}
+
+// MarkedCodec - кодек, позволяющий отдельным записям использовать другой кодек
+// (SetWithCodec). Такие записи начинаются с байта-маркера кодека, по которому
+// их распознает Unmarshal; остальные записи сериализуются кодеком по умолчанию без маркера,
+// поэтому записи, сохраненные до перехода на MarkedCodec, читаются как прежде.
+//
+// Маркер не должен совпадать с первым байтом данных кодека по умолчанию:
+// для JSONCodec подходят управляющие байты 0x01-0x1f.
+//
+//	codec := storage.NewMarkedCodec(storage.JSONCodec{})
+//	codec.Register(0x01, msgpackCodec)
+//	store, err := storage.NewRedis[Event](cfg, storage.WithCodec(codec))
+//	...
+//	err = store.SetWithCodec(ctx, "hot", event, time.Minute, msgpackCodec)
+//
+// Кодеки регистрируются до начала работы с хранилищем; методы безопасны
+// для использования из разных горутин.
+type MarkedCodec struct {
+	fallback Codec
+	mu       sync.RWMutex
+	codecs   map[byte]Codec
+}
+
+// NewMarkedCodec создает кодек с маркерами поверх fallback (nil - JSONCodec).
+func NewMarkedCodec(fallback Codec) *MarkedCodec {
+	if fallback == nil {
+		fallback = JSONCodec{}
+	}
+	return &MarkedCodec{fallback: fallback, codecs: make(map[byte]Codec)}
+}
+
+// Register связывает кодек с маркером, заменяя ранее заданный кодек маркера.
+func (c *MarkedCodec) Register(marker byte, codec Codec) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.codecs[marker] = codec
+}
+
+// Marshal сериализует значение кодеком по умолчанию без маркера.
+func (c *MarkedCodec) Marshal(v any) ([]byte, error) {
+	return c.fallback.Marshal(v)
+}
+
+// Unmarshal десериализует данные кодеком их маркера или кодеком по умолчанию.
+func (c *MarkedCodec) Unmarshal(data []byte, v any) error {
+	return c.UnmarshalWith(c.fallback, data, v)
+}
+
+// MarshalWith сериализует значение кодеком codec и помечает данные его маркером.
+// Кодек по умолчанию (или nil) маркера не добавляет.
+// Возвращает ошибку ErrCodecNotRegistered, если codec не зарегистрирован.
+func (c *MarkedCodec) MarshalWith(codec Codec, v any) ([]byte, error) {
+	if codec == nil || sameCodec(codec, c.fallback) {
+		return c.fallback.Marshal(v)
+	}
+	marker, ok := c.marker(codec)
+	if !ok {
+		return nil, fmt.Errorf("%w: %T", ErrCodecNotRegistered, codec)
+	}
+	data, err := codec.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{marker}, data...), nil
+}
+
+// UnmarshalWith десериализует помеченные данные кодеком их маркера,
+// а данные без маркера - кодеком codec (nil - кодек по умолчанию).
+func (c *MarkedCodec) UnmarshalWith(codec Codec, data []byte, v any) error {
+	if len(data) > 0 {
+		c.mu.RLock()
+		marked, ok := c.codecs[data[0]]
+		c.mu.RUnlock()
+		if ok {
+			return marked.Unmarshal(data[1:], v)
+		}
+	}
+	if codec == nil {
+		codec = c.fallback
+	}
+	return codec.Unmarshal(data, v)
+}
+
+// marker возвращает маркер зарегистрированного кодека.
+func (c *MarkedCodec) marker(codec Codec) (byte, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for marker, registered := range c.codecs {
+		if sameCodec(registered, codec) {
+			return marker, true
+		}
+	}
+	return 0, false
+}
+
+// sameCodec сравнивает кодеки, не паникуя на несравнимых типах.
+func sameCodec(a, b Codec) bool {
+	ta := reflect.TypeOf(a)
+	return ta == reflect.TypeOf(b) && ta != nil && ta.Comparable() && a == b
+}
+
+// checkCodec проверяет, что данные кодека codec можно будет прочитать хранилищем
+// с кодеком base (см. marshalWithCodec).
+func checkCodec(base, codec Codec) error {
+	if codec == nil || sameCodec(codec, base) {
+		return nil
+	}
+	if marked, ok := base.(*MarkedCodec); ok {
+		if _, ok := marked.marker(codec); ok || sameCodec(codec, marked.fallback) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %T", ErrCodecNotRegistered, codec)
+}
+
+// marshalWithCodec сериализует значение кодеком codec для хранилища с кодеком base.
+// Если base - MarkedCodec, данные помечаются маркером codec; иначе codec должен
+// совпадать с base, так как прочитать данные без маркера можно только им.
+func marshalWithCodec(base, codec Codec, v any) ([]byte, error) {
+	if marked, ok := base.(*MarkedCodec); ok {
+		return marked.MarshalWith(codec, v)
+	}
+	if codec == nil || sameCodec(codec, base) {
+		return base.Marshal(v)
+	}
+	return nil, fmt.Errorf("%w: %T (storage codec is not a MarkedCodec)", ErrCodecNotRegistered, codec)
+}
+
+// unmarshalWithCodec десериализует данные хранилища с кодеком base,
+// используя codec для данных без маркера.
+func unmarshalWithCodec(base, codec Codec, data []byte, v any) error {
+	if marked, ok := base.(*MarkedCodec); ok {
+		return marked.UnmarshalWith(codec, data, v)
+	}
+	if codec == nil {
+		codec = base
+	}
+	return codec.Unmarshal(data, v)
+}
@@ -0,0 +1,231 @@
+package storage_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alfzs/go-storage"
+	"github.com/stretchr/testify/require"
+)
+
+type timedRecord struct {
+	At      time.Time
+	Timeout time.Duration
+}
+
+func TestJSONCodec_TimeRoundTrip(t *testing.T) {
+	codec := storage.JSONCodec{}
+	zone := time.FixedZone("UTC+3", 3*60*60)
+
+	cases := []timedRecord{
+		{},
+		{At: time.Now(), Timeout: 1500 * time.Millisecond},
+		{At: time.Date(2024, 2, 29, 23, 59, 59, 123456789, zone), Timeout: time.Nanosecond},
+		{At: time.Date(1969, 7, 20, 20, 17, 0, 1, time.UTC), Timeout: -time.Hour},
+	}
+
+	for _, in := range cases {
+		data, err := codec.Marshal(in)
+		require.NoError(t, err)
+
+		var out timedRecord
+		require.NoError(t, codec.Unmarshal(data, &out))
+
+		require.True(t, in.At.Equal(out.At), "time %v restored as %v", in.At, out.At)
+		require.Equal(t, in.At.IsZero(), out.At.IsZero())
+		require.Equal(t, in.Timeout, out.Timeout)
+
+		_, inOffset := in.At.Zone()
+		_, outOffset := out.At.Zone()
+		require.Equal(t, inOffset, outOffset)
+	}
+}
+
+func TestTypedStorage_TimeRoundTrip(t *testing.T) {
+	base, _ := storage.NewMemory[[]byte](50 * time.Millisecond)
+	defer base.Close()
+	ctx := context.Background()
+
+	s := storage.Typed[timedRecord](base, nil)
+	in := timedRecord{At: time.Now(), Timeout: 42 * time.Nanosecond}
+	require.NoError(t, s.Set(ctx, "timed", in, 0))
+
+	out, found, err := s.Get(ctx, "timed")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.True(t, in.At.Equal(out.At))
+	require.Equal(t, in.At.UnixNano(), out.At.UnixNano())
+	require.Equal(t, in.Timeout, out.Timeout)
+}
+
+func TestJSONCodec_StableMapOrdering(t *testing.T) {
+	type tagged struct {
+		Name   string
+		Labels map[string]int
+		Nested map[string]map[string]bool
+	}
+
+	codec := storage.JSONCodec{}
+	labels := make(map[string]int)
+	nested := make(map[string]map[string]bool)
+	for i := range 50 {
+		key := string(rune('a'+i%26)) + string(rune('A'+i/26))
+		labels[key] = i
+		nested[key] = map[string]bool{"x": true, "y": false, key: true}
+	}
+	value := tagged{Name: "stable", Labels: labels, Nested: nested}
+
+	first, err := codec.Marshal(value)
+	require.NoError(t, err)
+
+	for range 100 {
+		// Равное значение, собранное заново, дает те же байты
+		copied := tagged{Name: value.Name, Labels: make(map[string]int), Nested: make(map[string]map[string]bool)}
+		for k, v := range value.Labels {
+			copied.Labels[k] = v
+		}
+		for k, v := range value.Nested {
+			copied.Nested[k] = v
+		}
+
+		data, err := codec.Marshal(copied)
+		require.NoError(t, err)
+		require.Equal(t, first, data)
+	}
+}
+
+type point struct {
+	X, Y int16
+}
+
+func TestTypeCodec_RegisteredType(t *testing.T) {
+	codec := storage.NewTypeCodec(nil)
+	var calls int
+	storage.RegisterType(codec,
+		func(p point) ([]byte, error) {
+			calls++
+			return []byte{byte(p.X >> 8), byte(p.X), byte(p.Y >> 8), byte(p.Y)}, nil
+		},
+		func(data []byte, p *point) error {
+			p.X = int16(data[0])<<8 | int16(data[1])
+			p.Y = int16(data[2])<<8 | int16(data[3])
+			return nil
+		},
+	)
+
+	s, _ := storage.NewMemory[point](time.Hour, storage.WithCodec(codec))
+	defer s.Close()
+	ctx := context.Background()
+
+	require.NoError(t, s.Set(ctx, "p", point{X: 300, Y: -2}, 0))
+	raw, _, err := s.GetRaw(ctx, "p")
+	require.NoError(t, err)
+	require.Equal(t, []byte{0x01, 0x2c, 0xff, 0xfe}, raw)
+	require.Equal(t, 1, calls)
+
+	base, _ := storage.NewMemory[[]byte](time.Hour)
+	defer base.Close()
+	typed := storage.Typed[point](base, codec)
+	require.NoError(t, typed.Set(ctx, "p", point{X: 1, Y: 2}, 0))
+	val, found, err := typed.Get(ctx, "p")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, point{X: 1, Y: 2}, val)
+
+	// Незарегистрированные типы сериализуются кодеком по умолчанию
+	data, err := codec.Marshal(map[string]int{"a": 1})
+	require.NoError(t, err)
+	require.Equal(t, `{"a":1}`, string(data))
+}
+
+// version сериализуется BinaryCodec в 3 байта.
+type version struct {
+	Major, Minor, Patch uint8
+}
+
+func (v version) MarshalBinary() ([]byte, error) {
+	return []byte{v.Major, v.Minor, v.Patch}, nil
+}
+
+func (v *version) UnmarshalBinary(data []byte) error {
+	if len(data) != 3 {
+		return fmt.Errorf("version: want 3 bytes, got %d", len(data))
+	}
+	v.Major, v.Minor, v.Patch = data[0], data[1], data[2]
+	return nil
+}
+
+func TestBinaryCodec(t *testing.T) {
+	codec := storage.BinaryCodec{}
+
+	data, err := codec.Marshal(version{1, 2, 3})
+	require.NoError(t, err)
+	require.Equal(t, []byte{1, 2, 3}, data)
+
+	var v version
+	require.NoError(t, codec.Unmarshal(data, &v))
+	require.Equal(t, version{1, 2, 3}, v)
+
+	// Типы без двоичной сериализации и указатели на них используют JSON
+	data, err = codec.Marshal(point{X: 1})
+	require.NoError(t, err)
+	require.Equal(t, `{"X":1,"Y":0}`, string(data))
+	data, err = codec.Marshal(&version{1, 2, 3})
+	require.NoError(t, err)
+	require.Equal(t, `{"Major":1,"Minor":2,"Patch":3}`, string(data))
+
+	base, _ := storage.NewMemory[[]byte](time.Hour)
+	defer base.Close()
+	ctx := context.Background()
+	s := storage.Typed[version](base, codec)
+	require.NoError(t, s.Set(ctx, "v", version{4, 5, 6}, 0))
+	raw, _, _ := base.Get(ctx, "v")
+	require.Equal(t, []byte{4, 5, 6}, raw)
+	v, found, err := s.Get(ctx, "v")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, version{4, 5, 6}, v)
+}
+
+func TestMarkedCodec(t *testing.T) {
+	codec := storage.NewMarkedCodec(nil)
+	codec.Register(0x01, storage.BinaryCodec{})
+
+	base, _ := storage.NewMemory[[]byte](time.Hour)
+	defer base.Close()
+	ctx := context.Background()
+	s := storage.Typed[version](base, codec)
+
+	// Запись другим кодеком помечается маркером и читается обычным Get
+	require.NoError(t, s.SetWithCodec(ctx, "hot", version{1, 2, 3}, 0, storage.BinaryCodec{}))
+	raw, _, _ := base.Get(ctx, "hot")
+	require.Equal(t, []byte{0x01, 1, 2, 3}, raw)
+	v, found, err := s.Get(ctx, "hot")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, version{1, 2, 3}, v)
+
+	// Остальные записи хранятся без маркера
+	require.NoError(t, s.Set(ctx, "cold", version{4, 5, 6}, 0))
+	raw, _, _ = base.Get(ctx, "cold")
+	require.Equal(t, `{"Major":4,"Minor":5,"Patch":6}`, string(raw))
+
+	// Записи без маркера GetWithCodec читает переданным кодеком
+	require.NoError(t, base.Set(ctx, "legacy", []byte{7, 8, 9}, 0))
+	v, found, err = s.GetWithCodec(ctx, "legacy", storage.BinaryCodec{})
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, version{7, 8, 9}, v)
+
+	err = s.SetWithCodec(ctx, "hot", version{}, 0, storage.NewTypeCodec(nil))
+	require.ErrorIs(t, err, storage.ErrCodecNotRegistered)
+
+	// Хранилище без MarkedCodec принимает только свой кодек
+	plain, _ := storage.NewMemory[version](time.Hour)
+	defer plain.Close()
+	require.NoError(t, plain.SetWithCodec(ctx, "v", version{}, 0, storage.JSONCodec{}))
+	err = plain.SetWithCodec(ctx, "v", version{}, 0, storage.BinaryCodec{})
+	require.ErrorIs(t, err, storage.ErrCodecNotRegistered)
+}
@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// defaultHealthCheckInterval - период проверки соединения по умолчанию (RedisConfig.HealthCheckInterval).
+const defaultHealthCheckInterval = 5 * time.Second
+
+// connectHook добавляет вызов пользовательского hook к обработчику OnConnect клиента go-redis.
+// next - уже заданный обработчик (например, включение CLIENT TRACKING) или nil;
+// hook вызывается только после его успешного выполнения.
+func connectHook(addr string, hook func(ctx context.Context, addr string), next func(ctx context.Context, cn *redis.Conn) error) func(ctx context.Context, cn *redis.Conn) error {
+	if hook == nil {
+		return next
+	}
+	return func(ctx context.Context, cn *redis.Conn) error {
+		if next != nil {
+			if err := next(ctx, cn); err != nil {
+				return err
+			}
+		}
+		hook(ctx, addr)
+		return nil
+	}
+}
+
+// healthTarget - сервер, соединение с которым проверяет watchHealth.
+type healthTarget struct {
+	addr   string
+	client redis.Cmdable
+	down   bool // Последняя проверка завершилась ошибкой
+}
+
+// watchHealth периодически проверяет соединение с серверами командой PING
+// и вызывает onDisconnect при первой неудачной проверке после успешной.
+// Работает в фоновой горутине до закрытия хранилища.
+func (s *redisStorage[T]) watchHealth(interval time.Duration, onDisconnect func(addr string, err error), targets []*healthTarget) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			for _, target := range targets {
+				ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+				err := target.client.Ping(ctx).Err()
+				cancel()
+
+				select {
+				case <-s.stop:
+					return // Ошибка вызвана закрытием хранилища
+				default:
+				}
+				if err != nil && !target.down {
+					onDisconnect(target.addr, err)
+				}
+				target.down = err != nil
+			}
+		case <-s.stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,21 @@
+package storage
+
+// QueueReadFrom адресует элементы смещением от начала очереди, поэтому смещения стабильны,
+// только пока очередь используется как журнал. Операции, удаляющие или переставляющие
+// элементы (Dequeue, Remove, RemoveAt, EnqueueCapped с обрезкой, RotateQueue, ReplaceQueue,
+// MoveMatching, PeekBatchReserve и т.д.), помечают очередь разобранной, и QueueReadFrom
+// такой очереди возвращает ErrQueueConsumed. Метка бессрочна: опустевшая и заново
+// наполненная очередь тоже не читается по смещениям. В Redis метка хранится отдельной
+// строкой с префиксом queueConsumedNamespace (не возвращается обходами ключей) в слоте
+// списка очереди и записывается атомарно с удалением элементов: тем же скриптом
+// или в той же транзакции MULTI/EXEC. BlockingDequeueAny записывает метку до BLPOP.
+
+// queueConsumedNamespace - префикс строки Redis с меткой разобранной очереди.
+// Применяется всегда: метка не может разделять имя с очередью.
+const queueConsumedNamespace = "qd:"
+
+// queueConsumedKey возвращает имя строки Redis с меткой разобранной очереди. Имя строится
+// из имени списка очереди, поэтому учитывает WithNamespaces и лежит в слоте списка.
+func (s *redisStorage[T]) queueConsumedKey(queueName string) string {
+	return sameSlotKey(queueConsumedNamespace, s.queueKey(queueName))
+}
@@ -0,0 +1,165 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// TTLReader реализуется хранилищами, позволяющими прочитать оставшееся время жизни записи.
+// Хранилища, созданные NewMemory и NewRedis, можно привести к этому интерфейсу:
+//
+//	if tr, ok := store.(storage.TTLReader); ok {
+//		ttl, found, err := tr.TTL(ctx, "key")
+//	}
+type TTLReader interface {
+	// TTL возвращает оставшееся время жизни записи
+	// Возвращает:
+	//   - время жизни (0 - запись бессрочная)
+	//   - флаг наличия записи (false - ключ не найден или истек)
+	//   - ошибку (если возникла)
+	TTL(ctx context.Context, key string) (time.Duration, bool, error)
+}
+
+// QueueLister реализуется хранилищами, позволяющими получить список очередей.
+// Хранилища, созданные NewMemory и NewRedis, можно привести к этому интерфейсу.
+type QueueLister interface {
+	// QueueNames возвращает имена непустых очередей в произвольном порядке
+	// Отложенные и зарезервированные элементы не учитываются
+	QueueNames(ctx context.Context) ([]string, error)
+}
+
+// CopyOptions задает параметры переноса данных функцией Copy.
+type CopyOptions struct {
+	Match     string   // Glob-шаблон копируемых ключей (пустая строка - все ключи)
+	Queues    []string // Переносимые очереди (nil - все очереди src, если он реализует QueueLister)
+	Cursor    uint64   // Курсор ScanPage, с которого продолжить копирование ключей (0 - с начала)
+	BatchSize int      // Размер страницы ScanPage (0 - по умолчанию)
+}
+
+// CopyStats содержит результат работы Copy.
+type CopyStats struct {
+	Keys    int64  // Количество скопированных ключей
+	Skipped int64  // Количество ключей, удаленных или истекших во время копирования
+	Queues  int64  // Количество перенесенных очередей
+	Items   int64  // Количество перенесенных элементов очередей
+	Cursor  uint64 // Курсор для возобновления копирования ключей (0 - ключи скопированы полностью)
+}
+
+// Copy переносит данные из src в dst: копирует записи, найденные через ScanPage,
+// и перекладывает элементы очередей из src в конец одноименных очередей dst.
+// Время жизни записей сохраняется, если src реализует TTLReader, иначе записи
+// становятся бессрочными. Элементы очередей удаляются из src после записи в dst,
+// поэтому очереди src после успешного завершения пусты.
+//
+// Copy рассчитана на перенос без конкурентной записи в src. При ошибке возвращается
+// накопленная статистика: повторный вызов с CopyOptions.Cursor = CopyStats.Cursor
+// продолжает копирование ключей с прерванной страницы, а очереди продолжают
+// переноситься с текущего начала (элемент, записанный в dst, но не удаленный из src,
+// может быть перенесен повторно). Отложенные и зарезервированные элементы не переносятся.
+func Copy[T any](ctx context.Context, src, dst Storage[T], opts CopyOptions) (CopyStats, error) {
+	var stats CopyStats
+	if err := copyKeys(ctx, src, dst, opts, &stats); err != nil {
+		return stats, err
+	}
+
+	queues := opts.Queues
+	if queues == nil {
+		if ql, ok := src.(QueueLister); ok {
+			names, err := ql.QueueNames(ctx)
+			if err != nil {
+				return stats, fmt.Errorf("copy: list queues: %w", err)
+			}
+			queues = names
+		}
+	}
+	for _, name := range queues {
+		if err := copyQueue(ctx, src, dst, name, &stats); err != nil {
+			return stats, err
+		}
+	}
+	return stats, nil
+}
+
+// copyKeys копирует записи src в dst постранично.
+// После каждой страницы stats.Cursor указывает на следующую.
+func copyKeys[T any](ctx context.Context, src, dst Storage[T], opts CopyOptions, stats *CopyStats) error {
+	ttlReader, _ := src.(TTLReader)
+
+	cursor := opts.Cursor
+	stats.Cursor = cursor
+	for {
+		keys, next, err := src.ScanPage(ctx, cursor, opts.Match, opts.BatchSize)
+		if err != nil {
+			return fmt.Errorf("copy: scan: %w", err)
+		}
+
+		for _, key := range keys {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			copied, err := copyKey(ctx, src, dst, ttlReader, key)
+			if err != nil {
+				return fmt.Errorf("copy key %q: %w", key, err)
+			}
+			if copied {
+				stats.Keys++
+			} else {
+				stats.Skipped++
+			}
+		}
+
+		cursor = next
+		stats.Cursor = cursor
+		if cursor == 0 {
+			return nil
+		}
+	}
+}
+
+// copyKey копирует одну запись. Возвращает false, если запись исчезла из src.
+func copyKey[T any](ctx context.Context, src, dst Storage[T], ttlReader TTLReader, key string) (bool, error) {
+	value, found, err := src.Get(ctx, key)
+	if err != nil || !found {
+		return false, err
+	}
+
+	var ttl time.Duration
+	if ttlReader != nil {
+		ttl, found, err = ttlReader.TTL(ctx, key)
+		if err != nil || !found {
+			return false, err // Запись истекла между чтениями
+		}
+	}
+	return true, dst.Set(ctx, key, value, ttl)
+}
+
+// copyQueue перекладывает элементы очереди из src в dst по одному:
+// элемент удаляется из src только после успешной записи в dst.
+func copyQueue[T any](ctx context.Context, src, dst Storage[T], name string, stats *CopyStats) error {
+	moved := false
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		value, found, err := src.Peek(ctx, name)
+		if err != nil {
+			return fmt.Errorf("copy queue %q: %w", name, err)
+		}
+		if !found {
+			break
+		}
+		if err := dst.Enqueue(ctx, name, value); err != nil {
+			return fmt.Errorf("copy queue %q: %w", name, err)
+		}
+		if _, err := src.Remove(ctx, name); err != nil {
+			return fmt.Errorf("copy queue %q: %w", name, err)
+		}
+		stats.Items++
+		moved = true
+	}
+	if moved {
+		stats.Queues++
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package storage_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alfzs/go-storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCopy(t *testing.T) {
+	src, _ := storage.NewMemory[string](time.Hour)
+	defer src.Close()
+	dst, _ := storage.NewMemory[string](time.Hour)
+	defer dst.Close()
+	ctx := context.Background()
+
+	for i := range 25 {
+		require.NoError(t, src.Set(ctx, fmt.Sprintf("key:%02d", i), fmt.Sprint(i), 0))
+	}
+	require.NoError(t, src.Set(ctx, "session", "token", time.Minute))
+	for _, v := range []string{"a", "b", "c"} {
+		require.NoError(t, src.Enqueue(ctx, "jobs", v))
+	}
+
+	stats, err := storage.Copy(ctx, src, dst, storage.CopyOptions{BatchSize: 10})
+	require.NoError(t, err)
+	require.Equal(t, storage.CopyStats{Keys: 26, Queues: 1, Items: 3}, stats)
+
+	val, found, err := dst.Get(ctx, "key:07")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "7", val)
+
+	ttl, found, err := dst.(storage.TTLReader).TTL(ctx, "session")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Greater(t, ttl, 50*time.Second)
+
+	ttl, _, _ = dst.(storage.TTLReader).TTL(ctx, "key:00")
+	require.Zero(t, ttl)
+
+	items, err := dst.QueueTail(ctx, "jobs", 10)
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "b", "c"}, items)
+	length, _ := src.QueueLen(ctx, "jobs")
+	require.Zero(t, length)
+}
+
+func TestCopy_Resume(t *testing.T) {
+	src, _ := storage.NewMemory[int](time.Hour)
+	defer src.Close()
+	dst, _ := storage.NewMemory[int](time.Hour)
+	defer dst.Close()
+
+	for i := range 30 {
+		require.NoError(t, src.Set(context.Background(), fmt.Sprintf("k%02d", i), i, 0))
+	}
+
+	// Прерываем копирование после первой страницы
+	ctx, cancel := context.WithCancel(context.Background())
+	limited := &cancelAfterSet[int]{Storage: dst, n: 10, cancel: cancel}
+	stats, err := storage.Copy[int](ctx, src, limited, storage.CopyOptions{BatchSize: 10})
+	require.ErrorIs(t, err, context.Canceled)
+	require.Equal(t, int64(10), stats.Keys)
+	require.Equal(t, uint64(10), stats.Cursor)
+
+	stats, err = storage.Copy(context.Background(), src, dst, storage.CopyOptions{BatchSize: 10, Cursor: stats.Cursor})
+	require.NoError(t, err)
+	require.Equal(t, int64(20), stats.Keys)
+	require.Zero(t, stats.Cursor)
+
+	for i := range 30 {
+		val, found, _ := dst.Get(context.Background(), fmt.Sprintf("k%02d", i))
+		require.True(t, found)
+		require.Equal(t, i, val)
+	}
+}
+
+// cancelAfterSet отменяет контекст после n записей.
+type cancelAfterSet[T any] struct {
+	storage.Storage[T]
+	n      int
+	cancel context.CancelFunc
+}
+
+func (s *cancelAfterSet[T]) Set(ctx context.Context, key string, value T, ttl time.Duration) error {
+	if err := s.Storage.Set(ctx, key, value, ttl); err != nil {
+		return err
+	}
+	if s.n--; s.n == 0 {
+		s.cancel()
+	}
+	return nil
+}
+
+func TestCopy_CancelKeepsQueueItems(t *testing.T) {
+	src, _ := storage.NewMemory[int](time.Hour)
+	defer src.Close()
+	dst, _ := storage.NewMemory[int](time.Hour)
+	defer dst.Close()
+
+	for i := range 5 {
+		require.NoError(t, src.Enqueue(context.Background(), "jobs", i))
+	}
+
+	// Прерываем перенос очереди на середине: ни один элемент не теряется
+	ctx, cancel := context.WithCancel(context.Background())
+	limited := &cancelAfterEnqueue[int]{Storage: dst, n: 2, cancel: cancel}
+	stats, err := storage.Copy[int](ctx, src, limited, storage.CopyOptions{Queues: []string{"jobs"}})
+	require.ErrorIs(t, err, context.Canceled)
+	require.Equal(t, int64(2), stats.Items)
+
+	moved, _ := dst.QueueTail(context.Background(), "jobs", 10)
+	rest, _ := src.QueueTail(context.Background(), "jobs", 10)
+	require.Equal(t, []int{0, 1}, moved)
+	require.Equal(t, []int{2, 3, 4}, rest)
+}
+
+// cancelAfterEnqueue отменяет контекст после n добавлений в очередь.
+type cancelAfterEnqueue[T any] struct {
+	storage.Storage[T]
+	n      int
+	cancel context.CancelFunc
+}
+
+func (s *cancelAfterEnqueue[T]) Enqueue(ctx context.Context, queueName string, value T) error {
+	if err := s.Storage.Enqueue(ctx, queueName, value); err != nil {
+		return err
+	}
+	if s.n--; s.n == 0 {
+		s.cancel()
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package storage
+
+import "github.com/redis/go-redis/v9"
+
+// delayedItem - отложенный элемент in-memory очереди.
+type delayedItem[T any] struct {
+	value T     // Значение элемента
+	ready int64 // Время готовности в наносекундах
+}
+
+// delayedKey возвращает ключ сортированного множества отложенных элементов очереди
+// со временем готовности в качестве веса.
+func delayedKey(queueName string) string {
+	return queueName + ":delayed"
+}
+
+// delayedMember возвращает элемент сортированного множества для отложенного значения.
+// Случайный префикс фиксированной длины делает одинаковые значения различимыми.
+func delayedMember(data []byte) string {
+	return newReservationToken() + string(data)
+}
+
+// enqueueDelayedScript добавляет значение в множество отложенных элементов.
+// Время готовности отсчитывается по часам Redis.
+// KEYS[1] - множество отложенных элементов; KEYS[2] - тег очереди (см. queueTagCheckLua);
+// ARGV[1] - тег элемента; ARGV[2] - элемент; ARGV[3] - задержка в миллисекундах.
+// Возвращает 1 или тег очереди при несовпадении.
+var enqueueDelayedScript = redis.NewScript(queueTagCheckLua + `
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+redis.call('ZADD', KEYS[1], now + tonumber(ARGV[3]), ARGV[2])
+` + queueTagSetLua + `
+return 1
+`)
+
+// promoteDelayedScript переносит готовые отложенные элементы в конец очереди
+// в порядке времени готовности. Возвращает количество перенесенных элементов.
+// KEYS[1] - очередь; KEYS[2] - множество отложенных элементов.
+var promoteDelayedScript = redis.NewScript(`
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local members = redis.call('ZRANGEBYSCORE', KEYS[2], '-inf', now)
+for _, member in ipairs(members) do
+	redis.call('RPUSH', KEYS[1], string.sub(member, 33))
+	redis.call('ZREM', KEYS[2], member)
+end
+return #members
+`)
@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// deleteIfScript удаляет ключ, только если он хранит ожидаемое сериализованное значение.
+// Возвращает 1, если ключ удален, и 0 в остальных случаях.
+// KEYS[1] - ключ; ARGV[1] - ожидаемое значение.
+var deleteIfScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// deleteIfViaTransact удаляет ключ внутри транзакции хранилища s, если его значение
+// сериализуется кодеком codec в те же байты, что и expected.
+func deleteIfViaTransact[T any](ctx context.Context, s Storage[T], codec Codec, key string, expected T) (bool, error) {
+	want, err := codec.Marshal(expected)
+	if err != nil {
+		return false, fmt.Errorf("marshal failed: %w", err)
+	}
+
+	var deleted bool
+	err = s.Transact(ctx, func(tx Tx[T]) error {
+		current, found, err := tx.Get(key)
+		if deleted = false; err != nil || !found {
+			return err
+		}
+		got, err := codec.Marshal(current)
+		if err != nil {
+			return fmt.Errorf("marshal failed: %w", err)
+		}
+		if !bytes.Equal(got, want) {
+			return nil
+		}
+		deleted = true
+		return tx.Delete(key)
+	})
+	return deleted && err == nil, err
+}
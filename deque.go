@@ -0,0 +1,210 @@
+package storage
+
+import "iter"
+
+// dequeChunkSize - количество элементов в одном блоке очереди.
+const dequeChunkSize = 256
+
+// deque - очередь in-memory хранилища в виде связного списка блоков фиксированного размера.
+// Добавление и извлечение выполняются за амортизированное O(1) без перевыделения
+// всей очереди при росте, а полностью извлеченные блоки сразу становятся мусором.
+// Нулевое значение - пустая очередь; методы чтения допускают nil-получатель.
+// Не безопасна для конкурентного использования.
+type deque[T any] struct {
+	head  *dequeChunk[T] // Первый блок (nil, если очередь никогда не заполнялась)
+	tail  *dequeChunk[T] // Последний блок
+	start int            // Индекс первого элемента в head
+	end   int            // Индекс за последним элементом в tail
+	n     int            // Количество элементов
+}
+
+// dequeChunk - блок элементов очереди.
+type dequeChunk[T any] struct {
+	items [dequeChunkSize]T
+	next  *dequeChunk[T]
+}
+
+// Len возвращает количество элементов очереди.
+func (d *deque[T]) Len() int {
+	if d == nil {
+		return 0
+	}
+	return d.n
+}
+
+// Cap возвращает количество ячеек во всех блоках очереди, включая
+// уже извлеченные ячейки первого блока и свободные ячейки последнего.
+// Обходит список блоков, поэтому предназначена для диагностики.
+func (d *deque[T]) Cap() int {
+	if d == nil {
+		return 0
+	}
+	var chunks int
+	for c := d.head; c != nil; c = c.next {
+		chunks++
+	}
+	return chunks * dequeChunkSize
+}
+
+// PushBack добавляет элемент в конец очереди.
+func (d *deque[T]) PushBack(value T) {
+	switch {
+	case d.tail == nil:
+		d.head = &dequeChunk[T]{}
+		d.tail = d.head
+	case d.end == dequeChunkSize:
+		d.tail.next = &dequeChunk[T]{}
+		d.tail = d.tail.next
+		d.end = 0
+	}
+	d.tail.items[d.end] = value
+	d.end++
+	d.n++
+}
+
+// PushFront добавляет элементы в начало очереди с сохранением их порядка:
+// values[0] становится первым элементом.
+func (d *deque[T]) PushFront(values ...T) {
+	for i := len(values) - 1; i >= 0; i-- {
+		if d.head == nil {
+			d.PushBack(values[i])
+			continue
+		}
+		if d.start == 0 {
+			d.head = &dequeChunk[T]{next: d.head}
+			d.start = dequeChunkSize
+		}
+		d.start--
+		d.head.items[d.start] = values[i]
+		d.n++
+	}
+}
+
+// Front возвращает первый элемент очереди без его удаления.
+func (d *deque[T]) Front() (T, bool) {
+	if d.Len() == 0 {
+		var zero T
+		return zero, false
+	}
+	return d.head.items[d.start], true
+}
+
+// SetFront заменяет первый элемент очереди и возвращает прежний.
+// Пустая очередь не изменяется.
+func (d *deque[T]) SetFront(value T) (T, bool) {
+	old, ok := d.Front()
+	if ok {
+		d.head.items[d.start] = value
+	}
+	return old, ok
+}
+
+// PopFront извлекает первый элемент очереди.
+func (d *deque[T]) PopFront() (T, bool) {
+	value, ok := d.Front()
+	if ok {
+		d.DropFront(1)
+	}
+	return value, ok
+}
+
+// PopFrontN извлекает до k первых элементов очереди в новый срез.
+func (d *deque[T]) PopFrontN(k int) []T {
+	k = min(k, d.n)
+	items := make([]T, 0, k)
+	for _, value := range d.All() {
+		if len(items) == k {
+			break
+		}
+		items = append(items, value)
+	}
+	d.DropFront(k)
+	return items
+}
+
+// DropFront удаляет до k первых элементов очереди.
+// Освобожденные ячейки обнуляются, чтобы не удерживать значения от сборщика мусора.
+func (d *deque[T]) DropFront(k int) {
+	var zero T
+	for k = min(k, d.n); k > 0; k-- {
+		d.head.items[d.start] = zero
+		d.start++
+		d.n--
+		if d.n == 0 {
+			// Переиспользуем первый блок; последующие (после PushFront в пустую очередь) пусты
+			d.start, d.end = 0, 0
+			d.head.next = nil
+			d.tail = d.head
+			return
+		}
+		if d.start == dequeChunkSize {
+			d.head = d.head.next
+			d.start = 0
+		}
+	}
+}
+
+// RemoveAt удаляет элемент с индексом i (0 - первый) и возвращает его.
+// Предшествующие элементы сдвигаются на одну позицию к концу, поэтому
+// стоимость пропорциональна i.
+func (d *deque[T]) RemoveAt(i int) (T, bool) {
+	var carry T
+	if i < 0 || i >= d.Len() {
+		return carry, false
+	}
+	chunk, pos := d.head, d.start
+	for range i + 1 {
+		if pos == dequeChunkSize {
+			chunk, pos = chunk.next, 0
+		}
+		carry, chunk.items[pos] = chunk.items[pos], carry
+		pos++
+	}
+	d.DropFront(1) // Первая ячейка уже обнулена сдвигом
+	return carry, true
+}
+
+// All возвращает итератор по элементам очереди от первого к последнему
+// вместе с их индексами. Очередь не должна изменяться во время обхода.
+func (d *deque[T]) All() iter.Seq2[int, T] {
+	return d.from(0)
+}
+
+// Tail возвращает копию последних n элементов очереди (от старых к новым).
+func (d *deque[T]) Tail(n int) []T {
+	return d.Range(max(d.Len()-n, 0), n)
+}
+
+// Range возвращает копию до n элементов, начиная с индекса skip.
+func (d *deque[T]) Range(skip, n int) []T {
+	items := make([]T, 0, max(min(n, d.Len()-skip), 0))
+	for _, value := range d.from(skip) {
+		if len(items) == n {
+			break
+		}
+		items = append(items, value)
+	}
+	return items
+}
+
+// from возвращает итератор по элементам, начиная с индекса skip.
+// Пропущенные блоки не просматриваются поэлементно.
+func (d *deque[T]) from(skip int) iter.Seq2[int, T] {
+	return func(yield func(int, T) bool) {
+		if skip >= d.Len() {
+			return
+		}
+		chunk, pos := d.head, d.start+skip
+		for pos >= dequeChunkSize {
+			chunk, pos = chunk.next, pos-dequeChunkSize
+		}
+		for i := skip; i < d.n; chunk, pos = chunk.next, 0 {
+			for ; pos < dequeChunkSize && i < d.n; pos++ {
+				if !yield(i, chunk.items[pos]) {
+					return
+				}
+				i++
+			}
+		}
+	}
+}
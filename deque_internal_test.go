@@ -0,0 +1,153 @@
+package storage
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// collect возвращает элементы очереди в порядке обхода.
+func collect[T any](d *deque[T]) []T {
+	var items []T
+	for _, v := range d.All() {
+		items = append(items, v)
+	}
+	return items
+}
+
+func TestDeque_MatchesSlice(t *testing.T) {
+	var d deque[int]
+	var want []int
+	next := 0
+
+	// Чередуем добавление и извлечение так, чтобы пересекать границы блоков
+	for round := range 20 {
+		for range dequeChunkSize/2 + round*7 {
+			d.PushBack(next)
+			want = append(want, next)
+			next++
+		}
+		for range dequeChunkSize / 3 {
+			v, ok := d.PopFront()
+			require.True(t, ok)
+			require.Equal(t, want[0], v)
+			want = want[1:]
+		}
+		require.Equal(t, len(want), d.Len())
+	}
+	require.Equal(t, want, collect(&d))
+	require.Equal(t, want[len(want)-10:], d.Tail(10))
+	require.Equal(t, want, d.Tail(len(want)+5))
+
+	front := []int{-3, -2, -1}
+	d.PushFront(front...)
+	want = append(slices.Clone(front), want...)
+	require.Equal(t, want, collect(&d))
+
+	require.Equal(t, want[:dequeChunkSize+1], d.PopFrontN(dequeChunkSize+1))
+	want = want[dequeChunkSize+1:]
+	require.Equal(t, want, collect(&d))
+
+	d.DropFront(d.Len())
+	require.Zero(t, d.Len())
+	_, ok := d.Front()
+	require.False(t, ok)
+	require.Empty(t, collect(&d))
+}
+
+func TestDeque_PushFrontIntoEmpty(t *testing.T) {
+	var d deque[string]
+	d.PushBack("x")
+	d.PopFront()
+
+	d.PushFront("a", "b")
+	d.PushBack("c")
+	require.Equal(t, []string{"a", "b", "c"}, collect(&d))
+
+	d.DropFront(3)
+	d.PushBack("d")
+	require.Equal(t, []string{"d"}, collect(&d))
+}
+
+func TestDeque_Cap(t *testing.T) {
+	var d deque[int]
+	require.Zero(t, d.Cap())
+
+	for i := range 3 * dequeChunkSize {
+		d.PushBack(i)
+	}
+	require.Equal(t, 3*dequeChunkSize, d.Cap())
+
+	// Полностью извлеченные блоки освобождаются
+	d.DropFront(2*dequeChunkSize + 1)
+	require.Equal(t, dequeChunkSize, d.Cap())
+	d.DropFront(d.Len())
+	require.Equal(t, dequeChunkSize, d.Cap(), "пустая очередь переиспользует первый блок")
+}
+
+func TestDeque_NilReceiver(t *testing.T) {
+	var d *deque[int]
+	require.Zero(t, d.Len())
+	require.Zero(t, d.Cap())
+	_, ok := d.Front()
+	require.False(t, ok)
+	require.Empty(t, d.Tail(5))
+	require.Empty(t, collect(d))
+}
+
+// BenchmarkQueue сравнивает очередь из блоков со срезом при установившемся
+// режиме: очередь держит backlog элементов, каждая итерация добавляет и извлекает один.
+func BenchmarkQueue(b *testing.B) {
+	const backlog = 100_000
+
+	b.Run("deque", func(b *testing.B) {
+		var d deque[int]
+		for i := range backlog {
+			d.PushBack(i)
+		}
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := range b.N {
+			d.PushBack(i)
+			d.PopFront()
+		}
+	})
+
+	b.Run("slice", func(b *testing.B) {
+		queue := make([]int, 0, backlog)
+		for i := range backlog {
+			queue = append(queue, i)
+		}
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := range b.N {
+			queue = append(queue, i)
+			queue = queue[1:]
+		}
+	})
+}
+
+func TestDeque_RemoveAt(t *testing.T) {
+	var d deque[int]
+	var want []int
+	for i := range 3*dequeChunkSize + 10 {
+		d.PushBack(i)
+		want = append(want, i)
+	}
+	d.DropFront(5) // Первый блок заполнен не с начала
+	want = want[5:]
+
+	for _, i := range []int{dequeChunkSize + 3, 0, 3 * dequeChunkSize, dequeChunkSize - 6} {
+		v, ok := d.RemoveAt(i)
+		require.True(t, ok)
+		require.Equal(t, want[i], v)
+		want = slices.Delete(want, i, i+1)
+		require.Equal(t, want, collect(&d))
+	}
+
+	_, ok := d.RemoveAt(d.Len())
+	require.False(t, ok)
+	_, ok = d.RemoveAt(-1)
+	require.False(t, ok)
+}
@@ -0,0 +1,37 @@
+package storage
+
+import "context"
+
+// DequeueResult - результат DequeueWithResult.
+// Ровно одно из условий истинно: Err != nil (ошибка хранилища), Empty (очередь пуста)
+// или элемент Value извлечен.
+type DequeueResult[T any] struct {
+	Value T     // Извлеченный элемент (нулевое значение, если Empty или Err != nil)
+	Empty bool  // Очередь пуста, ошибки нет
+	Err   error // Ошибка хранилища (Empty = false)
+}
+
+// DequeueWithResult извлекает элемент из начала очереди, как Dequeue, и явно разделяет
+// пустую очередь и ошибку хранилища, чтобы цикл обработчика не путал одно с другим:
+//
+//	for {
+//		r := storage.DequeueWithResult(ctx, s, "jobs")
+//		switch {
+//		case r.Err != nil:
+//			retry.Wait(r.Err) // Временная ошибка: повторить с задержкой
+//		case r.Empty:
+//			backoff.Wait() // Очередь пуста: подождать новых элементов
+//		default:
+//			handle(r.Value)
+//		}
+//	}
+func DequeueWithResult[T any](ctx context.Context, s Storage[T], queueName string) DequeueResult[T] {
+	value, found, err := s.Dequeue(ctx, queueName)
+	if err != nil {
+		return DequeueResult[T]{Err: err}
+	}
+	if !found {
+		return DequeueResult[T]{Empty: true}
+	}
+	return DequeueResult[T]{Value: value}
+}
@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ErrWrongType возвращается, когда операция применяется к ключу
+// несовместимого типа (например, очередь по ключу, где хранится строка).
+var ErrWrongType = errors.New("storage: wrong type")
+
+// ErrInvalidKey возвращается, если ключ отклонен валидатором WithKeyValidator.
+var ErrInvalidKey = errors.New("storage: invalid key")
+
+// ErrInvalidInterval возвращается NewMemory при неположительном интервале очистки.
+var ErrInvalidInterval = errors.New("storage: cleanup interval must be positive")
+
+// ErrInvalidMaxLen возвращается EnqueueCapped при неположительной максимальной длине.
+var ErrInvalidMaxLen = errors.New("storage: max length must be positive")
+
+// ErrInvalidOffset возвращается QueueReadFrom при отрицательном смещении.
+var ErrInvalidOffset = errors.New("storage: offset must be non-negative")
+
+// ErrQueueConsumed возвращается QueueReadFrom для очереди, из которой удалялись
+// элементы (Dequeue, Remove, EnqueueCapped с обрезкой и т.д.): смещения ее элементов
+// сдвинулись, и чтение по сохраненному смещению вернуло бы не те элементы.
+var ErrQueueConsumed = errors.New("storage: queue has been consumed, offsets are not stable")
+
+// ErrInvalidRateLimit возвращается IncrWindow при неположительном окне или лимите.
+var ErrInvalidRateLimit = errors.New("storage: rate limit window and limit must be positive")
+
+// ErrTypeMismatch возвращается операциями записи в очередь и Dequeue, если тег типа
+// очереди не совпадает с типом значения или хранилища (см. WithQueueTypeCheck).
+var ErrTypeMismatch = errors.New("storage: queue type mismatch")
+
+// ErrClosed возвращается повторным вызовом Close: ресурсы уже освобождены первым вызовом.
+var ErrClosed = errors.New("storage: already closed")
+
+// ErrValueTooLarge возвращается при записи значения, сериализованный размер
+// которого превышает ограничение WithMaxValueBytes.
+var ErrValueTooLarge = errors.New("storage: value too large")
+
+// ErrCodecNotRegistered возвращается SetWithCodec, если кодек не зарегистрирован
+// в MarkedCodec хранилища: без маркера запись нельзя было бы прочитать.
+var ErrCodecNotRegistered = errors.New("storage: codec not registered")
+
+// ErrUnsupported возвращается необязательными методами, которые данное
+// хранилище не реализует. Текст ошибки содержит имя метода, а само условие
+// распознается через errors.Is. Проверить поддержку заранее можно через Capabilities.
+var ErrUnsupported = errors.New("storage: operation not supported")
+
+// BatchError возвращается пакетной операцией, выполненной не для всех элементов пакета.
+// Для элементов, которых нет в Errs, операция выполнена успешно.
+// Ошибки отдельных элементов доступны через errors.Is и errors.As.
+type BatchError struct {
+	Errs map[string]error // Имя элемента (например, очереди) -> ошибка
+}
+
+// Error перечисляет неудачные элементы в порядке имен.
+func (e *BatchError) Error() string {
+	names := make([]string, 0, len(e.Errs))
+	for name := range e.Errs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = fmt.Sprintf("%s: %v", name, e.Errs[name])
+	}
+	return fmt.Sprintf("batch failed for %d items: %s", len(names), strings.Join(parts, "; "))
+}
+
+// Unwrap возвращает ошибки элементов для errors.Is и errors.As.
+func (e *BatchError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Errs))
+	for _, err := range e.Errs {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+// unsupported возвращает ErrUnsupported с именем метода.
+func unsupported(method string) error {
+	return fmt.Errorf("%w: %s", ErrUnsupported, method)
+}
+
+// wrapRedisErr оборачивает ошибку Redis с указанием имени команды.
+// Ошибки WRONGTYPE дополнительно помечаются ErrWrongType,
+// чтобы их можно было распознать через errors.Is.
+func wrapRedisErr(op string, err error) error {
+	if strings.HasPrefix(err.Error(), "WRONGTYPE") {
+		return fmt.Errorf("redis %s failed: %w: %w", op, ErrWrongType, err)
+	}
+	return fmt.Errorf("redis %s failed: %w", op, err)
+}
@@ -0,0 +1,48 @@
+package storage
+
+import "container/heap"
+
+// expiryQueue - очередь истечений записей in-memory хранилища, упорядоченная
+// по времени истечения (min-heap). Каждая запись с временем жизни добавляет вхождение;
+// вхождения перезаписанных, продленных и удаленных ключей не ищутся и не удаляются
+// сразу, а пропускаются при извлечении, если запись еще не истекла.
+// Нулевое значение готово к использованию. Должна использоваться под блокировкой itemMu.
+type expiryQueue []expiryEntry
+
+// expiryEntry - запланированное истечение записи.
+type expiryEntry struct {
+	key        string
+	expiration int64 // Время истечения в наносекундах
+}
+
+// schedule планирует истечение ключа (expiration = 0 - запись бессрочная, не планируется).
+func (q *expiryQueue) schedule(key string, expiration int64) {
+	if expiration > 0 {
+		heap.Push(q, expiryEntry{key: key, expiration: expiration})
+	}
+}
+
+// popDue извлекает ключ с самым ранним временем истечения, если оно прошло к моменту now.
+func (q *expiryQueue) popDue(now int64) (string, bool) {
+	if len(*q) == 0 || now <= (*q)[0].expiration {
+		return "", false
+	}
+	return heap.Pop(q).(expiryEntry).key, true
+}
+
+// Методы heap.Interface.
+
+func (q expiryQueue) Len() int           { return len(q) }
+func (q expiryQueue) Less(i, j int) bool { return q[i].expiration < q[j].expiration }
+func (q expiryQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+
+func (q *expiryQueue) Push(x any) { *q = append(*q, x.(expiryEntry)) }
+
+func (q *expiryQueue) Pop() any {
+	old := *q
+	last := len(old) - 1
+	e := old[last]
+	old[last] = expiryEntry{} // Не удерживаем строку ключа
+	*q = old[:last]
+	return e
+}
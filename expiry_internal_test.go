@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeleteExpired_ReleasesLockBetweenChunks(t *testing.T) {
+	store, err := newMemoryStorage[int](context.Background(), time.Hour, nil)
+	require.NoError(t, err)
+	defer store.Close()
+	s := store.(*memoryStorage[int])
+	ctx := context.Background()
+
+	total := 200 * gcChunkSize
+	for i := range total {
+		require.NoError(t, s.Set(ctx, fmt.Sprintf("k%d", i), i, time.Millisecond))
+	}
+	time.Sleep(5 * time.Millisecond)
+
+	// Конкурентная операция получает блокировку посреди прохода,
+	// а не после удаления всех записей
+	sweepCtx, cancel := context.WithCancel(ctx)
+	go func() {
+		s.itemMu.Lock()
+		cancel()
+		s.itemMu.Unlock()
+	}()
+	removed, err := s.deleteExpired(sweepCtx)
+	require.ErrorIs(t, err, context.Canceled)
+	require.Less(t, removed, total)
+	require.Zero(t, removed%gcChunkSize, "проход прерывается только между порциями")
+
+	// Следующий проход удаляет оставшиеся записи
+	rest, err := s.deleteExpired(ctx)
+	require.NoError(t, err)
+	require.Equal(t, total, removed+rest)
+}
+
+func TestExpiryQueue_PopDueInOrder(t *testing.T) {
+	var q expiryQueue
+	q.schedule("c", 30)
+	q.schedule("a", 10)
+	q.schedule("persistent", 0)
+	q.schedule("b", 20)
+
+	var keys []string
+	for {
+		key, due := q.popDue(25)
+		if !due {
+			break
+		}
+		keys = append(keys, key)
+	}
+	require.Equal(t, []string{"a", "b"}, keys)
+	require.Equal(t, 1, q.Len())
+}
@@ -0,0 +1,50 @@
+package storage
+
+import "github.com/redis/go-redis/v9"
+
+// Множества с истечением элементов хранятся в Redis как сортированные множества,
+// где вес элемента - время его истечения в миллисекундах по часам Redis
+// (+inf для бессрочных). Истекшие элементы удаляются при каждом обращении,
+// а сам ключ истекает вместе с последним элементом.
+
+// saddExScript удаляет истекшие элементы, добавляет элемент (или продлевает его)
+// и выставляет ключу время истечения самого позднего элемента.
+// KEYS[1] - множество; ARGV[1] - элемент; ARGV[2] - TTL в миллисекундах (0 - бессрочно).
+var saddExScript = redis.NewScript(`
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now)
+local ttl = tonumber(ARGV[2])
+if ttl > 0 then
+	redis.call('ZADD', KEYS[1], now + ttl, ARGV[1])
+else
+	redis.call('ZADD', KEYS[1], '+inf', ARGV[1])
+end
+local last = redis.call('ZRANGE', KEYS[1], -1, -1, 'WITHSCORES')
+if last[2] == 'inf' then
+	redis.call('PERSIST', KEYS[1])
+else
+	redis.call('PEXPIREAT', KEYS[1], last[2])
+end
+return 1
+`)
+
+// scountScript удаляет истекшие элементы и возвращает количество оставшихся.
+// KEYS[1] - множество.
+var scountScript = redis.NewScript(`
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now)
+return redis.call('ZCARD', KEYS[1])
+`)
+
+// sweepSet удаляет из множества in-memory хранилища элементы, истекшие к моменту now.
+// Возвращает количество оставшихся элементов.
+func sweepSet(members map[string]int64, now int64) int {
+	for member, expiration := range members {
+		if expiration > 0 && now > expiration {
+			delete(members, member)
+		}
+	}
+	return len(members)
+}
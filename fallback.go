@@ -0,0 +1,399 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// FallbackReporter реализуется хранилищем NewRedisWithFallback.
+// Позволяет узнать, какое хранилище обслуживает операции:
+//
+//	if fr, ok := store.(storage.FallbackReporter); ok && fr.UsingFallback() {
+//		log.Println("redis недоступен, данные хранятся в памяти процесса")
+//	}
+type FallbackReporter interface {
+	// UsingFallback сообщает, что операции выполняются in-memory хранилищем
+	UsingFallback() bool
+}
+
+// fallbackStorage выполняет операции в Redis, пока он доступен, и в in-memory
+// хранилище, пока нет. Доступность Redis проверяется фоновой горутиной.
+type fallbackStorage[T any] struct {
+	cfg  RedisConfig
+	opts []Option
+
+	mu    sync.RWMutex
+	redis Storage[T] // Подключенный Redis (nil, пока подключиться не удалось)
+	up    bool       // Последняя проверка Redis прошла успешно
+
+	memory  Storage[T]    // Хранилище на время недоступности Redis
+	logger  *slog.Logger  // Логгер переключений
+	stop    chan struct{} // Канал для остановки проверок
+	done    chan struct{} // Закрывается после остановки проверок
+	closing atomic.Bool   // Close уже вызывался
+}
+
+// newFallbackStorage создает хранилище с переключением между Redis и памятью.
+// Если Redis недоступен при создании, хранилище начинает работу в памяти.
+func newFallbackStorage[T any](cfg RedisConfig, memInterval time.Duration, opts []Option) (Storage[T], error) {
+	memory, err := newMemoryStorage[T](context.Background(), memInterval, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	interval := cfg.HealthCheckInterval
+	if interval <= 0 {
+		interval = defaultHealthCheckInterval
+	}
+	cfg.HealthCheckInterval = 0 // Доступность проверяет fallbackStorage
+
+	s := &fallbackStorage[T]{
+		cfg:    cfg,
+		opts:   slices.Clip(opts),
+		memory: memory,
+		logger: defaultOptions(opts).logger,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	if r, err := newRedisStorage[T](cfg, s.opts); err == nil {
+		s.redis, s.up = r, true
+	} else {
+		s.logger.Warn("storage: redis unavailable, using memory fallback",
+			slog.String("addr", cfg.Addr), slog.Any("error", err))
+	}
+
+	go s.probe(interval)
+	return s, nil
+}
+
+// active возвращает хранилище, обслуживающее операции.
+func (s *fallbackStorage[T]) active() Storage[T] {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.up {
+		return s.redis
+	}
+	return s.memory
+}
+
+// UsingFallback сообщает, что операции выполняются in-memory хранилищем.
+func (s *fallbackStorage[T]) UsingFallback() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return !s.up
+}
+
+// probe периодически проверяет Redis и переключает хранилище.
+// Работает в фоновой горутине до закрытия хранилища.
+func (s *fallbackStorage[T]) probe(interval time.Duration) {
+	defer close(s.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.check()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// check подключается к Redis, если подключения еще нет, или проверяет его командой PING.
+func (s *fallbackStorage[T]) check() {
+	s.mu.RLock()
+	r, wasUp := s.redis, s.up
+	s.mu.RUnlock()
+
+	var err error
+	if r == nil {
+		r, err = newRedisStorage[T](s.cfg, s.opts)
+	} else {
+		ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+		err = r.(*redisStorage[T]).client.Ping(ctx).Err()
+		cancel()
+	}
+
+	s.mu.Lock()
+	if err == nil {
+		s.redis = r
+	}
+	s.up = err == nil
+	s.mu.Unlock()
+
+	switch {
+	case err != nil && wasUp:
+		s.logger.Warn("storage: redis unavailable, using memory fallback",
+			slog.String("addr", s.cfg.Addr), slog.Any("error", err))
+	case err == nil && !wasUp:
+		s.logger.Info("storage: redis available again", slog.String("addr", s.cfg.Addr))
+	}
+}
+
+// Close останавливает проверки Redis и закрывает оба хранилища.
+// Повторный вызов возвращает ErrClosed.
+func (s *fallbackStorage[T]) Close() error {
+	if !s.closing.CompareAndSwap(false, true) {
+		return ErrClosed
+	}
+	close(s.stop)
+	<-s.done // Проверка могла подключиться к Redis до остановки
+
+	errs := []error{s.memory.Close()}
+	if s.redis != nil {
+		errs = append(errs, s.redis.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// Capabilities возвращает возможности хранилища, обслуживающего операции сейчас.
+func (s *fallbackStorage[T]) Capabilities() Capability {
+	return s.active().Capabilities()
+}
+
+// Pipeline создает пакет команд хранилища, обслуживающего операции сейчас.
+// Пакет выполняется в нем, даже если к моменту Exec хранилище переключилось.
+func (s *fallbackStorage[T]) Pipeline() Pipeline[T] {
+	return s.active().Pipeline()
+}
+
+// Остальные методы выполняют операцию в хранилище, обслуживающем операции
+// в момент вызова (см. active).
+
+func (s *fallbackStorage[T]) Set(ctx context.Context, key string, value T, ttl time.Duration) error {
+	return s.active().Set(ctx, key, value, ttl)
+}
+
+func (s *fallbackStorage[T]) SetX(ctx context.Context, key string, value T, ttl time.Duration) (time.Time, error) {
+	return s.active().SetX(ctx, key, value, ttl)
+}
+
+func (s *fallbackStorage[T]) Get(ctx context.Context, key string) (T, bool, error) {
+	return s.active().Get(ctx, key)
+}
+
+func (s *fallbackStorage[T]) GetInto(ctx context.Context, key string, dst *T) (bool, error) {
+	return s.active().GetInto(ctx, key, dst)
+}
+
+func (s *fallbackStorage[T]) SetWithCodec(ctx context.Context, key string, value T, ttl time.Duration, codec Codec) error {
+	return s.active().SetWithCodec(ctx, key, value, ttl, codec)
+}
+
+func (s *fallbackStorage[T]) GetWithCodec(ctx context.Context, key string, codec Codec) (T, bool, error) {
+	return s.active().GetWithCodec(ctx, key, codec)
+}
+
+func (s *fallbackStorage[T]) GetEx(ctx context.Context, key string, ttl time.Duration) (T, bool, error) {
+	return s.active().GetEx(ctx, key, ttl)
+}
+
+func (s *fallbackStorage[T]) WaitForKey(ctx context.Context, key string, timeout time.Duration) (T, bool, error) {
+	return s.active().WaitForKey(ctx, key, timeout)
+}
+
+func (s *fallbackStorage[T]) GetRaw(ctx context.Context, key string) ([]byte, bool, error) {
+	return s.active().GetRaw(ctx, key)
+}
+
+func (s *fallbackStorage[T]) GetWithMeta(ctx context.Context, key string) (T, Meta, bool, error) {
+	return s.active().GetWithMeta(ctx, key)
+}
+
+func (s *fallbackStorage[T]) GetWithTTL(ctx context.Context, key string) (T, time.Duration, bool, error) {
+	return s.active().GetWithTTL(ctx, key)
+}
+
+func (s *fallbackStorage[T]) Delete(ctx context.Context, key string) error {
+	return s.active().Delete(ctx, key)
+}
+
+func (s *fallbackStorage[T]) DeleteIf(ctx context.Context, key string, expected T) (bool, error) {
+	return s.active().DeleteIf(ctx, key, expected)
+}
+
+func (s *fallbackStorage[T]) GetDelete(ctx context.Context, key string) (T, bool, error) {
+	return s.active().GetDelete(ctx, key)
+}
+
+func (s *fallbackStorage[T]) Toggle(ctx context.Context, key string) (bool, error) {
+	return s.active().Toggle(ctx, key)
+}
+
+func (s *fallbackStorage[T]) IncrementField(ctx context.Context, key, field string, delta int64) (int64, error) {
+	return s.active().IncrementField(ctx, key, field, delta)
+}
+
+func (s *fallbackStorage[T]) ClaimOnce(ctx context.Context, key string, ttl time.Duration) (claimed bool, err error) {
+	return s.active().ClaimOnce(ctx, key, ttl)
+}
+
+func (s *fallbackStorage[T]) CopyKey(ctx context.Context, src, dst string, replace bool) (bool, error) {
+	return s.active().CopyKey(ctx, src, dst, replace)
+}
+
+func (s *fallbackStorage[T]) SetVersioned(ctx context.Context, key string, value T, keep int) error {
+	return s.active().SetVersioned(ctx, key, value, keep)
+}
+
+func (s *fallbackStorage[T]) GetVersion(ctx context.Context, key string, n int) (T, bool, error) {
+	return s.active().GetVersion(ctx, key, n)
+}
+
+func (s *fallbackStorage[T]) DeletePattern(ctx context.Context, pattern string) (int64, error) {
+	return s.active().DeletePattern(ctx, pattern)
+}
+
+func (s *fallbackStorage[T]) SetWithTags(ctx context.Context, key string, value T, ttl time.Duration, tags []string) error {
+	return s.active().SetWithTags(ctx, key, value, ttl, tags)
+}
+
+func (s *fallbackStorage[T]) InvalidateTag(ctx context.Context, tag string) (int64, error) {
+	return s.active().InvalidateTag(ctx, tag)
+}
+
+func (s *fallbackStorage[T]) CountPattern(ctx context.Context, pattern string) (int64, error) {
+	return s.active().CountPattern(ctx, pattern)
+}
+
+func (s *fallbackStorage[T]) GetByPrefix(ctx context.Context, prefix string) (map[string]T, error) {
+	return s.active().GetByPrefix(ctx, prefix)
+}
+
+func (s *fallbackStorage[T]) ScanPage(ctx context.Context, cursor uint64, match string, count int) (keys []string, next uint64, err error) {
+	return s.active().ScanPage(ctx, cursor, match, count)
+}
+
+func (s *fallbackStorage[T]) Inspect(ctx context.Context, key string) (ObjectInfo, bool, error) {
+	return s.active().Inspect(ctx, key)
+}
+
+func (s *fallbackStorage[T]) Enqueue(ctx context.Context, queueName string, value T) error {
+	return s.active().Enqueue(ctx, queueName, value)
+}
+
+func (s *fallbackStorage[T]) EnqueueN(ctx context.Context, queueName string, value T) (int64, error) {
+	return s.active().EnqueueN(ctx, queueName, value)
+}
+
+func (s *fallbackStorage[T]) EnqueueFanout(ctx context.Context, queueNames []string, value T) error {
+	return s.active().EnqueueFanout(ctx, queueNames, value)
+}
+
+func (s *fallbackStorage[T]) EnqueueCapped(ctx context.Context, queueName string, value T, maxLen int64) error {
+	return s.active().EnqueueCapped(ctx, queueName, value, maxLen)
+}
+
+func (s *fallbackStorage[T]) EnqueueBounded(ctx context.Context, queueName string, value T, maxLen int64) (bool, error) {
+	return s.active().EnqueueBounded(ctx, queueName, value, maxLen)
+}
+
+func (s *fallbackStorage[T]) TryEnqueue(ctx context.Context, queueName string, value T, maxLen int64) (ok bool, reason EnqueueReason, err error) {
+	return s.active().TryEnqueue(ctx, queueName, value, maxLen)
+}
+
+func (s *fallbackStorage[T]) EnqueueDelayed(ctx context.Context, queueName string, value T, delay time.Duration) error {
+	return s.active().EnqueueDelayed(ctx, queueName, value, delay)
+}
+
+func (s *fallbackStorage[T]) Dequeue(ctx context.Context, queueName string) (T, bool, error) {
+	return s.active().Dequeue(ctx, queueName)
+}
+
+func (s *fallbackStorage[T]) DequeueTracked(ctx context.Context, queueName, trackingKey string, ttl time.Duration) (T, bool, error) {
+	return s.active().DequeueTracked(ctx, queueName, trackingKey, ttl)
+}
+
+func (s *fallbackStorage[T]) BlockingDequeueAny(ctx context.Context, queueNames []string, timeout time.Duration) (queue string, value T, found bool, err error) {
+	return s.active().BlockingDequeueAny(ctx, queueNames, timeout)
+}
+
+func (s *fallbackStorage[T]) Peek(ctx context.Context, queueName string) (T, bool, error) {
+	return s.active().Peek(ctx, queueName)
+}
+
+func (s *fallbackStorage[T]) Remove(ctx context.Context, queueName string) (bool, error) {
+	return s.active().Remove(ctx, queueName)
+}
+
+func (s *fallbackStorage[T]) RemoveAt(ctx context.Context, queueName string, index int64) (bool, error) {
+	return s.active().RemoveAt(ctx, queueName, index)
+}
+
+func (s *fallbackStorage[T]) ReplaceQueue(ctx context.Context, queueName string, values []T) error {
+	return s.active().ReplaceQueue(ctx, queueName, values)
+}
+
+func (s *fallbackStorage[T]) RotateQueue(ctx context.Context, queueName string) (T, bool, error) {
+	return s.active().RotateQueue(ctx, queueName)
+}
+
+func (s *fallbackStorage[T]) SetHead(ctx context.Context, queueName string, value T) (T, bool, error) {
+	return s.active().SetHead(ctx, queueName, value)
+}
+
+func (s *fallbackStorage[T]) MoveMatching(ctx context.Context, from, to string, match func(T) bool, limit int) (int, error) {
+	return s.active().MoveMatching(ctx, from, to, match, limit)
+}
+
+func (s *fallbackStorage[T]) QueueTail(ctx context.Context, queueName string, n int) ([]T, error) {
+	return s.active().QueueTail(ctx, queueName, n)
+}
+
+func (s *fallbackStorage[T]) QueueReadFrom(ctx context.Context, queueName string, offset int64, n int) ([]T, int64, error) {
+	return s.active().QueueReadFrom(ctx, queueName, offset, n)
+}
+
+func (s *fallbackStorage[T]) QueueIterate(ctx context.Context, queueName string, fn func(T) error) error {
+	return s.active().QueueIterate(ctx, queueName, fn)
+}
+
+func (s *fallbackStorage[T]) QueueLen(ctx context.Context, queueName string) (int64, error) {
+	return s.active().QueueLen(ctx, queueName)
+}
+
+func (s *fallbackStorage[T]) QueueLenMany(ctx context.Context, queueNames []string) (map[string]int64, error) {
+	return s.active().QueueLenMany(ctx, queueNames)
+}
+
+func (s *fallbackStorage[T]) QueueStats(ctx context.Context, queueName string) (QueueStats, error) {
+	return s.active().QueueStats(ctx, queueName)
+}
+
+func (s *fallbackStorage[T]) QueueIndexOf(ctx context.Context, queueName string, match func(T) bool) (int64, bool, error) {
+	return s.active().QueueIndexOf(ctx, queueName, match)
+}
+
+func (s *fallbackStorage[T]) QueueIndexOfValue(ctx context.Context, queueName string, value T) (int64, bool, error) {
+	return s.active().QueueIndexOfValue(ctx, queueName, value)
+}
+
+func (s *fallbackStorage[T]) PeekBatchReserve(ctx context.Context, queueName string, n int, vt time.Duration) (items []T, token string, err error) {
+	return s.active().PeekBatchReserve(ctx, queueName, n, vt)
+}
+
+func (s *fallbackStorage[T]) AckBatch(ctx context.Context, queueName, token string) error {
+	return s.active().AckBatch(ctx, queueName, token)
+}
+
+func (s *fallbackStorage[T]) IncrWindow(ctx context.Context, key string, window time.Duration, limit int64) (count int64, allowed bool, err error) {
+	return s.active().IncrWindow(ctx, key, window, limit)
+}
+
+func (s *fallbackStorage[T]) SAddEx(ctx context.Context, setName string, member T, ttl time.Duration) error {
+	return s.active().SAddEx(ctx, setName, member, ttl)
+}
+
+func (s *fallbackStorage[T]) SCount(ctx context.Context, setName string) (int64, error) {
+	return s.active().SCount(ctx, setName)
+}
+
+func (s *fallbackStorage[T]) Transact(ctx context.Context, fn func(tx Tx[T]) error) error {
+	return s.active().Transact(ctx, fn)
+}
@@ -0,0 +1,43 @@
+package storage_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alfzs/go-storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFallback_StartsInMemoryWhenRedisDown(t *testing.T) {
+	cfg := storage.RedisConfig{Addr: "127.0.0.1:1", HealthCheckInterval: 10 * time.Millisecond}
+	s, err := storage.NewRedisWithFallback[string](cfg, time.Hour)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	require.True(t, s.(storage.FallbackReporter).UsingFallback())
+	require.NoError(t, s.Set(ctx, "key", "value", 0))
+	value, found, err := s.Get(ctx, "key")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "value", value)
+
+	// Неудачные попытки переподключения не прерывают работу в памяти
+	time.Sleep(50 * time.Millisecond)
+	require.True(t, s.(storage.FallbackReporter).UsingFallback())
+	require.NoError(t, s.Enqueue(ctx, "jobs", "job-1"))
+
+	require.NoError(t, s.Close())
+	require.ErrorIs(t, s.Close(), storage.ErrClosed)
+
+	_, err = storage.NewRedisWithFallback[string](cfg, 0)
+	require.ErrorIs(t, err, storage.ErrInvalidInterval)
+}
+
+func TestFallback_UsesRedisWhenAvailable(t *testing.T) {
+	s, err := storage.NewRedisWithFallback[string](storage.RedisConfig{Addr: "localhost:6379"}, time.Hour)
+	require.NoError(t, err)
+	defer s.Close()
+	require.False(t, s.(storage.FallbackReporter).UsingFallback())
+	require.NotZero(t, s.Capabilities()&storage.CapScan)
+}
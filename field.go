@@ -0,0 +1,178 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// incrementFieldScript атомарно увеличивает целочисленное поле верхнего уровня
+// JSON-объекта, не пересобирая документ: cjson кодирует числа с точностью
+// 14 значащих цифр и исказил бы остальные поля. Объект просматривается
+// с учетом строк и вложенности, заменяется только текст числа.
+// Отсутствующий ключ создается как {"поле":delta}, отсутствующее поле (omitempty)
+// добавляется в начало объекта. Время жизни сохраняется (KEEPTTL).
+// Значение поля, не являющееся целым числом, и результат вне диапазона
+// типа поля возвращают ошибку.
+// KEYS[1] - ключ; ARGV[1] - имя поля в JSON с двоеточием ("views":); ARGV[2] - delta;
+// ARGV[3], ARGV[4] - наименьшее и наибольшее значение поля (см. intFieldRange).
+// Возвращает новое значение поля.
+var incrementFieldScript = redis.NewScript(`
+local needle, delta = ARGV[1], tonumber(ARGV[2])
+local lo, hi = tonumber(ARGV[3]), tonumber(ARGV[4])
+local data = redis.call('GET', KEYS[1])
+if not data then
+	if delta < lo or delta > hi then
+		return redis.error_reply('ERR increment overflows')
+	end
+	redis.call('SET', KEYS[1], '{' .. needle .. ARGV[2] .. '}')
+	return delta
+end
+if string.sub(data, 1, 1) ~= '{' then
+	return redis.error_reply('WRONGTYPE value is not a json object')
+end
+local depth, i = 0, 1
+while true do
+	i = string.find(data, '[\"{}%[%]]', i)
+	if not i then
+		break
+	end
+	local c = string.sub(data, i, i)
+	if c == '"' then
+		if depth == 1 and string.sub(data, i, i + #needle - 1) == needle then
+			local from = i + #needle
+			local token = string.match(data, '^-?%d+', from)
+			if not token or string.find(string.sub(data, from + #token, from + #token), '[%.eE]') then
+				return redis.error_reply('WRONGTYPE field is not an integer')
+			end
+			local n = tonumber(token) + delta
+			if n > hi or n < lo then
+				return redis.error_reply('ERR increment overflows')
+			end
+			local patched = string.sub(data, 1, from - 1) .. string.format('%.0f', n) .. string.sub(data, from + #token)
+			redis.call('SET', KEYS[1], patched, 'KEEPTTL')
+			return n
+		end
+		-- Пропускаем строку вместе с экранированными символами
+		i = i + 1
+		while true do
+			i = string.find(data, '[\"\\]', i)
+			if not i or string.sub(data, i, i) == '"' then
+				break
+			end
+			i = i + 2
+		end
+		if not i then
+			break
+		end
+	elseif c == '{' or c == '[' then
+		depth = depth + 1
+	else
+		depth = depth - 1
+	end
+	i = i + 1
+end
+if delta < lo or delta > hi then
+	return redis.error_reply('ERR increment overflows')
+end
+local rest = string.sub(data, 2)
+local sep = ','
+if string.match(rest, '^%s*}') then
+	sep = ''
+end
+redis.call('SET', KEYS[1], '{' .. needle .. ARGV[2] .. sep .. rest, 'KEEPTTL')
+return delta
+`)
+
+// incrementStructField прибавляет delta к целочисленному полю field структуры value.
+// Возвращает новое значение типа T и значение поля.
+func incrementStructField[T any](value T, field string, delta int64) (T, int64, error) {
+	f, err := structIntField(reflect.ValueOf(&value).Elem(), field)
+	if err != nil {
+		return value, 0, err
+	}
+	n, err := addIntValue(f, delta)
+	return value, n, err
+}
+
+// structIntField возвращает целочисленное поле field структуры v.
+// Поле ищется по имени в JSON (тег json или имя поля Go), как его видит JSONCodec.
+// Для неструктурных типов, нецелочисленных полей и отсутствующего поля возвращает ErrWrongType.
+func structIntField(v reflect.Value, field string) (reflect.Value, error) {
+	if v.Kind() != reflect.Struct {
+		return reflect.Value{}, fmt.Errorf("%w: increment field of %s", ErrWrongType, v.Type())
+	}
+	f, ok := structFieldByJSONName(v, field)
+	if !ok {
+		return reflect.Value{}, fmt.Errorf("%w: %s has no field %q", ErrWrongType, v.Type(), field)
+	}
+	switch f.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+	default:
+		return reflect.Value{}, fmt.Errorf("%w: increment of field %q of type %s", ErrWrongType, field, f.Type())
+	}
+	return f, nil
+}
+
+// intFieldRange возвращает диапазон значений целочисленного поля f,
+// ограниченный диапазоном точных целых чисел Lua (±2^53).
+func intFieldRange(f reflect.Value) (int64, int64) {
+	const exact = 1 << 53
+	bits := f.Type().Bits()
+	if f.CanUint() {
+		if bits >= 53 {
+			return 0, exact
+		}
+		return 0, 1<<bits - 1
+	}
+	if bits > 53 {
+		return -exact, exact
+	}
+	return -(1 << (bits - 1)), 1<<(bits-1) - 1
+}
+
+// structFieldByJSONName возвращает экспортируемое поле верхнего уровня структуры v
+// с именем name в JSON. Поля с тегом json:"-" не учитываются.
+func structFieldByJSONName(v reflect.Value, name string) (reflect.Value, bool) {
+	t := v.Type()
+	for i := range t.NumField() {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		tag, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+		if tag == "-" {
+			continue
+		}
+		if tag == "" {
+			tag = sf.Name
+		}
+		if tag == name {
+			return v.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// incrementFieldViaTransact увеличивает поле значения внутри транзакции хранилища s.
+// Запись выполняется Tx.Set с ttl 0, то есть по правилам Set хранилища s.
+func incrementFieldViaTransact[T any](ctx context.Context, s Storage[T], key, field string, delta int64) (int64, error) {
+	var n int64
+	err := s.Transact(ctx, func(tx Tx[T]) error {
+		current, _, err := tx.Get(key)
+		if err != nil {
+			return err
+		}
+		next, value, err := incrementStructField(current, field, delta)
+		if err != nil {
+			return err
+		}
+		n = value
+		return tx.Set(key, next, 0)
+	})
+	return n, err
+}
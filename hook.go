@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Hook - наблюдатель хранилища (экспорт метрик, логирование), регистрируемый опцией WithHook.
+// Close вызывает Flush каждого хука до освобождения ресурсов, чтобы короткоживущие
+// программы не теряли последние накопленные данные.
+type Hook interface {
+	// Flush отправляет накопленные хуком данные
+	// ctx ограничивает время отправки (hookFlushTimeout)
+	Flush(ctx context.Context) error
+}
+
+// StatsHook - хук, получающий итоговую статистику хранилища при Close перед Flush.
+// Статистику передают хранилища, реализующие MemoryStatsProvider.
+type StatsHook interface {
+	Hook
+
+	// FinalStats получает значения счетчиков хранилища на момент закрытия
+	FinalStats(stats MemoryStats)
+}
+
+// GCHook - хук, получающий длительность каждого прохода сборщика мусора
+// in-memory хранилища, чтобы подобрать интервал очистки по метрикам.
+// Хранилища Redis его не вызывают.
+type GCHook interface {
+	Hook
+
+	// OnGCSweep вызывается после прохода, удалившего removed истекших записей за duration
+	// Вызывается синхронно в горутине сборщика мусора (или PurgeExpired)
+	// и должен быстро возвращать управление
+	OnGCSweep(removed int, duration time.Duration)
+}
+
+// observeGCSweep передает результат прохода сборщика мусора хукам GCHook.
+func observeGCSweep(hooks []Hook, removed int, duration time.Duration) {
+	for _, h := range hooks {
+		if gh, ok := h.(GCHook); ok {
+			gh.OnGCSweep(removed, duration)
+		}
+	}
+}
+
+// hookFlushTimeout - время, отведенное хукам на Flush при закрытии хранилища.
+const hookFlushTimeout = 5 * time.Second
+
+// flushHooks передает хукам итоговую статистику (если stats не nil) и вызывает их Flush.
+// Ошибки всех хуков объединяются. Без хуков ничего не делает.
+func flushHooks(hooks []Hook, stats func() MemoryStats) error {
+	if len(hooks) == 0 {
+		return nil
+	}
+
+	if stats != nil {
+		final := stats()
+		for _, h := range hooks {
+			if sh, ok := h.(StatsHook); ok {
+				sh.FinalStats(final)
+			}
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), hookFlushTimeout)
+	defer cancel()
+
+	var errs []error
+	for _, h := range hooks {
+		if err := h.Flush(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"sort"
+	"sync"
+)
+
+// KeyCount содержит ключ и оценку количества обращений к нему
+type KeyCount struct {
+	Key   string // Ключ
+	Count int64  // Оценка количества обращений (может быть завышена для редких ключей)
+}
+
+// HotKeyReporter реализуется хранилищами, созданными с опцией WithHotKeyTracking.
+// Хранилище можно привести к этому интерфейсу:
+//
+//	if hr, ok := store.(storage.HotKeyReporter); ok {
+//		top := hr.HotKeys(10)
+//	}
+type HotKeyReporter interface {
+	// HotKeys возвращает до n самых часто используемых ключей в порядке убывания
+	// Если отслеживание выключено, возвращает nil
+	HotKeys(n int) []KeyCount
+}
+
+// hotKeyTracker отслеживает самые частые ключи алгоритмом Space-Saving.
+// Хранит не более capacity счетчиков: при переполнении ключ с наименьшим
+// счетчиком вытесняется новым, который наследует его значение + 1.
+// Частые ключи при этом гарантированно остаются в наборе.
+type hotKeyTracker struct {
+	mu       sync.Mutex       // Мьютекс для доступа к counts
+	counts   map[string]int64 // Счетчики обращений
+	capacity int              // Максимальное количество отслеживаемых ключей
+}
+
+// newHotKeyTracker создает трекер на capacity ключей.
+// Возвращает nil, если capacity не положительно (отслеживание выключено).
+func newHotKeyTracker(capacity int) *hotKeyTracker {
+	if capacity <= 0 {
+		return nil
+	}
+	return &hotKeyTracker{
+		counts:   make(map[string]int64, capacity),
+		capacity: capacity,
+	}
+}
+
+// record учитывает обращение к ключу. Безопасен для вызова на nil.
+func (t *hotKeyTracker) record(key string) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.counts[key]; ok || len(t.counts) < t.capacity {
+		t.counts[key]++
+		return
+	}
+
+	// Вытесняем ключ с минимальным счетчиком
+	minKey, minCount := "", int64(-1)
+	for k, c := range t.counts {
+		if minCount < 0 || c < minCount {
+			minKey, minCount = k, c
+		}
+	}
+	delete(t.counts, minKey)
+	t.counts[key] = minCount + 1
+}
+
+// top возвращает до n ключей с наибольшими счетчиками. Безопасен для вызова на nil.
+func (t *hotKeyTracker) top(n int) []KeyCount {
+	if t == nil || n <= 0 {
+		return nil
+	}
+
+	t.mu.Lock()
+	result := make([]KeyCount, 0, len(t.counts))
+	for k, c := range t.counts {
+		result = append(result, KeyCount{Key: k, Count: c})
+	}
+	t.mu.Unlock()
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Key < result[j].Key
+	})
+	if len(result) > n {
+		result = result[:n]
+	}
+	return result
+}
@@ -0,0 +1,16 @@
+package storage
+
+import "time"
+
+// ObjectInfo содержит отладочные сведения о внутреннем представлении записи.
+type ObjectInfo struct {
+	// Encoding - внутреннее представление значения: для Redis - ответ OBJECT ENCODING
+	// ("int", "embstr", "raw" и т.д.), для in-memory хранилища - Go-тип значения
+	Encoding string
+	// IdleTime - время с последнего обращения к записи (OBJECT IDLETIME, точность - секунда)
+	// In-memory хранилище время обращений не отслеживает и возвращает 0;
+	// Redis с политикой вытеснения LFU тоже не отслеживает его
+	IdleTime time.Duration
+	// RefCount - количество ссылок на значение (OBJECT REFCOUNT), для in-memory хранилища - 1
+	RefCount int64
+}
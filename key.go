@@ -0,0 +1,76 @@
+package storage
+
+import (
+	"strconv"
+	"strings"
+)
+
+// KeySeparator - разделитель частей составного ключа Key и KeyBuilder.
+const KeySeparator = ":"
+
+// keyEscaper кодирует разделитель и символ экранирования внутри частей ключа
+// процентной записью. Обратная косая черта не используется, чтобы ключи
+// сопоставлялись glob-шаблонами ScanPage и DeletePattern без двойного экранирования.
+var keyEscaper = strings.NewReplacer("%", "%25", KeySeparator, "%3A")
+
+// Key соединяет части составного ключа через KeySeparator. Разделитель и "%"
+// внутри частей кодируются ("%3A" и "%25"), поэтому разные наборы частей
+// всегда дают разные ключи: Key("a:b", "c") != Key("a", "b:c").
+// Пустые части сохраняются: Key("a", "", "b") == "a::b".
+func Key(parts ...string) string {
+	var b KeyBuilder
+	for _, part := range parts {
+		b.Add(part)
+	}
+	return b.String()
+}
+
+// KeyPrefix возвращает префикс ключей, построенных Key из parts и дополнительных частей,
+// для GetByPrefix: KeyPrefix("user", id) - префикс Key("user", id, "session").
+func KeyPrefix(parts ...string) string {
+	return Key(parts...) + KeySeparator
+}
+
+// KeyBuilder строит составной ключ из частей разных типов по тем же правилам, что и Key.
+// Нулевое значение готово к использованию:
+//
+//	var b storage.KeyBuilder
+//	key := b.Add("user").AddInt(42).Add("session").String() // "user:42:session"
+type KeyBuilder struct {
+	b       strings.Builder
+	started bool // Добавлена хотя бы одна часть
+}
+
+// Add добавляет строковую часть, кодируя в ней разделитель.
+func (k *KeyBuilder) Add(part string) *KeyBuilder {
+	k.separate()
+	keyEscaper.WriteString(&k.b, part)
+	return k
+}
+
+// AddInt добавляет целочисленную часть в десятичной записи.
+func (k *KeyBuilder) AddInt(n int64) *KeyBuilder {
+	k.separate()
+	k.b.WriteString(strconv.FormatInt(n, 10))
+	return k
+}
+
+// AddUint добавляет беззнаковую целочисленную часть в десятичной записи.
+func (k *KeyBuilder) AddUint(n uint64) *KeyBuilder {
+	k.separate()
+	k.b.WriteString(strconv.FormatUint(n, 10))
+	return k
+}
+
+// String возвращает построенный ключ.
+func (k *KeyBuilder) String() string {
+	return k.b.String()
+}
+
+// separate добавляет разделитель перед каждой частью, кроме первой.
+func (k *KeyBuilder) separate() {
+	if k.started {
+		k.b.WriteString(KeySeparator)
+	}
+	k.started = true
+}
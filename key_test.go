@@ -0,0 +1,40 @@
+package storage_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alfzs/go-storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKey(t *testing.T) {
+	require.Equal(t, "user:42:session", storage.Key("user", "42", "session"))
+	require.Equal(t, "a::b", storage.Key("a", "", "b"))
+	require.Empty(t, storage.Key())
+
+	// Разделитель внутри частей не создает коллизий
+	require.Equal(t, "a%3Ab:c", storage.Key("a:b", "c"))
+	require.Equal(t, "a:b%3Ac", storage.Key("a", "b:c"))
+	require.Equal(t, "100%25:x", storage.Key("100%", "x"))
+	require.NotEqual(t, storage.Key("a%3Ab"), storage.Key("a:b"))
+
+	var b storage.KeyBuilder
+	require.Equal(t, storage.Key("user", "42", "a:b"), b.Add("user").AddInt(42).Add("a:b").String())
+	require.Equal(t, "user:42:", storage.KeyPrefix("user", "42"))
+}
+
+func TestKey_PrefixLookup(t *testing.T) {
+	s, _ := storage.NewMemory[string](time.Hour)
+	defer s.Close()
+	ctx := context.Background()
+
+	require.NoError(t, s.Set(ctx, storage.Key("user", "4", "name"), "four", 0))
+	require.NoError(t, s.Set(ctx, storage.Key("user", "42", "name"), "forty-two", 0))
+	require.NoError(t, s.Set(ctx, storage.Key("user", "4:2", "name"), "escaped", 0))
+
+	values, err := s.GetByPrefix(ctx, storage.KeyPrefix("user", "4"))
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{storage.Key("user", "4", "name"): "four"}, values)
+}
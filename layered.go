@@ -0,0 +1,660 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// freshReadKey - ключ контекста, помечающий чтение в обход L1.
+type freshReadKey struct{}
+
+// WithFreshRead возвращает контекст, в котором Get многоуровневого хранилища
+// читает значение напрямую из L2, минуя L1, и обновляет им L1.
+// Используется, когда известно, что значение изменилось в обход хранилища,
+// и ждать истечения TTL в L1 нельзя. Каждое такое чтение создает нагрузку на L2,
+// поэтому применять его стоит точечно, а не для всех запросов.
+func WithFreshRead(ctx context.Context) context.Context {
+	return context.WithValue(ctx, freshReadKey{}, true)
+}
+
+// isFreshRead проверяет, запрошено ли в контексте чтение в обход L1.
+func isFreshRead(ctx context.Context) bool {
+	fresh, _ := ctx.Value(freshReadKey{}).(bool)
+	return fresh
+}
+
+// layeredStorage объединяет быстрый кэш (L1) и основное хранилище (L2).
+// Значения читаются из L1, при промахе - из L2 с записью результата в L1.
+// Записи и удаления выполняются в L2 и затем отражаются в L1.
+// Очереди хранятся только в L2.
+type layeredStorage[T any] struct {
+	l1    Storage[T]     // Быстрый кэш (обычно in-memory)
+	l2    Storage[T]     // Основное хранилище (обычно Redis)
+	l1TTL time.Duration  // Максимальное время жизни записи в L1
+	stale *staleCache[T] // Последние известные значения для ошибок L2 (nil - выключено)
+
+	closing atomic.Bool // Close уже вызывался
+}
+
+// newLayeredStorage создает многоуровневое хранилище из L1 и L2.
+// При staleTTL > 0 хранит последние известные значения для выдачи при ошибках L2.
+func newLayeredStorage[T any](l1, l2 Storage[T], l1TTL, staleTTL time.Duration) Storage[T] {
+	s := &layeredStorage[T]{l1: l1, l2: l2, l1TTL: l1TTL}
+	if staleTTL > 0 {
+		s.stale = newStaleCache[T](staleTTL)
+	}
+	return s
+}
+
+// evict удаляет ключи из L1 и из копии последних значений.
+// Ошибка L1 не должна ломать операцию, поэтому игнорируется.
+func (s *layeredStorage[T]) evict(ctx context.Context, keys ...string) {
+	for _, key := range keys {
+		_ = s.l1.Delete(ctx, key)
+	}
+	s.stale.forget(keys...)
+}
+
+// cacheTTL вычисляет время жизни записи в L1.
+// Запись в L1 не должна жить дольше, чем в L2.
+func (s *layeredStorage[T]) cacheTTL(ttl time.Duration) time.Duration {
+	if ttl > 0 && (s.l1TTL <= 0 || ttl < s.l1TTL) {
+		return ttl
+	}
+	return s.l1TTL
+}
+
+// remainingTTL возвращает оставшееся время жизни записи в L2 (0 - бессрочная).
+// Если L2 не реализует TTLReader или запись не удалось найти, возвращает false:
+// значение тогда не кэшируется в L1, чтобы не пережить запись в L2.
+func (s *layeredStorage[T]) remainingTTL(ctx context.Context, key string) (time.Duration, bool) {
+	tr, ok := s.l2.(TTLReader)
+	if !ok {
+		return 0, false
+	}
+	ttl, found, err := tr.TTL(ctx, key)
+	return ttl, err == nil && found
+}
+
+// Set сохраняет значение в L2, затем обновляет L1.
+func (s *layeredStorage[T]) Set(ctx context.Context, key string, value T, ttl time.Duration) error {
+	if err := s.l2.Set(ctx, key, value, ttl); err != nil {
+		return err
+	}
+	s.stale.remember(key, value)
+	return s.l1.Set(ctx, key, value, s.cacheTTL(ttl))
+}
+
+// SetX сохраняет значение в L2 и возвращает время истечения, примененное L2.
+// Запись в L1 живет не дольше записи в L2.
+func (s *layeredStorage[T]) SetX(ctx context.Context, key string, value T, ttl time.Duration) (time.Time, error) {
+	expiresAt, err := s.l2.SetX(ctx, key, value, ttl)
+	if err != nil {
+		return time.Time{}, err
+	}
+	s.stale.remember(key, value)
+
+	var remaining time.Duration
+	if !expiresAt.IsZero() {
+		if remaining = time.Until(expiresAt); remaining <= 0 {
+			s.evict(ctx, key) // Запись в L2 уже истекла
+			return expiresAt, nil
+		}
+	}
+	return expiresAt, s.l1.Set(ctx, key, value, s.cacheTTL(remaining))
+}
+
+// Get получает значение из L1, а при промахе - из L2 с записью в L1
+// на время не дольше оставшегося времени жизни записи в L2.
+// Если контекст создан WithFreshRead, L1 пропускается.
+// В режиме NewLayeredStaleOnError при ошибке L2 возвращает последнее известное значение
+// без ошибки; узнать об этом можно через GetStale.
+func (s *layeredStorage[T]) Get(ctx context.Context, key string) (T, bool, error) {
+	val, found, _, err := s.GetStale(ctx, key)
+	return val, found, err
+}
+
+// GetStale получает значение как Get и сообщает, взято ли оно из копии последних
+// известных значений из-за ошибки L2.
+func (s *layeredStorage[T]) GetStale(ctx context.Context, key string) (T, bool, bool, error) {
+	if !isFreshRead(ctx) {
+		if val, found, err := s.l1.Get(ctx, key); err == nil && found {
+			s.stale.remember(key, val)
+			return val, true, false, nil
+		}
+	}
+
+	val, remaining, found, err := s.l2.GetWithTTL(ctx, key)
+	if err != nil {
+		if stale, ok := s.stale.get(key); ok && servesStale(ctx, err) {
+			return stale, true, true, nil
+		}
+		return val, false, false, err
+	}
+	if !found {
+		s.evict(ctx, key) // Убираем запись, исчезнувшую из L2
+		return val, false, false, nil
+	}
+
+	s.stale.remember(key, val)
+	_ = s.l1.Set(ctx, key, val, s.cacheTTL(remaining)) // Ошибка L1 не должна ломать чтение
+	return val, true, false, nil
+}
+
+// WaitForKey ждет появления ключа в L2 (другие процессы пишут в него, а не в L1),
+// затем записывает значение в L1 на время не дольше оставшегося времени жизни в L2.
+func (s *layeredStorage[T]) WaitForKey(ctx context.Context, key string, timeout time.Duration) (T, bool, error) {
+	val, found, err := s.l2.WaitForKey(ctx, key, timeout)
+	if err != nil || !found {
+		return val, false, err
+	}
+
+	s.stale.remember(key, val)
+	if remaining, ok := s.remainingTTL(ctx, key); ok {
+		_ = s.l1.Set(ctx, key, val, s.cacheTTL(remaining)) // Ошибка L1 не должна ломать чтение
+	}
+	return val, true, nil
+}
+
+// GetEx получает значение и обновляет TTL в L2, затем обновляет L1 на время
+// не дольше времени жизни, которое запись получила в L2.
+// Операция всегда обращается к L2, так как должна продлить время жизни записи в нем.
+func (s *layeredStorage[T]) GetEx(ctx context.Context, key string, ttl time.Duration) (T, bool, error) {
+	val, found, err := s.l2.GetEx(ctx, key, ttl)
+	if err != nil || !found {
+		s.evict(ctx, key)
+		return val, false, err
+	}
+
+	s.stale.remember(key, val)
+	switch {
+	case ttl > 0:
+		_ = s.l1.Set(ctx, key, val, s.cacheTTL(ttl))
+	case ttl < 0:
+		_ = s.l1.Set(ctx, key, val, s.l1TTL) // Запись в L2 стала бессрочной
+	default:
+		// Время жизни в L2 не изменилось: L1 не должен его пережить
+		if remaining, ok := s.remainingTTL(ctx, key); ok {
+			_ = s.l1.Set(ctx, key, val, s.cacheTTL(remaining))
+		} else {
+			_ = s.l1.Delete(ctx, key) // Прежняя запись L1 могла быть рассчитана на другой TTL
+		}
+	}
+	return val, true, nil
+}
+
+// GetInto получает значение как Get (с заполнением L1) и копирует его в *dst.
+func (s *layeredStorage[T]) GetInto(ctx context.Context, key string, dst *T) (bool, error) {
+	value, found, err := s.Get(ctx, key)
+	if found {
+		*dst = value
+	}
+	return found, err
+}
+
+// SetWithCodec сохраняет значение в L2 кодеком codec, затем обновляет L1.
+func (s *layeredStorage[T]) SetWithCodec(ctx context.Context, key string, value T, ttl time.Duration, codec Codec) error {
+	if err := s.l2.SetWithCodec(ctx, key, value, ttl, codec); err != nil {
+		return err
+	}
+	s.stale.remember(key, value)
+	return s.l1.Set(ctx, key, value, s.cacheTTL(ttl))
+}
+
+// GetWithCodec получает значение из L1, а при промахе - из L2 кодеком codec с записью в L1.
+// Если контекст создан WithFreshRead, L1 пропускается.
+func (s *layeredStorage[T]) GetWithCodec(ctx context.Context, key string, codec Codec) (T, bool, error) {
+	if !isFreshRead(ctx) {
+		if val, found, err := s.l1.Get(ctx, key); err == nil && found {
+			return val, true, nil
+		}
+	}
+
+	val, found, err := s.l2.GetWithCodec(ctx, key, codec)
+	if err != nil {
+		return val, false, err
+	}
+	if !found {
+		s.evict(ctx, key) // Убираем запись, исчезнувшую из L2
+		return val, false, nil
+	}
+
+	s.stale.remember(key, val)
+	if remaining, ok := s.remainingTTL(ctx, key); ok {
+		_ = s.l1.Set(ctx, key, val, s.cacheTTL(remaining)) // Ошибка L1 не должна ломать чтение
+	}
+	return val, true, nil
+}
+
+// GetRaw получает сериализованное значение из L2, где хранится исходная запись.
+func (s *layeredStorage[T]) GetRaw(ctx context.Context, key string) ([]byte, bool, error) {
+	return s.l2.GetRaw(ctx, key)
+}
+
+// Inspect возвращает сведения о записи в L2.
+func (s *layeredStorage[T]) Inspect(ctx context.Context, key string) (ObjectInfo, bool, error) {
+	return s.l2.Inspect(ctx, key)
+}
+
+// GetWithMeta получает значение с метаданными из L2.
+// Метаданные хранятся только в L2, поэтому L1 не используется.
+func (s *layeredStorage[T]) GetWithMeta(ctx context.Context, key string) (T, Meta, bool, error) {
+	return s.l2.GetWithMeta(ctx, key)
+}
+
+// GetWithTTL получает значение и время жизни из L2.
+// Время жизни копии в L1 ограничено l1TTL и не совпадает с исходным, поэтому L1 не используется.
+func (s *layeredStorage[T]) GetWithTTL(ctx context.Context, key string) (T, time.Duration, bool, error) {
+	return s.l2.GetWithTTL(ctx, key)
+}
+
+// Delete удаляет значение из L2, затем из L1.
+func (s *layeredStorage[T]) Delete(ctx context.Context, key string) error {
+	if err := s.l2.Delete(ctx, key); err != nil {
+		return err
+	}
+	s.stale.forget(key)
+	return s.l1.Delete(ctx, key)
+}
+
+// DeleteIf сравнивает и удаляет значение в L2: копия в L1 может быть устаревшей.
+// После удаления удаляет ключ и из L1.
+func (s *layeredStorage[T]) DeleteIf(ctx context.Context, key string, expected T) (bool, error) {
+	deleted, err := s.l2.DeleteIf(ctx, key, expected)
+	if err != nil {
+		return false, err
+	}
+	if deleted {
+		s.evict(ctx, key)
+	}
+	return deleted, nil
+}
+
+// GetDelete атомарно получает и удаляет значение в L2, затем удаляет его из L1.
+// Значение всегда читается из L2, чтобы сохранить семантику однократного получения.
+func (s *layeredStorage[T]) GetDelete(ctx context.Context, key string) (T, bool, error) {
+	val, found, err := s.l2.GetDelete(ctx, key)
+	if err != nil {
+		return val, false, err
+	}
+	s.evict(ctx, key)
+	return val, found, nil
+}
+
+// Toggle инвертирует значение в транзакции L2 и удаляет ключ из L1.
+func (s *layeredStorage[T]) Toggle(ctx context.Context, key string) (bool, error) {
+	return toggleViaTransact[T](ctx, s, key)
+}
+
+// IncrementField увеличивает поле в L2 и удаляет ключ из L1.
+func (s *layeredStorage[T]) IncrementField(ctx context.Context, key, field string, delta int64) (int64, error) {
+	n, err := s.l2.IncrementField(ctx, key, field, delta)
+	if err != nil {
+		return 0, err
+	}
+	s.evict(ctx, key)
+	return n, nil
+}
+
+// ClaimOnce записывает маркер в L2 и удаляет устаревшую копию ключа из L1.
+func (s *layeredStorage[T]) ClaimOnce(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	claimed, err := s.l2.ClaimOnce(ctx, key, ttl)
+	if err != nil {
+		return false, err
+	}
+	if claimed {
+		s.evict(ctx, key)
+	}
+	return claimed, nil
+}
+
+// SetVersioned записывает значение и историю в L2 и удаляет устаревшую копию ключа из L1.
+func (s *layeredStorage[T]) SetVersioned(ctx context.Context, key string, value T, keep int) error {
+	if err := s.l2.SetVersioned(ctx, key, value, keep); err != nil {
+		return err
+	}
+	s.evict(ctx, key)
+	return nil
+}
+
+// GetVersion читает историю из L2: L1 историю не хранит.
+func (s *layeredStorage[T]) GetVersion(ctx context.Context, key string, n int) (T, bool, error) {
+	return s.l2.GetVersion(ctx, key, n)
+}
+
+// IncrWindow увеличивает счетчик окна в L2: счетчик должен быть общим для всех процессов.
+func (s *layeredStorage[T]) IncrWindow(ctx context.Context, key string, window time.Duration, limit int64) (int64, bool, error) {
+	return s.l2.IncrWindow(ctx, key, window, limit)
+}
+
+// CopyKey копирует запись в L2 и удаляет прежнее значение dst из L1.
+func (s *layeredStorage[T]) CopyKey(ctx context.Context, src, dst string, replace bool) (bool, error) {
+	copied, err := s.l2.CopyKey(ctx, src, dst, replace)
+	if err != nil {
+		return false, err
+	}
+	if copied {
+		s.evict(ctx, dst)
+	}
+	return copied, nil
+}
+
+// DeletePattern удаляет записи по шаблону в L2, затем в L1.
+// Возвращает количество записей, удаленных из L2.
+func (s *layeredStorage[T]) DeletePattern(ctx context.Context, pattern string) (int64, error) {
+	deleted, err := s.l2.DeletePattern(ctx, pattern)
+	if err != nil {
+		return deleted, err
+	}
+	_, _ = s.l1.DeletePattern(ctx, pattern)
+	s.stale.forgetPattern(pattern)
+	return deleted, nil
+}
+
+// SetWithTags сохраняет значение с тегами в L2, затем в L1 с теми же тегами.
+func (s *layeredStorage[T]) SetWithTags(ctx context.Context, key string, value T, ttl time.Duration, tags []string) error {
+	if err := s.l2.SetWithTags(ctx, key, value, ttl, tags); err != nil {
+		return err
+	}
+	s.stale.remember(key, value)
+	return s.l1.SetWithTags(ctx, key, value, s.cacheTTL(ttl), tags)
+}
+
+// InvalidateTag удаляет записи тега в L2, затем в L1.
+// Возвращает количество записей, удаленных из L2.
+// Записи, попавшие в L1 при чтении из L2, не знают своих тегов
+// и остаются в L1 до истечения его TTL.
+func (s *layeredStorage[T]) InvalidateTag(ctx context.Context, tag string) (int64, error) {
+	deleted, err := s.l2.InvalidateTag(ctx, tag)
+	if err != nil {
+		return deleted, err
+	}
+	_, _ = s.l1.InvalidateTag(ctx, tag)
+	return deleted, nil
+}
+
+// CountPattern подсчитывает записи по шаблону в L2.
+func (s *layeredStorage[T]) CountPattern(ctx context.Context, pattern string) (int64, error) {
+	return s.l2.CountPattern(ctx, pattern)
+}
+
+// GetByPrefix получает записи с префиксом из L2 без заполнения L1.
+func (s *layeredStorage[T]) GetByPrefix(ctx context.Context, prefix string) (map[string]T, error) {
+	return s.l2.GetByPrefix(ctx, prefix)
+}
+
+// ScanPage возвращает страницу ключей L2.
+func (s *layeredStorage[T]) ScanPage(ctx context.Context, cursor uint64, match string, count int) ([]string, uint64, error) {
+	return s.l2.ScanPage(ctx, cursor, match, count)
+}
+
+// Enqueue добавляет элемент в очередь L2.
+func (s *layeredStorage[T]) Enqueue(ctx context.Context, queueName string, value T) error {
+	return s.l2.Enqueue(ctx, queueName, value)
+}
+
+// EnqueueFanout добавляет элемент в очереди L2.
+func (s *layeredStorage[T]) EnqueueFanout(ctx context.Context, queueNames []string, value T) error {
+	return s.l2.EnqueueFanout(ctx, queueNames, value)
+}
+
+// DequeueTracked извлекает элемент из очереди L2 и удаляет устаревшую копию ключа отслеживания из L1.
+func (s *layeredStorage[T]) DequeueTracked(ctx context.Context, queueName, trackingKey string, ttl time.Duration) (T, bool, error) {
+	value, found, err := s.l2.DequeueTracked(ctx, queueName, trackingKey, ttl)
+	if err != nil || !found {
+		return value, found, err
+	}
+	s.evict(ctx, trackingKey)
+	return value, true, nil
+}
+
+// EnqueueN добавляет элемент в очередь L2 и возвращает ее новую длину.
+func (s *layeredStorage[T]) EnqueueN(ctx context.Context, queueName string, value T) (int64, error) {
+	return s.l2.EnqueueN(ctx, queueName, value)
+}
+
+// EnqueueCapped добавляет элемент в ограниченную очередь L2.
+func (s *layeredStorage[T]) EnqueueCapped(ctx context.Context, queueName string, value T, maxLen int64) error {
+	return s.l2.EnqueueCapped(ctx, queueName, value, maxLen)
+}
+
+// TryEnqueue добавляет элемент в ограниченную очередь L2 и сообщает причину отказа.
+func (s *layeredStorage[T]) TryEnqueue(ctx context.Context, queueName string, value T, maxLen int64) (bool, EnqueueReason, error) {
+	return s.l2.TryEnqueue(ctx, queueName, value, maxLen)
+}
+
+// EnqueueBounded добавляет элемент в ограниченную очередь L2, если в ней есть место.
+func (s *layeredStorage[T]) EnqueueBounded(ctx context.Context, queueName string, value T, maxLen int64) (bool, error) {
+	return s.l2.EnqueueBounded(ctx, queueName, value, maxLen)
+}
+
+// EnqueueDelayed добавляет отложенный элемент в очередь L2.
+func (s *layeredStorage[T]) EnqueueDelayed(ctx context.Context, queueName string, value T, delay time.Duration) error {
+	return s.l2.EnqueueDelayed(ctx, queueName, value, delay)
+}
+
+// Dequeue извлекает элемент из очереди L2.
+func (s *layeredStorage[T]) Dequeue(ctx context.Context, queueName string) (T, bool, error) {
+	return s.l2.Dequeue(ctx, queueName)
+}
+
+// Peek просматривает первый элемент очереди L2.
+func (s *layeredStorage[T]) Peek(ctx context.Context, queueName string) (T, bool, error) {
+	return s.l2.Peek(ctx, queueName)
+}
+
+// Remove удаляет первый элемент очереди L2.
+func (s *layeredStorage[T]) Remove(ctx context.Context, queueName string) (bool, error) {
+	return s.l2.Remove(ctx, queueName)
+}
+
+// RemoveAt удаляет элемент по позиции в очереди L2.
+func (s *layeredStorage[T]) RemoveAt(ctx context.Context, queueName string, index int64) (bool, error) {
+	return s.l2.RemoveAt(ctx, queueName, index)
+}
+
+// BlockingDequeueAny ждет элемент в очередях L2.
+func (s *layeredStorage[T]) BlockingDequeueAny(ctx context.Context, queueNames []string, timeout time.Duration) (string, T, bool, error) {
+	return s.l2.BlockingDequeueAny(ctx, queueNames, timeout)
+}
+
+// ReplaceQueue заменяет содержимое очереди L2.
+func (s *layeredStorage[T]) ReplaceQueue(ctx context.Context, queueName string, values []T) error {
+	return s.l2.ReplaceQueue(ctx, queueName, values)
+}
+
+// RotateQueue переносит первый элемент очереди L2 в ее конец.
+func (s *layeredStorage[T]) RotateQueue(ctx context.Context, queueName string) (T, bool, error) {
+	return s.l2.RotateQueue(ctx, queueName)
+}
+
+// SetHead заменяет первый элемент очереди L2.
+func (s *layeredStorage[T]) SetHead(ctx context.Context, queueName string, value T) (T, bool, error) {
+	return s.l2.SetHead(ctx, queueName, value)
+}
+
+// MoveMatching переносит элементы между очередями L2.
+func (s *layeredStorage[T]) MoveMatching(ctx context.Context, from, to string, match func(T) bool, limit int) (int, error) {
+	return s.l2.MoveMatching(ctx, from, to, match, limit)
+}
+
+// QueueLen возвращает длину очереди L2.
+func (s *layeredStorage[T]) QueueLen(ctx context.Context, queueName string) (int64, error) {
+	return s.l2.QueueLen(ctx, queueName)
+}
+
+// QueueReadFrom читает элементы очереди L2 по смещению.
+func (s *layeredStorage[T]) QueueReadFrom(ctx context.Context, queueName string, offset int64, n int) ([]T, int64, error) {
+	return s.l2.QueueReadFrom(ctx, queueName, offset, n)
+}
+
+// QueueIterate обходит очередь L2.
+func (s *layeredStorage[T]) QueueIterate(ctx context.Context, queueName string, fn func(T) error) error {
+	return s.l2.QueueIterate(ctx, queueName, fn)
+}
+
+// QueueTail возвращает последние элементы очереди L2.
+func (s *layeredStorage[T]) QueueTail(ctx context.Context, queueName string, n int) ([]T, error) {
+	return s.l2.QueueTail(ctx, queueName, n)
+}
+
+// QueueStats возвращает статистику очереди L2.
+func (s *layeredStorage[T]) QueueStats(ctx context.Context, queueName string) (QueueStats, error) {
+	return s.l2.QueueStats(ctx, queueName)
+}
+
+// QueueLenMany возвращает длины очередей L2.
+func (s *layeredStorage[T]) QueueLenMany(ctx context.Context, queueNames []string) (map[string]int64, error) {
+	return s.l2.QueueLenMany(ctx, queueNames)
+}
+
+// QueueIndexOf ищет элемент в очереди L2.
+func (s *layeredStorage[T]) QueueIndexOf(ctx context.Context, queueName string, match func(T) bool) (int64, bool, error) {
+	return s.l2.QueueIndexOf(ctx, queueName, match)
+}
+
+// QueueIndexOfValue ищет значение в очереди L2.
+func (s *layeredStorage[T]) QueueIndexOfValue(ctx context.Context, queueName string, value T) (int64, bool, error) {
+	return s.l2.QueueIndexOfValue(ctx, queueName, value)
+}
+
+// PeekBatchReserve резервирует элементы очереди L2.
+func (s *layeredStorage[T]) PeekBatchReserve(ctx context.Context, queueName string, n int, vt time.Duration) ([]T, string, error) {
+	return s.l2.PeekBatchReserve(ctx, queueName, n, vt)
+}
+
+// AckBatch подтверждает резервирование в L2.
+func (s *layeredStorage[T]) AckBatch(ctx context.Context, queueName, token string) error {
+	return s.l2.AckBatch(ctx, queueName, token)
+}
+
+// SAddEx добавляет элемент в множество L2.
+func (s *layeredStorage[T]) SAddEx(ctx context.Context, setName string, member T, ttl time.Duration) error {
+	return s.l2.SAddEx(ctx, setName, member, ttl)
+}
+
+// SCount возвращает количество элементов множества L2.
+func (s *layeredStorage[T]) SCount(ctx context.Context, setName string) (int64, error) {
+	return s.l2.SCount(ctx, setName)
+}
+
+// Transact выполняет транзакцию в L2 и после ее фиксации удаляет из L1
+// все записанные ключи, чтобы следующее чтение получило значения из L2.
+func (s *layeredStorage[T]) Transact(ctx context.Context, fn func(tx Tx[T]) error) error {
+	var written []string
+	err := s.l2.Transact(ctx, func(tx Tx[T]) error {
+		ltx := &layeredTx[T]{Tx: tx}
+		err := fn(ltx)
+		written = ltx.written // При повторе транзакции список перезаписывается
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	s.evict(ctx, written...)
+	return nil
+}
+
+// layeredTx запоминает ключи, записанные в транзакции L2.
+type layeredTx[T any] struct {
+	Tx[T]
+	written []string
+}
+
+// Set записывает значение в транзакции L2.
+func (tx *layeredTx[T]) Set(key string, value T, ttl time.Duration) error {
+	tx.written = append(tx.written, key)
+	return tx.Tx.Set(key, value, ttl)
+}
+
+// Delete удаляет значение в транзакции L2.
+func (tx *layeredTx[T]) Delete(key string) error {
+	tx.written = append(tx.written, key)
+	return tx.Tx.Delete(key)
+}
+
+// Increment увеличивает значение в транзакции L2.
+func (tx *layeredTx[T]) Increment(key string, delta int64) (int64, error) {
+	tx.written = append(tx.written, key)
+	return tx.Tx.Increment(key, delta)
+}
+
+// setKeepTTL записывает значение с сохранением времени жизни в транзакции L2,
+// если она это поддерживает (keepTTLSetter).
+func (tx *layeredTx[T]) setKeepTTL(key string, value T) error {
+	base, ok := tx.Tx.(keepTTLSetter[T])
+	if !ok {
+		return tx.Set(key, value, 0)
+	}
+	tx.written = append(tx.written, key)
+	return base.setKeepTTL(key, value)
+}
+
+// Pipeline создает пакет команд, выполняемых в L2 с последующим обновлением L1.
+func (s *layeredStorage[T]) Pipeline() Pipeline[T] {
+	return &layeredPipeline[T]{s: s}
+}
+
+// layeredPipeline выполняет команды в L2, а затем отражает их результаты в L1.
+type layeredPipeline[T any] struct {
+	pipelineOps[T]
+	s *layeredStorage[T]
+}
+
+// Exec выполняет команды пакетом в L2 и обновляет L1 пакетом по результатам:
+// успешные записи и найденные значения кэшируются (не дольше оставшегося времени
+// жизни в L2), удаления и промахи удаляются из L1.
+func (p *layeredPipeline[T]) Exec(ctx context.Context) ([]PipelineResult[T], error) {
+	ops := p.take()
+
+	l2 := p.s.l2.Pipeline()
+	for _, op := range ops {
+		op.apply(l2)
+	}
+	results, err := l2.Exec(ctx)
+
+	l1 := p.s.l1.Pipeline()
+	for i, r := range results {
+		op := ops[i]
+		if r.Err != nil {
+			continue
+		}
+		switch op.kind {
+		case pipelineSet:
+			l1.Set(op.key, op.value, p.s.cacheTTL(op.ttl))
+			p.s.stale.remember(op.key, op.value)
+		case pipelineGet:
+			if r.Found {
+				l1.Set(op.key, r.Value, p.s.cacheTTL(r.TTL))
+				p.s.stale.remember(op.key, r.Value)
+			} else {
+				l1.Delete(op.key)
+				p.s.stale.forget(op.key)
+			}
+		case pipelineDelete:
+			l1.Delete(op.key)
+			p.s.stale.forget(op.key)
+		}
+	}
+	_, _ = l1.Exec(ctx) // Ошибка L1 не должна ломать пакет
+
+	return results, err
+}
+
+// Capabilities возвращает возможности L2: все операции, кроме чтения, выполняются в нем.
+// Диагностика уровней (популярные ключи, статистика) через обертку недоступна.
+func (s *layeredStorage[T]) Capabilities() Capability {
+	return s.l2.Capabilities() &^ (CapHotKeys | CapStats)
+}
+
+// Close закрывает оба уровня и возвращает объединенную ошибку.
+// Повторный вызов возвращает ErrClosed.
+func (s *layeredStorage[T]) Close() error {
+	if !s.closing.CompareAndSwap(false, true) {
+		return ErrClosed
+	}
+	return errors.Join(s.l1.Close(), s.l2.Close())
+}
@@ -0,0 +1,202 @@
+package storage_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alfzs/go-storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLayeredStorage_ReadThrough(t *testing.T) {
+	l1, _ := storage.NewMemory[string](50 * time.Millisecond)
+	l2, _ := storage.NewMemory[string](50 * time.Millisecond)
+	s := storage.NewLayered(l1, l2, time.Minute)
+	defer s.Close()
+	ctx := context.Background()
+
+	require.NoError(t, l2.Set(ctx, "key", "v1", 0))
+
+	val, found, err := s.Get(ctx, "key")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "v1", val)
+
+	// Значение закэшировано в L1
+	val, found, _ = l1.Get(ctx, "key")
+	require.True(t, found)
+	require.Equal(t, "v1", val)
+}
+
+func TestLayeredStorage_ReadThroughKeepsL2Expiry(t *testing.T) {
+	l1, _ := storage.NewMemory[string](50 * time.Millisecond)
+	l2, _ := storage.NewMemory[string](50 * time.Millisecond)
+	s := storage.NewLayered(l1, l2, time.Minute)
+	defer s.Close()
+	ctx := context.Background()
+
+	require.NoError(t, l2.Set(ctx, "key", "v1", 30*time.Millisecond))
+
+	_, found, err := s.Get(ctx, "key")
+	require.NoError(t, err)
+	require.True(t, found)
+
+	// Запись в L1 истекает вместе с L2, а не через l1TTL
+	time.Sleep(50 * time.Millisecond)
+	_, found, err = s.Get(ctx, "key")
+	require.NoError(t, err)
+	require.False(t, found)
+}
+
+func TestLayeredStorage_GetExKeepsL2Expiry(t *testing.T) {
+	l1, _ := storage.NewMemory[string](50 * time.Millisecond)
+	l2, _ := storage.NewMemory[string](50 * time.Millisecond)
+	s := storage.NewLayered(l1, l2, time.Minute)
+	defer s.Close()
+	ctx := context.Background()
+
+	require.NoError(t, l2.Set(ctx, "key", "v1", 30*time.Millisecond))
+
+	// ttl == 0 оставляет время жизни в L2 без изменений
+	_, found, err := s.GetEx(ctx, "key", 0)
+	require.NoError(t, err)
+	require.True(t, found)
+
+	time.Sleep(50 * time.Millisecond)
+	_, found, _ = l1.Get(ctx, "key")
+	require.False(t, found)
+}
+
+func TestLayeredStorage_FreshRead(t *testing.T) {
+	l1, _ := storage.NewMemory[string](50 * time.Millisecond)
+	l2, _ := storage.NewMemory[string](50 * time.Millisecond)
+	s := storage.NewLayered(l1, l2, time.Minute)
+	defer s.Close()
+	ctx := context.Background()
+
+	require.NoError(t, s.Set(ctx, "key", "v1", 0))
+	require.NoError(t, l2.Set(ctx, "key", "v2", 0)) // Изменение в обход L1
+
+	val, _, _ := s.Get(ctx, "key")
+	require.Equal(t, "v1", val)
+
+	val, found, err := s.Get(storage.WithFreshRead(ctx), "key")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "v2", val)
+
+	// L1 обновлен свежим значением
+	val, _, _ = s.Get(ctx, "key")
+	require.Equal(t, "v2", val)
+}
+
+func TestLayeredStorage_Pipeline(t *testing.T) {
+	l1, _ := storage.NewMemory[string](50 * time.Millisecond)
+	l2, _ := storage.NewMemory[string](50 * time.Millisecond)
+	s := storage.NewLayered(l1, l2, time.Minute)
+	defer s.Close()
+	ctx := context.Background()
+
+	require.NoError(t, l1.Set(ctx, "stale", "cached", 0))
+
+	pipe := s.Pipeline()
+	pipe.Set("a", "1", 0)
+	pipe.Delete("stale")
+	_, err := pipe.Exec(ctx)
+	require.NoError(t, err)
+
+	val, found, _ := l1.Get(ctx, "a")
+	require.True(t, found)
+	require.Equal(t, "1", val)
+
+	_, found, _ = l1.Get(ctx, "stale")
+	require.False(t, found)
+}
+
+func TestLayeredStorage_PipelineGetKeepsL2Expiry(t *testing.T) {
+	l1, _ := storage.NewMemory[string](50 * time.Millisecond)
+	l2, _ := storage.NewMemory[string](50 * time.Millisecond)
+	s := storage.NewLayered(l1, l2, time.Minute)
+	defer s.Close()
+	ctx := context.Background()
+
+	require.NoError(t, l2.Set(ctx, "key", "v1", 30*time.Millisecond))
+
+	pipe := s.Pipeline()
+	pipe.Get("key")
+	results, err := pipe.Exec(ctx)
+	require.NoError(t, err)
+	require.True(t, results[0].Found)
+	require.Positive(t, results[0].TTL)
+
+	// Запись в L1 истекает вместе с L2, а не через l1TTL
+	time.Sleep(50 * time.Millisecond)
+	_, found, _ := l1.Get(ctx, "key")
+	require.False(t, found)
+}
+
+func TestLayeredStorage_StaleOnError(t *testing.T) {
+	l1, _ := storage.NewMemory[string](50 * time.Millisecond)
+	mem, _ := storage.NewMemory[string](50 * time.Millisecond)
+	l2 := &flakyGet[string]{Storage: mem}
+	s := storage.NewLayeredStaleOnError[string](l1, l2, 10*time.Millisecond, time.Minute)
+	defer s.Close()
+	ctx := context.Background()
+
+	require.NoError(t, s.Set(ctx, "key", "v1", 0))
+	require.NoError(t, s.Set(ctx, "gone", "v1", 0))
+	require.NoError(t, s.Delete(ctx, "gone"))
+	time.Sleep(20 * time.Millisecond) // Запись в L1 истекла
+
+	l2.failing.Store(true)
+	val, found, stale, err := s.(storage.StaleReader[string]).GetStale(ctx, "key")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.True(t, stale)
+	require.Equal(t, "v1", val)
+
+	val, found, err = s.Get(ctx, "key")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "v1", val)
+
+	// Удаленный ключ и ключ без копии возвращают ошибку L2
+	_, _, err = s.Get(ctx, "gone")
+	require.ErrorIs(t, err, errBackendDown)
+	_, _, err = s.Get(ctx, "missing")
+	require.ErrorIs(t, err, errBackendDown)
+
+	l2.failing.Store(false)
+	_, found, stale, err = s.(storage.StaleReader[string]).GetStale(ctx, "key")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.False(t, stale)
+}
+
+// errBackendDown имитирует недоступность основного хранилища.
+var errBackendDown = errors.New("backend down")
+
+// flakyGet возвращает errBackendDown из Get, пока включен failing.
+type flakyGet[T any] struct {
+	storage.Storage[T]
+	failing atomic.Bool
+}
+
+func (s *flakyGet[T]) Get(ctx context.Context, key string) (T, bool, error) {
+	if s.failing.Load() {
+		var zero T
+		return zero, false, errBackendDown
+	}
+	return s.Storage.Get(ctx, key)
+}
+
+func (s *flakyGet[T]) GetWithTTL(ctx context.Context, key string) (T, time.Duration, bool, error) {
+	if s.failing.Load() {
+		var zero T
+		return zero, 0, false, errBackendDown
+	}
+	return s.Storage.GetWithTTL(ctx, key)
+}
@@ -0,0 +1,123 @@
+package storage
+
+import (
+	"container/list"
+	"sync"
+)
+
+// lruTracker учитывает оценку размера записей in-memory хранилища и порядок
+// обращений к ним для вытеснения по WithMaxBytes.
+// Методы допускают nil-получатель: ограничение выключено.
+type lruTracker struct {
+	mu       sync.Mutex               // Мьютекс для доступа к order и entries
+	order    *list.List               // Ключи от последнего использованного к давнему (*lruEntry)
+	entries  map[string]*list.Element // Ключ -> элемент order
+	bytes    int64                    // Сумма размеров записей
+	maxBytes int64                    // Бюджет размера записей
+}
+
+// lruEntry - запись в порядке обращений.
+type lruEntry struct {
+	key  string
+	size int64 // Длина ключа и сериализованного значения
+}
+
+// newLRUTracker создает трекер с бюджетом maxBytes.
+// Возвращает nil, если maxBytes не положительно (ограничение выключено).
+func newLRUTracker(maxBytes int64) *lruTracker {
+	if maxBytes <= 0 {
+		return nil
+	}
+	return &lruTracker{
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+		maxBytes: maxBytes,
+	}
+}
+
+// put учитывает запись ключа размером size и возвращает давно не использованные
+// ключи, которые нужно вытеснить, чтобы уложиться в бюджет.
+// Только что записанный ключ не вытесняется, даже если он один превышает бюджет.
+func (t *lruTracker) put(key string, size int64) []string {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if el, ok := t.entries[key]; ok {
+		e := el.Value.(*lruEntry)
+		t.bytes += size - e.size
+		e.size = size
+		t.order.MoveToFront(el)
+	} else {
+		t.entries[key] = t.order.PushFront(&lruEntry{key: key, size: size})
+		t.bytes += size
+	}
+
+	var evict []string
+	for t.bytes > t.maxBytes && t.order.Len() > 1 {
+		e := t.order.Remove(t.order.Back()).(*lruEntry)
+		delete(t.entries, e.key)
+		t.bytes -= e.size
+		evict = append(evict, e.key)
+	}
+	return evict
+}
+
+// touch отмечает обращение к ключу.
+func (t *lruTracker) touch(key string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if el, ok := t.entries[key]; ok {
+		t.order.MoveToFront(el)
+	}
+}
+
+// remove перестает учитывать удаленный ключ.
+func (t *lruTracker) remove(key string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if el, ok := t.entries[key]; ok {
+		t.bytes -= el.Value.(*lruEntry).size
+		t.order.Remove(el)
+		delete(t.entries, key)
+	}
+}
+
+// size возвращает сумму размеров учтенных записей.
+func (t *lruTracker) size() int64 {
+	if t == nil {
+		return 0
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.bytes
+}
+
+// track учитывает размер записанного значения и вытесняет давно не использованные
+// записи при превышении WithMaxBytes. Размер оценивается длиной ключа
+// и значения, сериализованного кодеком хранилища.
+// Должен вызываться под блокировкой itemMu на запись после записи в items.
+func (s *memoryStorage[T]) track(key string, value T) {
+	if s.lru == nil {
+		return
+	}
+	size := int64(len(key))
+	if data, err := s.opts.codec.Marshal(value); err == nil {
+		size += int64(len(data))
+	}
+	for _, evicted := range s.lru.put(key, size) {
+		delete(s.items, evicted)
+		s.tags.remove(evicted)
+		s.stats.pressureEvictions.Add(1)
+	}
+}
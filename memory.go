@@ -1,8 +1,16 @@
 package storage
 
 import (
+	"container/heap"
 	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -11,30 +19,132 @@ import (
 // Хранит данные в map для ключ-значение и map для очередей.
 // Использует sync.RWMutex для безопасного доступа из разных горутин.
 type memoryStorage[T any] struct {
-	items   map[string]item[T] // Хранилище ключ-значение
-	queues  map[string][]T     // Хранилище очередей (имя очереди -> элементы)
-	itemMu  sync.RWMutex       // Мьютекс для доступа к items
-	queueMu sync.RWMutex       // Мьютекс для доступа к queues
-	stop    chan struct{}      // Канал для остановки сборщика мусора
+	items   map[string]item[T]   // Хранилище ключ-значение
+	expiry  expiryQueue          // Очередь истечений записей items, защищена itemMu
+	queues  map[string]*deque[T] // Хранилище очередей (имя очереди -> элементы)
+	itemMu  sync.RWMutex         // Мьютекс для доступа к items
+	queueMu sync.RWMutex         // Мьютекс для доступа к queues
+	stop    chan struct{}        // Канал для остановки сборщика мусора
+	bg      sync.WaitGroup       // Фоновые горутины (сборщик мусора и запись снимков), которых ждет Close
+	closing atomic.Bool          // Close уже вызывался (защита от повторного закрытия stop)
+	stats   memoryCounters       // Счетчики операций
+	opts    options              // Дополнительные параметры хранилища
+	hot     *hotKeyTracker       // Трекер частых ключей (nil, если выключен)
+	lru     *lruTracker          // Размер и порядок обращений для WithMaxBytes (nil, если выключен)
+	rates   queueRates           // Скорости пополнения и разбора очередей
+	wal     *queueWAL            // Журнал очередей (nil, если выключен), защищен queueMu
+
+	// Зарезервированные элементы (имя очереди -> токен -> пачка), защищены queueMu
+	reservations map[string]map[string]reservation[T]
+	// Отложенные элементы, упорядоченные по времени готовности, защищены queueMu
+	delayed map[string][]delayedItem[T]
+	// Каналы ожидающих BlockingDequeueAny (имя очереди -> каналы), защищены queueMu
+	waiters map[string]map[chan struct{}]struct{}
+	// Каналы ожидающих WaitForKey (ключ -> каналы), защищены itemMu
+	keyWaiters map[string]map[chan struct{}]struct{}
+
+	windowMu sync.Mutex               // Мьютекс для доступа к windows
+	windows  map[string]windowCounter // Счетчики окон IncrWindow
+
+	setMu sync.Mutex                  // Мьютекс для доступа к sets
+	sets  map[string]map[string]int64 // Множества (имя -> сериализованный элемент -> время истечения)
+
+	tags tagIndex // Теги ключей SetWithTags, защищены itemMu
+
+	// Теги типов очередей WithQueueTypeCheck (имя очереди -> тег), защищены queueMu
+	queueTags map[string]string
+	// Очереди, из которых удалялись элементы (см. ErrQueueConsumed), защищены queueMu
+	consumed map[string]struct{}
+
+	historyMu sync.Mutex     // Мьютекс для доступа к histories (захватывается после itemMu)
+	histories map[string][]T // История версий SetVersioned (ключ -> версии от последней)
+}
+
+// memoryCounters содержит атомарные счетчики операций хранилища.
+type memoryCounters struct {
+	hits      atomic.Int64
+	misses    atomic.Int64
+	sets      atomic.Int64
+	deletes   atomic.Int64
+	evictions atomic.Int64
+
+	pressureEvictions atomic.Int64
 }
 
 // newMemoryStorage создает новый экземпляр in-memory хранилища.
-// Принимает интервал очистки устаревших элементов и возвращает интерфейс Storage[T].
-// Запускает фоновую горутину для периодической очистки устаревших элементов.
-func newMemoryStorage[T any](cleanupInterval time.Duration) Storage[T] {
+// Принимает интервал очистки устаревших элементов и дополнительные опции,
+// возвращает интерфейс Storage[T].
+// Если задан WithSnapshot, загружает снимок и запускает его периодическую запись.
+// Если задан WithQueueWAL, восстанавливает очереди из журнала.
+// Запускает фоновую горутину для периодической очистки устаревших элементов,
+// которая останавливается при вызове Close или отмене ctx.
+func newMemoryStorage[T any](ctx context.Context, cleanupInterval time.Duration, opts []Option) (Storage[T], error) {
+	// time.NewTicker паникует при неположительном интервале, причем в фоновой горутине
+	if cleanupInterval <= 0 {
+		return nil, fmt.Errorf("%w, got %s", ErrInvalidInterval, cleanupInterval)
+	}
+
+	o := defaultOptions(opts)
 	s := &memoryStorage[T]{
 		items:  make(map[string]item[T]),
-		queues: make(map[string][]T),
+		queues: make(map[string]*deque[T]),
 		stop:   make(chan struct{}),
+		opts:   o,
+		hot:    newHotKeyTracker(o.hotKeys),
+		lru:    newLRUTracker(o.maxBytes),
+
+		reservations: make(map[string]map[string]reservation[T]),
+		delayed:      make(map[string][]delayedItem[T]),
+		waiters:      make(map[string]map[chan struct{}]struct{}),
+		keyWaiters:   make(map[string]map[chan struct{}]struct{}),
+		sets:         make(map[string]map[string]int64),
+		windows:      make(map[string]windowCounter),
+		histories:    make(map[string][]T),
+		queueTags:    make(map[string]string),
+		consumed:     make(map[string]struct{}),
+	}
+
+	if o.snapshotPath != "" {
+		if err := s.loadSnapshot(); err != nil {
+			return nil, err
+		}
+		if o.snapshotInterval > 0 {
+			s.bg.Add(1)
+			go func() {
+				defer s.bg.Done()
+				s.runSnapshots(ctx, o.snapshotInterval) // Запускаем периодическую запись снимков
+			}()
+		}
 	}
-	go s.runGC(cleanupInterval) // Запускаем сборщик мусора
-	return s
+	if o.queueWALPath != "" {
+		if err := s.openWAL(o.queueWALPath); err != nil {
+			return nil, err
+		}
+	}
+
+	s.bg.Add(1)
+	go func() {
+		defer s.bg.Done()
+		s.runGC(ctx, cleanupInterval) // Запускаем сборщик мусора
+	}()
+	return s, nil
 }
 
 // item представляет элемент хранилища с значением и временем истечения срока жизни.
 type item[T any] struct {
-	value      T     // Значение элемента
-	expiration int64 // Время истечения в наносекундах (0 - бессрочно)
+	value      T      // Значение элемента
+	expiration int64  // Время истечения в наносекундах (0 - бессрочно)
+	created    int64  // Время создания в наносекундах (только в режиме WithMetadata)
+	updated    int64  // Время обновления в наносекундах (только в режиме WithMetadata)
+	origKey    string // Исходный ключ, если он был заменен хешем (только в режиме WithMetadata)
+}
+
+// meta возвращает метаданные элемента.
+func (i item[T]) meta() Meta {
+	if i.created == 0 {
+		return Meta{}
+	}
+	return Meta{CreatedAt: time.Unix(0, i.created), UpdatedAt: time.Unix(0, i.updated), Key: i.origKey}
 }
 
 // isExpired проверяет, истек ли срок жизни элемента.
@@ -43,25 +153,69 @@ func (i item[T]) isExpired() bool {
 	return i.expiration > 0 && time.Now().UnixNano() > i.expiration
 }
 
-// Close останавливает фоновый сборщик мусора и освобождает ресурсы.
+// Close останавливает фоновый сборщик мусора и дожидается завершения фоновых горутин,
+// затем освобождает ресурсы.
+// Сначала передает итоговую статистику хукам WithHook и вызывает их Flush.
+// Если задан WithSnapshot, записывает последний снимок и возвращает ошибку записи.
+// Если задан WithQueueWAL, синхронизирует журнал очередей с диском и закрывает его.
 // Должен вызываться при завершении работы с хранилищем.
+// Повторный вызов безопасен и возвращает ErrClosed.
 func (s *memoryStorage[T]) Close() error {
+	if !s.closing.CompareAndSwap(false, true) {
+		return ErrClosed
+	}
+	hookErr := flushHooks(s.opts.hooks, s.Stats)
 	close(s.stop) // Посылаем сигнал остановки сборщику мусора
+	s.bg.Wait()   // Ждем текущую запись снимка, иначе она заменит итоговый снимок
+	var snapshotErr error
+	if s.opts.snapshotPath != "" {
+		snapshotErr = s.writeSnapshot()
+	}
+	var walErr error
+	s.queueMu.Lock()
+	if s.wal != nil {
+		walErr = s.wal.close()
+		s.wal = nil // Последующие изменения очередей журнал не пишут
+	}
+	s.queueMu.Unlock()
+	if err := errors.Join(hookErr, snapshotErr, walErr); err != nil {
+		return fmt.Errorf("memory close failed: %w", err)
+	}
 	return nil
 }
 
+// Capabilities возвращает возможности in-memory хранилища.
+// Метаданные и отслеживание популярных ключей учитываются, только если включены опциями.
+func (s *memoryStorage[T]) Capabilities() Capability {
+	caps := CapTTL | CapQueues | CapPipeline | CapPatternDelete | CapScan | CapStats | CapTransactions
+	if s.opts.metadata {
+		caps |= CapMetadata
+	}
+	if s.hot != nil {
+		caps |= CapHotKeys
+	}
+	return caps
+}
+
 // runGC запускает сборщик мусора, который периодически удаляет устаревшие элементы.
-// Работает в фоновой горутине до получения сигнала остановки.
-func (s *memoryStorage[T]) runGC(interval time.Duration) {
+// Работает в фоновой горутине до получения сигнала остановки или отмены ctx.
+func (s *memoryStorage[T]) runGC(ctx context.Context, interval time.Duration) {
 	ticker := time.NewTicker(interval) // Таймер для периодического запуска
 	defer ticker.Stop()                // Освобождаем ресурсы таймера при остановке
 
 	for {
 		select {
 		case <-ticker.C: // По истечении интервала
-			s.deleteExpired() // Удаляем устаревшие элементы
+			s.sweepExpired(ctx, interval) // Удаляем устаревшие элементы
+			s.requeueExpired()            // Возвращаем истекшие резервирования в очереди
+			s.promoteDelayed()            // Переносим готовые отложенные элементы в очереди
+			s.sweepSets()                 // Удаляем истекшие элементы множеств
+			s.sweepWindows()              // Удаляем счетчики закончившихся окон
+			s.compactWAL()                // Сжимаем журнал очередей
 		case <-s.stop: // При получении сигнала остановки
 			return // Завершаем работу горутины
+		case <-ctx.Done(): // При отмене контекста владельца (аналог Close)
+			return
 		}
 	}
 }
@@ -70,141 +224,2002 @@ func (s *memoryStorage[T]) runGC(interval time.Duration) {
 // Принимает контекст, ключ, значение и время жизни записи (TTL).
 // Если TTL > 0, устанавливает время жизни записи, иначе запись хранится бессрочно.
 func (s *memoryStorage[T]) Set(ctx context.Context, key string, value T, ttl time.Duration) error {
+	_, err := s.SetX(ctx, key, value, ttl)
+	return err
+}
+
+// SetX сохраняет значение как Set и возвращает примененное время истечения.
+func (s *memoryStorage[T]) SetX(ctx context.Context, key string, value T, ttl time.Duration) (time.Time, error) {
+	key, origKey, err := s.opts.resolveKey(key)
+	if err != nil {
+		return time.Time{}, err
+	}
+	s.hot.record(key)
+	if err := s.opts.checkValueSize(value); err != nil {
+		return time.Time{}, err
+	}
+
+	var expiresAt time.Time
+	if ttl = s.opts.jitterTTL(ttl); ttl > 0 {
+		expiresAt = time.Now().Add(ttl) // Вычисляем время истечения
+	}
 	var expiration int64
-	if ttl > 0 {
-		expiration = time.Now().Add(ttl).UnixNano() // Вычисляем время истечения
+	if !expiresAt.IsZero() {
+		expiration = expiresAt.UnixNano()
 	}
 
 	s.itemMu.Lock()         // Блокируем на запись
 	defer s.itemMu.Unlock() // Гарантируем разблокировку
 
-	s.items[key] = item[T]{
-		value:      value,
-		expiration: expiration,
+	s.items[key] = s.newItem(key, origKey, value, expiration)
+	s.track(key, value)
+	s.stats.sets.Add(1)
+	return expiresAt, nil
+}
+
+// deleteItem удаляет запись и исключает ключ из учета WithMaxBytes и из тегов.
+// Должен вызываться под блокировкой itemMu на запись.
+func (s *memoryStorage[T]) deleteItem(key string) {
+	delete(s.items, key)
+	s.lru.remove(key)
+	s.tags.remove(key)
+}
+
+// newItem создает элемент для записи по ключу, планирует его истечение
+// и будит ожидающих WaitForKey.
+// В режиме WithMetadata сохраняет время создания существующего элемента
+// и исходный ключ origKey, если key является его хешем.
+// Запись поверх истекшего, но еще не удаленного элемента не наследует его теги.
+// Должен вызываться под блокировкой itemMu на запись.
+func (s *memoryStorage[T]) newItem(key, origKey string, value T, expiration int64) item[T] {
+	s.notifyKey(key)
+	s.expiry.schedule(key, expiration)
+	if old, found := s.items[key]; found && old.isExpired() {
+		s.tags.remove(key)
 	}
-	return nil
+	it := item[T]{value: value, expiration: expiration}
+	if s.opts.metadata {
+		now := time.Now().UnixNano()
+		it.created, it.updated, it.origKey = now, now, origKey
+		if old, found := s.items[key]; found && !old.isExpired() && old.created != 0 {
+			it.created = old.created
+		}
+	}
+	return it
+}
+
+// WaitForKey ждет записи ключа: каждая запись будит ожидающих этого ключа,
+// поэтому значение возвращается без опроса и задержки. Ожидание прерывается по timeout,
+// отмене ctx или закрытию хранилища (found=false).
+func (s *memoryStorage[T]) WaitForKey(ctx context.Context, key string, timeout time.Duration) (T, bool, error) {
+	var zero T
+	key, err := s.opts.checkKey(key)
+	if err != nil {
+		return zero, false, err
+	}
+	s.hot.record(key)
+
+	var deadline <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	// Регистрация до первой проверки: запись между проверкой и ожиданием не теряется
+	wake := make(chan struct{}, 1)
+	s.itemMu.Lock()
+	if s.keyWaiters[key] == nil {
+		s.keyWaiters[key] = make(map[chan struct{}]struct{})
+	}
+	s.keyWaiters[key][wake] = struct{}{}
+	s.itemMu.Unlock()
+	defer func() {
+		s.itemMu.Lock()
+		delete(s.keyWaiters[key], wake)
+		if len(s.keyWaiters[key]) == 0 {
+			delete(s.keyWaiters, key)
+		}
+		s.itemMu.Unlock()
+	}()
+
+	for {
+		s.itemMu.RLock()
+		item, found := s.items[key]
+		s.itemMu.RUnlock()
+		if found && !item.isExpired() {
+			s.stats.hits.Add(1)
+			return item.value, true, nil
+		}
+
+		select {
+		case <-wake:
+		case <-deadline:
+			s.stats.misses.Add(1)
+			return zero, false, nil
+		case <-ctx.Done():
+			return zero, false, ctx.Err()
+		case <-s.stop:
+			return zero, false, nil
+		}
+	}
+}
+
+// notifyKey будит ожидающих записи ключа key.
+// Должен вызываться под блокировкой itemMu на запись.
+func (s *memoryStorage[T]) notifyKey(key string) {
+	for wake := range s.keyWaiters[key] {
+		select {
+		case wake <- struct{}{}:
+		default: // Ожидающий уже разбужен
+		}
+	}
+}
+
+// GetInto копирует значение записи в *dst присваиванием, как Get.
+// Значения хранятся без сериализации, поэтому декодировать нечего.
+func (s *memoryStorage[T]) GetInto(ctx context.Context, key string, dst *T) (bool, error) {
+	value, found, err := s.Get(ctx, key)
+	if found {
+		*dst = value
+	}
+	return found, err
+}
+
+// SetWithCodec сохраняет значение как Set. Значения хранятся без сериализации,
+// поэтому кодек только проверяется, как в Redis, чтобы ошибка не проявилась при смене хранилища.
+func (s *memoryStorage[T]) SetWithCodec(ctx context.Context, key string, value T, ttl time.Duration, codec Codec) error {
+	if err := checkCodec(s.opts.codec, codec); err != nil {
+		return err
+	}
+	return s.Set(ctx, key, value, ttl)
+}
+
+// GetWithCodec получает значение как Get: декодировать нечего.
+func (s *memoryStorage[T]) GetWithCodec(ctx context.Context, key string, codec Codec) (T, bool, error) {
+	return s.Get(ctx, key)
 }
 
 // Get получает значение из хранилища по ключу.
 // Возвращает значение, флаг наличия значения и ошибку.
 // Если ключ не найден или срок действия истек, возвращает false во втором возвращаемом значении.
 func (s *memoryStorage[T]) Get(ctx context.Context, key string) (T, bool, error) {
+	var zero T // Нулевое значение типа T для возврата по умолчанию
+
+	key, err := s.opts.checkKey(key)
+	if err != nil {
+		return zero, false, err
+	}
+	s.hot.record(key)
+
 	s.itemMu.RLock()         // Блокируем на чтение
 	defer s.itemMu.RUnlock() // Гарантируем разблокировку
 
-	var zero T // Нулевое значение типа T для возврата по умолчанию
 	item, found := s.items[key]
 	if !found || item.isExpired() {
+		s.stats.misses.Add(1)
+		return zero, false, nil
+	}
+	s.lru.touch(key)
+	s.stats.hits.Add(1)
+	return item.value, true, nil
+}
+
+// GetWithTTL получает значение и оставшееся время жизни записи под одной блокировкой на чтение.
+func (s *memoryStorage[T]) GetWithTTL(ctx context.Context, key string) (T, time.Duration, bool, error) {
+	var zero T
+
+	key, err := s.opts.checkKey(key)
+	if err != nil {
+		return zero, 0, false, err
+	}
+	s.hot.record(key)
+
+	s.itemMu.RLock()         // Блокируем на чтение
+	defer s.itemMu.RUnlock() // Гарантируем разблокировку
+
+	item, found := s.items[key]
+	if !found || item.isExpired() {
+		s.stats.misses.Add(1)
+		return zero, 0, false, nil
+	}
+	s.lru.touch(key)
+	s.stats.hits.Add(1)
+
+	var ttl time.Duration
+	if item.expiration != 0 {
+		ttl = max(time.Until(time.Unix(0, item.expiration)), 1)
+	}
+	return item.value, ttl, true, nil
+}
+
+// GetEx получает значение и обновляет время жизни записи в одной критической секции.
+// ttl > 0 задает новое время жизни, ttl == 0 оставляет его без изменений,
+// отрицательный ttl (PersistTTL) делает запись бессрочной.
+func (s *memoryStorage[T]) GetEx(ctx context.Context, key string, ttl time.Duration) (T, bool, error) {
+	var zero T
+
+	key, err := s.opts.checkKey(key)
+	if err != nil {
+		return zero, false, err
+	}
+	s.hot.record(key)
+
+	s.itemMu.Lock()         // Блокируем на запись
+	defer s.itemMu.Unlock() // Гарантируем разблокировку
+
+	item, found := s.items[key]
+	if !found || item.isExpired() {
+		s.stats.misses.Add(1)
 		return zero, false, nil
 	}
+
+	switch {
+	case ttl > 0:
+		item.expiration = time.Now().Add(ttl).UnixNano()
+		s.items[key] = item
+		s.expiry.schedule(key, item.expiration)
+	case ttl < 0:
+		item.expiration = 0 // Бессрочно
+		s.items[key] = item
+	}
+
+	s.lru.touch(key)
+	s.stats.hits.Add(1)
 	return item.value, true, nil
 }
 
+// GetRaw получает значение и сериализует его кодеком хранилища.
+// Значение копируется под блокировкой на чтение, сериализуется после ее снятия.
+func (s *memoryStorage[T]) GetRaw(ctx context.Context, key string) ([]byte, bool, error) {
+	key, err := s.opts.checkKey(key)
+	if err != nil {
+		return nil, false, err
+	}
+	s.hot.record(key)
+
+	s.itemMu.RLock() // Блокируем на чтение
+	item, found := s.items[key]
+	s.itemMu.RUnlock()
+	if !found || item.isExpired() {
+		return nil, false, nil
+	}
+	s.lru.touch(key)
+
+	data, err := s.opts.codec.Marshal(item.value)
+	if err != nil {
+		return nil, false, fmt.Errorf("marshal failed: %w", err)
+	}
+	return data, true, nil
+}
+
+// GetWithMeta получает значение вместе с временем создания и обновления.
+// Без опции WithMetadata возвращает пустую Meta.
+func (s *memoryStorage[T]) GetWithMeta(ctx context.Context, key string) (T, Meta, bool, error) {
+	var zero T
+
+	key, err := s.opts.checkKey(key)
+	if err != nil {
+		return zero, Meta{}, false, err
+	}
+	s.hot.record(key)
+
+	s.itemMu.RLock()         // Блокируем на чтение
+	defer s.itemMu.RUnlock() // Гарантируем разблокировку
+
+	item, found := s.items[key]
+	if !found || item.isExpired() {
+		s.stats.misses.Add(1)
+		return zero, Meta{}, false, nil
+	}
+	s.lru.touch(key)
+	s.stats.hits.Add(1)
+	return item.value, item.meta(), true, nil
+}
+
 // Delete удаляет значение из хранилища по ключу.
 // Возвращает ошибку, если операция не удалась.
 func (s *memoryStorage[T]) Delete(ctx context.Context, key string) error {
+	key, err := s.opts.checkKey(key)
+	if err != nil {
+		return err
+	}
+	s.hot.record(key)
+
 	s.itemMu.Lock()         // Блокируем на запись
 	defer s.itemMu.Unlock() // Гарантируем разблокировку
-	delete(s.items, key)
+	s.deleteItem(key)
+	s.stats.deletes.Add(1)
 	return nil
 }
 
-// Enqueue добавляет элемент в конец очереди.
-// Принимает имя очереди и значение для добавления.
-// Если очередь не существует, создает новую.
-func (s *memoryStorage[T]) Enqueue(ctx context.Context, queueName string, value T) error {
-	s.queueMu.Lock()         // Блокируем на запись
-	defer s.queueMu.Unlock() // Гарантируем разблокировку
+// DeleteIf сравнивает значение с expected через reflect.DeepEqual и удаляет запись
+// под той же блокировкой на запись.
+func (s *memoryStorage[T]) DeleteIf(ctx context.Context, key string, expected T) (bool, error) {
+	key, err := s.opts.checkKey(key)
+	if err != nil {
+		return false, err
+	}
+	s.hot.record(key)
 
-	s.queues[queueName] = append(s.queues[queueName], value)
-	return nil
-}
+	s.itemMu.Lock()         // Блокируем на запись
+	defer s.itemMu.Unlock() // Гарантируем разблокировку
 
-// Dequeue извлекает и удаляет элемент из начала очереди.
-// Возвращает элемент, флаг наличия элемента и ошибку.
-// Если очередь пуста, возвращает false во втором возвращаемом значении.
-func (s *memoryStorage[T]) Dequeue(ctx context.Context, queueName string) (T, bool, error) {
-	s.queueMu.Lock()         // Блокируем на запись
-	defer s.queueMu.Unlock() // Гарантируем разблокировку
+	item, found := s.items[key]
+	if !found || item.isExpired() || !reflect.DeepEqual(item.value, expected) {
+		return false, nil
+	}
+
+	s.deleteItem(key)
+	s.stats.deletes.Add(1)
+	return true, nil
+}
 
+// GetDelete получает значение по ключу и удаляет его в одной критической секции.
+// Повторный вызов для того же ключа вернет false, поэтому значение
+// может быть получено только один раз.
+func (s *memoryStorage[T]) GetDelete(ctx context.Context, key string) (T, bool, error) {
 	var zero T
-	queue, exists := s.queues[queueName]
-	if !exists || len(queue) == 0 {
-		return zero, false, nil
+
+	key, err := s.opts.checkKey(key)
+	if err != nil {
+		return zero, false, err
 	}
+	s.hot.record(key)
 
-	value := queue[0]
-	s.queues[queueName] = queue[1:] // Удаляем первый элемент сдвигом слайса
+	s.itemMu.Lock()         // Блокируем на запись
+	defer s.itemMu.Unlock() // Гарантируем разблокировку
 
-	// Оптимизация: если очередь пуста, удаляем её из мапы
-	if len(s.queues[queueName]) == 0 {
-		delete(s.queues, queueName)
+	item, found := s.items[key]
+	if !found || item.isExpired() {
+		s.stats.misses.Add(1)
+		return zero, false, nil
 	}
 
-	return value, true, nil
+	s.deleteItem(key)
+	s.stats.hits.Add(1)
+	s.stats.deletes.Add(1)
+	return item.value, true, nil
 }
 
-// Peek возвращает первый элемент из очереди без его удаления.
-// Возвращает элемент, флаг наличия элемента и ошибку.
-// Если очередь пуста, возвращает false во втором возвращаемом значении.
-func (s *memoryStorage[T]) Peek(ctx context.Context, queueName string) (T, bool, error) {
-	s.queueMu.RLock()         // Блокируем на чтение
-	defer s.queueMu.RUnlock() // Гарантируем разблокировку
+// ClaimOnce записывает маркер под блокировкой на запись, если ключ отсутствует или истек.
+// Время жизни не смещается опцией WithTTLJitter: маркер должен истечь точно в срок.
+func (s *memoryStorage[T]) ClaimOnce(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	key, origKey, err := s.opts.resolveKey(key)
+	if err != nil {
+		return false, err
+	}
+	s.hot.record(key)
 
-	var zero T
-	queue, exists := s.queues[queueName]
-	if !exists || len(queue) == 0 {
-		return zero, false, nil
+	var expiration int64
+	if ttl > 0 {
+		expiration = time.Now().Add(ttl).UnixNano()
 	}
 
-	return queue[0], true, nil
+	s.itemMu.Lock()         // Блокируем на запись
+	defer s.itemMu.Unlock() // Гарантируем разблокировку
+
+	if existing, found := s.items[key]; found && !existing.isExpired() {
+		return false, nil
+	}
+	var marker T
+	s.items[key] = s.newItem(key, origKey, marker, expiration)
+	s.track(key, marker)
+	s.stats.sets.Add(1)
+	return true, nil
 }
 
-// Remove удаляет первый элемент из очереди без его возврата.
-// Возвращает флаг успешности операции и ошибку.
-// Если очередь пуста, возвращает false в первом возвращаемом значении.
-func (s *memoryStorage[T]) Remove(ctx context.Context, queueName string) (bool, error) {
-	s.queueMu.Lock()         // Блокируем на запись
-	defer s.queueMu.Unlock() // Гарантируем разблокировку
+// CopyKey копирует запись под блокировкой на запись: копия получает то же значение
+// и время истечения. Значение копируется через кодек хранилища (как в снимке), поэтому
+// ссылочные типы (срезы, карты, указатели) не разделяются между src и dst, как и в
+// Redis. Метаданные (WithMetadata) копируются без изменений, а теги dst (SetWithTags)
+// удаляются: копия не наследует теги src.
+func (s *memoryStorage[T]) CopyKey(ctx context.Context, src, dst string, replace bool) (bool, error) {
+	src, err := s.opts.checkKey(src)
+	if err != nil {
+		return false, err
+	}
+	dst, _, err = s.opts.resolveKey(dst)
+	if err != nil {
+		return false, err
+	}
+
+	s.itemMu.Lock()         // Блокируем на запись
+	defer s.itemMu.Unlock() // Гарантируем разблокировку
 
-	queue, exists := s.queues[queueName]
-	if !exists || len(queue) == 0 {
+	item, found := s.items[src]
+	if !found || item.isExpired() {
+		return false, nil
+	}
+	if existing, ok := s.items[dst]; ok && !existing.isExpired() && !replace {
 		return false, nil
 	}
 
-	s.queues[queueName] = queue[1:] // Удаляем первый элемент сдвигом слайса
-
-	// Оптимизация: если очередь пуста, удаляем её из мапы
-	if len(s.queues[queueName]) == 0 {
-		delete(s.queues, queueName)
+	data, err := s.opts.codec.Marshal(item.value)
+	if err != nil {
+		return false, fmt.Errorf("marshal failed: %w", err)
 	}
+	var value T
+	if err := s.opts.codec.Unmarshal(data, &value); err != nil {
+		return false, fmt.Errorf("unmarshal failed: %w", err)
+	}
+	item.value = value
 
+	s.tags.remove(dst)
+	s.items[dst] = item
+	s.track(dst, item.value)
+	s.expiry.schedule(dst, item.expiration)
+	s.notifyKey(dst)
+	s.stats.sets.Add(1)
 	return true, nil
 }
 
-// QueueLen возвращает текущую длину очереди.
-// Возвращает количество элементов в очереди и ошибку, если операция не удалась.
-// Если очередь не существует, возвращает 0.
-func (s *memoryStorage[T]) QueueLen(ctx context.Context, queueName string) (int64, error) {
-	s.queueMu.RLock()         // Блокируем на чтение
-	defer s.queueMu.RUnlock() // Гарантируем разблокировку
+// SetVersioned записывает значение как Set с ttl 0 и добавляет его в начало истории ключа
+// под блокировками items и истории, поэтому значение и история меняются атомарно.
+func (s *memoryStorage[T]) SetVersioned(ctx context.Context, key string, value T, keep int) error {
+	if keep <= 0 {
+		return ErrInvalidMaxLen
+	}
+	key, origKey, err := s.opts.resolveKey(key)
+	if err != nil {
+		return err
+	}
+	s.hot.record(key)
+	if err := s.opts.checkValueSize(value); err != nil {
+		return err
+	}
 
-	queue, exists := s.queues[queueName]
-	if !exists {
-		return 0, nil
+	s.itemMu.Lock()            // Блокируем items на запись
+	defer s.itemMu.Unlock()    // Гарантируем разблокировку
+	s.historyMu.Lock()         // Блокируем историю на запись
+	defer s.historyMu.Unlock() // Гарантируем разблокировку
+
+	s.items[key] = s.newItem(key, origKey, value, 0)
+	s.track(key, value)
+	s.stats.sets.Add(1)
+
+	versions := s.histories[key]
+	if len(versions) >= keep {
+		versions = versions[:keep-1]
+	}
+	s.histories[key] = slices.Insert(versions, 0, value)
+	return nil
+}
+
+// GetVersion возвращает версию из истории ключа.
+func (s *memoryStorage[T]) GetVersion(ctx context.Context, key string, n int) (T, bool, error) {
+	var zero T
+	if n < 0 {
+		return zero, false, ErrInvalidOffset
+	}
+	key, err := s.opts.checkKey(key)
+	if err != nil {
+		return zero, false, err
+	}
+
+	s.historyMu.Lock()         // Блокируем историю
+	defer s.historyMu.Unlock() // Гарантируем разблокировку
+
+	versions := s.histories[key]
+	if n >= len(versions) {
+		return zero, false, nil
+	}
+	return versions[n], true, nil
+}
+
+// Toggle инвертирует логическое значение под блокировкой на запись.
+// Время истечения существующей записи сохраняется.
+func (s *memoryStorage[T]) Toggle(ctx context.Context, key string) (bool, error) {
+	key, origKey, err := s.opts.resolveKey(key)
+	if err != nil {
+		return false, err
 	}
-	return int64(len(queue)), nil
+	s.hot.record(key)
+
+	s.itemMu.Lock()         // Блокируем на запись
+	defer s.itemMu.Unlock() // Гарантируем разблокировку
+
+	var current T
+	var expiration int64
+	if item, found := s.items[key]; found && !item.isExpired() {
+		current, expiration = item.value, item.expiration
+	}
+	next, toggled, err := toggleBool(current)
+	if err != nil {
+		return false, err
+	}
+
+	s.items[key] = s.newItem(key, origKey, next, expiration)
+	s.track(key, next)
+	s.stats.sets.Add(1)
+	return toggled, nil
+}
+
+// IncrementField увеличивает поле структуры через reflect под блокировкой на запись.
+// Время истечения существующей записи сохраняется.
+func (s *memoryStorage[T]) IncrementField(ctx context.Context, key, field string, delta int64) (int64, error) {
+	key, origKey, err := s.opts.resolveKey(key)
+	if err != nil {
+		return 0, err
+	}
+	s.hot.record(key)
+
+	s.itemMu.Lock()         // Блокируем на запись
+	defer s.itemMu.Unlock() // Гарантируем разблокировку
+
+	var current T
+	var expiration int64
+	if item, found := s.items[key]; found && !item.isExpired() {
+		current, expiration = item.value, item.expiration
+	}
+	next, n, err := incrementStructField(current, field, delta)
+	if err != nil {
+		return 0, err
+	}
+
+	s.items[key] = s.newItem(key, origKey, next, expiration)
+	s.track(key, next)
+	s.stats.sets.Add(1)
+	return n, nil
 }
 
-// deleteExpired удаляет все элементы с истекшим сроком жизни из хранилища.
-// Вызывается периодически сборщиком мусора.
-func (s *memoryStorage[T]) deleteExpired() {
+// DeletePattern удаляет все записи, ключи которых соответствуют шаблону.
+// Перебор и удаление выполняются под одной блокировкой на запись.
+// Возвращает количество удаленных записей (без учета уже истекших).
+func (s *memoryStorage[T]) DeletePattern(ctx context.Context, pattern string) (int64, error) {
 	s.itemMu.Lock()         // Блокируем на запись
 	defer s.itemMu.Unlock() // Гарантируем разблокировку
 
+	var deleted int64
+	for key, item := range s.items {
+		if !matchPattern(pattern, key) {
+			continue
+		}
+		if !item.isExpired() {
+			deleted++
+		}
+		s.deleteItem(key)
+	}
+	s.stats.deletes.Add(deleted)
+	return deleted, nil
+}
+
+// CountPattern подсчитывает неистекшие записи, ключи которых соответствуют шаблону,
+// под блокировкой на чтение, не копируя ключи.
+func (s *memoryStorage[T]) CountPattern(ctx context.Context, pattern string) (int64, error) {
+	s.itemMu.RLock()         // Блокируем на чтение
+	defer s.itemMu.RUnlock() // Гарантируем разблокировку
+
+	var n int64
 	for key, item := range s.items {
-		if item.isExpired() {
-			delete(s.items, key) // Удаляем устаревший элемент
+		if !item.isExpired() && matchPattern(pattern, key) {
+			n++
 		}
 	}
+	return n, nil
+}
+
+// ScanPage возвращает страницу ключей, соответствующих шаблону.
+// Ключи сортируются, а курсор кодирует смещение в отсортированном списке,
+// поэтому ключи, добавленные между вызовами, могут сдвинуть страницы.
+func (s *memoryStorage[T]) ScanPage(ctx context.Context, cursor uint64, match string, count int) ([]string, uint64, error) {
+	if match == "" {
+		match = "*"
+	}
+	if count <= 0 {
+		count = s.opts.scanCount
+	}
+
+	s.itemMu.RLock() // Блокируем на чтение
+	keys := make([]string, 0, len(s.items))
+	for key, item := range s.items {
+		if !item.isExpired() && matchPattern(match, key) {
+			keys = append(keys, key)
+		}
+	}
+	s.itemMu.RUnlock()
+
+	sort.Strings(keys)
+	if cursor >= uint64(len(keys)) {
+		return nil, 0, nil
+	}
+
+	end := min(cursor+uint64(count), uint64(len(keys)))
+	page := keys[cursor:end]
+	if end == uint64(len(keys)) {
+		return page, 0, nil
+	}
+	return page, end, nil
+}
+
+// GetByPrefix обходит записи под блокировкой на чтение, пропуская истекшие.
+func (s *memoryStorage[T]) GetByPrefix(ctx context.Context, prefix string) (map[string]T, error) {
+	s.itemMu.RLock()         // Блокируем на чтение
+	defer s.itemMu.RUnlock() // Гарантируем разблокировку
+
+	values := make(map[string]T)
+	for key, item := range s.items {
+		if strings.HasPrefix(key, prefix) && !item.isExpired() {
+			values[key] = item.value
+		}
+	}
+	s.stats.hits.Add(int64(len(values)))
+	return values, nil
+}
+
+// Inspect возвращает Go-тип значения записи в качестве ее представления.
+// Время обращений не отслеживается, поэтому IdleTime всегда равно 0.
+func (s *memoryStorage[T]) Inspect(ctx context.Context, key string) (ObjectInfo, bool, error) {
+	key, err := s.opts.checkKey(key)
+	if err != nil {
+		return ObjectInfo{}, false, err
+	}
+
+	s.itemMu.RLock()         // Блокируем на чтение
+	defer s.itemMu.RUnlock() // Гарантируем разблокировку
+
+	item, found := s.items[key]
+	if !found || item.isExpired() {
+		return ObjectInfo{}, false, nil
+	}
+	return ObjectInfo{Encoding: fmt.Sprintf("%T", item.value), RefCount: 1}, true, nil
+}
+
+// TTL возвращает оставшееся время жизни записи (0 - бессрочная).
+func (s *memoryStorage[T]) TTL(ctx context.Context, key string) (time.Duration, bool, error) {
+	key, err := s.opts.checkKey(key)
+	if err != nil {
+		return 0, false, err
+	}
+
+	s.itemMu.RLock()         // Блокируем на чтение
+	defer s.itemMu.RUnlock() // Гарантируем разблокировку
+
+	item, found := s.items[key]
+	if !found || item.isExpired() {
+		return 0, false, nil
+	}
+	if item.expiration == 0 {
+		return 0, true, nil
+	}
+	return max(time.Until(time.Unix(0, item.expiration)), 1), true, nil
+}
+
+// QueueNames возвращает имена непустых очередей.
+func (s *memoryStorage[T]) QueueNames(ctx context.Context) ([]string, error) {
+	s.queueMu.RLock()         // Блокируем на чтение
+	defer s.queueMu.RUnlock() // Гарантируем разблокировку
+
+	names := make([]string, 0, len(s.queues))
+	for name, queue := range s.queues {
+		if queue.Len() > 0 {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// QueueCapacity возвращает длину очереди и емкость ее блоков.
+// Отложенные и зарезервированные элементы хранятся отдельно и не учитываются.
+func (s *memoryStorage[T]) QueueCapacity(queueName string) (int, int, error) {
+	s.queueMu.RLock()         // Блокируем на чтение
+	defer s.queueMu.RUnlock() // Гарантируем разблокировку
+
+	queue := s.queues[queueName]
+	return queue.Len(), queue.Cap(), nil
+}
+
+// Enqueue добавляет элемент в конец очереди.
+// Принимает имя очереди и значение для добавления.
+// Если очередь не существует, создает новую.
+func (s *memoryStorage[T]) Enqueue(ctx context.Context, queueName string, value T) error {
+	if err := s.opts.checkValueSize(value); err != nil {
+		return err
+	}
+
+	s.queueMu.Lock()         // Блокируем на запись
+	defer s.queueMu.Unlock() // Гарантируем разблокировку
+
+	tag, err := s.checkQueueTag(queueName, value)
+	if err != nil {
+		return err
+	}
+	if err := s.walPush(queueName, value); err != nil {
+		return err
+	}
+	s.setQueueTag(queueName, tag)
+	s.queue(queueName).PushBack(value)
+	s.rates.enqueued(queueName, 1)
+	return nil
+}
+
+// EnqueueN добавляет элемент в конец очереди и возвращает ее новую длину.
+// Если очередь не существует, создает новую.
+func (s *memoryStorage[T]) EnqueueN(ctx context.Context, queueName string, value T) (int64, error) {
+	if err := s.opts.checkValueSize(value); err != nil {
+		return 0, err
+	}
+
+	s.queueMu.Lock()         // Блокируем на запись
+	defer s.queueMu.Unlock() // Гарантируем разблокировку
+
+	tag, err := s.checkQueueTag(queueName, value)
+	if err != nil {
+		return 0, err
+	}
+	if err := s.walPush(queueName, value); err != nil {
+		return 0, err
+	}
+	s.setQueueTag(queueName, tag)
+	queue := s.queue(queueName)
+	queue.PushBack(value)
+	s.rates.enqueued(queueName, 1)
+	return int64(queue.Len()), nil
+}
+
+// EnqueueFanout добавляет элемент во все очереди под одной блокировкой,
+// поэтому другие горутины видят его сразу во всех очередях или ни в одной.
+func (s *memoryStorage[T]) EnqueueFanout(ctx context.Context, queueNames []string, value T) error {
+	if err := s.opts.checkValueSize(value); err != nil {
+		return err
+	}
+
+	s.queueMu.Lock()         // Блокируем на запись
+	defer s.queueMu.Unlock() // Гарантируем разблокировку
+
+	tags := make([]string, len(queueNames))
+	for i, queueName := range queueNames {
+		tag, err := s.checkQueueTag(queueName, value)
+		if err != nil {
+			return err // Элемент не добавляется ни в одну очередь
+		}
+		tags[i] = tag
+	}
+
+	data, err := s.walEncode(value)
+	if err != nil {
+		return err
+	}
+	if data != nil {
+		records := make([]walRecord, len(queueNames))
+		for i, queueName := range queueNames {
+			records[i] = walRecord{Op: walPush, Queue: queueName, V: data}
+		}
+		if err := s.walAppend(records...); err != nil {
+			return err
+		}
+	}
+	for i, queueName := range queueNames {
+		s.setQueueTag(queueName, tags[i])
+		s.queue(queueName).PushBack(value)
+		s.rates.enqueued(queueName, 1)
+	}
+	return nil
+}
+
+// EnqueueCapped добавляет элемент в конец очереди и отбрасывает самые старые элементы,
+// если длина очереди превысила maxLen.
+func (s *memoryStorage[T]) EnqueueCapped(ctx context.Context, queueName string, value T, maxLen int64) error {
+	if maxLen <= 0 {
+		return ErrInvalidMaxLen
+	}
+	if err := s.opts.checkValueSize(value); err != nil {
+		return err
+	}
+
+	s.queueMu.Lock()         // Блокируем на запись
+	defer s.queueMu.Unlock() // Гарантируем разблокировку
+
+	tag, err := s.checkQueueTag(queueName, value)
+	if err != nil {
+		return err
+	}
+	data, err := s.walEncode(value)
+	if err != nil {
+		return err
+	}
+	if data != nil {
+		records := []walRecord{{Op: walPush, Queue: queueName, V: data}}
+		if excess := int64(s.queues[queueName].Len()) + 1 - maxLen; excess > 0 {
+			records = append(records, walRecord{Op: walDrop, Queue: queueName, N: int(excess)})
+		}
+		if err := s.walAppend(records...); err != nil {
+			return err
+		}
+	}
+	s.setQueueTag(queueName, tag)
+	queue := s.queue(queueName)
+	queue.PushBack(value)
+	if int64(queue.Len()) > maxLen {
+		queue.DropFront(queue.Len() - int(maxLen)) // Отбрасываем начало очереди
+		s.consumed[queueName] = struct{}{}
+	}
+	s.rates.enqueued(queueName, 1)
+	return nil
+}
+
+// EnqueueBounded добавляет элемент в конец очереди, только если в ней меньше maxLen элементов.
+// Проверка и добавление выполняются под одной блокировкой.
+func (s *memoryStorage[T]) EnqueueBounded(ctx context.Context, queueName string, value T, maxLen int64) (bool, error) {
+	if maxLen <= 0 {
+		return false, ErrInvalidMaxLen
+	}
+	if err := s.opts.checkValueSize(value); err != nil {
+		return false, err
+	}
+
+	s.queueMu.Lock()         // Блокируем на запись
+	defer s.queueMu.Unlock() // Гарантируем разблокировку
+
+	if int64(s.queues[queueName].Len()) >= maxLen {
+		return false, nil // Очередь заполнена
+	}
+	tag, err := s.checkQueueTag(queueName, value)
+	if err != nil {
+		return false, err
+	}
+	if err := s.walPush(queueName, value); err != nil {
+		return false, err
+	}
+	s.setQueueTag(queueName, tag)
+	s.queue(queueName).PushBack(value)
+	s.rates.enqueued(queueName, 1)
+	return true, nil
+}
+
+// TryEnqueue добавляет элемент, как EnqueueBounded, и возвращает причину отказа.
+// После Close элементы не добавляются (ReasonClosed).
+func (s *memoryStorage[T]) TryEnqueue(ctx context.Context, queueName string, value T, maxLen int64) (bool, EnqueueReason, error) {
+	if s.closed() {
+		return false, ReasonClosed, nil
+	}
+	ok, err := s.EnqueueBounded(ctx, queueName, value, maxLen)
+	switch {
+	case err != nil:
+		return false, ReasonError, err
+	case !ok:
+		return false, ReasonFull, nil
+	default:
+		return true, ReasonOK, nil
+	}
+}
+
+// closed проверяет, было ли хранилище закрыто.
+func (s *memoryStorage[T]) closed() bool {
+	select {
+	case <-s.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// EnqueueDelayed добавляет элемент в список отложенных элементов очереди.
+// Элемент переносится в конец очереди, когда истекает delay: при обращении
+// к очереди (Dequeue, Peek, Remove, PeekBatchReserve) или сборщиком мусора.
+// Если delay не положительна, элемент добавляется сразу.
+func (s *memoryStorage[T]) EnqueueDelayed(ctx context.Context, queueName string, value T, delay time.Duration) error {
+	if delay <= 0 {
+		return s.Enqueue(ctx, queueName, value)
+	}
+	if err := s.opts.checkValueSize(value); err != nil {
+		return err
+	}
+
+	s.queueMu.Lock()         // Блокируем на запись
+	defer s.queueMu.Unlock() // Гарантируем разблокировку
+
+	tag, err := s.checkQueueTag(queueName, value)
+	if err != nil {
+		return err
+	}
+	ready := time.Now().Add(delay).UnixNano()
+	data, err := s.walEncode(value)
+	if err != nil {
+		return err
+	}
+	if data != nil {
+		if err := s.walAppend(walRecord{Op: walDelay, Queue: queueName, V: data, R: ready}); err != nil {
+			return err
+		}
+	}
+	s.setQueueTag(queueName, tag)
+	pending := s.delayed[queueName]
+	i := sort.Search(len(pending), func(i int) bool { return pending[i].ready > ready })
+	s.delayed[queueName] = slices.Insert(pending, i, delayedItem[T]{value: value, ready: ready})
+	s.rates.enqueued(queueName, 1)
+	return nil
+}
+
+// promoteDelayed переносит готовые отложенные элементы всех очередей.
+func (s *memoryStorage[T]) promoteDelayed() {
+	s.queueMu.Lock()         // Блокируем на запись
+	defer s.queueMu.Unlock() // Гарантируем разблокировку
+
+	now := time.Now().UnixNano()
+	for queueName := range s.delayed {
+		s.promoteQueueLocked(queueName, now)
+	}
+}
+
+// promoteDue переносит готовые отложенные элементы очереди, если они есть.
+// Блокировка на запись берется, только когда переносить есть что.
+func (s *memoryStorage[T]) promoteDue(queueName string) {
+	now := time.Now().UnixNano()
+
+	s.queueMu.RLock()
+	pending := s.delayed[queueName]
+	due := len(pending) > 0 && pending[0].ready <= now
+	s.queueMu.RUnlock()
+
+	if due {
+		s.queueMu.Lock()
+		s.promoteQueueLocked(queueName, now)
+		s.queueMu.Unlock()
+	}
+}
+
+// promoteQueueLocked переносит готовые отложенные элементы в конец очереди
+// в порядке времени готовности.
+// Должен вызываться под блокировкой queueMu на запись.
+func (s *memoryStorage[T]) promoteQueueLocked(queueName string, now int64) {
+	pending := s.delayed[queueName]
+	n := sort.Search(len(pending), func(i int) bool { return pending[i].ready > now })
+	if n == 0 {
+		return
+	}
+
+	s.walLog(walRecord{Op: walPromote, Queue: queueName, N: n})
+	queue := s.queue(queueName)
+	for _, d := range pending[:n] {
+		queue.PushBack(d.value)
+	}
+	if n == len(pending) {
+		delete(s.delayed, queueName)
+	} else {
+		s.delayed[queueName] = pending[n:]
+	}
+}
+
+// queue возвращает очередь с именем name, создавая ее при необходимости,
+// и будит ожидающих ее BlockingDequeueAny: очередь запрашивается для добавления.
+// Должен вызываться под блокировкой queueMu на запись.
+func (s *memoryStorage[T]) queue(name string) *deque[T] {
+	s.notify(name)
+	queue, ok := s.queues[name]
+	if !ok {
+		queue = &deque[T]{}
+		s.queues[name] = queue
+	}
+	return queue
+}
+
+// dropFront удаляет k первых элементов очереди, помечает ее разобранной
+// и удаляет опустевшую очередь из карты.
+// Должен вызываться под блокировкой queueMu на запись.
+func (s *memoryStorage[T]) dropFront(name string, k int) {
+	queue := s.queues[name]
+	if queue == nil {
+		return
+	}
+	s.consumed[name] = struct{}{}
+	s.walLog(walRecord{Op: walDrop, Queue: name, N: k})
+	queue.DropFront(k)
+	s.dropIfEmpty(name, queue)
+}
+
+// dropIfEmpty удаляет опустевшую очередь из карты вместе с ее тегом типа
+// (WithQueueTypeCheck): следующий Enqueue задаст тег заново.
+// Должен вызываться под блокировкой queueMu на запись.
+func (s *memoryStorage[T]) dropIfEmpty(name string, queue *deque[T]) {
+	if queue.Len() == 0 {
+		delete(s.queues, name)
+		delete(s.queueTags, name)
+	}
+}
+
+// checkQueueTag проверяет, что теги типов values (WithQueueTypeCheck) совпадают между собой
+// и с тегом очереди, и возвращает тег, который запоминает setQueueTag после записи
+// ("" - проверка выключена или значений нет). Используется всеми операциями записи в очередь.
+// Должен вызываться под блокировкой queueMu.
+func (s *memoryStorage[T]) checkQueueTag(name string, values ...T) (string, error) {
+	if !s.opts.queueTypeCheck || len(values) == 0 {
+		return "", nil
+	}
+	tag := s.opts.queueTag(values[0])
+	for _, value := range values[1:] {
+		if other := s.opts.queueTag(value); other != tag {
+			return "", typeMismatch(name, tag, other)
+		}
+	}
+	if have, ok := s.queueTags[name]; ok && have != tag {
+		return "", typeMismatch(name, have, tag)
+	}
+	return tag, nil
+}
+
+// setQueueTag запоминает тег очереди, возвращенный checkQueueTag.
+// Должен вызываться под блокировкой queueMu на запись.
+func (s *memoryStorage[T]) setQueueTag(name, tag string) {
+	if tag != "" {
+		s.queueTags[name] = tag
+	}
+}
+
+// checkDequeueTag проверяет, что тег очереди (WithQueueTypeCheck) совпадает с тегом,
+// который ожидает хранилище. Используется всеми операциями извлечения из очереди.
+// Должен вызываться под блокировкой queueMu.
+func (s *memoryStorage[T]) checkDequeueTag(name string) error {
+	if !s.opts.queueTypeCheck {
+		return nil
+	}
+	want, known := expectedQueueTag[T](&s.opts)
+	if have, ok := s.queueTags[name]; ok && known && have != want {
+		return typeMismatch(name, have, want)
+	}
+	return nil
+}
+
+// Dequeue извлекает и удаляет элемент из начала очереди.
+// Возвращает элемент, флаг наличия элемента и ошибку.
+// Если очередь пуста, возвращает false во втором возвращаемом значении.
+// Отложенные элементы, время которых еще не наступило, не возвращаются.
+func (s *memoryStorage[T]) Dequeue(ctx context.Context, queueName string) (T, bool, error) {
+	s.queueMu.Lock()         // Блокируем на запись
+	defer s.queueMu.Unlock() // Гарантируем разблокировку
+
+	s.promoteQueueLocked(queueName, time.Now().UnixNano())
+
+	value, found := s.queues[queueName].Front()
+	if !found {
+		return value, false, nil
+	}
+	if err := s.checkDequeueTag(queueName); err != nil {
+		var zero T
+		return zero, false, err
+	}
+
+	s.dropFront(queueName, 1)
+	s.rates.dequeued(queueName, 1)
+	return value, true, nil
+}
+
+// DequeueTracked извлекает элемент и записывает его по ключу под блокировками items
+// и queues, поэтому другие горутины видят элемент либо в очереди, либо по ключу.
+// Время жизни ключа не смещается опцией WithTTLJitter.
+func (s *memoryStorage[T]) DequeueTracked(ctx context.Context, queueName, trackingKey string, ttl time.Duration) (T, bool, error) {
+	var zero T
+	key, origKey, err := s.opts.resolveKey(trackingKey)
+	if err != nil {
+		return zero, false, err
+	}
+
+	s.itemMu.Lock()          // Блокируем items на запись
+	defer s.itemMu.Unlock()  // Гарантируем разблокировку
+	s.queueMu.Lock()         // Блокируем queues на запись
+	defer s.queueMu.Unlock() // Гарантируем разблокировку
+
+	now := time.Now()
+	s.promoteQueueLocked(queueName, now.UnixNano())
+
+	value, found := s.queues[queueName].Front()
+	if !found {
+		return zero, false, nil
+	}
+	if err := s.checkDequeueTag(queueName); err != nil {
+		return zero, false, err
+	}
+	s.dropFront(queueName, 1)
+	s.rates.dequeued(queueName, 1)
+
+	var expiration int64
+	if ttl > 0 {
+		expiration = now.Add(ttl).UnixNano()
+	}
+	s.items[key] = s.newItem(key, origKey, value, expiration)
+	s.track(key, value)
+	s.stats.sets.Add(1)
+	return value, true, nil
+}
+
+// BlockingDequeueAny извлекает первый элемент первой непустой очереди из queueNames,
+// а если все пусты - ждет добавления в любую из них. Ожидание прерывается по timeout,
+// отмене ctx или закрытию хранилища (found=false). Готовность отложенных элементов
+// ожидается по их времени, без опроса.
+func (s *memoryStorage[T]) BlockingDequeueAny(ctx context.Context, queueNames []string, timeout time.Duration) (string, T, bool, error) {
+	var zero T
+	if len(queueNames) == 0 {
+		return "", zero, false, nil
+	}
+
+	var deadline <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	// Регистрация до первой проверки: добавление между проверкой и ожиданием не теряется
+	wake := make(chan struct{}, 1)
+	s.queueMu.Lock()
+	for _, name := range queueNames {
+		if s.waiters[name] == nil {
+			s.waiters[name] = make(map[chan struct{}]struct{})
+		}
+		s.waiters[name][wake] = struct{}{}
+	}
+	s.queueMu.Unlock()
+	defer func() {
+		s.queueMu.Lock()
+		for _, name := range queueNames {
+			delete(s.waiters[name], wake)
+			if len(s.waiters[name]) == 0 {
+				delete(s.waiters, name)
+			}
+		}
+		s.queueMu.Unlock()
+	}()
+
+	for {
+		name, value, found, readyIn, err := s.dequeueAny(queueNames)
+		if err != nil || found {
+			return name, value, found, err
+		}
+
+		var ready <-chan time.Time
+		if readyIn > 0 {
+			ready = time.After(readyIn)
+		}
+		select {
+		case <-wake:
+		case <-ready:
+		case <-deadline:
+			return "", zero, false, nil
+		case <-ctx.Done():
+			return "", zero, false, ctx.Err()
+		case <-s.stop:
+			return "", zero, false, nil
+		}
+	}
+}
+
+// dequeueAny извлекает первый элемент первой непустой очереди из queueNames.
+// Если все пусты, возвращает время до готовности ближайшего отложенного элемента (0 - таких нет).
+// Если тег первой непустой очереди не совпадает с ожидаемым, возвращает ErrTypeMismatch.
+func (s *memoryStorage[T]) dequeueAny(queueNames []string) (string, T, bool, time.Duration, error) {
+	s.queueMu.Lock()         // Блокируем на запись
+	defer s.queueMu.Unlock() // Гарантируем разблокировку
+
+	var zero T
+	now := time.Now().UnixNano()
+	var next int64
+	for _, name := range queueNames {
+		s.promoteQueueLocked(name, now)
+		if value, found := s.queues[name].Front(); found {
+			if err := s.checkDequeueTag(name); err != nil {
+				return "", zero, false, 0, err
+			}
+			s.dropFront(name, 1)
+			s.rates.dequeued(name, 1)
+			return name, value, true, 0, nil
+		}
+		if pending := s.delayed[name]; len(pending) > 0 && (next == 0 || pending[0].ready < next) {
+			next = pending[0].ready
+		}
+	}
+
+	if next == 0 {
+		return "", zero, false, 0, nil
+	}
+	return "", zero, false, time.Duration(max(next-now, 1)), nil
+}
+
+// notify будит ожидающих добавления в очередь name.
+// Должен вызываться под блокировкой queueMu на запись.
+func (s *memoryStorage[T]) notify(name string) {
+	for wake := range s.waiters[name] {
+		select {
+		case wake <- struct{}{}:
+		default: // Ожидающий уже разбужен
+		}
+	}
+}
+
+// Peek возвращает первый элемент из очереди без его удаления.
+// Возвращает элемент, флаг наличия элемента и ошибку.
+// Если очередь пуста, возвращает false во втором возвращаемом значении.
+func (s *memoryStorage[T]) Peek(ctx context.Context, queueName string) (T, bool, error) {
+	s.promoteDue(queueName)
+
+	s.queueMu.RLock()         // Блокируем на чтение
+	defer s.queueMu.RUnlock() // Гарантируем разблокировку
+
+	value, found := s.queues[queueName].Front()
+	return value, found, nil
+}
+
+// Remove удаляет первый элемент из очереди без его возврата.
+// Возвращает флаг успешности операции и ошибку.
+// Если очередь пуста, возвращает false в первом возвращаемом значении.
+func (s *memoryStorage[T]) Remove(ctx context.Context, queueName string) (bool, error) {
+	s.queueMu.Lock()         // Блокируем на запись
+	defer s.queueMu.Unlock() // Гарантируем разблокировку
+
+	s.promoteQueueLocked(queueName, time.Now().UnixNano())
+
+	if s.queues[queueName].Len() == 0 {
+		return false, nil
+	}
+
+	s.dropFront(queueName, 1)
+	s.rates.dequeued(queueName, 1)
+	return true, nil
+}
+
+// RemoveAt удаляет элемент очереди с позицией index под блокировкой на запись.
+// Отрицательная позиция отсчитывается с конца очереди.
+func (s *memoryStorage[T]) RemoveAt(ctx context.Context, queueName string, index int64) (bool, error) {
+	s.queueMu.Lock()         // Блокируем на запись
+	defer s.queueMu.Unlock() // Гарантируем разблокировку
+
+	s.promoteQueueLocked(queueName, time.Now().UnixNano())
+
+	queue := s.queues[queueName]
+	if index < 0 {
+		index += int64(queue.Len())
+	}
+	if index < 0 || index >= int64(queue.Len()) {
+		return false, nil
+	}
+
+	s.walLog(walRecord{Op: walRemove, Queue: queueName, N: int(index)})
+	queue.RemoveAt(int(index))
+	s.consumed[queueName] = struct{}{}
+	s.dropIfEmpty(queueName, queue)
+	return true, nil
+}
+
+// QueueTail возвращает копию последних n элементов очереди (от старых к новым).
+// Очередь читается под блокировкой на чтение.
+func (s *memoryStorage[T]) QueueTail(ctx context.Context, queueName string, n int) ([]T, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+	s.promoteDue(queueName)
+
+	s.queueMu.RLock()         // Блокируем на чтение
+	defer s.queueMu.RUnlock() // Гарантируем разблокировку
+
+	return s.queues[queueName].Tail(n), nil
+}
+
+// ReplaceQueue заменяет очередь новой, собранной из values, под блокировкой на запись.
+// Пустой values удаляет очередь.
+func (s *memoryStorage[T]) ReplaceQueue(ctx context.Context, queueName string, values []T) error {
+	queue := &deque[T]{}
+	for _, value := range values {
+		if err := s.opts.checkValueSize(value); err != nil {
+			return err
+		}
+		queue.PushBack(value)
+	}
+
+	s.queueMu.Lock()         // Блокируем на запись
+	defer s.queueMu.Unlock() // Гарантируем разблокировку
+
+	tag, err := s.checkQueueTag(queueName, values...)
+	if err != nil {
+		return err
+	}
+	data, err := s.walEncode(values...)
+	if err != nil {
+		return err
+	}
+	if data != nil {
+		if err := s.walAppend(walRecord{Op: walReplace, Queue: queueName, V: data}); err != nil {
+			return err
+		}
+	}
+	s.consumed[queueName] = struct{}{}
+	if queue.Len() == 0 {
+		s.dropIfEmpty(queueName, queue)
+		return nil
+	}
+	s.setQueueTag(queueName, tag)
+	s.queues[queueName] = queue
+	s.notify(queueName)
+	return nil
+}
+
+// RotateQueue переносит первый элемент очереди в ее конец под блокировкой на запись.
+// Готовые отложенные элементы сначала переносятся в очередь, как в Dequeue.
+func (s *memoryStorage[T]) RotateQueue(ctx context.Context, queueName string) (T, bool, error) {
+	s.queueMu.Lock()         // Блокируем на запись
+	defer s.queueMu.Unlock() // Гарантируем разблокировку
+
+	s.promoteQueueLocked(queueName, time.Now().UnixNano())
+
+	queue := s.queues[queueName]
+	value, found := queue.Front()
+	if !found {
+		return value, false, nil
+	}
+
+	data, err := s.walEncode(value)
+	if err != nil {
+		var zero T
+		return zero, false, err
+	}
+	if data != nil {
+		// Обе записи пишутся одним вызовом: после падения элемент не теряется и не удваивается
+		err := s.walAppend(walRecord{Op: walDrop, Queue: queueName, N: 1}, walRecord{Op: walPush, Queue: queueName, V: data})
+		if err != nil {
+			var zero T
+			return zero, false, err
+		}
+	}
+
+	queue.PopFront()
+	queue.PushBack(value)
+	s.consumed[queueName] = struct{}{}
+	return value, true, nil
+}
+
+// SetHead заменяет первый элемент очереди под блокировкой на запись.
+// Готовые отложенные элементы сначала переносятся в очередь, как в Dequeue.
+func (s *memoryStorage[T]) SetHead(ctx context.Context, queueName string, value T) (T, bool, error) {
+	var zero T
+	if err := s.opts.checkValueSize(value); err != nil {
+		return zero, false, err
+	}
+
+	s.queueMu.Lock()         // Блокируем на запись
+	defer s.queueMu.Unlock() // Гарантируем разблокировку
+
+	s.promoteQueueLocked(queueName, time.Now().UnixNano())
+
+	queue := s.queues[queueName]
+	if queue.Len() == 0 {
+		return zero, false, nil
+	}
+
+	data, err := s.walEncode(value)
+	if err != nil {
+		return zero, false, err
+	}
+	if data != nil {
+		if err := s.walAppend(walRecord{Op: walHead, Queue: queueName, V: data}); err != nil {
+			return zero, false, err
+		}
+	}
+
+	old, _ := queue.SetFront(value)
+	return old, true, nil
+}
+
+// QueueReadFrom возвращает копию до n элементов очереди, начиная с позиции offset.
+// Очередь читается под блокировкой на чтение. Признак разобранной очереди
+// хранится в памяти процесса и переживает перезапуск только через WithQueueWAL.
+func (s *memoryStorage[T]) QueueReadFrom(ctx context.Context, queueName string, offset int64, n int) ([]T, int64, error) {
+	if offset < 0 {
+		return nil, offset, ErrInvalidOffset
+	}
+	if n <= 0 {
+		return nil, offset, nil
+	}
+	s.promoteDue(queueName)
+
+	s.queueMu.RLock()         // Блокируем на чтение
+	defer s.queueMu.RUnlock() // Гарантируем разблокировку
+
+	if _, ok := s.consumed[queueName]; ok {
+		return nil, offset, fmt.Errorf("%w: %q", ErrQueueConsumed, queueName)
+	}
+	queue := s.queues[queueName]
+	if offset >= int64(queue.Len()) {
+		return nil, offset, nil
+	}
+	items := queue.Range(int(offset), n)
+	return items, offset + int64(len(items)), nil
+}
+
+// QueueIterate копирует элементы очереди под блокировкой на чтение и передает копию fn
+// после снятия блокировки, поэтому fn может обращаться к хранилищу.
+// Отмена ctx проверяется перед каждым элементом.
+func (s *memoryStorage[T]) QueueIterate(ctx context.Context, queueName string, fn func(T) error) error {
+	s.promoteDue(queueName)
+
+	s.queueMu.RLock() // Блокируем на чтение
+	queue := s.queues[queueName]
+	items := queue.Range(0, queue.Len())
+	s.queueMu.RUnlock()
+
+	for _, item := range items {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// QueueLen возвращает текущую длину очереди.
+// Возвращает количество элементов в очереди и ошибку, если операция не удалась.
+// Если очередь не существует, возвращает 0.
+func (s *memoryStorage[T]) QueueLen(ctx context.Context, queueName string) (int64, error) {
+	s.queueMu.RLock()         // Блокируем на чтение
+	defer s.queueMu.RUnlock() // Гарантируем разблокировку
+
+	return int64(s.queues[queueName].Len()), nil
+}
+
+// QueueStats возвращает длину очереди и скорости ее пополнения и разбора.
+func (s *memoryStorage[T]) QueueStats(ctx context.Context, queueName string) (QueueStats, error) {
+	length, _ := s.QueueLen(ctx, queueName)
+	return s.rates.stats(queueName, length), nil
+}
+
+// QueueLenMany возвращает длины нескольких очередей.
+// Все очереди читаются за один захват блокировки, поэтому результат согласован.
+// Несуществующие очереди отображаются в 0.
+func (s *memoryStorage[T]) QueueLenMany(ctx context.Context, queueNames []string) (map[string]int64, error) {
+	s.queueMu.RLock()         // Блокируем на чтение
+	defer s.queueMu.RUnlock() // Гарантируем разблокировку
+
+	lengths := make(map[string]int64, len(queueNames))
+	for _, name := range queueNames {
+		lengths[name] = int64(s.queues[name].Len())
+	}
+	return lengths, nil
+}
+
+// QueueIndexOf возвращает позицию первого элемента очереди, удовлетворяющего условию.
+// Очередь просматривается под блокировкой на чтение.
+func (s *memoryStorage[T]) QueueIndexOf(ctx context.Context, queueName string, match func(T) bool) (int64, bool, error) {
+	s.queueMu.RLock()         // Блокируем на чтение
+	defer s.queueMu.RUnlock() // Гарантируем разблокировку
+
+	for i, value := range s.queues[queueName].All() {
+		if match(value) {
+			return int64(i), true, nil
+		}
+	}
+	return 0, false, nil
+}
+
+// QueueIndexOfValue возвращает позицию первого элемента очереди, равного value.
+// Элементы сравниваются через reflect.DeepEqual.
+func (s *memoryStorage[T]) QueueIndexOfValue(ctx context.Context, queueName string, value T) (int64, bool, error) {
+	return s.QueueIndexOf(ctx, queueName, func(v T) bool {
+		return reflect.DeepEqual(v, value)
+	})
+}
+
+// PeekBatchReserve переносит до n элементов из начала очереди в резервирование
+// со временем видимости vt. Истекшие резервирования очереди возвращаются в ее начало
+// перед каждым вызовом, а также сборщиком мусора.
+func (s *memoryStorage[T]) PeekBatchReserve(ctx context.Context, queueName string, n int, vt time.Duration) ([]T, string, error) {
+	if n <= 0 {
+		return nil, "", nil
+	}
+
+	s.queueMu.Lock()         // Блокируем на запись
+	defer s.queueMu.Unlock() // Гарантируем разблокировку
+
+	now := time.Now().UnixNano()
+	s.requeueQueueLocked(queueName, now)
+	s.promoteQueueLocked(queueName, now)
+
+	queue := s.queues[queueName]
+	if queue.Len() == 0 {
+		return nil, "", nil
+	}
+
+	token := newReservationToken()
+	s.walLog(walRecord{Op: walReserve, Queue: queueName, N: min(n, queue.Len()), T: []string{token}})
+	items := queue.PopFrontN(n)
+	s.consumed[queueName] = struct{}{}
+	s.dropIfEmpty(queueName, queue)
+	s.rates.dequeued(queueName, len(items))
+
+	if s.reservations[queueName] == nil {
+		s.reservations[queueName] = make(map[string]reservation[T])
+	}
+	s.reservations[queueName][token] = reservation[T]{items: items, deadline: now + vt.Nanoseconds()}
+
+	return slices.Clone(items), token, nil
+}
+
+// AckBatch удаляет резервирование вместе с его элементами.
+// Если резервирование истекло, его элементы возвращаются в очередь
+// и возвращается ErrReservationNotFound.
+func (s *memoryStorage[T]) AckBatch(ctx context.Context, queueName, token string) error {
+	s.queueMu.Lock()         // Блокируем на запись
+	defer s.queueMu.Unlock() // Гарантируем разблокировку
+
+	s.requeueQueueLocked(queueName, time.Now().UnixNano())
+
+	if _, ok := s.reservations[queueName][token]; !ok {
+		return ErrReservationNotFound
+	}
+	s.walLog(walRecord{Op: walAck, Queue: queueName, T: []string{token}})
+	delete(s.reservations[queueName], token)
+	if len(s.reservations[queueName]) == 0 {
+		delete(s.reservations, queueName)
+	}
+	return nil
+}
+
+// SAddEx добавляет элемент в множество с временем истечения ttl.
+// Элементы различаются по значению, сериализованному кодеком хранилища.
+func (s *memoryStorage[T]) SAddEx(ctx context.Context, setName string, member T, ttl time.Duration) error {
+	data, err := s.opts.codec.Marshal(member)
+	if err != nil {
+		return fmt.Errorf("marshal failed: %w", err)
+	}
+
+	var expiration int64
+	if ttl > 0 {
+		expiration = time.Now().Add(ttl).UnixNano()
+	}
+
+	s.setMu.Lock()         // Блокируем на запись
+	defer s.setMu.Unlock() // Гарантируем разблокировку
+
+	members, ok := s.sets[setName]
+	if !ok {
+		members = make(map[string]int64)
+		s.sets[setName] = members
+	}
+	members[string(data)] = expiration
+	return nil
+}
+
+// SCount удаляет истекшие элементы множества и возвращает количество оставшихся.
+func (s *memoryStorage[T]) SCount(ctx context.Context, setName string) (int64, error) {
+	s.setMu.Lock()         // Блокируем на запись: истекшие элементы удаляются
+	defer s.setMu.Unlock() // Гарантируем разблокировку
+
+	members, ok := s.sets[setName]
+	if !ok {
+		return 0, nil
+	}
+	n := sweepSet(members, time.Now().UnixNano())
+	if n == 0 {
+		delete(s.sets, setName)
+	}
+	return int64(n), nil
+}
+
+// IncrWindow увеличивает счетчик окна; закончившееся окно начинается заново.
+func (s *memoryStorage[T]) IncrWindow(ctx context.Context, key string, window time.Duration, limit int64) (int64, bool, error) {
+	if window <= 0 || limit <= 0 {
+		return 0, false, ErrInvalidRateLimit
+	}
+	key, err := s.opts.checkKey(key)
+	if err != nil {
+		return 0, false, err
+	}
+
+	s.windowMu.Lock()         // Блокируем на запись
+	defer s.windowMu.Unlock() // Гарантируем разблокировку
+
+	now := time.Now()
+	w, ok := s.windows[key]
+	if !ok || now.UnixNano() > w.expiration {
+		w = windowCounter{expiration: now.Add(window).UnixNano()}
+	}
+	w.count++
+	s.windows[key] = w
+	return w.count, w.count <= limit, nil
+}
+
+// sweepWindows удаляет счетчики закончившихся окон.
+func (s *memoryStorage[T]) sweepWindows() {
+	s.windowMu.Lock()         // Блокируем на запись
+	defer s.windowMu.Unlock() // Гарантируем разблокировку
+
+	sweepWindows(s.windows, time.Now().UnixNano())
+}
+
+// sweepSets удаляет истекшие элементы всех множеств и опустевшие множества.
+func (s *memoryStorage[T]) sweepSets() {
+	s.setMu.Lock()         // Блокируем на запись
+	defer s.setMu.Unlock() // Гарантируем разблокировку
+
+	now := time.Now().UnixNano()
+	for setName, members := range s.sets {
+		if sweepSet(members, now) == 0 {
+			delete(s.sets, setName)
+		}
+	}
+}
+
+// requeueExpired возвращает истекшие резервирования всех очередей.
+func (s *memoryStorage[T]) requeueExpired() {
+	s.queueMu.Lock()         // Блокируем на запись
+	defer s.queueMu.Unlock() // Гарантируем разблокировку
+
+	now := time.Now().UnixNano()
+	for queueName := range s.reservations {
+		s.requeueQueueLocked(queueName, now)
+	}
+}
+
+// requeueQueueLocked возвращает элементы истекших резервирований в начало очереди.
+// Раньше зарезервированные пачки оказываются ближе к началу.
+// Должен вызываться под блокировкой queueMu на запись.
+func (s *memoryStorage[T]) requeueQueueLocked(queueName string, now int64) {
+	var expired []string
+	for token, r := range s.reservations[queueName] {
+		if r.deadline <= now {
+			expired = append(expired, token)
+		}
+	}
+	if len(expired) == 0 {
+		return
+	}
+
+	reserved := s.reservations[queueName]
+	sort.Slice(expired, func(i, j int) bool { return reserved[expired[i]].deadline < reserved[expired[j]].deadline })
+	s.walLog(walRecord{Op: walRequeue, Queue: queueName, T: expired})
+	var head []T
+	for _, token := range expired {
+		head = append(head, reserved[token].items...)
+		delete(reserved, token)
+	}
+	if len(reserved) == 0 {
+		delete(s.reservations, queueName)
+	}
+	s.queue(queueName).PushFront(head...)
+}
+
+// PurgeExpired немедленно удаляет истекшие записи, не дожидаясь сборщика мусора.
+// Возвращает количество удаленных записей. При отмене ctx удаление прерывается
+// между порциями и возвращается количество уже удаленных записей вместе с ошибкой ctx.
+func (s *memoryStorage[T]) PurgeExpired(ctx context.Context) (int, error) {
+	return s.sweep(ctx)
+}
+
+// sweepExpired запускает проход сборщика мусора, ограниченный интервалом interval:
+// проход не накладывается на следующий, а оставшиеся записи удаляет он.
+func (s *memoryStorage[T]) sweepExpired(ctx context.Context, interval time.Duration) {
+	ctx, cancel := context.WithTimeout(ctx, interval)
+	defer cancel()
+	_, _ = s.sweep(ctx)
+}
+
+// sweep удаляет истекшие записи и сообщает о проходе хукам GCHook.
+func (s *memoryStorage[T]) sweep(ctx context.Context) (int, error) {
+	start := time.Now()
+	removed, err := s.deleteExpired(ctx)
+	observeGCSweep(s.opts.hooks, removed, time.Since(start))
+	return removed, err
+}
+
+// gcChunkSize - количество истечений, обрабатываемых deleteExpired за один захват блокировки.
+const gcChunkSize = 1024
+
+// deleteExpired удаляет элементы с истекшим сроком жизни из хранилища.
+// Истекшие ключи берутся из очереди истечений, поэтому обход всех записей не нужен,
+// а удаляются порциями по gcChunkSize: между порциями блокировка освобождается,
+// и ожидающие операции выполняются без долгой паузы. При отмене ctx удаление
+// останавливается между порциями; оставшиеся записи удалит следующий проход.
+// Вызывается периодически сборщиком мусора и из PurgeExpired.
+// Возвращает количество удаленных элементов.
+func (s *memoryStorage[T]) deleteExpired(ctx context.Context) (int, error) {
+	var removed int
+	for {
+		if err := ctx.Err(); err != nil {
+			return removed, err
+		}
+
+		s.itemMu.Lock()
+		now := time.Now().UnixNano()
+		for range gcChunkSize {
+			key, due := s.expiry.popDue(now)
+			if !due {
+				s.compactExpiry()
+				s.itemMu.Unlock()
+				return removed, nil
+			}
+			// Запись могла быть удалена или стать бессрочной после планирования
+			if item, found := s.items[key]; found && item.expiration > 0 && now > item.expiration {
+				s.deleteItem(key) // Удаляем устаревший элемент
+				s.stats.evictions.Add(1)
+				removed++
+			}
+		}
+		s.itemMu.Unlock()
+	}
+}
+
+// compactExpiry пересобирает очередь истечений, если вхождений перезаписанных
+// и удаленных ключей в ней накопилось больше, чем записей (например, при частом
+// продлении ключей с долгим временем жизни). Пересборка обходит все записи,
+// но выполняется не чаще, чем очередь вырастает вдвое.
+// Должен вызываться под блокировкой itemMu на запись.
+func (s *memoryStorage[T]) compactExpiry() {
+	if len(s.expiry) <= 2*len(s.items)+gcChunkSize {
+		return
+	}
+	queue := make(expiryQueue, 0, len(s.items))
+	for key, item := range s.items {
+		if item.expiration > 0 {
+			queue = append(queue, expiryEntry{key: key, expiration: item.expiration})
+		}
+	}
+	heap.Init(&queue)
+	s.expiry = queue
+}
+
+// Stats возвращает текущие значения счетчиков операций.
+// Счетчики читаются атомарно, размеры хранилища - под блокировками на чтение.
+func (s *memoryStorage[T]) Stats() MemoryStats {
+	s.itemMu.RLock()
+	items := int64(len(s.items))
+	s.itemMu.RUnlock()
+
+	s.queueMu.RLock()
+	queues := int64(len(s.queues))
+	s.queueMu.RUnlock()
+
+	return MemoryStats{
+		Hits:      s.stats.hits.Load(),
+		Misses:    s.stats.misses.Load(),
+		Sets:      s.stats.sets.Load(),
+		Deletes:   s.stats.deletes.Load(),
+		Evictions: s.stats.evictions.Load(),
+		Items:     items,
+		Queues:    queues,
+
+		PressureEvictions: s.stats.pressureEvictions.Load(),
+		Bytes:             s.lru.size(),
+	}
+}
+
+// Dump возвращает содержимое хранилища в читаемом виде: неистекшие ключи
+// с оставшимся временем жизни и элементы очередей от головы к хвосту.
+// Ключи и очереди отсортированы по имени. Выполняется под блокировками на чтение.
+func (s *memoryStorage[T]) Dump() string {
+	var b strings.Builder
+	now := time.Now().UnixNano()
+
+	s.itemMu.RLock()
+	keys := make([]string, 0, len(s.items))
+	for key, item := range s.items {
+		if !item.isExpired() {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	fmt.Fprintf(&b, "keys (%d):\n", len(keys))
+	for _, key := range keys {
+		item := s.items[key]
+		ttl := "no ttl"
+		if item.expiration > 0 {
+			ttl = "ttl " + time.Duration(item.expiration-now).Round(time.Millisecond).String()
+		}
+		fmt.Fprintf(&b, "  %q = %+v (%s)\n", key, item.value, ttl)
+	}
+	s.itemMu.RUnlock()
+
+	s.queueMu.RLock()
+	names := make([]string, 0, len(s.queues))
+	for name := range s.queues {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	fmt.Fprintf(&b, "queues (%d):\n", len(names))
+	for _, name := range names {
+		q := s.queues[name]
+		fmt.Fprintf(&b, "  %q [%d]: %+v\n", name, q.Len(), q.Range(0, q.Len()))
+	}
+	s.queueMu.RUnlock()
+
+	return b.String()
+}
+
+// Transact выполняет fn под блокировкой items на запись на все время транзакции,
+// поэтому транзакции и остальные операции с ключами выполняются строго по очереди.
+// Записи применяются, только если fn вернула nil.
+// Внутри fn нельзя вызывать методы хранилища: их блокировка уже захвачена.
+func (s *memoryStorage[T]) Transact(ctx context.Context, fn func(tx Tx[T]) error) error {
+	s.itemMu.Lock()         // Блокируем на запись
+	defer s.itemMu.Unlock() // Гарантируем разблокировку
+
+	tx := &memoryTx[T]{s: s}
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, key := range tx.writes.keys {
+		write := tx.writes.writes[key]
+		if write.deleted {
+			s.deleteItem(key)
+			s.stats.deletes.Add(1)
+			continue
+		}
+		var expiration int64
+		switch {
+		case write.keepTTL:
+			if item, ok := s.items[key]; ok && !item.isExpired() {
+				expiration = item.expiration
+			}
+		case write.ttl > 0:
+			expiration = now.Add(write.ttl).UnixNano()
+		}
+		s.items[key] = s.newItem(key, write.origKey, write.value, expiration)
+		s.track(key, write.value)
+		s.stats.sets.Add(1)
+	}
+	return nil
+}
+
+// memoryTx - транзакция in-memory хранилища.
+// Выполняется под блокировкой itemMu, захваченной Transact.
+type memoryTx[T any] struct {
+	s      *memoryStorage[T]
+	writes txWrites[T]
+}
+
+// Get получает значение из буфера транзакции или из хранилища.
+func (tx *memoryTx[T]) Get(key string) (T, bool, error) {
+	var zero T
+
+	key, err := tx.s.opts.checkKey(key)
+	if err != nil {
+		return zero, false, err
+	}
+	if value, written, found := tx.writes.get(key); written {
+		return value, found, nil
+	}
+
+	item, found := tx.s.items[key]
+	if !found || item.isExpired() {
+		return zero, false, nil
+	}
+	return item.value, true, nil
+}
+
+// Set добавляет запись в буфер транзакции.
+func (tx *memoryTx[T]) Set(key string, value T, ttl time.Duration) error {
+	key, origKey, err := tx.s.opts.resolveKey(key)
+	if err != nil {
+		return err
+	}
+	if err := tx.s.opts.checkValueSize(value); err != nil {
+		return err
+	}
+	tx.writes.put(key, txWrite[T]{value: value, ttl: tx.s.opts.jitterTTL(ttl), origKey: origKey})
+	return nil
+}
+
+// Delete добавляет удаление в буфер транзакции.
+func (tx *memoryTx[T]) Delete(key string) error {
+	key, err := tx.s.opts.checkKey(key)
+	if err != nil {
+		return err
+	}
+	tx.writes.put(key, txWrite[T]{deleted: true})
+	return nil
+}
+
+// setKeepTTL добавляет в буфер транзакции запись, сохраняющую время жизни ключа.
+func (tx *memoryTx[T]) setKeepTTL(key string, value T) error {
+	key, origKey, err := tx.s.opts.resolveKey(key)
+	if err != nil {
+		return err
+	}
+	if err := tx.s.opts.checkValueSize(value); err != nil {
+		return err
+	}
+	tx.writes.putKeepTTL(key, origKey, value)
+	return nil
+}
+
+// Increment увеличивает числовое значение и добавляет результат в буфер транзакции
+// с сохранением времени жизни ключа.
+func (tx *memoryTx[T]) Increment(key string, delta int64) (int64, error) {
+	current, _, err := tx.Get(key)
+	if err != nil {
+		return 0, err
+	}
+	next, n, err := addInt(current, delta)
+	if err != nil {
+		return 0, err
+	}
+	return n, tx.setKeepTTL(key, next)
+}
+
+// Pipeline создает пакет команд для in-memory хранилища.
+func (s *memoryStorage[T]) Pipeline() Pipeline[T] {
+	return &memoryPipeline[T]{s: s}
+}
+
+// memoryPipeline выполняет пакет команд под одним захватом блокировок.
+type memoryPipeline[T any] struct {
+	pipelineOps[T]
+	s *memoryStorage[T]
+}
+
+// Exec выполняет накопленные команды под блокировками items и queues.
+// Другие горутины видят либо состояние до пакета, либо после него.
+// Команды с ключами, отклоненными валидатором, не выполняются и получают ошибку в результате.
+func (p *memoryPipeline[T]) Exec(ctx context.Context) ([]PipelineResult[T], error) {
+	ops := p.take()
+	results := make([]PipelineResult[T], len(ops))
+
+	s := p.s
+	s.itemMu.Lock()          // Блокируем items на запись
+	defer s.itemMu.Unlock()  // Гарантируем разблокировку
+	s.queueMu.Lock()         // Блокируем queues на запись
+	defer s.queueMu.Unlock() // Гарантируем разблокировку
+
+	now := time.Now()
+	for i, op := range ops {
+		var origKey string
+		if op.kind != pipelineEnqueue {
+			key, orig, err := s.opts.resolveKey(op.key)
+			if err != nil {
+				results[i].Err = err
+				continue
+			}
+			op.key, origKey = key, orig
+		}
+		if op.kind == pipelineSet || op.kind == pipelineEnqueue {
+			if err := s.opts.checkValueSize(op.value); err != nil {
+				results[i].Err = err
+				continue
+			}
+		}
+
+		switch op.kind {
+		case pipelineSet:
+			var expiration int64
+			if ttl := s.opts.jitterTTL(op.ttl); ttl > 0 {
+				expiration = now.Add(ttl).UnixNano()
+			}
+			s.items[op.key] = s.newItem(op.key, origKey, op.value, expiration)
+			s.track(op.key, op.value)
+			s.stats.sets.Add(1)
+		case pipelineGet:
+			it, found := s.items[op.key]
+			if !found || it.isExpired() {
+				s.stats.misses.Add(1)
+				continue
+			}
+			s.stats.hits.Add(1)
+			results[i] = PipelineResult[T]{Value: it.value, Found: true}
+			if it.expiration != 0 {
+				results[i].TTL = max(time.Until(time.Unix(0, it.expiration)), 1)
+			}
+		case pipelineDelete:
+			s.deleteItem(op.key)
+			s.stats.deletes.Add(1)
+		case pipelineEnqueue:
+			tag, err := s.checkQueueTag(op.key, op.value)
+			if err != nil {
+				results[i].Err = err
+				continue
+			}
+			if err := s.walPush(op.key, op.value); err != nil {
+				results[i].Err = err
+				continue
+			}
+			s.setQueueTag(op.key, tag)
+			s.queue(op.key).PushBack(op.value)
+			s.rates.enqueued(op.key, 1)
+		}
+	}
+	return results, firstErr(results)
+}
+
+// HotKeys возвращает до n самых часто используемых ключей.
+// Если отслеживание не включено опцией WithHotKeyTracking, возвращает nil.
+func (s *memoryStorage[T]) HotKeys(n int) []KeyCount {
+	return s.hot.top(n)
 }
@@ -2,7 +2,13 @@ package storage_test
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -81,3 +87,2140 @@ func TestMemoryStorage_ConcurrentAccess(t *testing.T) {
 
 	wg.Wait()
 }
+
+func TestMemoryStorage_QueueLenMany(t *testing.T) {
+	s, _ := storage.NewMemory[string](50 * time.Millisecond)
+	defer s.Close()
+	ctx := context.Background()
+
+	require.NoError(t, s.Enqueue(ctx, "q1", "a"))
+	require.NoError(t, s.Enqueue(ctx, "q1", "b"))
+	require.NoError(t, s.Enqueue(ctx, "q2", "c"))
+
+	lengths, err := s.QueueLenMany(ctx, []string{"q1", "q2", "missing"})
+	require.NoError(t, err)
+	require.Equal(t, map[string]int64{"q1": 2, "q2": 1, "missing": 0}, lengths)
+}
+
+func TestMemoryStorage_Stats(t *testing.T) {
+	s, _ := storage.NewMemory[string](10 * time.Millisecond)
+	defer s.Close()
+	ctx := context.Background()
+
+	sp, ok := s.(storage.MemoryStatsProvider)
+	require.True(t, ok)
+
+	require.NoError(t, s.Set(ctx, "a", "1", 0))
+	require.NoError(t, s.Set(ctx, "temp", "2", 5*time.Millisecond))
+	require.NoError(t, s.Enqueue(ctx, "q", "x"))
+
+	_, _, _ = s.Get(ctx, "a")
+	_, _, _ = s.Get(ctx, "missing")
+	require.NoError(t, s.Delete(ctx, "a"))
+
+	time.Sleep(50 * time.Millisecond)
+
+	stats := sp.Stats()
+	require.Equal(t, int64(1), stats.Hits)
+	require.Equal(t, int64(1), stats.Misses)
+	require.Equal(t, int64(2), stats.Sets)
+	require.Equal(t, int64(1), stats.Deletes)
+	require.Equal(t, int64(1), stats.Evictions)
+	require.Equal(t, int64(0), stats.Items)
+	require.Equal(t, int64(1), stats.Queues)
+}
+
+func TestMemoryStorage_EnqueueN(t *testing.T) {
+	s, _ := storage.NewMemory[string](50 * time.Millisecond)
+	defer s.Close()
+	ctx := context.Background()
+
+	n, err := s.EnqueueN(ctx, "q", "a")
+	require.NoError(t, err)
+	require.Equal(t, int64(1), n)
+
+	n, err = s.EnqueueN(ctx, "q", "b")
+	require.NoError(t, err)
+	require.Equal(t, int64(2), n)
+}
+
+func TestMemoryStorage_EnqueueFanout(t *testing.T) {
+	s, _ := storage.NewMemory[string](time.Hour)
+	defer s.Close()
+	ctx := context.Background()
+
+	require.NoError(t, s.Enqueue(ctx, "fan:b", "old"))
+	require.NoError(t, s.EnqueueFanout(ctx, []string{"fan:a", "fan:b", "fan:c"}, "event"))
+
+	lengths, err := s.QueueLenMany(ctx, []string{"fan:a", "fan:b", "fan:c"})
+	require.NoError(t, err)
+	require.Equal(t, map[string]int64{"fan:a": 1, "fan:b": 2, "fan:c": 1}, lengths)
+
+	tail, _ := s.QueueTail(ctx, "fan:b", 1)
+	require.Equal(t, []string{"event"}, tail)
+}
+
+func TestMemoryStorage_DequeueTracked(t *testing.T) {
+	s, _ := storage.NewMemory[string](time.Hour)
+	defer s.Close()
+	ctx := context.Background()
+
+	_, found, err := s.DequeueTracked(ctx, "jobs", "processing:jobs", time.Hour)
+	require.NoError(t, err)
+	require.False(t, found)
+	_, found, _ = s.Get(ctx, "processing:jobs")
+	require.False(t, found, "пустая очередь не записывает ключ")
+
+	require.NoError(t, s.Enqueue(ctx, "jobs", "job-1"))
+	val, found, err := s.DequeueTracked(ctx, "jobs", "processing:jobs", 20*time.Millisecond)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "job-1", val)
+
+	tracked, found, _ := s.Get(ctx, "processing:jobs")
+	require.True(t, found)
+	require.Equal(t, "job-1", tracked)
+	n, _ := s.QueueLen(ctx, "jobs")
+	require.Zero(t, n)
+
+	time.Sleep(30 * time.Millisecond)
+	_, found, _ = s.Get(ctx, "processing:jobs")
+	require.False(t, found)
+}
+
+func TestMemoryStorage_QueueCapacity(t *testing.T) {
+	s, _ := storage.NewMemory[int](time.Hour)
+	defer s.Close()
+	ctx := context.Background()
+
+	reporter := s.(storage.QueueCapacityReporter)
+	for i := range 1000 {
+		require.NoError(t, s.Enqueue(ctx, "cap", i))
+	}
+	length, capacity, err := reporter.QueueCapacity("cap")
+	require.NoError(t, err)
+	require.Equal(t, 1000, length)
+	require.GreaterOrEqual(t, capacity, length)
+
+	for range 900 {
+		_, _, _ = s.Dequeue(ctx, "cap")
+	}
+	length, shrunk, _ := reporter.QueueCapacity("cap")
+	require.Equal(t, 100, length)
+	require.Less(t, shrunk, capacity)
+
+	length, capacity, _ = reporter.QueueCapacity("missing")
+	require.Zero(t, length)
+	require.Zero(t, capacity)
+}
+
+func TestMemoryStorage_Pipeline(t *testing.T) {
+	s, _ := storage.NewMemory[string](50 * time.Millisecond)
+	defer s.Close()
+	ctx := context.Background()
+
+	require.NoError(t, s.Set(ctx, "old", "value", 0))
+
+	pipe := s.Pipeline()
+	pipe.Set("a", "1", 0)
+	pipe.Get("a")
+	pipe.Get("missing")
+	pipe.Delete("old")
+	pipe.Enqueue("q", "item")
+
+	results, err := pipe.Exec(ctx)
+	require.NoError(t, err)
+	require.Len(t, results, 5)
+	require.True(t, results[1].Found)
+	require.Equal(t, "1", results[1].Value)
+	require.False(t, results[2].Found)
+
+	_, found, _ := s.Get(ctx, "old")
+	require.False(t, found)
+
+	length, _ := s.QueueLen(ctx, "q")
+	require.Equal(t, int64(1), length)
+
+	// Пакет очищается после выполнения
+	results, err = pipe.Exec(ctx)
+	require.NoError(t, err)
+	require.Empty(t, results)
+}
+
+func TestMemoryStorage_GetDelete(t *testing.T) {
+	s, _ := storage.NewMemory[string](50 * time.Millisecond)
+	defer s.Close()
+	ctx := context.Background()
+
+	require.NoError(t, s.Set(ctx, "token", "secret", 0))
+
+	var wg sync.WaitGroup
+	var consumed atomic.Int32
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, found, err := s.GetDelete(ctx, "token")
+			require.NoError(t, err)
+			if found {
+				consumed.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	require.Equal(t, int32(1), consumed.Load())
+
+	_, found, err := s.GetDelete(ctx, "token")
+	require.NoError(t, err)
+	require.False(t, found)
+}
+
+func TestMemoryStorage_DeletePattern(t *testing.T) {
+	s, _ := storage.NewMemory[string](50 * time.Millisecond)
+	defer s.Close()
+	ctx := context.Background()
+
+	require.NoError(t, s.Set(ctx, "tenant:1:a", "x", 0))
+	require.NoError(t, s.Set(ctx, "tenant:1:b", "x", 0))
+	require.NoError(t, s.Set(ctx, "tenant:2:a", "x", 0))
+	require.NoError(t, s.Enqueue(ctx, "tenant:1:q", "x"))
+
+	deleted, err := s.DeletePattern(ctx, "tenant:1:*")
+	require.NoError(t, err)
+	require.Equal(t, int64(2), deleted)
+
+	_, found, _ := s.Get(ctx, "tenant:1:a")
+	require.False(t, found)
+	_, found, _ = s.Get(ctx, "tenant:2:a")
+	require.True(t, found)
+
+	length, _ := s.QueueLen(ctx, "tenant:1:q")
+	require.Equal(t, int64(1), length)
+}
+
+func TestMemoryStorage_HotKeys(t *testing.T) {
+	s, _ := storage.NewMemory[string](50*time.Millisecond, storage.WithHotKeyTracking(4))
+	defer s.Close()
+	ctx := context.Background()
+
+	hr, ok := s.(storage.HotKeyReporter)
+	require.True(t, ok)
+
+	for i := range 30 {
+		_, _, _ = s.Get(ctx, "hot")
+		if i%2 == 0 {
+			_, _, _ = s.Get(ctx, "warm")
+		}
+		if i%3 == 0 {
+			_, _, _ = s.Get(ctx, fmt.Sprintf("cold:%d", i))
+		}
+	}
+
+	top := hr.HotKeys(2)
+	require.Len(t, top, 2)
+	require.Equal(t, "hot", top[0].Key)
+	require.GreaterOrEqual(t, top[0].Count, int64(30))
+}
+
+func TestMemoryStorage_HotKeysDisabled(t *testing.T) {
+	s, _ := storage.NewMemory[string](50 * time.Millisecond)
+	defer s.Close()
+
+	_, _, _ = s.Get(context.Background(), "key")
+	require.Nil(t, s.(storage.HotKeyReporter).HotKeys(10))
+}
+
+func TestMemoryStorage_ScanPage(t *testing.T) {
+	s, _ := storage.NewMemory[int](50 * time.Millisecond)
+	defer s.Close()
+	ctx := context.Background()
+
+	for i := range 25 {
+		require.NoError(t, s.Set(ctx, fmt.Sprintf("page:%02d", i), i, 0))
+	}
+	require.NoError(t, s.Set(ctx, "other", 0, 0))
+
+	var all []string
+	var cursor uint64
+	pages := 0
+	for {
+		keys, next, err := s.ScanPage(ctx, cursor, "page:*", 10)
+		require.NoError(t, err)
+		all = append(all, keys...)
+		pages++
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+
+	require.Equal(t, 3, pages)
+	require.Len(t, all, 25)
+	require.Equal(t, "page:00", all[0])
+	require.Equal(t, "page:24", all[24])
+}
+
+func TestMemoryStorage_ScanCount(t *testing.T) {
+	s, _ := storage.NewMemory[int](time.Hour, storage.WithScanCount(4))
+	defer s.Close()
+	ctx := context.Background()
+
+	for i := range 10 {
+		require.NoError(t, s.Set(ctx, fmt.Sprintf("page:%02d", i), i, 0))
+	}
+
+	// Без явного count размер страницы задает опция
+	keys, next, err := s.ScanPage(ctx, 0, "page:*", 0)
+	require.NoError(t, err)
+	require.Equal(t, []string{"page:00", "page:01", "page:02", "page:03"}, keys)
+	require.NotZero(t, next)
+
+	keys, _, err = s.ScanPage(ctx, 0, "page:*", 10)
+	require.NoError(t, err)
+	require.Len(t, keys, 10)
+}
+
+func TestMemoryStorage_GetWithTTL(t *testing.T) {
+	s, _ := storage.NewMemory[string](time.Hour)
+	defer s.Close()
+	ctx := context.Background()
+
+	require.NoError(t, s.Set(ctx, "session", "data", time.Minute))
+	val, ttl, found, err := s.GetWithTTL(ctx, "session")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "data", val)
+	require.Greater(t, ttl, 59*time.Second)
+	require.LessOrEqual(t, ttl, time.Minute)
+
+	require.NoError(t, s.Set(ctx, "forever", "x", 0))
+	_, ttl, found, _ = s.GetWithTTL(ctx, "forever")
+	require.True(t, found)
+	require.Zero(t, ttl)
+
+	_, ttl, found, err = s.GetWithTTL(ctx, "missing")
+	require.NoError(t, err)
+	require.False(t, found)
+	require.Zero(t, ttl)
+}
+
+func TestMemoryStorage_GetEx(t *testing.T) {
+	s, _ := storage.NewMemory[string](10 * time.Millisecond)
+	defer s.Close()
+	ctx := context.Background()
+
+	require.NoError(t, s.Set(ctx, "session", "data", 30*time.Millisecond))
+
+	// Продлеваем время жизни при чтении
+	val, found, err := s.GetEx(ctx, "session", time.Second)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "data", val)
+
+	time.Sleep(50 * time.Millisecond)
+	_, found, _ = s.Get(ctx, "session")
+	require.True(t, found)
+
+	// Снимаем ограничение времени жизни
+	require.NoError(t, s.Set(ctx, "temp", "data", 30*time.Millisecond))
+	_, found, _ = s.GetEx(ctx, "temp", storage.PersistTTL)
+	require.True(t, found)
+	time.Sleep(50 * time.Millisecond)
+	_, found, _ = s.Get(ctx, "temp")
+	require.True(t, found)
+
+	// Нулевой ttl не меняет время жизни
+	require.NoError(t, s.Set(ctx, "short", "data", 30*time.Millisecond))
+	_, found, _ = s.GetEx(ctx, "short", 0)
+	require.True(t, found)
+	time.Sleep(50 * time.Millisecond)
+	_, found, _ = s.Get(ctx, "short")
+	require.False(t, found)
+}
+
+func TestMemoryStorage_GetWithMeta(t *testing.T) {
+	s, _ := storage.NewMemory[string](50*time.Millisecond, storage.WithMetadata(true))
+	defer s.Close()
+	ctx := context.Background()
+
+	require.NoError(t, s.Set(ctx, "audit", "v1", 0))
+	_, first, found, err := s.GetWithMeta(ctx, "audit")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.False(t, first.CreatedAt.IsZero())
+	require.Equal(t, first.CreatedAt, first.UpdatedAt)
+
+	time.Sleep(2 * time.Millisecond)
+	require.NoError(t, s.Set(ctx, "audit", "v2", 0))
+
+	val, second, found, err := s.GetWithMeta(ctx, "audit")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "v2", val)
+	require.Equal(t, first.CreatedAt, second.CreatedAt)
+	require.True(t, second.UpdatedAt.After(first.UpdatedAt))
+}
+
+func TestMemoryStorage_GetWithMetaDisabled(t *testing.T) {
+	s, _ := storage.NewMemory[string](50 * time.Millisecond)
+	defer s.Close()
+	ctx := context.Background()
+
+	require.NoError(t, s.Set(ctx, "plain", "v", 0))
+	val, meta, found, err := s.GetWithMeta(ctx, "plain")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "v", val)
+	require.Equal(t, storage.Meta{}, meta)
+}
+
+func TestMemoryStorage_QueueIndexOf(t *testing.T) {
+	s, _ := storage.NewMemory[string](50 * time.Millisecond)
+	defer s.Close()
+	ctx := context.Background()
+
+	for _, job := range []string{"job-1", "job-2", "job-3"} {
+		require.NoError(t, s.Enqueue(ctx, "waiting", job))
+	}
+
+	idx, found, err := s.QueueIndexOf(ctx, "waiting", func(v string) bool { return v == "job-3" })
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, int64(2), idx)
+
+	idx, found, err = s.QueueIndexOfValue(ctx, "waiting", "job-2")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, int64(1), idx)
+
+	_, found, err = s.QueueIndexOfValue(ctx, "waiting", "job-9")
+	require.NoError(t, err)
+	require.False(t, found)
+}
+
+func TestMemoryStorage_Capabilities(t *testing.T) {
+	s, _ := storage.NewMemory[string](50 * time.Millisecond)
+	defer s.Close()
+
+	caps := s.Capabilities()
+	require.True(t, caps.Has(storage.CapTTL|storage.CapQueues|storage.CapStats))
+	require.False(t, caps.Has(storage.CapPersistence))
+	require.False(t, caps.Has(storage.CapHotKeys))
+
+	tracked, _ := storage.NewMemory[string](50*time.Millisecond, storage.WithHotKeyTracking(10), storage.WithMetadata(true))
+	defer tracked.Close()
+	require.True(t, tracked.Capabilities().Has(storage.CapHotKeys|storage.CapMetadata))
+
+	layered := storage.NewLayered(tracked, s, 0)
+	require.False(t, layered.Capabilities().Has(storage.CapHotKeys))
+	require.Equal(t, "ttl|queues|pipeline|pattern-delete|scan|transactions", layered.Capabilities().String())
+}
+
+func TestMemoryStorage_PeekBatchReserve(t *testing.T) {
+	s, _ := storage.NewMemory[int](time.Hour)
+	defer s.Close()
+	ctx := context.Background()
+
+	for i := 1; i <= 5; i++ {
+		require.NoError(t, s.Enqueue(ctx, "jobs", i))
+	}
+
+	items, token, err := s.PeekBatchReserve(ctx, "jobs", 3, time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, []int{1, 2, 3}, items)
+	require.NotEmpty(t, token)
+
+	// Зарезервированные элементы не видны другим потребителям
+	length, _ := s.QueueLen(ctx, "jobs")
+	require.Equal(t, int64(2), length)
+
+	require.NoError(t, s.AckBatch(ctx, "jobs", token))
+	require.ErrorIs(t, s.AckBatch(ctx, "jobs", token), storage.ErrReservationNotFound)
+
+	length, _ = s.QueueLen(ctx, "jobs")
+	require.Equal(t, int64(2), length)
+}
+
+func TestMemoryStorage_PeekBatchReserveRedelivery(t *testing.T) {
+	s, _ := storage.NewMemory[int](time.Hour)
+	defer s.Close()
+	ctx := context.Background()
+
+	for i := 1; i <= 3; i++ {
+		require.NoError(t, s.Enqueue(ctx, "jobs", i))
+	}
+
+	_, token, err := s.PeekBatchReserve(ctx, "jobs", 2, 20*time.Millisecond)
+	require.NoError(t, err)
+
+	time.Sleep(30 * time.Millisecond)
+
+	// Неподтвержденная пачка возвращается в начало очереди в исходном порядке
+	items, _, err := s.PeekBatchReserve(ctx, "jobs", 10, time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, []int{1, 2, 3}, items)
+	require.ErrorIs(t, s.AckBatch(ctx, "jobs", token), storage.ErrReservationNotFound)
+}
+
+func TestMemoryStorage_EnqueueDelayed(t *testing.T) {
+	s, _ := storage.NewMemory[string](time.Hour)
+	defer s.Close()
+	ctx := context.Background()
+
+	require.NoError(t, s.EnqueueDelayed(ctx, "jobs", "later", 30*time.Millisecond))
+	require.NoError(t, s.EnqueueDelayed(ctx, "jobs", "soon", 10*time.Millisecond))
+	require.NoError(t, s.Enqueue(ctx, "jobs", "now"))
+
+	value, found, err := s.Dequeue(ctx, "jobs")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "now", value)
+
+	// Задержка еще не истекла
+	_, found, _ = s.Dequeue(ctx, "jobs")
+	require.False(t, found)
+
+	time.Sleep(40 * time.Millisecond)
+
+	value, found, _ = s.Peek(ctx, "jobs")
+	require.True(t, found)
+	require.Equal(t, "soon", value)
+
+	value, _, _ = s.Dequeue(ctx, "jobs")
+	require.Equal(t, "soon", value)
+	value, _, _ = s.Dequeue(ctx, "jobs")
+	require.Equal(t, "later", value)
+}
+
+func TestMemoryStorage_KeyValidator(t *testing.T) {
+	noSpaces := func(key string) error {
+		if strings.ContainsAny(key, " \t\n") {
+			return errors.New("key contains whitespace")
+		}
+		return nil
+	}
+	s, _ := storage.NewMemory[string](time.Hour,
+		storage.WithKeyValidator(noSpaces), storage.WithKeyNormalizer(strings.ToLower))
+	defer s.Close()
+	ctx := context.Background()
+
+	err := s.Set(ctx, "bad key", "value", 0)
+	require.ErrorIs(t, err, storage.ErrInvalidKey)
+	require.Contains(t, err.Error(), "key contains whitespace")
+
+	_, _, err = s.Get(ctx, "bad key")
+	require.ErrorIs(t, err, storage.ErrInvalidKey)
+
+	// Нормализованные ключи совпадают
+	require.NoError(t, s.Set(ctx, "User:1", "alice", 0))
+	value, found, err := s.Get(ctx, "user:1")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "alice", value)
+
+	pipe := s.Pipeline()
+	pipe.Get("USER:1")
+	pipe.Set("bad key", "value", 0)
+	results, err := pipe.Exec(ctx)
+	require.ErrorIs(t, err, storage.ErrInvalidKey)
+	require.Equal(t, "alice", results[0].Value)
+	require.ErrorIs(t, results[1].Err, storage.ErrInvalidKey)
+}
+
+func TestMemoryStorage_QueueTail(t *testing.T) {
+	s, _ := storage.NewMemory[int](time.Hour)
+	defer s.Close()
+	ctx := context.Background()
+
+	for i := 1; i <= 5; i++ {
+		require.NoError(t, s.Enqueue(ctx, "events", i))
+	}
+
+	tail, err := s.QueueTail(ctx, "events", 3)
+	require.NoError(t, err)
+	require.Equal(t, []int{3, 4, 5}, tail)
+
+	tail, err = s.QueueTail(ctx, "events", 10)
+	require.NoError(t, err)
+	require.Equal(t, []int{1, 2, 3, 4, 5}, tail)
+
+	tail, err = s.QueueTail(ctx, "missing", 3)
+	require.NoError(t, err)
+	require.Empty(t, tail)
+}
+
+func TestMemoryStorage_NewMemoryWithContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s, err := storage.NewMemoryWithContext[string](ctx, 10*time.Millisecond)
+	require.NoError(t, err)
+
+	require.NoError(t, s.Set(ctx, "key", "value", 0))
+	cancel()
+	time.Sleep(20 * time.Millisecond)
+
+	// После отмены контекста Close не обязателен, но безопасен (в том числе повторно)
+	require.NoError(t, s.Close())
+	require.ErrorIs(t, s.Close(), storage.ErrClosed)
+	require.ErrorIs(t, s.Close(), storage.ErrClosed, "ошибка повторного вызова не меняется")
+}
+
+func TestMemoryStorage_EnqueueCapped(t *testing.T) {
+	s, _ := storage.NewMemory[int](time.Hour)
+	defer s.Close()
+	ctx := context.Background()
+
+	for i := 1; i <= 5; i++ {
+		require.NoError(t, s.EnqueueCapped(ctx, "recent", i, 3))
+	}
+
+	tail, err := s.QueueTail(ctx, "recent", 10)
+	require.NoError(t, err)
+	require.Equal(t, []int{3, 4, 5}, tail)
+
+	require.ErrorIs(t, s.EnqueueCapped(ctx, "recent", 6, 0), storage.ErrInvalidMaxLen)
+}
+
+func TestMemoryStorage_Transact(t *testing.T) {
+	s, _ := storage.NewMemory[int](time.Hour)
+	defer s.Close()
+	ctx := context.Background()
+
+	require.NoError(t, s.Set(ctx, "alice", 100, 0))
+	require.NoError(t, s.Set(ctx, "bob", 10, 0))
+
+	transfer := func(amount int64) error {
+		return s.Transact(ctx, func(tx storage.Tx[int]) error {
+			balance, err := tx.Increment("alice", -amount)
+			if err != nil {
+				return err
+			}
+			if balance < 0 {
+				return errors.New("insufficient funds")
+			}
+			_, err = tx.Increment("bob", amount)
+			return err
+		})
+	}
+
+	require.NoError(t, transfer(30))
+	alice, _, _ := s.Get(ctx, "alice")
+	bob, _, _ := s.Get(ctx, "bob")
+	require.Equal(t, 70, alice)
+	require.Equal(t, 40, bob)
+
+	// Ошибка функции транзакции отменяет все записи
+	require.EqualError(t, transfer(500), "insufficient funds")
+	alice, _, _ = s.Get(ctx, "alice")
+	bob, _, _ = s.Get(ctx, "bob")
+	require.Equal(t, 70, alice)
+	require.Equal(t, 40, bob)
+
+	// Increment сохраняет время жизни ключа, как и в Redis
+	require.NoError(t, s.Set(ctx, "counter", 1, time.Minute))
+	require.NoError(t, s.Transact(ctx, func(tx storage.Tx[int]) error {
+		_, err := tx.Increment("counter", 1)
+		return err
+	}))
+	value, ttl, found, err := s.GetWithTTL(ctx, "counter")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, 2, value)
+	require.InDelta(t, time.Minute, ttl, float64(time.Second))
+
+	// Время жизни записи, сделанной в той же транзакции, тоже сохраняется
+	require.NoError(t, s.Transact(ctx, func(tx storage.Tx[int]) error {
+		if err := tx.Set("fresh", 1, time.Hour); err != nil {
+			return err
+		}
+		_, err := tx.Increment("fresh", 1)
+		return err
+	}))
+	_, ttl, _, _ = s.GetWithTTL(ctx, "fresh")
+	require.InDelta(t, time.Hour, ttl, float64(time.Second))
+}
+
+func TestMemoryStorage_TransactReadYourWrites(t *testing.T) {
+	s, _ := storage.NewMemory[string](time.Hour)
+	defer s.Close()
+	ctx := context.Background()
+
+	require.NoError(t, s.Set(ctx, "a", "old", 0))
+	err := s.Transact(ctx, func(tx storage.Tx[string]) error {
+		require.NoError(t, tx.Set("a", "new", 0))
+		value, found, err := tx.Get("a")
+		require.NoError(t, err)
+		require.True(t, found)
+		require.Equal(t, "new", value)
+
+		require.NoError(t, tx.Delete("a"))
+		_, found, _ = tx.Get("a")
+		require.False(t, found)
+
+		_, err = tx.Increment("a", 1)
+		require.ErrorIs(t, err, storage.ErrWrongType)
+		return nil
+	})
+	require.NoError(t, err)
+
+	_, found, _ := s.Get(ctx, "a")
+	require.False(t, found)
+}
+
+func TestMemoryStorage_Snapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.snapshot")
+	ctx := context.Background()
+
+	s, err := storage.NewMemory[string](time.Hour, storage.WithSnapshot(path, 0))
+	require.NoError(t, err)
+	require.NoError(t, s.Set(ctx, "name", "alice", 0))
+	require.NoError(t, s.Set(ctx, "short", "lived", time.Millisecond))
+	require.NoError(t, s.Enqueue(ctx, "jobs", "job-1"))
+	require.NoError(t, s.Enqueue(ctx, "jobs", "job-2"))
+	_, _, err = s.PeekBatchReserve(ctx, "jobs", 1, time.Minute)
+	require.NoError(t, err)
+	require.NoError(t, s.Close()) // Записывает последний снимок
+
+	time.Sleep(5 * time.Millisecond)
+
+	restored, err := storage.NewMemory[string](time.Hour, storage.WithSnapshot(path, 0))
+	require.NoError(t, err)
+	defer restored.Close()
+
+	value, found, err := restored.Get(ctx, "name")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "alice", value)
+
+	_, found, _ = restored.Get(ctx, "short")
+	require.False(t, found)
+
+	// Зарезервированный элемент возвращается в начало очереди
+	tail, err := restored.QueueTail(ctx, "jobs", 10)
+	require.NoError(t, err)
+	require.Equal(t, []string{"job-1", "job-2"}, tail)
+}
+
+func TestMemoryStorage_QueueWAL(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queues.wal")
+	ctx := context.Background()
+
+	s, err := storage.NewMemory[string](time.Hour, storage.WithQueueWAL(path))
+	require.NoError(t, err)
+	for _, job := range []string{"job-1", "job-2", "job-3", "job-4"} {
+		require.NoError(t, s.Enqueue(ctx, "jobs", job))
+	}
+	_, _, _ = s.Dequeue(ctx, "jobs")
+	_, _ = s.RemoveAt(ctx, "jobs", -1)
+	_, _, err = s.PeekBatchReserve(ctx, "jobs", 1, time.Minute)
+	require.NoError(t, err)
+	require.NoError(t, s.EnqueueDelayed(ctx, "jobs", "later", 300*time.Millisecond))
+	require.NoError(t, s.Enqueue(ctx, "mail", "hello"))
+	require.NoError(t, s.Enqueue(ctx, "mail", "world"))
+	_, _, err = s.RotateQueue(ctx, "mail")
+	require.NoError(t, err)
+	_, _, err = s.SetHead(ctx, "mail", "hi")
+	require.NoError(t, err)
+
+	// Имитируем падение: хранилище не закрывается, последняя запись журнала оборвана
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"op":"push","q":"mail","v":[`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	restored, err := storage.NewMemory[string](time.Hour, storage.WithQueueWAL(path))
+	require.NoError(t, err)
+
+	// Зарезервированный элемент возвращается в начало очереди
+	tail, err := restored.QueueTail(ctx, "jobs", 10)
+	require.NoError(t, err)
+	require.Equal(t, []string{"job-2", "job-3"}, tail)
+	tail, _ = restored.QueueTail(ctx, "mail", 10)
+	require.Equal(t, []string{"hi", "hello"}, tail)
+
+	// Отложенный элемент восстанавливается отложенным
+	require.Eventually(t, func() bool {
+		tail, _ = restored.QueueTail(ctx, "jobs", 10)
+		return len(tail) == 3
+	}, time.Second, 10*time.Millisecond)
+	require.Equal(t, []string{"job-2", "job-3", "later"}, tail)
+
+	_, _, _ = restored.Dequeue(ctx, "jobs")
+	require.NoError(t, restored.Close())
+	require.NoError(t, s.Close())
+
+	reopened, err := storage.NewMemory[string](time.Hour, storage.WithQueueWAL(path))
+	require.NoError(t, err)
+	defer reopened.Close()
+	tail, _ = reopened.QueueTail(ctx, "jobs", 10)
+	require.Equal(t, []string{"job-3", "later"}, tail)
+}
+
+func TestMemoryStorage_QueueWALCompaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queues.wal")
+	ctx := context.Background()
+
+	s, err := storage.NewMemory[int](10*time.Millisecond, storage.WithQueueWAL(path))
+	require.NoError(t, err)
+	defer s.Close()
+
+	require.NoError(t, s.Enqueue(ctx, "jobs", 42))
+	churn := func() {
+		for i := range 2000 {
+			require.NoError(t, s.Enqueue(ctx, "churn", i))
+			_, _, _ = s.Dequeue(ctx, "churn")
+		}
+	}
+	churn()
+
+	// Сборщик мусора удаляет из журнала извлеченные элементы. Сжатие может
+	// случиться посреди churn (например, под -race), и остатка записей
+	// не хватит для следующего сжатия, поэтому журнал пополняется снова
+	require.Eventually(t, func() bool {
+		info, err := os.Stat(path)
+		if err == nil && info.Size() < 100 {
+			return true
+		}
+		churn()
+		return false
+	}, 5*time.Second, 50*time.Millisecond)
+
+	restored, err := storage.NewMemory[int](time.Hour, storage.WithQueueWAL(path))
+	require.NoError(t, err)
+	defer restored.Close()
+	tail, _ := restored.QueueTail(ctx, "jobs", 10)
+	require.Equal(t, []int{42}, tail)
+
+	// Сжатый журнал сохраняет признак разобранной очереди
+	_, _, err = restored.QueueReadFrom(ctx, "churn", 0, 10)
+	require.ErrorIs(t, err, storage.ErrQueueConsumed)
+	items, _, err := restored.QueueReadFrom(ctx, "jobs", 0, 10)
+	require.NoError(t, err)
+	require.Equal(t, []int{42}, items)
+}
+
+func TestMemoryStorage_SnapshotPeriodic(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.snapshot")
+	ctx := context.Background()
+
+	s, err := storage.NewMemory[int](time.Hour, storage.WithSnapshot(path, 10*time.Millisecond))
+	require.NoError(t, err)
+	defer s.Close()
+	require.NoError(t, s.Set(ctx, "counter", 42, 0))
+
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(path)
+		return err == nil
+	}, time.Second, 5*time.Millisecond)
+
+	// Поврежденный снимок - ошибка создания хранилища, а не молчаливая потеря данных
+	broken := filepath.Join(t.TempDir(), "broken.snapshot")
+	require.NoError(t, os.WriteFile(broken, []byte("not json"), 0o600))
+	_, err = storage.NewMemory[int](time.Hour, storage.WithSnapshot(broken, 0))
+	require.Error(t, err)
+}
+
+func TestMemoryStorage_SnapshotCloseWaitsForPeriodic(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.snapshot")
+	ctx := context.Background()
+
+	// Периодическая запись идет почти непрерывно и не должна заменить итоговый снимок Close
+	for i := range 20 {
+		s, err := storage.NewMemory[int](time.Hour, storage.WithSnapshot(path, time.Microsecond))
+		require.NoError(t, err)
+		for j := range 200 {
+			require.NoError(t, s.Set(ctx, "counter", i*1000+j, 0))
+		}
+		require.NoError(t, s.Close())
+
+		restored, err := storage.NewMemory[int](time.Hour, storage.WithSnapshot(path, 0))
+		require.NoError(t, err)
+		value, _, _ := restored.Get(ctx, "counter")
+		require.Equal(t, i*1000+199, value)
+		require.NoError(t, restored.Close())
+	}
+}
+
+func TestMemoryStorage_InvalidInterval(t *testing.T) {
+	for _, interval := range []time.Duration{0, -time.Second} {
+		s, err := storage.NewMemory[string](interval)
+		require.ErrorIs(t, err, storage.ErrInvalidInterval)
+		require.Nil(t, s)
+	}
+
+	_, err := storage.NewMemoryWithContext[string](context.Background(), 0)
+	require.ErrorIs(t, err, storage.ErrInvalidInterval)
+}
+
+func TestMemoryStorage_EnqueueBounded(t *testing.T) {
+	s, _ := storage.NewMemory[int](time.Hour)
+	defer s.Close()
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	var accepted atomic.Int64
+	for i := range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ok, err := s.EnqueueBounded(ctx, "bounded", i, 10)
+			require.NoError(t, err)
+			if ok {
+				accepted.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	require.Equal(t, int64(10), accepted.Load())
+	length, _ := s.QueueLen(ctx, "bounded")
+	require.Equal(t, int64(10), length)
+}
+
+func TestMemoryStorage_HashLongKeys(t *testing.T) {
+	s, _ := storage.NewMemory[string](time.Hour, storage.WithHashLongKeys(100), storage.WithMetadata(true))
+	defer s.Close()
+	ctx := context.Background()
+
+	long := strings.Repeat("k", 200)
+	require.NoError(t, s.Set(ctx, long, "value", 0))
+	require.NoError(t, s.Set(ctx, "short", "plain", 0))
+
+	val, meta, found, err := s.GetWithMeta(ctx, long)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "value", val)
+	require.Equal(t, long, meta.Key)
+
+	_, meta, _, _ = s.GetWithMeta(ctx, "short")
+	require.Empty(t, meta.Key)
+
+	keys, _, err := s.ScanPage(ctx, 0, "*", 10)
+	require.NoError(t, err)
+	require.Len(t, keys, 2)
+	for _, key := range keys {
+		require.LessOrEqual(t, len(key), 100)
+	}
+	require.Contains(t, keys, "short")
+
+	require.NoError(t, s.Delete(ctx, long))
+	_, found, _ = s.Get(ctx, long)
+	require.False(t, found)
+}
+
+func TestMemoryStorage_QueueStats(t *testing.T) {
+	s, _ := storage.NewMemory[int](time.Hour)
+	defer s.Close()
+	ctx := context.Background()
+
+	stats, err := s.QueueStats(ctx, "jobs")
+	require.NoError(t, err)
+	require.Equal(t, storage.QueueStats{Window: time.Minute}, stats)
+
+	for i := range 12 {
+		require.NoError(t, s.Enqueue(ctx, "jobs", i))
+	}
+	_, _, _ = s.Dequeue(ctx, "jobs")
+	_, _ = s.Remove(ctx, "jobs")
+	_, _, _ = s.PeekBatchReserve(ctx, "jobs", 4, time.Minute)
+
+	stats, err = s.QueueStats(ctx, "jobs")
+	require.NoError(t, err)
+	require.Equal(t, int64(6), stats.Length)
+	require.InDelta(t, 12.0/60, stats.EnqueueRate, 1e-9)
+	require.InDelta(t, 6.0/60, stats.DequeueRate, 1e-9)
+}
+
+func TestMemoryStorage_TTLJitter(t *testing.T) {
+	s, _ := storage.NewMemory[int](time.Hour, storage.WithTTLJitter(10*time.Second))
+	defer s.Close()
+	ctx := context.Background()
+
+	distinct := make(map[time.Duration]bool)
+	for i := range 50 {
+		key := fmt.Sprint("k", i)
+		require.NoError(t, s.Set(ctx, key, i, time.Minute))
+		ttl, found, err := s.(storage.TTLReader).TTL(ctx, key)
+		require.NoError(t, err)
+		require.True(t, found)
+		require.GreaterOrEqual(t, ttl, 49*time.Second)
+		require.LessOrEqual(t, ttl, 70*time.Second)
+		distinct[ttl.Round(time.Millisecond)] = true
+	}
+	require.Greater(t, len(distinct), 1)
+
+	require.NoError(t, s.Set(ctx, "forever", 1, 0))
+	ttl, _, _ := s.(storage.TTLReader).TTL(ctx, "forever")
+	require.Zero(t, ttl)
+}
+
+func TestGetManyOrDefault(t *testing.T) {
+	s, _ := storage.NewMemory[string](time.Hour)
+	defer s.Close()
+	ctx := context.Background()
+
+	require.NoError(t, s.Set(ctx, "feature.a", "on", 0))
+	require.NoError(t, s.Set(ctx, "feature.b", "off", 0))
+
+	values, err := storage.GetManyOrDefault(ctx, s, []string{"feature.a", "feature.b", "feature.c", "feature.a"}, "default")
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"feature.a": "on", "feature.b": "off", "feature.c": "default"}, values)
+
+	values, err = storage.GetManyOrDefault(ctx, s, nil, "default")
+	require.NoError(t, err)
+	require.Empty(t, values)
+}
+
+func TestAppend(t *testing.T) {
+	s, _ := storage.NewMemory[[]int](time.Hour)
+	defer s.Close()
+	ctx := context.Background()
+
+	n, err := storage.Append(ctx, s, "list", 1, 2)
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+	before, _, _ := s.Get(ctx, "list")
+
+	// Параллельные добавления не теряются
+	var wg sync.WaitGroup
+	for i := range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := storage.Append(ctx, s, "list", i)
+			require.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+	value, _, _ := s.Get(ctx, "list")
+	require.Len(t, value, 52)
+	require.Equal(t, []int{1, 2}, before) // Прочитанный ранее срез не изменяется
+
+	// Время жизни существующей записи сохраняется
+	require.NoError(t, s.Set(ctx, "temp", []int{1}, time.Minute))
+	n, err = storage.Append(ctx, s, "temp", 2, 3)
+	require.NoError(t, err)
+	require.Equal(t, 3, n)
+	ttl, _, _ := s.(storage.TTLReader).TTL(ctx, "temp")
+	require.Greater(t, ttl, 59*time.Second)
+
+	n, err = storage.Append(ctx, s, "missing")
+	require.NoError(t, err)
+	require.Zero(t, n)
+	_, found, _ := s.Get(ctx, "missing")
+	require.False(t, found)
+
+	// Хранилища без собственной реализации добавляют через Transact
+	l1, _ := storage.NewMemory[[]int](time.Hour)
+	layered := storage.NewLayered(l1, s, 0)
+	defer layered.Close()
+	n, err = storage.Append(ctx, layered, "temp", 4)
+	require.NoError(t, err)
+	require.Equal(t, 4, n)
+	value, _, _ = layered.Get(ctx, "temp")
+	require.Equal(t, []int{1, 2, 3, 4}, value)
+}
+
+func TestWarm(t *testing.T) {
+	s, _ := storage.NewMemory[int](time.Hour)
+	defer s.Close()
+	ctx := context.Background()
+
+	entries := func(yield func(key string, value int) bool) {
+		for i := range 1200 {
+			if !yield(fmt.Sprintf("user:%d", i), i) {
+				return
+			}
+		}
+	}
+	require.NoError(t, storage.Warm(ctx, s, entries, time.Minute))
+	for _, i := range []int{0, 499, 500, 1199} {
+		value, found, err := s.Get(ctx, fmt.Sprintf("user:%d", i))
+		require.NoError(t, err)
+		require.True(t, found)
+		require.Equal(t, i, value)
+	}
+
+	// Отмена прерывает перебор
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	produced := 0
+	err := storage.Warm(cancelled, s, func(yield func(key string, value int) bool) {
+		for i := 0; yield(fmt.Sprintf("more:%d", i), i); i++ {
+			produced++
+		}
+	}, 0)
+	require.ErrorIs(t, err, context.Canceled)
+	require.Less(t, produced, 1000)
+}
+
+func TestDequeueWithResult(t *testing.T) {
+	s, _ := storage.NewMemory[int](time.Hour)
+	defer s.Close()
+	ctx := context.Background()
+
+	r := storage.DequeueWithResult(ctx, s, "jobs")
+	require.True(t, r.Empty)
+	require.NoError(t, r.Err)
+
+	require.NoError(t, s.Enqueue(ctx, "jobs", 7))
+	r = storage.DequeueWithResult(ctx, s, "jobs")
+	require.False(t, r.Empty)
+	require.NoError(t, r.Err)
+	require.Equal(t, 7, r.Value)
+
+	// Ошибка хранилища не выдается за пустую очередь
+	r = storage.DequeueWithResult[int](ctx, failingDequeue[int]{s}, "jobs")
+	require.False(t, r.Empty)
+	require.ErrorIs(t, r.Err, errBackendDown)
+}
+
+// failingDequeue возвращает errBackendDown из Dequeue.
+type failingDequeue[T any] struct {
+	storage.Storage[T]
+}
+
+func (failingDequeue[T]) Dequeue(ctx context.Context, queueName string) (T, bool, error) {
+	var zero T
+	return zero, false, errBackendDown
+}
+
+func TestMemoryStorage_QueueTypeCheck(t *testing.T) {
+	s, _ := storage.NewMemory[any](time.Hour, storage.WithQueueTypeCheck(""))
+	defer s.Close()
+	ctx := context.Background()
+
+	require.NoError(t, s.Enqueue(ctx, "events", 1))
+	require.NoError(t, s.Enqueue(ctx, "events", 2))
+	err := s.Enqueue(ctx, "events", "click")
+	require.ErrorIs(t, err, storage.ErrTypeMismatch)
+	require.ErrorContains(t, err, "holds int, got string")
+
+	_, _, _ = s.Dequeue(ctx, "events")
+	value, found, err := s.Dequeue(ctx, "events")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, 2, value)
+
+	// Опустевшая очередь теряет тег
+	require.NoError(t, s.Enqueue(ctx, "events", "click"))
+
+	// Тег теряют и очереди, опустошенные RemoveAt и ReplaceQueue
+	removed, err := s.RemoveAt(ctx, "events", 0)
+	require.NoError(t, err)
+	require.True(t, removed)
+	require.NoError(t, s.Enqueue(ctx, "events", 3))
+	require.NoError(t, s.ReplaceQueue(ctx, "events", nil))
+	require.NoError(t, s.Enqueue(ctx, "events", "click"))
+
+	// Остальные операции записи проверяют тот же тег
+	_, err = s.EnqueueN(ctx, "events", 4)
+	require.ErrorIs(t, err, storage.ErrTypeMismatch)
+	require.ErrorIs(t, s.EnqueueCapped(ctx, "events", 4, 10), storage.ErrTypeMismatch)
+	require.ErrorIs(t, s.EnqueueFanout(ctx, []string{"other", "events"}, 4), storage.ErrTypeMismatch)
+	require.ErrorIs(t, s.ReplaceQueue(ctx, "events", []any{"a", 4}), storage.ErrTypeMismatch)
+	n, err := s.QueueLen(ctx, "events")
+	require.NoError(t, err)
+	require.Equal(t, int64(1), n)
+	n, err = s.QueueLen(ctx, "other")
+	require.NoError(t, err)
+	require.Zero(t, n, "EnqueueFanout не пишет ни в одну очередь при несовпадении")
+
+	n, err = s.EnqueueN(ctx, "events", "scroll")
+	require.NoError(t, err)
+	require.Equal(t, int64(2), n)
+	require.NoError(t, s.EnqueueCapped(ctx, "events", "hover", 10))
+}
+
+func TestMemoryStorage_MaxBytes(t *testing.T) {
+	// Ключ "kN" (2 байта) и JSON-строка из 8 символов с кавычками (10 байт)
+	s, _ := storage.NewMemory[string](time.Hour, storage.WithMaxBytes(40))
+	defer s.Close()
+	ctx := context.Background()
+
+	for _, key := range []string{"k1", "k2", "k3"} {
+		require.NoError(t, s.Set(ctx, key, "12345678", 0))
+	}
+	_, _, _ = s.Get(ctx, "k1") // k2 становится давно не использованным
+
+	require.NoError(t, s.Set(ctx, "k4", "12345678", 0))
+	_, found, _ := s.Get(ctx, "k2")
+	require.False(t, found, "вытесняется давно не использованная запись")
+	for _, key := range []string{"k1", "k3", "k4"} {
+		_, found, _ := s.Get(ctx, key)
+		require.True(t, found, key)
+	}
+
+	stats := s.(storage.MemoryStatsProvider).Stats()
+	require.Equal(t, int64(1), stats.PressureEvictions)
+	require.Equal(t, int64(36), stats.Bytes)
+
+	// Запись больше бюджета вытесняет остальные, но сохраняется сама
+	require.NoError(t, s.Set(ctx, "big", strings.Repeat("x", 100), 0))
+	_, found, _ = s.Get(ctx, "big")
+	require.True(t, found)
+	require.Equal(t, int64(1), s.(storage.MemoryStatsProvider).Stats().Items)
+
+	require.NoError(t, s.Delete(ctx, "big"))
+	require.Zero(t, s.(storage.MemoryStatsProvider).Stats().Bytes)
+}
+
+func TestMemoryStorage_SetX(t *testing.T) {
+	s, _ := storage.NewMemory[int](time.Hour, storage.WithTTLJitter(10*time.Second))
+	defer s.Close()
+	ctx := context.Background()
+
+	before := time.Now()
+	expiresAt, err := s.SetX(ctx, "k", 1, time.Minute)
+	require.NoError(t, err)
+	require.WithinRange(t, expiresAt, before.Add(50*time.Second), time.Now().Add(70*time.Second))
+
+	// Возвращенное время совпадает с TTL, который видит хранилище
+	ttl, _, _ := s.(storage.TTLReader).TTL(ctx, "k")
+	require.InDelta(t, time.Until(expiresAt).Seconds(), ttl.Seconds(), 0.1)
+
+	expiresAt, err = s.SetX(ctx, "forever", 1, 0)
+	require.NoError(t, err)
+	require.True(t, expiresAt.IsZero())
+}
+
+// recordingHook запоминает итоговую статистику и вызовы Flush.
+type recordingHook struct {
+	stats   storage.MemoryStats
+	flushes int
+	err     error
+}
+
+func (h *recordingHook) FinalStats(stats storage.MemoryStats) { h.stats = stats }
+
+func (h *recordingHook) Flush(ctx context.Context) error {
+	h.flushes++
+	return h.err
+}
+
+func TestMemoryStorage_CloseFlushesHooks(t *testing.T) {
+	hook := &recordingHook{}
+	failing := &recordingHook{err: errors.New("exporter down")}
+	s, _ := storage.NewMemory[int](time.Hour, storage.WithHook(hook), storage.WithHook(failing))
+	ctx := context.Background()
+
+	require.NoError(t, s.Set(ctx, "a", 1, 0))
+	_, _, _ = s.Get(ctx, "a")
+
+	err := s.Close()
+	require.ErrorIs(t, err, failing.err)
+	require.Equal(t, 1, hook.flushes)
+	require.Equal(t, int64(1), hook.stats.Sets)
+	require.Equal(t, int64(1), hook.stats.Hits)
+
+	require.ErrorIs(t, s.Close(), storage.ErrClosed) // Повторный Close не вызывает хуки снова
+	require.Equal(t, 1, hook.flushes)
+}
+
+// sweepHook запоминает проходы сборщика мусора.
+type sweepHook struct {
+	mu      sync.Mutex
+	removed []int
+}
+
+func (h *sweepHook) Flush(ctx context.Context) error { return nil }
+
+func (h *sweepHook) OnGCSweep(removed int, duration time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.removed = append(h.removed, removed)
+}
+
+func TestMemoryStorage_GCHook(t *testing.T) {
+	hook := &sweepHook{}
+	s, _ := storage.NewMemory[int](time.Hour, storage.WithHook(hook))
+	defer s.Close()
+	ctx := context.Background()
+
+	require.NoError(t, s.Set(ctx, "a", 1, time.Millisecond))
+	require.NoError(t, s.Set(ctx, "b", 2, time.Millisecond))
+	require.NoError(t, s.Set(ctx, "c", 3, 0))
+	time.Sleep(5 * time.Millisecond)
+
+	n, err := s.(storage.ExpiryPurger).PurgeExpired(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+	_, _ = s.(storage.ExpiryPurger).PurgeExpired(ctx)
+
+	hook.mu.Lock()
+	defer hook.mu.Unlock()
+	require.Equal(t, []int{2, 0}, hook.removed)
+}
+
+func TestMemoryStorage_GetRaw(t *testing.T) {
+	type user struct {
+		Name string `json:"name"`
+	}
+	s, _ := storage.NewMemory[user](time.Hour)
+	defer s.Close()
+	ctx := context.Background()
+
+	require.NoError(t, s.Set(ctx, "user:1", user{Name: "Bob"}, 0))
+
+	raw, found, err := s.GetRaw(ctx, "user:1")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.JSONEq(t, `{"name":"Bob"}`, string(raw))
+
+	raw, found, err = s.GetRaw(ctx, "missing")
+	require.NoError(t, err)
+	require.False(t, found)
+	require.Nil(t, raw)
+}
+
+func TestMemoryStorage_Inspect(t *testing.T) {
+	s, _ := storage.NewMemory[any](time.Hour)
+	defer s.Close()
+	ctx := context.Background()
+
+	require.NoError(t, s.Set(ctx, "n", 42, 0))
+
+	info, found, err := s.Inspect(ctx, "n")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, storage.ObjectInfo{Encoding: "int", RefCount: 1}, info)
+
+	_, found, err = s.Inspect(ctx, "missing")
+	require.NoError(t, err)
+	require.False(t, found)
+}
+
+func TestMemoryStorage_GetByPrefix(t *testing.T) {
+	s, _ := storage.NewMemory[string](time.Hour)
+	defer s.Close()
+	ctx := context.Background()
+
+	require.NoError(t, s.Set(ctx, "cfg:a", "1", 0))
+	require.NoError(t, s.Set(ctx, "cfg:b", "2", 0))
+	require.NoError(t, s.Set(ctx, "cfg:old", "3", time.Millisecond))
+	require.NoError(t, s.Set(ctx, "other", "4", 0))
+	time.Sleep(5 * time.Millisecond)
+
+	values, err := s.GetByPrefix(ctx, "cfg:")
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"cfg:a": "1", "cfg:b": "2"}, values)
+
+	values, err = s.GetByPrefix(ctx, "cfg*")
+	require.NoError(t, err)
+	require.Empty(t, values)
+}
+
+func TestMemoryStorage_Toggle(t *testing.T) {
+	s, _ := storage.NewMemory[bool](time.Hour)
+	defer s.Close()
+	ctx := context.Background()
+
+	on, err := s.Toggle(ctx, "flag")
+	require.NoError(t, err)
+	require.True(t, on)
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := s.Toggle(ctx, "flag")
+			require.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	val, found, _ := s.Get(ctx, "flag")
+	require.True(t, found)
+	require.True(t, val) // Четное количество переключений возвращает исходное значение
+
+	require.NoError(t, s.Set(ctx, "temp", true, time.Minute))
+	off, err := s.Toggle(ctx, "temp")
+	require.NoError(t, err)
+	require.False(t, off)
+	ttl, _, _ := s.(storage.TTLReader).TTL(ctx, "temp")
+	require.Greater(t, ttl, 50*time.Second)
+
+	ints, _ := storage.NewMemory[int](time.Hour)
+	defer ints.Close()
+	_, err = ints.Toggle(ctx, "n")
+	require.ErrorIs(t, err, storage.ErrWrongType)
+}
+
+func TestMemoryStorage_IncrementField(t *testing.T) {
+	type page struct {
+		Title string
+		Views int64 `json:"views"`
+		Score float64
+	}
+	s, _ := storage.NewMemory[page](time.Hour)
+	defer s.Close()
+	ctx := context.Background()
+
+	require.NoError(t, s.Set(ctx, "page", page{Title: "home", Views: 5}, time.Minute))
+
+	var wg sync.WaitGroup
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := s.IncrementField(ctx, "page", "views", 2)
+			require.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	val, _, _ := s.Get(ctx, "page")
+	require.Equal(t, page{Title: "home", Views: 105}, val)
+	ttl, _, _ := s.(storage.TTLReader).TTL(ctx, "page")
+	require.Greater(t, ttl, 50*time.Second)
+
+	n, err := s.IncrementField(ctx, "new", "views", -3)
+	require.NoError(t, err)
+	require.Equal(t, int64(-3), n)
+
+	_, err = s.IncrementField(ctx, "page", "Score", 1)
+	require.ErrorIs(t, err, storage.ErrWrongType)
+	_, err = s.IncrementField(ctx, "page", "Views", 1) // Имя в JSON задано тегом
+	require.ErrorIs(t, err, storage.ErrWrongType)
+
+	ints, _ := storage.NewMemory[int](time.Hour)
+	defer ints.Close()
+	_, err = ints.IncrementField(ctx, "n", "views", 1)
+	require.ErrorIs(t, err, storage.ErrWrongType)
+}
+
+func TestMemoryStorage_SAddEx(t *testing.T) {
+	s, _ := storage.NewMemory[string](time.Hour)
+	defer s.Close()
+	ctx := context.Background()
+
+	require.NoError(t, s.SAddEx(ctx, "recent", "a", 50*time.Millisecond))
+	require.NoError(t, s.SAddEx(ctx, "recent", "b", 50*time.Millisecond))
+	require.NoError(t, s.SAddEx(ctx, "recent", "c", time.Minute))
+	require.NoError(t, s.SAddEx(ctx, "recent", "d", 0))
+	require.NoError(t, s.SAddEx(ctx, "recent", "b", time.Minute)) // Продлевает элемент
+
+	n, err := s.SCount(ctx, "recent")
+	require.NoError(t, err)
+	require.Equal(t, int64(4), n)
+
+	time.Sleep(80 * time.Millisecond)
+	n, err = s.SCount(ctx, "recent")
+	require.NoError(t, err)
+	require.Equal(t, int64(3), n)
+
+	n, err = s.SCount(ctx, "missing")
+	require.NoError(t, err)
+	require.Zero(t, n)
+}
+
+func TestMemoryStorage_RemoveAt(t *testing.T) {
+	s, _ := storage.NewMemory[string](time.Hour)
+	defer s.Close()
+	ctx := context.Background()
+
+	for _, v := range []string{"a", "b", "c", "d"} {
+		require.NoError(t, s.Enqueue(ctx, "jobs", v))
+	}
+
+	ok, err := s.RemoveAt(ctx, "jobs", 1)
+	require.NoError(t, err)
+	require.True(t, ok)
+	ok, _ = s.RemoveAt(ctx, "jobs", -1)
+	require.True(t, ok)
+	ok, _ = s.RemoveAt(ctx, "jobs", 2)
+	require.False(t, ok)
+	ok, _ = s.RemoveAt(ctx, "missing", 0)
+	require.False(t, ok)
+
+	tail, _ := s.QueueTail(ctx, "jobs", 10)
+	require.Equal(t, []string{"a", "c"}, tail)
+}
+
+func TestMemoryStorage_BlockingDequeueAny(t *testing.T) {
+	s, _ := storage.NewMemory[string](time.Hour)
+	defer s.Close()
+	ctx := context.Background()
+	queues := []string{"high", "low"}
+
+	// Очереди проверяются в порядке приоритета
+	require.NoError(t, s.Enqueue(ctx, "low", "l1"))
+	require.NoError(t, s.Enqueue(ctx, "high", "h1"))
+	name, val, found, err := s.BlockingDequeueAny(ctx, queues, time.Second)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "high", name)
+	require.Equal(t, "h1", val)
+	name, _, _, _ = s.BlockingDequeueAny(ctx, queues, time.Second)
+	require.Equal(t, "low", name)
+
+	// Ожидание прерывается добавлением в любую из очередей
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		_ = s.Enqueue(ctx, "low", "l2")
+	}()
+	name, val, found, err = s.BlockingDequeueAny(ctx, queues, time.Second)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "low", name)
+	require.Equal(t, "l2", val)
+
+	// Отложенный элемент дожидается своей готовности
+	require.NoError(t, s.EnqueueDelayed(ctx, "high", "later", 20*time.Millisecond))
+	_, val, found, _ = s.BlockingDequeueAny(ctx, queues, time.Second)
+	require.True(t, found)
+	require.Equal(t, "later", val)
+
+	start := time.Now()
+	_, _, found, err = s.BlockingDequeueAny(ctx, queues, 30*time.Millisecond)
+	require.NoError(t, err)
+	require.False(t, found)
+	require.GreaterOrEqual(t, time.Since(start), 30*time.Millisecond)
+
+	cancelCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	_, _, found, err = s.BlockingDequeueAny(cancelCtx, queues, 0)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.False(t, found)
+}
+
+func TestMemoryStorage_GetInto(t *testing.T) {
+	s, _ := storage.NewMemory[string](time.Hour)
+	defer s.Close()
+	ctx := context.Background()
+
+	require.NoError(t, s.Set(ctx, "k", "v", 0))
+	dst := "old"
+	found, err := s.GetInto(ctx, "missing", &dst)
+	require.NoError(t, err)
+	require.False(t, found)
+	require.Equal(t, "old", dst)
+
+	found, err = s.GetInto(ctx, "k", &dst)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "v", dst)
+}
+
+func TestMemoryStorage_ClaimOnce(t *testing.T) {
+	s, _ := storage.NewMemory[string](time.Hour)
+	defer s.Close()
+	ctx := context.Background()
+
+	claimed, err := s.ClaimOnce(ctx, "job:1", 20*time.Millisecond)
+	require.NoError(t, err)
+	require.True(t, claimed)
+	claimed, err = s.ClaimOnce(ctx, "job:1", time.Hour)
+	require.NoError(t, err)
+	require.False(t, claimed)
+
+	// После истечения маркер можно захватить снова
+	time.Sleep(30 * time.Millisecond)
+	claimed, _ = s.ClaimOnce(ctx, "job:1", 0)
+	require.True(t, claimed)
+	_, found, _ := s.Get(ctx, "job:1")
+	require.True(t, found)
+}
+
+func TestMemoryStorage_SetVersioned(t *testing.T) {
+	s, _ := storage.NewMemory[string](time.Hour)
+	defer s.Close()
+	ctx := context.Background()
+
+	for _, v := range []string{"v1", "v2", "v3", "v4"} {
+		require.NoError(t, s.SetVersioned(ctx, "doc", v, 3))
+	}
+	val, _, _ := s.Get(ctx, "doc")
+	require.Equal(t, "v4", val)
+
+	for n, want := range []string{"v4", "v3", "v2"} {
+		val, found, err := s.GetVersion(ctx, "doc", n)
+		require.NoError(t, err)
+		require.True(t, found)
+		require.Equal(t, want, val)
+	}
+	_, found, err := s.GetVersion(ctx, "doc", 3) // v1 вытеснена ограничением keep
+	require.NoError(t, err)
+	require.False(t, found)
+
+	// История переживает удаление значения
+	require.NoError(t, s.Delete(ctx, "doc"))
+	val, found, _ = s.GetVersion(ctx, "doc", 0)
+	require.True(t, found)
+	require.Equal(t, "v4", val)
+
+	require.ErrorIs(t, s.SetVersioned(ctx, "doc", "v5", 0), storage.ErrInvalidMaxLen)
+	_, _, err = s.GetVersion(ctx, "doc", -1)
+	require.ErrorIs(t, err, storage.ErrInvalidOffset)
+
+	// Конкурентные записи оставляют значение, совпадающее с последней версией
+	var wg sync.WaitGroup
+	for i := range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = s.SetVersioned(ctx, "doc", fmt.Sprint(i), 100)
+		}()
+	}
+	wg.Wait()
+	val, _, _ = s.Get(ctx, "doc")
+	latest, _, _ := s.GetVersion(ctx, "doc", 0)
+	require.Equal(t, latest, val)
+}
+
+func TestMemoryStorage_WaitForKey(t *testing.T) {
+	s, _ := storage.NewMemory[string](time.Hour)
+	defer s.Close()
+	ctx := context.Background()
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		_ = s.Set(ctx, "ready", "published", 0)
+	}()
+	start := time.Now()
+	val, found, err := s.WaitForKey(ctx, "ready", time.Second)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "published", val)
+	require.Less(t, time.Since(start), 500*time.Millisecond)
+
+	// Существующий ключ возвращается сразу
+	val, found, _ = s.WaitForKey(ctx, "ready", 0)
+	require.True(t, found)
+	require.Equal(t, "published", val)
+
+	_, found, err = s.WaitForKey(ctx, "missing", 20*time.Millisecond)
+	require.NoError(t, err)
+	require.False(t, found)
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, _, err = s.WaitForKey(cancelled, "missing", 0)
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestMemoryStorage_IncrWindow(t *testing.T) {
+	s, _ := storage.NewMemory[string](time.Hour)
+	defer s.Close()
+	ctx := context.Background()
+
+	for i := int64(1); i <= 3; i++ {
+		count, allowed, err := s.IncrWindow(ctx, "rate:user", 30*time.Millisecond, 2)
+		require.NoError(t, err)
+		require.Equal(t, i, count)
+		require.Equal(t, i <= 2, allowed)
+	}
+
+	// Счетчик не виден как запись
+	_, found, _ := s.Get(ctx, "rate:user")
+	require.False(t, found)
+
+	// Новое окно начинается после окончания предыдущего
+	time.Sleep(40 * time.Millisecond)
+	count, allowed, err := s.IncrWindow(ctx, "rate:user", 30*time.Millisecond, 2)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), count)
+	require.True(t, allowed)
+
+	_, _, err = s.IncrWindow(ctx, "rate:user", 0, 2)
+	require.ErrorIs(t, err, storage.ErrInvalidRateLimit)
+}
+
+func TestMemoryStorage_DeleteIf(t *testing.T) {
+	s, _ := storage.NewMemory[string](time.Hour)
+	defer s.Close()
+	ctx := context.Background()
+
+	require.NoError(t, s.Set(ctx, "lock", "owner-a", time.Minute))
+
+	deleted, err := s.DeleteIf(ctx, "lock", "owner-b")
+	require.NoError(t, err)
+	require.False(t, deleted)
+	_, found, _ := s.Get(ctx, "lock")
+	require.True(t, found, "чужое значение не удаляется")
+
+	deleted, err = s.DeleteIf(ctx, "lock", "owner-a")
+	require.NoError(t, err)
+	require.True(t, deleted)
+	_, found, _ = s.Get(ctx, "lock")
+	require.False(t, found)
+
+	deleted, _ = s.DeleteIf(ctx, "lock", "owner-a")
+	require.False(t, deleted)
+}
+
+func TestMemoryStorage_CopyKey(t *testing.T) {
+	s, _ := storage.NewMemory[string](time.Hour)
+	defer s.Close()
+	ctx := context.Background()
+
+	require.NoError(t, s.Set(ctx, "src", "v1", time.Hour))
+	require.NoError(t, s.Set(ctx, "taken", "v2", 0))
+
+	copied, err := s.CopyKey(ctx, "src", "dst", false)
+	require.NoError(t, err)
+	require.True(t, copied)
+	val, _, _ := s.Get(ctx, "dst")
+	require.Equal(t, "v1", val)
+	ttl, _, _ := s.(storage.TTLReader).TTL(ctx, "dst")
+	require.Greater(t, ttl, 59*time.Minute)
+
+	copied, _ = s.CopyKey(ctx, "src", "taken", false)
+	require.False(t, copied)
+	copied, _ = s.CopyKey(ctx, "src", "taken", true)
+	require.True(t, copied)
+	val, _, _ = s.Get(ctx, "taken")
+	require.Equal(t, "v1", val)
+
+	copied, _ = s.CopyKey(ctx, "missing", "dst", true)
+	require.False(t, copied)
+
+	// Замененный dst теряет свои теги
+	require.NoError(t, s.SetWithTags(ctx, "tagged", "v3", 0, []string{"group"}))
+	copied, _ = s.CopyKey(ctx, "src", "tagged", true)
+	require.True(t, copied)
+	n, err := s.InvalidateTag(ctx, "group")
+	require.NoError(t, err)
+	require.Zero(t, n)
+	_, found, _ := s.Get(ctx, "tagged")
+	require.True(t, found)
+}
+
+func TestMemoryStorage_CopyKeyDeepCopy(t *testing.T) {
+	s, _ := storage.NewMemory[[]int](time.Hour)
+	defer s.Close()
+	ctx := context.Background()
+
+	require.NoError(t, s.Set(ctx, "src", []int{1, 2}, 0))
+	copied, err := s.CopyKey(ctx, "src", "dst", false)
+	require.NoError(t, err)
+	require.True(t, copied)
+
+	// Копия не разделяет срез с исходной записью
+	src, _, _ := s.Get(ctx, "src")
+	src[0] = 9
+	dst, _, _ := s.Get(ctx, "dst")
+	require.Equal(t, []int{1, 2}, dst)
+}
+
+func TestMemoryStorage_CountPattern(t *testing.T) {
+	s, _ := storage.NewMemory[int](time.Hour)
+	defer s.Close()
+	ctx := context.Background()
+
+	require.NoError(t, s.Set(ctx, "user:1", 1, 0))
+	require.NoError(t, s.Set(ctx, "user:2", 2, 0))
+	require.NoError(t, s.Set(ctx, "user:3", 3, time.Millisecond))
+	require.NoError(t, s.Set(ctx, "order:1", 1, 0))
+	require.NoError(t, s.Enqueue(ctx, "user:queue", 1))
+	time.Sleep(5 * time.Millisecond)
+
+	n, err := s.CountPattern(ctx, "user:*")
+	require.NoError(t, err)
+	require.Equal(t, int64(2), n) // Истекшая запись и очередь не учитываются
+}
+
+func TestMemoryStorage_ReplaceQueue(t *testing.T) {
+	s, _ := storage.NewMemory[string](time.Hour)
+	defer s.Close()
+	ctx := context.Background()
+
+	require.NoError(t, s.Enqueue(ctx, "schedule", "old"))
+	require.NoError(t, s.ReplaceQueue(ctx, "schedule", []string{"a", "b", "c"}))
+	tail, _ := s.QueueTail(ctx, "schedule", 10)
+	require.Equal(t, []string{"a", "b", "c"}, tail)
+
+	require.NoError(t, s.ReplaceQueue(ctx, "schedule", nil))
+	n, _ := s.QueueLen(ctx, "schedule")
+	require.Zero(t, n)
+}
+
+func TestMemoryStorage_RotateQueue(t *testing.T) {
+	s, _ := storage.NewMemory[string](time.Hour)
+	defer s.Close()
+	ctx := context.Background()
+
+	_, found, err := s.RotateQueue(ctx, "workers")
+	require.NoError(t, err)
+	require.False(t, found)
+
+	require.NoError(t, s.ReplaceQueue(ctx, "workers", []string{"a", "b", "c"}))
+	var order []string
+	for range 4 {
+		v, found, err := s.RotateQueue(ctx, "workers")
+		require.NoError(t, err)
+		require.True(t, found)
+		order = append(order, v)
+	}
+	require.Equal(t, []string{"a", "b", "c", "a"}, order)
+
+	tail, _ := s.QueueTail(ctx, "workers", 10)
+	require.Equal(t, []string{"b", "c", "a"}, tail)
+}
+
+func TestMemoryStorage_SetHead(t *testing.T) {
+	s, _ := storage.NewMemory[string](time.Hour)
+	defer s.Close()
+	ctx := context.Background()
+
+	// Пустая очередь не создается
+	_, found, err := s.SetHead(ctx, "jobs", "x")
+	require.NoError(t, err)
+	require.False(t, found)
+	n, _ := s.QueueLen(ctx, "jobs")
+	require.Zero(t, n)
+
+	require.NoError(t, s.ReplaceQueue(ctx, "jobs", []string{"a", "b"}))
+	old, found, err := s.SetHead(ctx, "jobs", "a2")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "a", old)
+
+	tail, _ := s.QueueTail(ctx, "jobs", 10)
+	require.Equal(t, []string{"a2", "b"}, tail)
+}
+
+func TestMemoryStorage_MaxValueBytes(t *testing.T) {
+	s, _ := storage.NewMemory[string](time.Hour, storage.WithMaxValueBytes(10))
+	defer s.Close()
+	ctx := context.Background()
+
+	require.NoError(t, s.Set(ctx, "small", "abc", 0)) // "abc" в JSON занимает 5 байт
+	err := s.Set(ctx, "big", "abcdefghijk", 0)
+	require.ErrorIs(t, err, storage.ErrValueTooLarge)
+	_, found, _ := s.Get(ctx, "big")
+	require.False(t, found)
+
+	require.ErrorIs(t, s.Enqueue(ctx, "jobs", "abcdefghijk"), storage.ErrValueTooLarge)
+	length, _ := s.QueueLen(ctx, "jobs")
+	require.Zero(t, length)
+
+	p := s.Pipeline()
+	p.Set("big", "abcdefghijk", 0)
+	p.Set("ok", "a", 0)
+	results, err := p.Exec(ctx)
+	require.ErrorIs(t, err, storage.ErrValueTooLarge)
+	require.ErrorIs(t, results[0].Err, storage.ErrValueTooLarge)
+	require.NoError(t, results[1].Err) // Остальные команды пакета выполняются
+}
+
+func TestMemoryStorage_TryEnqueue(t *testing.T) {
+	s, _ := storage.NewMemory[int](time.Hour)
+	ctx := context.Background()
+
+	ok, reason, err := s.TryEnqueue(ctx, "jobs", 1, 1)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, storage.ReasonOK, reason)
+
+	ok, reason, err = s.TryEnqueue(ctx, "jobs", 2, 1)
+	require.NoError(t, err)
+	require.False(t, ok)
+	require.Equal(t, storage.ReasonFull, reason)
+
+	_, reason, err = s.TryEnqueue(ctx, "jobs", 2, 0)
+	require.ErrorIs(t, err, storage.ErrInvalidMaxLen)
+	require.Equal(t, storage.ReasonError, reason)
+
+	require.NoError(t, s.Close())
+	ok, reason, err = s.TryEnqueue(ctx, "jobs", 3, 10)
+	require.NoError(t, err)
+	require.False(t, ok)
+	require.Equal(t, "closed", reason.String())
+}
+
+func TestMemoryStorage_PurgeExpired(t *testing.T) {
+	s, _ := storage.NewMemory[string](time.Hour) // Сборщик мусора не успеет сработать
+	defer s.Close()
+	ctx := context.Background()
+
+	require.NoError(t, s.Set(ctx, "short1", "v", time.Millisecond))
+	require.NoError(t, s.Set(ctx, "short2", "v", time.Millisecond))
+	require.NoError(t, s.Set(ctx, "long", "v", time.Hour))
+	time.Sleep(5 * time.Millisecond)
+
+	purger := s.(storage.ExpiryPurger)
+	removed, err := purger.PurgeExpired(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 2, removed)
+	require.Equal(t, int64(1), s.(storage.MemoryStatsProvider).Stats().Items)
+
+	removed, _ = purger.PurgeExpired(ctx)
+	require.Zero(t, removed)
+}
+
+func TestMemoryStorage_PurgeExpiredSkipsRewritten(t *testing.T) {
+	s, _ := storage.NewMemory[int](time.Hour)
+	defer s.Close()
+	ctx := context.Background()
+
+	const total = 5000 // Несколько порций удаления
+	for i := range total {
+		require.NoError(t, s.Set(ctx, fmt.Sprintf("expired:%d", i), i, 50*time.Millisecond))
+	}
+	// Перезаписанный и продленный ключи не удаляются по старому времени истечения
+	require.NoError(t, s.Set(ctx, "expired:0", 0, time.Hour))
+	require.NoError(t, s.Set(ctx, "expired:1", 1, 0))
+	_, found, _ := s.GetEx(ctx, "expired:2", time.Hour)
+	require.True(t, found)
+	time.Sleep(60 * time.Millisecond)
+
+	purger := s.(storage.ExpiryPurger)
+	removed, err := purger.PurgeExpired(ctx)
+	require.NoError(t, err)
+	require.Equal(t, total-3, removed)
+	require.Equal(t, int64(3), s.(storage.MemoryStatsProvider).Stats().Items)
+
+	require.NoError(t, s.Set(ctx, "gone", 0, time.Millisecond))
+	time.Sleep(5 * time.Millisecond)
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	removed, err = purger.PurgeExpired(cancelled)
+	require.ErrorIs(t, err, context.Canceled)
+	require.Zero(t, removed)
+}
+
+func TestMemoryStorage_Dump(t *testing.T) {
+	s, _ := storage.NewMemory[int](time.Hour)
+	defer s.Close()
+	ctx := context.Background()
+
+	require.NoError(t, s.Set(ctx, "gone", 3, time.Millisecond))
+	time.Sleep(5 * time.Millisecond)
+	require.NoError(t, s.Set(ctx, "b", 2, 0))
+	require.NoError(t, s.Set(ctx, "a", 1, time.Hour))
+	require.NoError(t, s.Enqueue(ctx, "jobs", 10))
+	require.NoError(t, s.Enqueue(ctx, "jobs", 20))
+
+	dump := s.(storage.Dumper).Dump()
+	require.Contains(t, dump, "keys (2):\n  \"a\" = 1 (ttl 1h0m0s)\n  \"b\" = 2 (no ttl)\n")
+	require.Contains(t, dump, "queues (1):\n  \"jobs\" [2]: [10 20]\n")
+	require.NotContains(t, dump, "gone")
+}
+
+func TestMemoryStorage_QueueReadFrom(t *testing.T) {
+	s, _ := storage.NewMemory[int](time.Hour)
+	defer s.Close()
+	ctx := context.Background()
+
+	for i := range 5 {
+		require.NoError(t, s.Enqueue(ctx, "log", i))
+	}
+
+	// Два потребителя читают журнал независимо
+	first, next, err := s.QueueReadFrom(ctx, "log", 0, 3)
+	require.NoError(t, err)
+	require.Equal(t, []int{0, 1, 2}, first)
+	require.Equal(t, int64(3), next)
+
+	rest, next, err := s.QueueReadFrom(ctx, "log", next, 10)
+	require.NoError(t, err)
+	require.Equal(t, []int{3, 4}, rest)
+	require.Equal(t, int64(5), next)
+
+	other, _, _ := s.QueueReadFrom(ctx, "log", 1, 2)
+	require.Equal(t, []int{1, 2}, other)
+
+	items, same, err := s.QueueReadFrom(ctx, "log", next, 10)
+	require.NoError(t, err)
+	require.Empty(t, items)
+	require.Equal(t, next, same)
+
+	require.NoError(t, s.Enqueue(ctx, "log", 5))
+	items, _, _ = s.QueueReadFrom(ctx, "log", next, 10)
+	require.Equal(t, []int{5}, items)
+
+	_, _, err = s.QueueReadFrom(ctx, "log", -1, 10)
+	require.ErrorIs(t, err, storage.ErrInvalidOffset)
+
+	// Извлечение сдвигает смещения, поэтому разобранная очередь по ним не читается
+	_, _, err = s.Dequeue(ctx, "log")
+	require.NoError(t, err)
+	_, _, err = s.QueueReadFrom(ctx, "log", 0, 10)
+	require.ErrorIs(t, err, storage.ErrQueueConsumed)
+
+	// EnqueueCapped помечает очередь, только отбросив ее начало
+	require.NoError(t, s.EnqueueCapped(ctx, "capped", 1, 1))
+	items, _, err = s.QueueReadFrom(ctx, "capped", 0, 10)
+	require.NoError(t, err)
+	require.Equal(t, []int{1}, items)
+	require.NoError(t, s.EnqueueCapped(ctx, "capped", 2, 1))
+	_, _, err = s.QueueReadFrom(ctx, "capped", 0, 10)
+	require.ErrorIs(t, err, storage.ErrQueueConsumed)
+}
+
+func TestMemoryStorage_QueueIterate(t *testing.T) {
+	s, _ := storage.NewMemory[int](time.Hour)
+	defer s.Close()
+	ctx := context.Background()
+
+	for i := range 5 {
+		require.NoError(t, s.Enqueue(ctx, "log", i))
+	}
+
+	var seen []int
+	require.NoError(t, s.QueueIterate(ctx, "log", func(v int) error {
+		seen = append(seen, v)
+		return nil
+	}))
+	require.Equal(t, []int{0, 1, 2, 3, 4}, seen)
+
+	// Ошибка обработчика прекращает обход
+	errStop := errors.New("stop")
+	seen = nil
+	err := s.QueueIterate(ctx, "log", func(v int) error {
+		seen = append(seen, v)
+		if v == 2 {
+			return errStop
+		}
+		return nil
+	})
+	require.ErrorIs(t, err, errStop)
+	require.Equal(t, []int{0, 1, 2}, seen)
+
+	// Обход не удаляет элементы, а обработчик может менять очередь
+	require.NoError(t, s.QueueIterate(ctx, "log", func(v int) error {
+		return s.Enqueue(ctx, "log", v)
+	}))
+	n, _ := s.QueueLen(ctx, "log")
+	require.Equal(t, int64(10), n)
+
+	require.NoError(t, s.QueueIterate(ctx, "missing", func(int) error {
+		t.Fatal("unexpected element")
+		return nil
+	}))
+}
+
+func TestMemoryStorage_QueueFIFOUnderContention(t *testing.T) {
+	s, _ := storage.NewMemory[[2]int](time.Hour)
+	defer s.Close()
+	ctx := context.Background()
+
+	const producers, consumers, perProducer = 8, 8, 2000
+
+	// Потребители работают, пока производители пишут, и очередь многократно пустеет
+	var produced sync.WaitGroup
+	for p := range producers {
+		produced.Add(1)
+		go func() {
+			defer produced.Done()
+			for seq := range perProducer {
+				require.NoError(t, s.Enqueue(ctx, "fifo", [2]int{p, seq}))
+			}
+		}()
+	}
+	done := make(chan struct{})
+	go func() {
+		produced.Wait()
+		close(done)
+	}()
+
+	received := make([][][2]int, consumers)
+	var consumed sync.WaitGroup
+	for c := range consumers {
+		consumed.Add(1)
+		go func() {
+			defer consumed.Done()
+			for {
+				v, found, err := s.Dequeue(ctx, "fifo")
+				require.NoError(t, err)
+				if found {
+					received[c] = append(received[c], v)
+					continue
+				}
+				select {
+				case <-done:
+					if n, _ := s.QueueLen(ctx, "fifo"); n == 0 {
+						return
+					}
+				default:
+				}
+			}
+		}()
+	}
+	consumed.Wait()
+
+	seen := make(map[[2]int]bool)
+	for _, items := range received {
+		last := make(map[int]int) // Производитель -> последний номер, полученный потребителем
+		for _, v := range items {
+			require.False(t, seen[v], "duplicate %v", v)
+			seen[v] = true
+			if prev, ok := last[v[0]]; ok {
+				require.Greater(t, v[1], prev, "producer %d out of order", v[0])
+			}
+			last[v[0]] = v[1]
+		}
+	}
+	require.Len(t, seen, producers*perProducer)
+}
+
+func TestMemoryStorage_Tags(t *testing.T) {
+	s, _ := storage.NewMemory[string](50 * time.Millisecond)
+	defer s.Close()
+	ctx := context.Background()
+
+	require.NoError(t, s.SetWithTags(ctx, "price:1", "10", 0, []string{"product:1"}))
+	require.NoError(t, s.SetWithTags(ctx, "page:1", "<html>", 0, []string{"product:1", "pages"}))
+	require.NoError(t, s.SetWithTags(ctx, "page:2", "<html>", 0, []string{"pages"}))
+	require.NoError(t, s.SetWithTags(ctx, "brief", "x", 20*time.Millisecond, []string{"product:1"}))
+
+	// Удаленный и истекший ключи исключаются из тега
+	require.NoError(t, s.Delete(ctx, "price:1"))
+	require.Eventually(t, func() bool {
+		_, found, _ := s.Get(ctx, "brief")
+		return !found
+	}, time.Second, 10*time.Millisecond)
+	require.NoError(t, s.Set(ctx, "price:1", "11", 0))
+	require.NoError(t, s.Set(ctx, "brief", "y", 0))
+
+	n, err := s.InvalidateTag(ctx, "product:1")
+	require.NoError(t, err)
+	require.Equal(t, int64(1), n)
+
+	_, found, _ := s.Get(ctx, "page:1")
+	require.False(t, found)
+	_, found, _ = s.Get(ctx, "price:1")
+	require.True(t, found)
+	_, found, _ = s.Get(ctx, "brief")
+	require.True(t, found)
+
+	// page:1 исключен из всех тегов
+	n, err = s.InvalidateTag(ctx, "pages")
+	require.NoError(t, err)
+	require.Equal(t, int64(1), n)
+	n, err = s.InvalidateTag(ctx, "pages")
+	require.NoError(t, err)
+	require.Zero(t, n)
+}
+
+func TestMemoryStorage_MoveMatching(t *testing.T) {
+	s, _ := storage.NewMemory[int](time.Hour)
+	defer s.Close()
+	ctx := context.Background()
+
+	require.NoError(t, s.ReplaceQueue(ctx, "dlq", []int{1, 2, 3, 4, 5, 6}))
+	require.NoError(t, s.Enqueue(ctx, "work", 100))
+	even := func(v int) bool { return v%2 == 0 }
+
+	n, err := s.MoveMatching(ctx, "dlq", "work", even, 2)
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+	tail, _ := s.QueueTail(ctx, "dlq", 10)
+	require.Equal(t, []int{1, 3, 5, 6}, tail)
+	tail, _ = s.QueueTail(ctx, "work", 10)
+	require.Equal(t, []int{100, 2, 4}, tail)
+
+	// Без ограничения переносятся все подходящие; опустевшая очередь удаляется
+	n, err = s.MoveMatching(ctx, "dlq", "work", func(int) bool { return true }, 0)
+	require.NoError(t, err)
+	require.Equal(t, 4, n)
+	length, _ := s.QueueLen(ctx, "dlq")
+	require.Zero(t, length)
+
+	n, err = s.MoveMatching(ctx, "dlq", "work", even, 0)
+	require.NoError(t, err)
+	require.Zero(t, n)
+}
@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"context"
+	"encoding/base64"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Meta содержит служебные метаданные записи
+type Meta struct {
+	CreatedAt time.Time // Время первой записи значения по ключу
+	UpdatedAt time.Time // Время последнего обновления значения
+	Key       string    // Исходный ключ, если он был заменен хешем (WithHashLongKeys)
+}
+
+// metaEnvelope - формат хранения записи с метаданными в Redis.
+// Значение хранится в сериализованном кодеком виде (в JSON - как base64).
+type metaEnvelope struct {
+	V []byte    `json:"v"`           // Значение, сериализованное кодеком хранилища
+	C time.Time `json:"c"`           // Время создания
+	U time.Time `json:"u"`           // Время обновления
+	K string    `json:"k,omitempty"` // Исходный ключ, если он был заменен хешем
+}
+
+// setMetaScript атомарно записывает значение в конверте с метаданными.
+// Время создания берется из существующего конверта, если он есть,
+// поэтому сохраняется при обновлениях.
+// KEYS[1] - ключ; ARGV[1] - значение в base64; ARGV[2] - текущее время (RFC 3339);
+// ARGV[3] - TTL в миллисекундах (0 - сохранить текущий TTL);
+// ARGV[4] - исходный ключ, если он был заменен хешем (пустая строка - нет).
+var setMetaScript = redis.NewScript(`
+local created = ARGV[2]
+local old = redis.call('GET', KEYS[1])
+if old then
+	local ok, env = pcall(cjson.decode, old)
+	if ok and type(env) == 'table' and type(env.c) == 'string' then
+		created = env.c
+	end
+end
+local env = {v = ARGV[1], c = created, u = ARGV[2]}
+if ARGV[4] ~= '' then
+	env.k = ARGV[4]
+end
+local data = cjson.encode(env)
+if tonumber(ARGV[3]) > 0 then
+	redis.call('SET', KEYS[1], data, 'PX', ARGV[3])
+else
+	redis.call('SET', KEYS[1], data, 'KEEPTTL')
+end
+return 1
+`)
+
+// setMetaArgs возвращает аргументы скрипта setMetaScript.
+func setMetaArgs(data []byte, ttl time.Duration, origKey string) []any {
+	var ttlMs int64
+	if ttl > 0 {
+		ttlMs = max(ttl.Milliseconds(), 1) // PX не принимает 0
+	}
+	return []any{
+		base64.StdEncoding.EncodeToString(data),
+		time.Now().UTC().Format(time.RFC3339Nano),
+		ttlMs,
+		origKey,
+	}
+}
+
+// setWithMeta записывает сериализованное значение в конверте с метаданными.
+// origKey - исходный ключ, если key является его хешем, иначе пустая строка.
+func (s *redisStorage[T]) setWithMeta(ctx context.Context, key, origKey string, data []byte, ttl time.Duration) error {
+	return setMetaScript.Run(ctx, s.client, []string{key}, setMetaArgs(data, ttl, origKey)...).Err()
+}
@@ -0,0 +1,162 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// MoveMatching переносит подходящие элементы под одной блокировкой очередей на запись,
+// поэтому другие горутины видят элементы либо в from, либо в to.
+// Готовые отложенные элементы from сначала переносятся в очередь, как в Dequeue.
+func (s *memoryStorage[T]) MoveMatching(ctx context.Context, from, to string, match func(T) bool, limit int) (int, error) {
+	s.queueMu.Lock()         // Блокируем на запись
+	defer s.queueMu.Unlock() // Гарантируем разблокировку
+
+	s.promoteQueueLocked(from, time.Now().UnixNano())
+
+	queue := s.queues[from]
+	kept := &deque[T]{}
+	var moved []T
+	for _, value := range queue.All() {
+		if (limit <= 0 || len(moved) < limit) && match(value) {
+			moved = append(moved, value)
+		} else {
+			kept.PushBack(value)
+		}
+	}
+	if len(moved) == 0 {
+		return 0, nil
+	}
+	tag, err := s.checkQueueTag(to, moved...)
+	if err != nil {
+		return 0, err
+	}
+
+	if s.wal != nil {
+		keptData, err := s.walEncode(kept.Range(0, kept.Len())...)
+		if err != nil {
+			return 0, err
+		}
+		movedData, err := s.walEncode(moved...)
+		if err != nil {
+			return 0, err
+		}
+		err = s.walAppend(walRecord{Op: walReplace, Queue: from, V: keptData}, walRecord{Op: walPush, Queue: to, V: movedData})
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	s.consumed[from] = struct{}{}
+	if kept.Len() == 0 {
+		s.dropIfEmpty(from, kept)
+	} else {
+		s.queues[from] = kept
+	}
+	s.setQueueTag(to, tag)
+	target := s.queue(to)
+	for _, value := range moved {
+		target.PushBack(value)
+	}
+	return len(moved), nil
+}
+
+// MoveMatching читает очередь from командой LRANGE под WATCH, проверяет элементы match
+// на стороне клиента и перезаписывает обе очереди в транзакции MULTI/EXEC.
+// Если очереди изменились до EXEC, попытка повторяется (до maxTxAttempts раз,
+// затем возвращается ErrTxConflict), поэтому match может быть вызвана несколько раз
+// для одного элемента. Элементы, которые не удалось десериализовать, остаются в from.
+// С WithQueueTypeCheck теги обеих очередей отслеживаются вместе с очередями: перенос
+// в очередь с другим тегом возвращает ErrTypeMismatch, не изменяя очереди.
+// Очередь from читается целиком, поэтому операция рассчитана на очереди умеренной длины
+// (например, очереди недоставленных сообщений). В Redis Cluster обе очереди
+// должны находиться в одном слоте (hash tag).
+func (s *redisStorage[T]) MoveMatching(ctx context.Context, from, to string, match func(T) bool, limit int) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, 1*time.Second)
+	defer cancel()
+
+	if err := s.prepareQueue(ctx, from, false); err != nil {
+		return 0, err
+	}
+
+	fromKey, toKey := s.queueKey(from), s.queueKey(to)
+	watched := []string{fromKey, toKey}
+	fromTagKey, toTagKey := s.queueTagKey(from), s.queueTagKey(to)
+	if s.opts.queueTypeCheck {
+		watched = append(watched, fromTagKey, toTagKey)
+	}
+	for range maxTxAttempts {
+		var n int
+		var mismatch error
+		err := s.client.Watch(ctx, func(rtx *redis.Tx) error {
+			items, err := rtx.LRange(ctx, fromKey, 0, -1).Result()
+			if err != nil {
+				return err
+			}
+
+			var kept, moved []any
+			var movedValues []T
+			for _, item := range items {
+				var value T
+				if (limit <= 0 || len(moved) < limit) && s.opts.codec.Unmarshal([]byte(item), &value) == nil && match(value) {
+					moved = append(moved, item)
+					movedValues = append(movedValues, value)
+				} else {
+					kept = append(kept, item)
+				}
+			}
+			if n = len(moved); n == 0 {
+				return nil
+			}
+
+			// Перенесенные элементы сохраняют тег from; без тега from он берется из значений
+			var tag, toTag string
+			if s.opts.queueTypeCheck {
+				tags, err := rtx.MGet(ctx, fromTagKey, toTagKey).Result()
+				if err != nil {
+					return err
+				}
+				tag, _ = tags[0].(string)
+				toTag, _ = tags[1].(string)
+				if tag == "" {
+					if tag, mismatch = s.valuesTag(from, movedValues); mismatch != nil {
+						return nil
+					}
+				}
+				if toTag != "" && toTag != tag {
+					mismatch = typeMismatch(to, toTag, tag)
+					return nil
+				}
+			}
+
+			_, err = rtx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				pipe.Del(ctx, fromKey)
+				if len(kept) > 0 {
+					pipe.RPush(ctx, fromKey, kept...)
+				} else if s.opts.queueTypeCheck {
+					pipe.Del(ctx, fromTagKey) // Опустевшая очередь теряет тег
+				}
+				pipe.RPush(ctx, toKey, moved...)
+				pipe.Set(ctx, s.queueConsumedKey(from), 1, 0)
+				if toTag == "" && tag != "" {
+					pipe.Set(ctx, toTagKey, tag, 0)
+				}
+				return nil
+			})
+			return err
+		}, watched...)
+		if err == redis.TxFailedErr {
+			continue // Очереди изменились - повторяем
+		}
+		if err != nil {
+			return 0, wrapRedisErr("move matching", err)
+		}
+		if mismatch != nil {
+			return 0, mismatch
+		}
+		return n, nil
+	}
+	return 0, ErrTxConflict
+}
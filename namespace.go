@@ -0,0 +1,119 @@
+package storage
+
+import (
+	"slices"
+	"strings"
+)
+
+// Префиксы пространств имен Redis (см. WithNamespaces).
+const (
+	keyNamespace    = "kv:"
+	queueNamespace  = "q:"
+	setNamespace    = "s:"
+	windowNamespace = "w:"
+
+	// История версий SetVersioned хранится под этим префиксом всегда:
+	// список не может разделять имя со значением ключа
+	historyNamespace = "h:"
+	// Манифесты и части значений SetChunked - по той же причине
+	chunkNamespace = "c:"
+	// Множества ключей тегов SetWithTags - по той же причине
+	tagNamespace = "tg:"
+	// Обратные индексы (множества тегов ключа) SetWithTags - по той же причине
+	keyTagsNamespace = "tgk:"
+)
+
+// internalPrefixes - префиксы служебных строковых ключей, которые лежат вне пространства
+// имен обычных ключей и исключаются из обхода SCAN (ScanPage, GetByPrefix, CountPattern,
+// DeletePattern и построенные на них Query и Copy). Служебные списки и множества
+// (historyNamespace, tagNamespace, keyTagsNamespace) отсекает фильтр типа SCAN.
+var internalPrefixes = []string{chunkNamespace, queueTagNamespace, queueConsumedNamespace}
+
+// isInternalKey сообщает, является ли ключ Redis служебным (см. internalPrefixes).
+func isInternalKey(redisKey string) bool {
+	return slices.ContainsFunc(internalPrefixes, func(prefix string) bool {
+		return strings.HasPrefix(redisKey, prefix)
+	})
+}
+
+// namespace возвращает префикс пространства имен или "", если WithNamespaces не задан.
+func (s *redisStorage[T]) namespace(prefix string) string {
+	if !s.opts.namespaces {
+		return ""
+	}
+	return prefix
+}
+
+// checkKey проверяет ключ (см. options.checkKey) и возвращает его имя в Redis.
+func (s *redisStorage[T]) checkKey(key string) (string, error) {
+	key, err := s.opts.checkKey(key)
+	if err != nil {
+		return "", err
+	}
+	return s.namespace(keyNamespace) + key, nil
+}
+
+// resolveKey приводит ключ к виду для записи (см. options.resolveKey)
+// и возвращает его имя в Redis вместе с исходным ключом.
+func (s *redisStorage[T]) resolveKey(key string) (string, string, error) {
+	key, origKey, err := s.opts.resolveKey(key)
+	if err != nil {
+		return "", "", err
+	}
+	return s.namespace(keyNamespace) + key, origKey, nil
+}
+
+// userKey возвращает ключ хранилища по имени ключа в Redis.
+func (s *redisStorage[T]) userKey(redisKey string) string {
+	return strings.TrimPrefix(redisKey, s.namespace(keyNamespace))
+}
+
+// queueKey возвращает имя списка Redis для очереди.
+func (s *redisStorage[T]) queueKey(queueName string) string {
+	return s.namespace(queueNamespace) + queueName
+}
+
+// sameSlotKey возвращает имя служебного ключа с префиксом prefix, который в Redis Cluster
+// попадает в тот же слот, что и key: ключ без hash tag заключается в фигурные скобки,
+// а ключ с hash tag сохраняет его. Ключ с непарными скобками только получает префикс
+// и, как и прежде, должен содержать hash tag, чтобы оказаться в одном слоте со служебным.
+func sameSlotKey(prefix, key string) string {
+	start := strings.IndexByte(key, '{')
+	if start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			return prefix + key // Слот определяет hash tag ключа
+		}
+	}
+	if start >= 0 || strings.IndexByte(key, '}') >= 0 {
+		return prefix + key
+	}
+	return prefix + "{" + key + "}"
+}
+
+// historyKey возвращает имя списка Redis для истории версий ключа в слоте ключа,
+// чтобы SetVersioned записывал значение и историю одной транзакцией.
+// key - имя ключа в Redis (см. checkKey).
+func (s *redisStorage[T]) historyKey(key string) string {
+	return sameSlotKey(historyNamespace, key)
+}
+
+// tagKey возвращает имя множества Redis с ключами тега.
+func (s *redisStorage[T]) tagKey(tag string) string {
+	return tagNamespace + tag
+}
+
+// keyTagsKey возвращает имя множества Redis с тегами ключа.
+// key - имя ключа в Redis (см. checkKey).
+func (s *redisStorage[T]) keyTagsKey(key string) string {
+	return keyTagsNamespace + key
+}
+
+// windowKey возвращает имя счетчика Redis для IncrWindow.
+func (s *redisStorage[T]) windowKey(key string) string {
+	return s.namespace(windowNamespace) + key
+}
+
+// setKey возвращает имя сортированного множества Redis для множества SAddEx.
+func (s *redisStorage[T]) setKey(setName string) string {
+	return s.namespace(setNamespace) + setName
+}
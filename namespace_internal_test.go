@@ -0,0 +1,23 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSameSlotKey(t *testing.T) {
+	cases := []struct {
+		key, want string
+	}{
+		{"jobs", "qt:{jobs}"},
+		{"q:jobs", "qt:{q:jobs}"},
+		{"{tenant}jobs", "qt:{tenant}jobs"},
+		{"q:{tenant}:jobs", "qt:q:{tenant}:jobs"},
+		{"jobs{}", "qt:jobs{}"},
+		{"jobs}", "qt:jobs}"},
+	}
+	for _, c := range cases {
+		require.Equal(t, c.want, sameSlotKey("qt:", c.key), c.key)
+	}
+}
@@ -0,0 +1,424 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"time"
+)
+
+// Option настраивает дополнительные параметры хранилища.
+// Передается в конструкторы в виде вариативного аргумента.
+type Option func(*options)
+
+// options содержит дополнительные параметры хранилища.
+// Значения по умолчанию задаются в defaultOptions.
+type options struct {
+	virtualNodes  int          // Количество виртуальных узлов на шард в кольце хеширования
+	shardHash     ShardHash    // Хеш для выбора шарда
+	logger        *slog.Logger // Логгер для диагностических сообщений
+	skipCorrupt   bool         // Пропускать поврежденные значения вместо возврата ошибки
+	deleteCorrupt bool         // Удалять поврежденные значения при пропуске
+	codec         Codec        // Способ сериализации значений
+	hotKeys       int          // Количество отслеживаемых частых ключей (0 - выключено)
+	metadata      bool         // Хранить время создания и обновления записей
+
+	queueTypeCheck bool   // Проверять теги типов очередей при записи и извлечении
+	queueTypeTag   string // Тег типа значений очередей ("" - имя типа значения)
+
+	snapshotPath     string        // Файл снимка in-memory хранилища ("" - без снимков)
+	snapshotInterval time.Duration // Период записи снимка (0 - только при Close)
+	queueWALPath     string        // Журнал очередей in-memory хранилища ("" - без журнала)
+
+	keyValidator  func(key string) error  // Проверка ключа перед операцией (nil - без проверки)
+	keyNormalizer func(key string) string // Приведение ключа к каноническому виду (nil - без изменений)
+	hashLongKeys  int                     // Максимальная длина ключа, длиннее которой ключ хешируется (0 - выключено)
+
+	ttlJitter time.Duration // Максимальное случайное отклонение TTL записей (0 - без отклонения)
+	hooks     []Hook        // Хуки, которые Close сбрасывает перед освобождением ресурсов
+
+	maxValueBytes int   // Максимальный размер сериализованного значения (0 - без ограничения)
+	maxBytes      int64 // Бюджет размера записей in-memory хранилища (0 - без ограничения)
+	namespaces    bool  // Разводить ключи, очереди и множества Redis по префиксам
+	scanCount     int   // Подсказка COUNT для команд SCAN
+
+	slowOpThreshold time.Duration                             // Порог медленной команды Redis (0 - выключено)
+	onSlowOp        func(method, key string, d time.Duration) // Обработчик медленных команд
+}
+
+// defaultOptions возвращает параметры по умолчанию с примененными опциями.
+func defaultOptions(opts []Option) options {
+	o := options{
+		virtualNodes: 160,
+		shardHash:    FNVHash,
+		logger:       slog.Default(),
+		codec:        JSONCodec{},
+		scanCount:    scanCount,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithVirtualNodes задает количество виртуальных узлов на один шард
+// в кольце консистентного хеширования (используется NewShardedRedis).
+// Большее значение дает более равномерное распределение ключей.
+// Значения меньше 1 игнорируются.
+func WithVirtualNodes(n int) Option {
+	return func(o *options) {
+		if n > 0 {
+			o.virtualNodes = n
+		}
+	}
+}
+
+// WithShardHash задает хеш, по которому ключи и очереди распределяются по шардам
+// (используется NewShardedRedis). По умолчанию - FNVHash. Смена хеша перемещает
+// почти все ключи на другие шарды, поэтому все клиенты одних шардов должны
+// использовать один хеш. nil игнорируется.
+func WithShardHash(hash ShardHash) Option {
+	return func(o *options) {
+		if hash != nil {
+			o.shardHash = hash
+		}
+	}
+}
+
+// WithLogger задает логгер для диагностических сообщений хранилища.
+// По умолчанию используется slog.Default(). Значение nil игнорируется.
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *options) {
+		if logger != nil {
+			o.logger = logger
+		}
+	}
+}
+
+// WithSkipCorrupt включает пропуск поврежденных значений.
+// Если значение не удается десериализовать, Get и Dequeue записывают
+// предупреждение в логгер и возвращают found=false вместо ошибки, а чтения
+// нескольких элементов очереди (QueueTail, QueueIterate и т.д.) пропускают элемент.
+// По умолчанию выключено: возвращается ошибка десериализации.
+func WithSkipCorrupt(skip bool) Option {
+	return func(o *options) {
+		o.skipCorrupt = skip
+	}
+}
+
+// WithDeleteCorrupt включает удаление поврежденного значения по ключу
+// при его пропуске (действует только вместе с WithSkipCorrupt).
+// Для Dequeue элемент уже извлечен из очереди, поэтому опция не нужна.
+func WithDeleteCorrupt(del bool) Option {
+	return func(o *options) {
+		o.deleteCorrupt = del
+	}
+}
+
+// WithCodec задает способ сериализации значений.
+// По умолчанию используется JSONCodec. Значение nil игнорируется.
+func WithCodec(codec Codec) Option {
+	return func(o *options) {
+		if codec != nil {
+			o.codec = codec
+		}
+	}
+}
+
+// WithHotKeyTracking включает отслеживание самых часто используемых ключей.
+// capacity - максимальное количество одновременно отслеживаемых ключей.
+// Каждая операция с ключом захватывает общий мьютекс трекера, поэтому
+// опцию стоит включать только на время диагностики.
+// Результаты доступны через интерфейс HotKeyReporter.
+func WithHotKeyTracking(capacity int) Option {
+	return func(o *options) {
+		o.hotKeys = capacity
+	}
+}
+
+// WithMetadata включает хранение метаданных записей: времени создания
+// и последнего обновления. Время создания сохраняется при перезаписи значения.
+// Метаданные доступны через GetWithMeta. В Redis значение хранится в конверте,
+// поэтому включать и выключать режим для существующих данных нельзя.
+func WithMetadata(enabled bool) Option {
+	return func(o *options) {
+		o.metadata = enabled
+	}
+}
+
+// WithSnapshot включает сохранение in-memory хранилища на диск (используется NewMemory).
+// При создании хранилище загружается из файла path, если он существует
+// (истекшие записи пропускаются). Снимок записывается атомарно (временный файл
+// и переименование) каждые interval и при Close; interval <= 0 - только при Close.
+// Значения сериализуются кодеком хранилища. Зарезервированные элементы очередей
+// сохраняются в начале очереди и после восстановления доставляются повторно.
+func WithSnapshot(path string, interval time.Duration) Option {
+	return func(o *options) {
+		o.snapshotPath = path
+		o.snapshotInterval = interval
+	}
+}
+
+// WithQueueWAL включает журнал очередей in-memory хранилища (используется NewMemory).
+// Каждое изменение очереди (добавление, извлечение, резервирование, перенос
+// отложенных элементов) дописывается в файл path до изменения очереди в памяти,
+// а при создании хранилища журнал воспроизводится. Чтение очередей по-прежнему
+// выполняется из памяти. Если запись в журнал не удалась, добавление возвращает ошибку,
+// а извлечение выполняется и ошибка логируется: после перезапуска элемент может
+// быть доставлен повторно, как и элементы, зарезервированные на момент падения.
+// Журнал переживает падение процесса, но не отключение питания: файл синхронизируется
+// с диском только при сжатии и Close. Сборщик мусора сжимает журнал, удаляя из него
+// извлеченные элементы. Вместе с WithSnapshot очереди сохраняются только в журнале.
+func WithQueueWAL(path string) Option {
+	return func(o *options) {
+		o.queueWALPath = path
+	}
+}
+
+// WithQueueTypeCheck включает проверку типа значений очередей при записи и извлечении.
+// Первая запись в очередь без тега запоминает тег ее типа: tag или, если tag пуст,
+// имя типа значения (reflect.TypeOf). Значение с другим тегом отклоняется любой операцией
+// записи (Enqueue, EnqueueN, EnqueueFanout, EnqueueCapped, EnqueueBounded, TryEnqueue,
+// EnqueueDelayed, ReplaceQueue, MoveMatching в целевую очередь и Enqueue в Pipeline),
+// а извлечение (Dequeue, DequeueTracked, BlockingDequeueAny) хранилищем, ожидающим другой
+// тег, возвращает ErrTypeMismatch; очередь при этом не изменяется. Без tag хранилище
+// с интерфейсным типом T тег при извлечении не проверяет: ожидаемый тип неизвестен.
+// Тег удаляется, когда очередь опустошается. В Redis тег хранится отдельной строкой
+// (префикс "qt:" перед именем списка очереди) в том же слоте Redis Cluster, что и очередь,
+// и проверяется Lua-скриптами.
+func WithQueueTypeCheck(tag string) Option {
+	return func(o *options) {
+		o.queueTypeCheck = true
+		o.queueTypeTag = tag
+	}
+}
+
+// WithKeyValidator задает проверку ключа, вызываемую в начале каждой операции
+// с ключом (Set, Get, Delete и т.д., включая команды Pipeline) до обращения к хранилищу.
+// Если validate возвращает ошибку, операция не выполняется и возвращается ошибка,
+// помеченная ErrInvalidKey. Имена очередей и шаблоны не проверяются.
+// Ключ проверяется после нормализации (см. WithKeyNormalizer).
+func WithKeyValidator(validate func(key string) error) Option {
+	return func(o *options) {
+		o.keyValidator = validate
+	}
+}
+
+// WithKeyNormalizer задает функцию приведения ключа к каноническому виду
+// (например, strings.ToLower или strings.TrimSpace), применяемую ко всем операциям
+// с ключом. Функция должна быть идемпотентной: NewShardedRedis применяет ее
+// для выбора шарда, а затем шард - повторно.
+func WithKeyNormalizer(normalize func(key string) string) Option {
+	return func(o *options) {
+		o.keyNormalizer = normalize
+	}
+}
+
+// WithHashLongKeys включает замену ключей длиннее maxLen байт на ключ фиксированной
+// длины вида "sha256:<hex>" (71 байт). Замена прозрачна: чтения хешируют ключ так же,
+// поэтому Get находит значение по исходному ключу. ScanPage и DeletePattern
+// работают с хранимыми (хешированными) ключами. В режиме WithMetadata исходный ключ
+// сохраняется вместе со значением и возвращается в Meta.Key.
+// Хешированный ключ теряет hash tag Redis Cluster. Значения меньше 71 увеличиваются
+// до 71, чтобы длина хранимых ключей оставалась ограниченной; 0 выключает замену.
+func WithHashLongKeys(maxLen int) Option {
+	return func(o *options) {
+		o.hashLongKeys = 0
+		if maxLen > 0 {
+			o.hashLongKeys = max(maxLen, hashedKeyLen)
+		}
+	}
+}
+
+// WithTTLJitter включает случайное отклонение времени жизни записей: каждая запись
+// с положительным TTL (Set, Tx.Set, Pipeline.Set) истекает через ttl ± случайное
+// значение не больше jitter. Это разносит во времени истечение ключей, записанных
+// с одинаковым TTL, и снижает нагрузку от одновременного промаха кэша.
+// Итоговый TTL не меньше 1 мс. Бессрочные записи и GetEx не затрагиваются.
+// Значения меньше или равные 0 выключают отклонение.
+func WithTTLJitter(jitter time.Duration) Option {
+	return func(o *options) {
+		o.ttlJitter = max(jitter, 0)
+	}
+}
+
+// WithHook регистрирует хук хранилища. Close передает хуку итоговую статистику
+// (если хук реализует StatsHook, а хранилище - MemoryStatsProvider) и вызывает его Flush
+// до освобождения ресурсов. Хук, реализующий GCHook, получает длительность проходов
+// сборщика мусора in-memory хранилища. Опцию можно передать несколько раз; nil игнорируется.
+// NewShardedRedis сбрасывает хуки один раз, а не для каждого шарда.
+func WithHook(h Hook) Option {
+	return func(o *options) {
+		if h != nil {
+			o.hooks = append(o.hooks, h)
+		}
+	}
+}
+
+// WithMaxValueBytes ограничивает размер значения, сериализованного кодеком хранилища.
+// Set, Tx.Set, Pipeline.Set и добавление в очередь отклоняют значения больше n байт
+// ошибкой ErrValueTooLarge до отправки в Redis. In-memory хранилище хранит значения
+// без сериализации, поэтому при включенном ограничении сериализует их только для проверки.
+// Значения меньше или равные 0 снимают ограничение (по умолчанию).
+func WithMaxValueBytes(n int) Option {
+	return func(o *options) {
+		o.maxValueBytes = max(n, 0)
+	}
+}
+
+// WithMaxBytes ограничивает оценку размера записей in-memory хранилища (используется NewMemory).
+// Размер записи - длина ключа и значения, сериализованного кодеком хранилища; он вычисляется
+// при каждой записи. Когда сумма размеров превышает n, вытесняются давно не использованные
+// записи (LRU): использованием считаются запись и чтение через Get, GetEx, GetRaw и GetWithMeta.
+// Только что записанная запись не вытесняется, даже если одна превышает бюджет.
+// Очереди, множества и истории версий не учитываются. Количество вытесненных записей
+// доступно в MemoryStats.PressureEvictions. Значения меньше или равные 0 снимают ограничение.
+func WithMaxBytes(n int64) Option {
+	return func(o *options) {
+		o.maxBytes = max(n, 0)
+	}
+}
+
+// WithNamespaces разводит ключи, очереди и множества Redis по отдельным префиксам:
+// ключи хранятся как "kv:<ключ>", очереди - как "q:<имя>", множества - как "s:<имя>".
+// Очередь и ключ с одинаковым именем перестают конфликтовать (ErrWrongType),
+// а ScanPage, GetByPrefix, DeletePattern и QueueNames видят только свое пространство имен.
+// Префиксы добавляются и удаляются прозрачно: ключи и имена очередей в API не меняются.
+// In-memory хранилище хранит ключи и очереди раздельно и опцию игнорирует.
+//
+// По умолчанию выключено: данные, записанные без опции, лежат без префиксов и
+// с опцией не видны. Для перехода скопируйте их функцией Copy из хранилища
+// без опции в хранилище с опцией, подключенное к другой базе (RedisConfig.DB):
+// в одной базе Copy увидела бы в источнике уже скопированные записи с префиксами.
+func WithNamespaces(enabled bool) Option {
+	return func(o *options) {
+		o.namespaces = enabled
+	}
+}
+
+// WithScanCount задает подсказку COUNT для команд SCAN, на которых построены
+// DeletePattern, CountPattern, GetByPrefix и QueueNames, а также ScanPage
+// без явного count (в том числе в Query и Copy без BatchSize). Большее значение сокращает количество
+// сетевых вызовов, но удлиняет каждый вызов: Redis обрабатывает команду целиком,
+// не выполняя другие. In-memory хранилище использует n как размер страницы
+// ScanPage по умолчанию. Значения меньше или равные 0 оставляют значение
+// по умолчанию (100).
+func WithScanCount(n int) Option {
+	return func(o *options) {
+		if n > 0 {
+			o.scanCount = n
+		}
+	}
+}
+
+// WithSlowOpThreshold вызывает onSlowOp для каждой команды Redis, выполнявшейся
+// дольше threshold: method - имя команды ("get", "evalsha"), key - имя первого ключа
+// в Redis ("" для команд без ключа), d - время выполнения вместе с сетевым вызовом.
+// Конвейеры и транзакции измеряются целиком и передаются как method "pipeline".
+// Обработчик вызывается синхронно в горутине команды и должен быстро возвращать управление.
+// NewRedisWithClient не изменяет переданный клиент и опцию игнорирует: владелец клиента
+// может добавить к нему хук NewSlowOpHook. In-memory хранилище опцию также игнорирует.
+// threshold <= 0 или nil onSlowOp выключают наблюдение (по умолчанию).
+func WithSlowOpThreshold(threshold time.Duration, onSlowOp func(method, key string, d time.Duration)) Option {
+	return func(o *options) {
+		o.slowOpThreshold = threshold
+		o.onSlowOp = onSlowOp
+	}
+}
+
+// withoutHooks отменяет хуки, зарегистрированные предыдущими опциями.
+// Используется для вложенных хранилищ, хуки которых сбрасывает внешнее.
+func withoutHooks() Option {
+	return func(o *options) {
+		o.hooks = nil
+	}
+}
+
+// jitterTTL применяет к положительному ttl случайное отклонение WithTTLJitter.
+func (o *options) jitterTTL(ttl time.Duration) time.Duration {
+	if ttl <= 0 || o.ttlJitter <= 0 {
+		return ttl
+	}
+	delta := time.Duration(rand.Int64N(int64(2*o.ttlJitter)+1)) - o.ttlJitter
+	return max(ttl+delta, time.Millisecond)
+}
+
+// marshalValue сериализует значение кодеком и проверяет ограничение WithMaxValueBytes.
+func (o *options) marshalValue(value any) ([]byte, error) {
+	data, err := o.codec.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("marshal failed: %w", err)
+	}
+	if o.maxValueBytes > 0 && len(data) > o.maxValueBytes {
+		return nil, fmt.Errorf("%w: %d bytes, limit %d", ErrValueTooLarge, len(data), o.maxValueBytes)
+	}
+	return data, nil
+}
+
+// marshalValueWith сериализует значение кодеком codec (см. marshalWithCodec)
+// и проверяет ограничение WithMaxValueBytes.
+func (o *options) marshalValueWith(codec Codec, value any) ([]byte, error) {
+	data, err := marshalWithCodec(o.codec, codec, value)
+	if err != nil {
+		return nil, fmt.Errorf("marshal failed: %w", err)
+	}
+	if o.maxValueBytes > 0 && len(data) > o.maxValueBytes {
+		return nil, fmt.Errorf("%w: %d bytes, limit %d", ErrValueTooLarge, len(data), o.maxValueBytes)
+	}
+	return data, nil
+}
+
+// checkValueSize проверяет ограничение WithMaxValueBytes для значения, которое
+// хранится без сериализации. Без ограничения ничего не делает.
+func (o *options) checkValueSize(value any) error {
+	if o.maxValueBytes <= 0 {
+		return nil
+	}
+	_, err := o.marshalValue(value)
+	return err
+}
+
+// normalizeKey применяет нормализатор ключа, если он задан.
+func (o *options) normalizeKey(key string) string {
+	if o.keyNormalizer != nil {
+		return o.keyNormalizer(key)
+	}
+	return key
+}
+
+// checkKey нормализует ключ и проверяет его валидатором.
+// Возвращает ключ, под которым запись хранится, или ошибку, помеченную ErrInvalidKey.
+func (o *options) checkKey(key string) (string, error) {
+	key, _, err := o.resolveKey(key)
+	return key, err
+}
+
+// resolveKey нормализует ключ, проверяет его и заменяет хешем, если ключ длиннее
+// порога WithHashLongKeys. Второе значение - исходный (нормализованный) ключ,
+// если он был заменен хешем, иначе пустая строка.
+func (o *options) resolveKey(key string) (string, string, error) {
+	key = o.normalizeKey(key)
+	if o.keyValidator != nil {
+		if err := o.keyValidator(key); err != nil {
+			return key, "", fmt.Errorf("%w %q: %w", ErrInvalidKey, key, err)
+		}
+	}
+	if o.hashLongKeys > 0 && len(key) > o.hashLongKeys {
+		return longKeyHash(key), key, nil
+	}
+	return key, "", nil
+}
+
+// hashedKeyPrefix - префикс ключей, замененных хешем.
+const hashedKeyPrefix = "sha256:"
+
+// hashedKeyLen - длина ключа, замененного хешем.
+const hashedKeyLen = len(hashedKeyPrefix) + 2*sha256.Size
+
+// longKeyHash возвращает ключ фиксированной длины hashedKeyLen из SHA-256 исходного ключа.
+func longKeyHash(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hashedKeyPrefix + hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,121 @@
+package storage
+
+import "strings"
+
+// escapePattern экранирует специальные символы glob-шаблона,
+// чтобы строка сопоставлялась буквально (например, как префикс в SCAN MATCH).
+func escapePattern(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '*', '?', '[', ']', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// matchPattern проверяет, соответствует ли ключ glob-шаблону в стиле Redis.
+// Поддерживаются:
+//   - * - любая последовательность символов (включая пустую);
+//   - ? - ровно один символ;
+//   - [abc], [a-z], [^a] - класс символов и его отрицание;
+//   - \x - экранирование специального символа.
+func matchPattern(pattern, key string) bool {
+	p, k := []rune(pattern), []rune(key)
+	return matchRunes(p, k)
+}
+
+// matchRunes рекурсивно сопоставляет шаблон и строку.
+func matchRunes(p, k []rune) bool {
+	for len(p) > 0 {
+		switch p[0] {
+		case '*':
+			for len(p) > 1 && p[1] == '*' {
+				p = p[1:] // Несколько звездочек подряд эквивалентны одной
+			}
+			if len(p) == 1 {
+				return true
+			}
+			for i := 0; i <= len(k); i++ {
+				if matchRunes(p[1:], k[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(k) == 0 {
+				return false
+			}
+		case '[':
+			if len(k) == 0 {
+				return false
+			}
+			matched, rest, ok := matchClass(p[1:], k[0])
+			if !ok {
+				// Незакрытый класс сравнивается как обычный символ
+				if k[0] != '[' {
+					return false
+				}
+			} else {
+				if !matched {
+					return false
+				}
+				p = rest
+				k = k[1:]
+				continue
+			}
+		case '\\':
+			if len(p) > 1 {
+				p = p[1:]
+			}
+			fallthrough
+		default:
+			if len(k) == 0 || p[0] != k[0] {
+				return false
+			}
+		}
+		p = p[1:]
+		k = k[1:]
+	}
+	return len(k) == 0
+}
+
+// matchClass сопоставляет символ с классом вида [...] (p начинается после '[').
+// Возвращает результат сопоставления, остаток шаблона после ']'
+// и признак того, что класс корректно закрыт.
+func matchClass(p []rune, c rune) (bool, []rune, bool) {
+	negate := false
+	if len(p) > 0 && p[0] == '^' {
+		negate = true
+		p = p[1:]
+	}
+
+	matched := false
+	for i := 0; i < len(p); i++ {
+		switch {
+		case p[i] == ']':
+			return matched != negate, p[i+1:], true
+		case p[i] == '\\' && i+1 < len(p):
+			i++
+			if p[i] == c {
+				matched = true
+			}
+		case i+2 < len(p) && p[i+1] == '-' && p[i+2] != ']':
+			lo, hi := p[i], p[i+2]
+			if lo > hi {
+				lo, hi = hi, lo
+			}
+			if c >= lo && c <= hi {
+				matched = true
+			}
+			i += 2
+		default:
+			if p[i] == c {
+				matched = true
+			}
+		}
+	}
+	return false, nil, false
+}
@@ -0,0 +1,44 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchPattern(t *testing.T) {
+	cases := []struct {
+		pattern, key string
+		want         bool
+	}{
+		{"*", "", true},
+		{"*", "anything", true},
+		{"tenant:42:*", "tenant:42:user:1", true},
+		{"tenant:42:*", "tenant:420:user:1", false},
+		{"h?llo", "hello", true},
+		{"h?llo", "hllo", false},
+		{"h[ae]llo", "hallo", true},
+		{"h[ae]llo", "hillo", false},
+		{"h[^e]llo", "hallo", true},
+		{"h[^e]llo", "hello", false},
+		{"h[a-c]llo", "hbllo", true},
+		{"h[a-c]llo", "hdllo", false},
+		{`h\*llo`, "h*llo", true},
+		{`h\*llo`, "hello", false},
+		{"a/*/c", "a/b/c", true},
+		{"[unclosed", "[unclosed", true},
+	}
+
+	for _, c := range cases {
+		require.Equal(t, c.want, matchPattern(c.pattern, c.key), "pattern %q key %q", c.pattern, c.key)
+	}
+}
+
+func TestEscapePattern(t *testing.T) {
+	for _, prefix := range []string{"cfg:", "a*b", "q?[x]", `back\slash`} {
+		pattern := escapePattern(prefix) + "*"
+		require.True(t, matchPattern(pattern, prefix+"tail"), "prefix %q", prefix)
+		require.False(t, matchPattern(pattern, "x"+prefix), "prefix %q", prefix)
+	}
+	require.False(t, matchPattern(escapePattern("a*b")+"*", "axxb"))
+}
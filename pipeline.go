@@ -0,0 +1,195 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Pipeline накапливает команды и выполняет их одним пакетом.
+// Команды выполняются в порядке добавления при вызове Exec.
+// Pipeline не предназначен для использования из нескольких горутин одновременно.
+type Pipeline[T any] interface {
+	// Set добавляет команду сохранения значения с заданным TTL (0 - бессрочно)
+	Set(key string, value T, ttl time.Duration)
+
+	// Get добавляет команду получения значения по ключу
+	Get(key string)
+
+	// Delete добавляет команду удаления значения по ключу
+	Delete(key string)
+
+	// Enqueue добавляет команду добавления элемента в конец очереди
+	Enqueue(queueName string, value T)
+
+	// Exec выполняет накопленные команды за один сетевой вызов и очищает пакет
+	// ctx - контекст для управления временем выполнения
+	// Возвращает:
+	//   - результаты команд в порядке их добавления
+	//   - первую ошибку среди команд (если возникла)
+	Exec(ctx context.Context) ([]PipelineResult[T], error)
+}
+
+// PipelineResult содержит результат одной команды пакета
+type PipelineResult[T any] struct {
+	Value T             // Полученное значение (только для Get)
+	Found bool          // Флаг наличия значения (только для Get)
+	TTL   time.Duration // Оставшееся время жизни найденного значения (только для Get, 0 - бессрочно)
+	Err   error         // Ошибка выполнения команды
+}
+
+// pipelineOpKind определяет тип команды пакета.
+type pipelineOpKind int
+
+const (
+	pipelineSet pipelineOpKind = iota
+	pipelineGet
+	pipelineDelete
+	pipelineEnqueue
+)
+
+// pipelineOp описывает одну накопленную команду пакета.
+type pipelineOp[T any] struct {
+	kind  pipelineOpKind // Тип команды
+	key   string         // Ключ или имя очереди
+	value T              // Значение (для Set и Enqueue)
+	ttl   time.Duration  // Время жизни (для Set)
+}
+
+// pipelineOps накапливает команды пакета.
+// Встраивается в реализации Pipeline, которым остается реализовать Exec.
+type pipelineOps[T any] struct {
+	ops []pipelineOp[T]
+}
+
+// Set добавляет команду сохранения значения.
+func (p *pipelineOps[T]) Set(key string, value T, ttl time.Duration) {
+	p.ops = append(p.ops, pipelineOp[T]{kind: pipelineSet, key: key, value: value, ttl: ttl})
+}
+
+// Get добавляет команду получения значения.
+func (p *pipelineOps[T]) Get(key string) {
+	p.ops = append(p.ops, pipelineOp[T]{kind: pipelineGet, key: key})
+}
+
+// Delete добавляет команду удаления значения.
+func (p *pipelineOps[T]) Delete(key string) {
+	p.ops = append(p.ops, pipelineOp[T]{kind: pipelineDelete, key: key})
+}
+
+// Enqueue добавляет команду добавления элемента в очередь.
+func (p *pipelineOps[T]) Enqueue(queueName string, value T) {
+	p.ops = append(p.ops, pipelineOp[T]{kind: pipelineEnqueue, key: queueName, value: value})
+}
+
+// take возвращает накопленные команды и очищает пакет.
+func (p *pipelineOps[T]) take() []pipelineOp[T] {
+	ops := p.ops
+	p.ops = nil
+	return ops
+}
+
+// apply добавляет команду в другой пакет.
+// Используется обертками, которые передают команды вложенному хранилищу.
+func (op pipelineOp[T]) apply(pipe Pipeline[T]) {
+	switch op.kind {
+	case pipelineSet:
+		pipe.Set(op.key, op.value, op.ttl)
+	case pipelineGet:
+		pipe.Get(op.key)
+	case pipelineDelete:
+		pipe.Delete(op.key)
+	case pipelineEnqueue:
+		pipe.Enqueue(op.key, op.value)
+	}
+}
+
+// firstErr возвращает первую ошибку среди результатов команд.
+func firstErr[T any](results []PipelineResult[T]) error {
+	for _, r := range results {
+		if r.Err != nil {
+			return r.Err
+		}
+	}
+	return nil
+}
+
+// GetManyOrDefault получает значения ключей одним пакетом Pipeline и возвращает
+// карту, в которой есть каждый запрошенный ключ: найденные ключи сопоставлены
+// своим значениям, отсутствующие и истекшие - значению def.
+// Повторяющиеся ключи запрашиваются один раз.
+// Если чтение какого-либо ключа завершилось ошибкой, возвращает nil и первую ошибку.
+func GetManyOrDefault[T any](ctx context.Context, s Storage[T], keys []string, def T) (map[string]T, error) {
+	values := make(map[string]T, len(keys))
+	pipe := s.Pipeline()
+	var unique []string
+	for _, key := range keys {
+		if _, ok := values[key]; ok {
+			continue
+		}
+		values[key] = def
+		unique = append(unique, key)
+		pipe.Get(key)
+	}
+	if len(unique) == 0 {
+		return values, nil
+	}
+
+	results, err := pipe.Exec(ctx)
+	if len(results) != len(unique) {
+		return nil, err // Пакет не выполнен целиком
+	}
+	for i, r := range results {
+		if r.Err != nil {
+			return nil, fmt.Errorf("get %q: %w", unique[i], r.Err)
+		}
+		if r.Found {
+			values[unique[i]] = r.Value
+		}
+	}
+	return values, nil
+}
+
+// warmBatchSize - количество записей в одном пакете Warm.
+const warmBatchSize = 500
+
+// Warm загружает записи из entries пакетами Pipeline по warmBatchSize команд Set
+// с временем жизни ttl (0 - бессрочно), например чтобы заполнить кэш при запуске.
+// Отмена ctx проверяется перед каждым пакетом: уже записанные пакеты остаются в хранилище,
+// а перебор entries прекращается. entries совместим с iter.Seq2[string, T].
+// Возвращает ошибку отмены ctx или первую ошибку записи; перебор прекращается на ней.
+func Warm[T any](ctx context.Context, s Storage[T], entries func(yield func(key string, value T) bool), ttl time.Duration) error {
+	pipe := s.Pipeline()
+	keys := make([]string, 0, warmBatchSize)
+
+	flush := func() error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		results, err := pipe.Exec(ctx)
+		if len(results) != len(keys) {
+			return err // Пакет не выполнен целиком
+		}
+		for i, r := range results {
+			if r.Err != nil {
+				return fmt.Errorf("set %q: %w", keys[i], r.Err)
+			}
+		}
+		keys = keys[:0]
+		return nil
+	}
+
+	var err error
+	entries(func(key string, value T) bool {
+		pipe.Set(key, value, ttl)
+		keys = append(keys, key)
+		if len(keys) == warmBatchSize {
+			err = flush()
+		}
+		return err == nil
+	})
+	if err != nil || len(keys) == 0 {
+		return err
+	}
+	return flush()
+}
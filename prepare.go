@@ -0,0 +1,110 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Отложенные элементы и резервирования очереди (EnqueueDelayed, PeekBatchReserve)
+// могли оставить другие процессы или этот процесс до перезапуска. Скрипты извлечения
+// из очереди начинаются с queuePrepareLua и подготавливают очередь атомарно
+// с извлечением, не добавляя обращений к Redis. Операции чтения без извлечения
+// (Peek, QueueTail и т.д.) очередь не подготавливают, чтобы не писать на основной сервер
+// и читать с реплики: готовые элементы переносит фоновая горутина (см. runMaintenance)
+// не позже чем через maintenanceInterval.
+
+// queuePrepareLua переносит в конец очереди готовые отложенные элементы и возвращает {3},
+// если у очереди есть истекшие резервирования: их элементы должны вернуться в начало
+// очереди до извлечения, а ключи их списков скрипт не может объявить (см. runPrepared).
+// Во всех таких скриптах KEYS[1] - очередь, KEYS[2] - множество отложенных элементов,
+// KEYS[3] - множество резервирований, KEYS[4] - метка разобранной очереди, которую скрипт
+// записывает вместе с удалением элементов (см. queuePrepareKeys), а now - время Redis
+// в миллисекундах.
+const queuePrepareLua = `
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local due = redis.call('ZRANGEBYSCORE', KEYS[2], '-inf', now)
+for _, member in ipairs(due) do
+	redis.call('RPUSH', KEYS[1], string.sub(member, 33))
+	redis.call('ZREM', KEYS[2], member)
+end
+if #redis.call('ZRANGEBYSCORE', KEYS[3], '-inf', now, 'LIMIT', 0, 1) > 0 then
+	return {3}
+end
+`
+
+// prepareQueueScript подготавливает очередь для операций, которые извлекают элементы
+// не скриптом (см. prepareQueue), и записывает метку разобранной очереди, если ARGV[1] = 1.
+// Возвращает {0} или {3}.
+var prepareQueueScript = redis.NewScript(queuePrepareLua + `
+if ARGV[1] == '1' then
+	redis.call('SET', KEYS[4], 1)
+end
+return {0}
+`)
+
+// dequeueScript извлекает элемент из начала подготовленной очереди. Если передан тег
+// очереди (KEYS[5], только с WithQueueTypeCheck), элемент извлекается при совпадении тега
+// с ожидаемым (пустой ARGV[1] - без проверки), а опустевшая очередь теряет тег.
+// Возвращает {1, элемент}, {0} для пустой очереди или {2, тег очереди} при несовпадении.
+var dequeueScript = redis.NewScript(queuePrepareLua + `
+local tag = KEYS[5] and redis.call('GET', KEYS[5])
+if tag and ARGV[1] ~= '' and tag ~= ARGV[1] then
+	return {2, tag}
+end
+local v = redis.call('LPOP', KEYS[1])
+if KEYS[5] and (not v or redis.call('LLEN', KEYS[1]) == 0) then
+	redis.call('DEL', KEYS[5])
+end
+if not v then
+	return {0}
+end
+redis.call('SET', KEYS[4], 1)
+return {1, v}
+`)
+
+// queuePrepareKeys возвращает ключи очереди для скриптов с queuePrepareLua, дополненные extra.
+func (s *redisStorage[T]) queuePrepareKeys(queueName string, extra ...string) []string {
+	key := s.queueKey(queueName)
+	return append([]string{key, delayedKey(key), inflightKey(key), s.queueConsumedKey(queueName)}, extra...)
+}
+
+// watchQueues передает очередь фоновой горутине, которая возвращает истекшие
+// резервирования и переносит готовые отложенные элементы между чтениями.
+func (s *redisStorage[T]) watchQueues(queueName string) {
+	s.watchQueue(&s.reserved, queueName)
+	s.watchQueue(&s.delayed, queueName)
+}
+
+// runPrepared выполняет скрипт, начинающийся с queuePrepareLua (EVALSHA с повтором
+// через EVAL при NOSCRIPT). Если у очереди есть истекшие резервирования (ответ {3}),
+// возвращает их в начало очереди и повторяет скрипт.
+func (s *redisStorage[T]) runPrepared(ctx context.Context, op string, script *redis.Script, queueName string, keys []string, args ...any) ([]any, error) {
+	s.watchQueues(queueName)
+	for {
+		res, err := script.Run(ctx, s.client, keys, args...).Slice()
+		if err != nil {
+			return nil, wrapRedisErr(op, err)
+		}
+		if code, _ := res[0].(int64); code != 3 {
+			return res, nil
+		}
+		if err := s.requeueExpired(ctx, queueName); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// prepareQueue подготавливает очередь к извлечению одним скриптом prepareQueueScript
+// для операций, которые не могут подготовить ее в своем скрипте (транзакции MULTI/EXEC,
+// BLPOP). Если consume задан, очередь сразу помечается разобранной: BLPOP не может
+// записать метку атомарно с извлечением, и метка записывается до него.
+func (s *redisStorage[T]) prepareQueue(ctx context.Context, queueName string, consume bool) error {
+	var flag int
+	if consume {
+		flag = 1
+	}
+	_, err := s.runPrepared(ctx, "prepare queue", prepareQueueScript, queueName, s.queuePrepareKeys(queueName), flag)
+	return err
+}
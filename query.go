@@ -0,0 +1,145 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidPath возвращается Query при синтаксической ошибке в JSON-пути.
+var ErrInvalidPath = errors.New("storage: invalid json path")
+
+// Query возвращает ключи, соответствующие glob-шаблону pattern, значение которых
+// по пути jsonPath равно equals. Значения читаются через GetRaw и разбираются как JSON
+// без знания типа T, поэтому запрос работает только с JSONCodec; записи, которые
+// не являются JSON или не содержат путь, пропускаются.
+//
+// Путь записывается в точечной нотации: "$.user.address.city", "$.items[0].id"
+// ("$" и начальную точку можно опустить). equals сравнивается после преобразования
+// в JSON и обратно, поэтому числа сравниваются как float64, а структуры - по полям JSON.
+//
+// Query перебирает все ключи шаблона через ScanPage и читает каждое значение
+// отдельным запросом: время работы и нагрузка на Redis растут линейно с количеством
+// ключей. Используйте как можно более узкий шаблон и не вызывайте Query на горячем пути;
+// функция предназначена для диагностики и административных инструментов.
+func Query[T any](ctx context.Context, s Storage[T], pattern, jsonPath string, equals any) ([]string, error) {
+	path, err := parseJSONPath(jsonPath)
+	if err != nil {
+		return nil, err
+	}
+	want, err := jsonNormalize(equals)
+	if err != nil {
+		return nil, fmt.Errorf("query: marshal target: %w", err)
+	}
+
+	var matched []string
+	var cursor uint64
+	for {
+		keys, next, err := s.ScanPage(ctx, cursor, pattern, 0)
+		if err != nil {
+			return matched, fmt.Errorf("query: scan: %w", err)
+		}
+
+		for _, key := range keys {
+			if err := ctx.Err(); err != nil {
+				return matched, err
+			}
+			raw, found, err := s.GetRaw(ctx, key)
+			if err != nil {
+				return matched, fmt.Errorf("query key %q: %w", key, err)
+			}
+			if !found {
+				continue // Запись удалена или истекла во время обхода
+			}
+			var doc any
+			if json.Unmarshal(raw, &doc) != nil {
+				continue
+			}
+			if got, ok := lookupJSONPath(doc, path); ok && reflect.DeepEqual(got, want) {
+				matched = append(matched, key)
+			}
+		}
+
+		if cursor = next; cursor == 0 {
+			return matched, nil
+		}
+	}
+}
+
+// parseJSONPath разбирает путь в последовательность имен полей (string)
+// и индексов массивов (int).
+func parseJSONPath(path string) ([]any, error) {
+	p := strings.TrimPrefix(path, "$")
+	if p != "" && p[0] != '.' && p[0] != '[' {
+		p = "." + p
+	}
+
+	var segments []any
+	for p != "" {
+		switch p[0] {
+		case '.':
+			p = p[1:]
+			end := strings.IndexAny(p, ".[")
+			if end < 0 {
+				end = len(p)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("%w %q: empty field name", ErrInvalidPath, path)
+			}
+			segments = append(segments, p[:end])
+			p = p[end:]
+		case '[':
+			end := strings.IndexByte(p, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("%w %q: unclosed bracket", ErrInvalidPath, path)
+			}
+			index, err := strconv.Atoi(p[1:end])
+			if err != nil || index < 0 {
+				return nil, fmt.Errorf("%w %q: bad index %q", ErrInvalidPath, path, p[1:end])
+			}
+			segments = append(segments, index)
+			p = p[end+1:]
+		default:
+			return nil, fmt.Errorf("%w %q: unexpected %q", ErrInvalidPath, path, p[0])
+		}
+	}
+	return segments, nil
+}
+
+// lookupJSONPath возвращает значение разобранного JSON-документа по пути.
+func lookupJSONPath(doc any, path []any) (any, bool) {
+	for _, segment := range path {
+		switch key := segment.(type) {
+		case string:
+			obj, ok := doc.(map[string]any)
+			if !ok {
+				return nil, false
+			}
+			if doc, ok = obj[key]; !ok {
+				return nil, false
+			}
+		case int:
+			arr, ok := doc.([]any)
+			if !ok || key >= len(arr) {
+				return nil, false
+			}
+			doc = arr[key]
+		}
+	}
+	return doc, true
+}
+
+// jsonNormalize приводит значение к виду, который дает json.Unmarshal в any.
+func jsonNormalize(v any) (any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var out any
+	err = json.Unmarshal(data, &out)
+	return out, err
+}
@@ -0,0 +1,57 @@
+package storage_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alfzs/go-storage"
+	"github.com/stretchr/testify/require"
+)
+
+type account struct {
+	Name    string   `json:"name"`
+	Address address  `json:"address"`
+	Tags    []string `json:"tags"`
+	Age     int      `json:"age"`
+}
+
+type address struct {
+	City string `json:"city"`
+}
+
+func TestQuery(t *testing.T) {
+	s, _ := storage.NewMemory[account](time.Hour)
+	defer s.Close()
+	ctx := context.Background()
+
+	require.NoError(t, s.Set(ctx, "user:1", account{Name: "ann", Address: address{City: "Kazan"}, Tags: []string{"vip"}, Age: 30}, 0))
+	require.NoError(t, s.Set(ctx, "user:2", account{Name: "bob", Address: address{City: "Omsk"}, Age: 30}, 0))
+	require.NoError(t, s.Set(ctx, "user:3", account{Name: "eve", Address: address{City: "Kazan"}, Age: 41}, 0))
+	require.NoError(t, s.Set(ctx, "admin:1", account{Address: address{City: "Kazan"}}, 0))
+
+	keys, err := storage.Query(ctx, s, "user:*", "$.address.city", "Kazan")
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"user:1", "user:3"}, keys)
+
+	keys, err = storage.Query(ctx, s, "user:*", "age", 30)
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"user:1", "user:2"}, keys)
+
+	keys, err = storage.Query(ctx, s, "*", "$.tags[0]", "vip")
+	require.NoError(t, err)
+	require.Equal(t, []string{"user:1"}, keys)
+
+	keys, err = storage.Query(ctx, s, "*", "$.address", address{City: "Omsk"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"user:2"}, keys)
+
+	keys, err = storage.Query(ctx, s, "*", "$.missing.field", "x")
+	require.NoError(t, err)
+	require.Empty(t, keys)
+
+	_, err = storage.Query(ctx, s, "*", "$.tags[x]", "vip")
+	require.ErrorIs(t, err, storage.ErrInvalidPath)
+	_, err = storage.Query(ctx, s, "*", "$..name", "ann")
+	require.ErrorIs(t, err, storage.ErrInvalidPath)
+}
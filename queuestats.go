@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// QueueStats содержит длину очереди и скорость ее пополнения и разбора.
+// Скорости измеряются в текущем процессе и учитывают только операции,
+// выполненные через это хранилище.
+type QueueStats struct {
+	Length      int64         // Текущее количество элементов в очереди
+	EnqueueRate float64       // Добавлений в секунду за окно Window
+	DequeueRate float64       // Извлечений в секунду за окно Window
+	Window      time.Duration // Скользящее окно, за которое измерены скорости
+}
+
+// queueRateBuckets - количество секундных интервалов скользящего окна.
+const queueRateBuckets = 60
+
+// queueRateWindow - скользящее окно измерения скоростей очередей.
+const queueRateWindow = queueRateBuckets * time.Second
+
+// queueRates учитывает добавления и извлечения элементов по очередям.
+// Нулевое значение готово к использованию.
+type queueRates struct {
+	windows sync.Map // Имя очереди -> *rateWindow
+}
+
+// rateWindow - кольцевой буфер счетчиков по секундам.
+type rateWindow struct {
+	buckets [queueRateBuckets]rateBucket
+}
+
+// rateBucket - счетчики операций за одну секунду.
+type rateBucket struct {
+	sec      atomic.Int64 // Unix-время секунды, к которой относятся счетчики
+	enqueued atomic.Int64 // Количество добавленных элементов
+	dequeued atomic.Int64 // Количество извлеченных элементов
+}
+
+// window возвращает окно очереди, создавая его при первом обращении.
+func (r *queueRates) window(queueName string) *rateWindow {
+	if w, ok := r.windows.Load(queueName); ok {
+		return w.(*rateWindow)
+	}
+	w, _ := r.windows.LoadOrStore(queueName, &rateWindow{})
+	return w.(*rateWindow)
+}
+
+// enqueued учитывает добавление n элементов в очередь.
+func (r *queueRates) enqueued(queueName string, n int) {
+	if n > 0 {
+		r.window(queueName).bucket(time.Now().Unix()).enqueued.Add(int64(n))
+	}
+}
+
+// dequeued учитывает извлечение n элементов из очереди.
+func (r *queueRates) dequeued(queueName string, n int) {
+	if n > 0 {
+		r.window(queueName).bucket(time.Now().Unix()).dequeued.Add(int64(n))
+	}
+}
+
+// stats возвращает статистику очереди с заданной длиной.
+func (r *queueRates) stats(queueName string, length int64) QueueStats {
+	stats := QueueStats{Length: length, Window: queueRateWindow}
+	v, ok := r.windows.Load(queueName)
+	if !ok {
+		return stats
+	}
+
+	w := v.(*rateWindow)
+	now := time.Now().Unix()
+	var enqueued, dequeued int64
+	for i := range w.buckets {
+		b := &w.buckets[i]
+		if sec := b.sec.Load(); sec > now-queueRateBuckets && sec <= now {
+			enqueued += b.enqueued.Load()
+			dequeued += b.dequeued.Load()
+		}
+	}
+	stats.EnqueueRate = float64(enqueued) / queueRateWindow.Seconds()
+	stats.DequeueRate = float64(dequeued) / queueRateWindow.Seconds()
+	return stats
+}
+
+// bucket возвращает счетчики секунды sec, обнуляя устаревшие.
+// Обнуление не согласовано с конкурентными инкрементами, поэтому на границе
+// секунды несколько операций могут не попасть в счетчики: скорости приблизительные.
+func (w *rateWindow) bucket(sec int64) *rateBucket {
+	b := &w.buckets[sec%queueRateBuckets]
+	if old := b.sec.Load(); old != sec && b.sec.CompareAndSwap(old, sec) {
+		b.enqueued.Store(0)
+		b.dequeued.Store(0)
+	}
+	return b
+}
@@ -0,0 +1,35 @@
+package storage
+
+import "github.com/redis/go-redis/v9"
+
+// Счетчики фиксированного окна IncrWindow хранятся в Redis целым числом в строке
+// с временем жизни окна, в памяти - в отдельной от записей карте.
+// Окно начинается с первого увеличения и заканчивается через window,
+// после чего счетчик начинается заново.
+
+// incrWindowScript увеличивает счетчик и при первом увеличении в окне выставляет
+// ему время жизни окна. Ключ без времени жизни (например, записанный в обход скрипта)
+// тоже получает его, чтобы счетчик не рос бесконечно.
+// KEYS[1] - счетчик; ARGV[1] - длительность окна в миллисекундах.
+var incrWindowScript = redis.NewScript(`
+local n = redis.call('INCR', KEYS[1])
+if n == 1 or redis.call('PTTL', KEYS[1]) == -1 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+return n
+`)
+
+// windowCounter - счетчик окна in-memory хранилища.
+type windowCounter struct {
+	count      int64
+	expiration int64 // Конец окна в наносекундах
+}
+
+// sweepWindows удаляет счетчики окон, закончившихся к моменту now.
+func sweepWindows(windows map[string]windowCounter, now int64) {
+	for key, w := range windows {
+		if now > w.expiration {
+			delete(windows, key)
+		}
+	}
+}
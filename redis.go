@@ -3,77 +3,348 @@ package storage
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 )
 
+// scanCount - подсказка COUNT для команд SCAN по умолчанию (см. WithScanCount)
+// и размер страницы LRANGE при поиске в очереди.
+const scanCount = 100
+
+// maintenanceInterval - период фонового обслуживания очередей в Redis:
+// возврата истекших резервирований и переноса готовых отложенных элементов.
+const maintenanceInterval = 1 * time.Second
+
+const (
+	defaultConnectBackoff = 100 * time.Millisecond // Начальная задержка между попытками подключения
+	maxConnectBackoff     = 5 * time.Second        // Максимальная задержка между попытками подключения
+)
+
 // redisStorage представляет реализацию хранилища данных на основе Redis.
 // Это обобщенная структура, которая может работать с любым типом данных T.
 type redisStorage[T any] struct {
-	client *redis.Client // Клиент Redis для выполнения операций
+	client redis.UniversalClient // Клиент Redis для выполнения операций
+	opts   options               // Дополнительные параметры хранилища
+	cache  *clientCache[T]       // Кэш на стороне клиента (nil, если выключен)
+	hot    *hotKeyTracker        // Трекер частых ключей (nil, если выключен)
+	owned  bool                  // Закрывать client в Close (false, если клиент передан извне)
+
+	reserved     sync.Map      // Очереди, которые этот процесс читал (возврат истекших резервирований)
+	delayed      sync.Map      // Очереди, которые этот процесс читал или в которые добавлял отложенные элементы
+	maintainOnce sync.Once     // Однократный запуск фонового обслуживания очередей
+	stop         chan struct{} // Канал для остановки фоновых горутин
+	closing      atomic.Bool   // Close уже вызывался
+	rates        queueRates    // Скорости очередей, измеряемые этим процессом
+
+	replicas    []*redis.Client // Клиенты реплик для команд чтения (пусто - читать с client)
+	nextReplica atomic.Uint64   // Счетчик для выбора реплики по кругу
 }
 
 // newRedisStorage создает новый экземпляр Redis-хранилища.
-// Принимает конфигурацию RedisConfig и возвращает интерфейс Storage[T].
+// Принимает конфигурацию RedisConfig и дополнительные опции, возвращает интерфейс Storage[T].
 // Выполняет проверку соединения с Redis через команду PING.
-func newRedisStorage[T any](cfg RedisConfig) (Storage[T], error) {
-	client := redis.NewClient(&redis.Options{
+func newRedisStorage[T any](cfg RedisConfig, opts []Option) (Storage[T], error) {
+	s := &redisStorage[T]{opts: defaultOptions(opts), stop: make(chan struct{})}
+	s.hot = newHotKeyTracker(s.opts.hotKeys)
+
+	clientOpts := &redis.Options{
 		Addr:     cfg.Addr,     // Адрес Redis сервера
 		Username: cfg.Username, // Имя пользователя
 		Password: cfg.Password, // Пароль (если требуется)
 		DB:       cfg.DB,       // Номер базы данных
-	})
+
+		// Ротируемые учетные данные (имеют приоритет над Username/Password)
+		CredentialsProviderContext: cfg.CredentialsProvider,
+	}
+
+	// Попытки подключения повторяются до общего для всех этапов срока
+	deadline := time.Now().Add(cfg.ConnectMaxWait)
+
+	// Кэш на стороне клиента: каждое соединение пула включает CLIENT TRACKING
+	if cfg.ClientCacheTTL > 0 {
+		err := retryConnect(deadline, cfg.ConnectBackoff, func() error {
+			cache, err := newClientCache[T](clientOpts, cfg.ClientCacheTTL, s.opts.logger)
+			s.cache = cache
+			return err
+		})
+		if err != nil {
+			return nil, fmt.Errorf("redis client tracking failed: %w", err)
+		}
+		clientOpts.OnConnect = s.cache.enableTracking
+	}
+	clientOpts.OnConnect = connectHook(cfg.Addr, cfg.OnConnect, clientOpts.OnConnect)
+
+	client := redis.NewClient(clientOpts)
+	if hook := newSlowOpHook(s.opts); hook != nil {
+		client.AddHook(hook)
+	}
+	s.client = client
+	s.owned = true
 	ctx := context.Background()
 
 	// Проверяем соединение с Redis
-	if err := client.Ping(ctx).Err(); err != nil {
+	err := retryConnect(deadline, cfg.ConnectBackoff, func() error {
+		return s.client.Ping(ctx).Err()
+	})
+	if err != nil {
+		_ = s.Close()
+		return nil, fmt.Errorf("redis ping failed: %w", err)
+	}
+
+	// Реплики используют те же параметры, но без CLIENT TRACKING
+	for _, addr := range cfg.ReplicaAddrs {
+		replicaOpts := *clientOpts
+		replicaOpts.Addr = addr
+		replicaOpts.OnConnect = connectHook(addr, cfg.OnConnect, nil)
+		replica := redis.NewClient(&replicaOpts)
+		if hook := newSlowOpHook(s.opts); hook != nil {
+			replica.AddHook(hook)
+		}
+		s.replicas = append(s.replicas, replica)
+
+		err := retryConnect(deadline, cfg.ConnectBackoff, func() error {
+			return replica.Ping(ctx).Err()
+		})
+		if err != nil {
+			_ = s.Close()
+			return nil, fmt.Errorf("redis replica %s ping failed: %w", addr, err)
+		}
+	}
+
+	if cfg.OnDisconnect != nil {
+		interval := cfg.HealthCheckInterval
+		if interval <= 0 {
+			interval = defaultHealthCheckInterval
+		}
+		targets := []*healthTarget{{addr: cfg.Addr, client: s.client}}
+		for i, replica := range s.replicas {
+			targets = append(targets, &healthTarget{addr: cfg.ReplicaAddrs[i], client: replica})
+		}
+		go s.watchHealth(interval, cfg.OnDisconnect, targets)
+	}
+
+	return s, nil
+}
+
+// reader возвращает клиент для команды чтения: очередную реплику
+// из RedisConfig.ReplicaAddrs или основной клиент, если реплик нет.
+func (s *redisStorage[T]) reader() redis.Cmdable {
+	if len(s.replicas) == 0 {
+		return s.client
+	}
+	return s.replicas[(s.nextReplica.Add(1)-1)%uint64(len(s.replicas))]
+}
+
+// WithDB создает хранилище поверх нового клиента с теми же параметрами подключения,
+// базой db и пулом из одного соединения: go-redis выполняет SELECT при его установке.
+// Кэш на стороне клиента, реплики и проверка соединения в производном хранилище не используются.
+func (s *redisStorage[T]) WithDB(db int) (Storage[T], error) {
+	base, ok := s.client.(*redis.Client)
+	if !ok {
+		return nil, fmt.Errorf("%w: WithDB requires a single-node client", ErrUnsupported)
+	}
+
+	clientOpts := *base.Options()
+	clientOpts.DB = db
+	clientOpts.PoolSize = 1     // Закрепленное соединение
+	clientOpts.OnConnect = nil  // Без CLIENT TRACKING основного клиента
+	clientOpts.MinIdleConns = 0 // Соединение устанавливается при первой команде
+	client := redis.NewClient(&clientOpts)
+	if hook := newSlowOpHook(s.opts); hook != nil {
+		client.AddHook(hook)
+	}
+
+	derived := &redisStorage[T]{client: client, opts: s.opts, owned: true, stop: make(chan struct{})}
+	derived.hot = newHotKeyTracker(s.opts.hotKeys)
+	derived.opts.hooks = nil // Хуки сбрасывает основное хранилище
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	if err := client.Ping(ctx).Err(); err != nil { // Неверный номер базы отклоняется SELECT
+		_ = client.Close()
+		return nil, fmt.Errorf("redis select db %d failed: %w", db, err)
+	}
+	return derived, nil
+}
+
+// newRedisStorageWithClient создает Redis-хранилище поверх готового клиента.
+// Клиент не закрывается в Close: его жизненным циклом управляет вызывающий код.
+// Выполняет проверку соединения через команду PING.
+func newRedisStorageWithClient[T any](client redis.UniversalClient, opts []Option) (Storage[T], error) {
+	if client == nil {
+		return nil, errors.New("redis: nil client")
+	}
+	s := &redisStorage[T]{client: client, opts: defaultOptions(opts), stop: make(chan struct{})}
+	s.hot = newHotKeyTracker(s.opts.hotKeys)
+	// Хук WithSlowOpThreshold не добавляется: клиент принадлежит вызывающему коду,
+	// а go-redis не позволяет удалить хук (см. NewSlowOpHook)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	if err := s.client.Ping(ctx).Err(); err != nil {
 		return nil, fmt.Errorf("redis ping failed: %w", err)
 	}
+	return s, nil
+}
+
+// retryConnect выполняет попытку подключения, повторяя ее при ошибке
+// с экспоненциально растущей задержкой, пока не наступит deadline.
+// Если deadline уже прошел, выполняется ровно одна попытка.
+// Возвращает ошибку последней попытки.
+func retryConnect(deadline time.Time, backoff time.Duration, attempt func() error) error {
+	if backoff <= 0 {
+		backoff = defaultConnectBackoff
+	}
+
+	for {
+		err := attempt()
+		if err == nil || time.Now().Add(backoff).After(deadline) {
+			return err
+		}
 
-	return &redisStorage[T]{client: client}, nil
+		time.Sleep(backoff)
+		backoff = min(backoff*2, maxConnectBackoff)
+	}
 }
 
 // Set сохраняет значение в Redis по указанному ключу.
 // Принимает контекст, ключ, значение и время жизни записи (TTL).
 // Если TTL > 0, устанавливает время жизни записи, иначе использует redis.KeepTTL.
-// Значение сериализуется в JSON перед сохранением.
+// Значение сериализуется кодеком хранилища перед сохранением.
 func (s *redisStorage[T]) Set(ctx context.Context, key string, value T, ttl time.Duration) error {
+	_, _, err := s.set(ctx, key, value, ttl)
+	return err
+}
+
+// SetX сохраняет значение как Set и возвращает примененное время истечения.
+// Время истечения вычисляется по часам клиента. Если ttl не положителен,
+// сохраненный TTL ключа запрашивается отдельной командой PTTL после записи.
+func (s *redisStorage[T]) SetX(ctx context.Context, key string, value T, ttl time.Duration) (time.Time, error) {
+	key, ttl, err := s.set(ctx, key, value, ttl)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if ttl > 0 {
+		return time.Now().Add(ttl), nil
+	}
+
 	ctx, cancel := context.WithTimeout(ctx, 1*time.Second)
 	defer cancel()
 
-	// Сериализуем значение в JSON
-	data, err := json.Marshal(value)
+	pttl, err := s.client.PTTL(ctx, key).Result()
 	if err != nil {
-		return fmt.Errorf("marshal failed: %w", err)
+		return time.Time{}, wrapRedisErr("pttl", err)
+	}
+	if pttl <= 0 {
+		return time.Time{}, nil // Ключ бессрочный
+	}
+	return time.Now().Add(pttl), nil
+}
+
+// set записывает значение и возвращает ключ, по которому оно сохранено,
+// и TTL после случайного отклонения.
+func (s *redisStorage[T]) set(ctx context.Context, key string, value T, ttl time.Duration) (string, time.Duration, error) {
+	return s.setWithCodec(ctx, key, value, ttl, nil)
+}
+
+// SetWithCodec сохраняет значение как Set, сериализуя его кодеком codec.
+// Кодек должен быть зарегистрирован в MarkedCodec хранилища (см. WithCodec):
+// запись помечается его маркером, поэтому ее читают Get и остальные операции чтения.
+func (s *redisStorage[T]) SetWithCodec(ctx context.Context, key string, value T, ttl time.Duration, codec Codec) error {
+	_, _, err := s.setWithCodec(ctx, key, value, ttl, codec)
+	return err
+}
+
+// setWithCodec записывает значение, сериализованное кодеком codec (nil - кодек хранилища).
+func (s *redisStorage[T]) setWithCodec(ctx context.Context, key string, value T, ttl time.Duration, codec Codec) (string, time.Duration, error) {
+	key, origKey, err := s.resolveKey(key)
+	if err != nil {
+		return "", 0, err
+	}
+	s.hot.record(key)
+
+	ctx, cancel := context.WithTimeout(ctx, 1*time.Second)
+	defer cancel()
+
+	// Сериализуем значение
+	data, err := s.opts.marshalValueWith(codec, value)
+	if err != nil {
+		return "", 0, err
 	}
 
+	ttl = s.opts.jitterTTL(ttl)
 	var redisErr error
-	if ttl > 0 {
-		redisErr = s.client.Set(ctx, key, data, ttl).Err()
-	} else {
+	switch {
+	case ttl > 0:
+		// Теги ключа (SetWithTags) истекают вместе с ним
+		pipe := s.client.Pipeline()
+		var set redis.Cmder
+		if s.opts.metadata {
+			set = setMetaScript.Eval(ctx, pipe, []string{key}, setMetaArgs(data, ttl, origKey)...) // EVALSHA в конвейере не повторяется при NOSCRIPT
+		} else {
+			set = pipe.Set(ctx, key, data, ttl)
+		}
+		s.expireKeyTags(ctx, pipe, key, ttl)
+		_, _ = pipe.Exec(ctx) // Ошибка записи проверяется ниже, ошибка PEXPIRE не важна
+		redisErr = set.Err()
+	case s.opts.metadata:
+		redisErr = s.setWithMeta(ctx, key, origKey, data, ttl)
+	default:
 		redisErr = s.client.Set(ctx, key, data, redis.KeepTTL).Err()
 	}
 
 	if redisErr != nil {
-		return fmt.Errorf("redis set failed: %w", redisErr)
+		return "", 0, fmt.Errorf("redis set failed: %w", redisErr)
 	}
 
-	return nil
+	s.invalidate(key)
+	return key, ttl, nil
 }
 
 // Get получает значение из Redis по ключу.
 // Возвращает значение, флаг наличия значения и ошибку.
 // Если ключ не найден, возвращает false во втором возвращаемом значении.
-// Значение десериализуется из JSON перед возвратом.
+// Значение десериализуется кодеком хранилища перед возвратом.
 func (s *redisStorage[T]) Get(ctx context.Context, key string) (T, bool, error) {
 	var zero T // Нулевое значение типа T для возврата по умолчанию
 
+	key, err := s.checkKey(key)
+	if err != nil {
+		return zero, false, err
+	}
+	s.hot.record(key)
+
+	// Сначала проверяем кэш на стороне клиента (если включен)
+	var version uint64
+	if s.cache != nil {
+		var cached T
+		var found bool
+		if cached, found, version = s.cache.get(key); found {
+			return cached, true, nil
+		}
+	}
+
 	ctx, cancel := context.WithTimeout(ctx, 1*time.Second)
 	defer cancel()
 
-	val, err := s.client.Get(ctx, key).Result()
+	// С кэшем читаем с основного сервера: только он отслеживает ключи соединения
+	var client redis.Cmdable = s.client
+	if s.cache == nil {
+		client = s.reader()
+	}
+	val, err := client.Get(ctx, key).Result()
 	if err == redis.Nil {
 		return zero, false, nil // Ключ не найден - это не ошибка
 	}
@@ -81,135 +352,2228 @@ func (s *redisStorage[T]) Get(ctx context.Context, key string) (T, bool, error)
 		return zero, false, fmt.Errorf("redis get failed: %w", err)
 	}
 
-	var out T
-	if err := json.Unmarshal([]byte(val), &out); err != nil {
-		return zero, false, fmt.Errorf("unmarshal failed: %w", err)
+	out, _, found, err := s.decodeValue(ctx, "get", key, val, true)
+	if s.cache != nil && found {
+		s.cache.store(key, out, version)
 	}
+	return out, found, err
+}
 
+// GetInto читает значение так же, как Get, и десериализует его кодеком прямо в dst.
+// Прочитанное значение не сохраняется в кэше на стороне клиента: dst принадлежит
+// вызывающему коду и будет переиспользован, а кэш не должен разделять с ним память.
+func (s *redisStorage[T]) GetInto(ctx context.Context, key string, dst *T) (bool, error) {
+	return s.getInto(ctx, key, dst, nil)
+}
+
+// GetWithCodec читает значение так же, как GetInto, десериализуя записи без маркера
+// кодеком codec, а помеченные SetWithCodec - кодеком их маркера (см. MarkedCodec).
+func (s *redisStorage[T]) GetWithCodec(ctx context.Context, key string, codec Codec) (T, bool, error) {
+	var out T
+	found, err := s.getInto(ctx, key, &out, codec)
+	if err != nil || !found {
+		var zero T
+		return zero, false, err
+	}
 	return out, true, nil
 }
 
-// Delete удаляет значение из Redis по ключу.
-// Возвращает ошибку, если операция не удалась.
-func (s *redisStorage[T]) Delete(ctx context.Context, key string) error {
+// getInto десериализует значение ключа в dst кодеком codec (nil - кодек хранилища).
+func (s *redisStorage[T]) getInto(ctx context.Context, key string, dst *T, codec Codec) (bool, error) {
+	key, err := s.checkKey(key)
+	if err != nil {
+		return false, err
+	}
+	s.hot.record(key)
+
+	if s.cache != nil {
+		if cached, found, _ := s.cache.get(key); found {
+			*dst = cached
+			return true, nil
+		}
+	}
+
 	ctx, cancel := context.WithTimeout(ctx, 1*time.Second)
 	defer cancel()
 
-	if err := s.client.Del(ctx, key).Err(); err != nil {
-		return fmt.Errorf("redis delete failed: %w", err)
+	var client redis.Cmdable = s.client
+	if s.cache == nil {
+		client = s.reader()
 	}
-	return nil
+	data, err := client.Get(ctx, key).Bytes()
+	if err == redis.Nil {
+		return false, nil // Ключ не найден - это не ошибка
+	}
+	if err != nil {
+		return false, fmt.Errorf("redis get failed: %w", err)
+	}
+
+	if s.opts.metadata {
+		var env metaEnvelope
+		if err := json.Unmarshal(data, &env); err != nil {
+			_, found, err := s.corrupt(ctx, "get", key, err, true)
+			return found, err
+		}
+		data = env.V
+	}
+	if err := unmarshalWithCodec(s.opts.codec, codec, data, dst); err != nil {
+		_, found, err := s.corrupt(ctx, "get", key, err, true)
+		return found, err
+	}
+	return true, nil
 }
 
-// Enqueue добавляет элемент в конец очереди (списка) Redis.
-// Принимает имя очереди и значение для добавления.
-// Значение сериализуется в JSON перед добавлением.
-func (s *redisStorage[T]) Enqueue(ctx context.Context, queueName string, value T) error {
+// Интервалы опроса WaitForKey: первый повтор через waitPollMin, затем интервал
+// удваивается до waitPollMax.
+const (
+	waitPollMin = 10 * time.Millisecond
+	waitPollMax = 500 * time.Millisecond
+)
+
+// WaitForKey опрашивает ключ через Get с экспоненциально растущим интервалом
+// (от waitPollMin до waitPollMax). Уведомления keyspace не используются:
+// они требуют настройки notify-keyspace-events на сервере и теряются при переподключении.
+// Поэтому ключ обнаруживается с задержкой до waitPollMax после записи,
+// а долгое ожидание стоит одного GET в полсекунды.
+// Закрытие хранилища прерывает ожидание не позже чем через waitPollMax.
+func (s *redisStorage[T]) WaitForKey(ctx context.Context, key string, timeout time.Duration) (T, bool, error) {
+	var zero T
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+
+	for wait := waitPollMin; ; wait = min(wait*2, waitPollMax) {
+		value, found, err := s.Get(ctx, key)
+		if err != nil || found {
+			return value, found, err
+		}
+
+		if !deadline.IsZero() {
+			left := time.Until(deadline)
+			if left <= 0 {
+				return zero, false, nil
+			}
+			wait = min(wait, left)
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return zero, false, ctx.Err()
+		case <-s.stop:
+			timer.Stop()
+			return zero, false, nil
+		}
+	}
+}
+
+// GetRaw получает строку, хранящуюся по ключу, без десериализации.
+// Кэш на стороне клиента не используется, поэтому возвращается актуальное
+// содержимое Redis, в том числе поврежденное значение.
+func (s *redisStorage[T]) GetRaw(ctx context.Context, key string) ([]byte, bool, error) {
+	key, err := s.checkKey(key)
+	if err != nil {
+		return nil, false, err
+	}
+	s.hot.record(key)
+
 	ctx, cancel := context.WithTimeout(ctx, 1*time.Second)
 	defer cancel()
 
-	data, err := json.Marshal(value)
+	data, err := s.client.Get(ctx, key).Bytes()
+	if err == redis.Nil {
+		return nil, false, nil // Ключ не найден - это не ошибка
+	}
 	if err != nil {
-		return fmt.Errorf("marshal failed: %w", err)
+		return nil, false, wrapRedisErr("get", err)
+	}
+	return data, true, nil
+}
+
+// GetEx получает значение и обновляет время жизни записи командой GETEX (Redis 6.2+).
+// ttl > 0 задает новое время жизни (EX/PX), ttl == 0 оставляет его без изменений,
+// отрицательный ttl (PersistTTL) делает запись бессрочной (PERSIST).
+func (s *redisStorage[T]) GetEx(ctx context.Context, key string, ttl time.Duration) (T, bool, error) {
+	var zero T
+
+	key, err := s.checkKey(key)
+	if err != nil {
+		return zero, false, err
 	}
+	s.hot.record(key)
+
+	ctx, cancel := context.WithTimeout(ctx, 1*time.Second)
+	defer cancel()
 
-	// Используем RPush для добавления в конец списка
-	if err := s.client.RPush(ctx, queueName, data).Err(); err != nil {
-		return fmt.Errorf("redis rpush failed: %w", err)
+	// В go-redis 0 означает PERSIST, а отрицательное значение - GETEX без опций
+	expiration := ttl
+	switch {
+	case ttl == 0:
+		expiration = -1
+	case ttl < 0:
+		expiration = 0
 	}
 
-	return nil
+	pipe := s.client.Pipeline()
+	getex := pipe.GetEx(ctx, key, expiration)
+	s.expireKeyTags(ctx, pipe, key, ttl) // Теги ключа (SetWithTags) истекают вместе с ним
+	_, _ = pipe.Exec(ctx)                // Ошибка GETEX проверяется ниже
+	val, err := getex.Result()
+	if err == redis.Nil {
+		return zero, false, nil // Ключ не найден - это не ошибка
+	}
+	if err != nil {
+		return zero, false, wrapRedisErr("getex", err)
+	}
+
+	out, _, found, err := s.decodeValue(ctx, "getex", key, val, true)
+	return out, found, err
 }
 
-// Dequeue извлекает и удаляет элемент из начала очереди (списка) Redis.
-// Возвращает элемент, флаг наличия элемента и ошибку.
-// Если очередь пуста, возвращает false во втором возвращаемом значении.
-// Значение десериализуется из JSON перед возвратом.
-func (s *redisStorage[T]) Dequeue(ctx context.Context, queueName string) (T, bool, error) {
+// GetWithTTL отправляет GET и PTTL одним конвейером (pipeline).
+// Кэш на стороне клиента не используется, так как он не хранит время жизни.
+// Поврежденные значения обрабатываются так же, как в Get.
+func (s *redisStorage[T]) GetWithTTL(ctx context.Context, key string) (T, time.Duration, bool, error) {
 	var zero T
 
+	key, err := s.checkKey(key)
+	if err != nil {
+		return zero, 0, false, err
+	}
+	s.hot.record(key)
+
 	ctx, cancel := context.WithTimeout(ctx, 1*time.Second)
 	defer cancel()
 
-	// Используем LPop для извлечения из начала списка
-	val, err := s.client.LPop(ctx, queueName).Result()
+	pipe := s.reader().Pipeline()
+	get := pipe.Get(ctx, key)
+	pttl := pipe.PTTL(ctx, key)
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return zero, 0, false, wrapRedisErr("get with ttl", err)
+	}
+
+	val, err := get.Result()
 	if err == redis.Nil {
-		return zero, false, nil // Очередь пуста - это не ошибка
+		return zero, 0, false, nil // Ключ не найден - это не ошибка
 	}
 	if err != nil {
-		return zero, false, fmt.Errorf("redis lpop failed: %w", err)
+		return zero, 0, false, wrapRedisErr("get", err)
 	}
 
-	var out T
-	if err := json.Unmarshal([]byte(val), &out); err != nil {
-		return zero, false, fmt.Errorf("unmarshal failed: %w", err)
+	out, _, found, err := s.decodeValue(ctx, "get", key, val, true)
+	if !found {
+		return out, 0, false, err
 	}
-
-	return out, true, nil
+	// PTTL возвращает -1 для ключа без времени жизни и -2, если ключ истек после GET
+	ttl := max(pttl.Val(), 0)
+	return out, ttl, true, nil
 }
 
-// Peek получает элемент из начала очереди без его удаления.
-// Возвращает элемент, флаг наличия элемента и ошибку.
-// Если очередь пуста, возвращает false во втором возвращаемом значении.
-// Значение десериализуется из JSON перед возвратом.
-func (s *redisStorage[T]) Peek(ctx context.Context, queueName string) (T, bool, error) {
+// GetWithMeta получает значение вместе с временем создания и обновления.
+// Без опции WithMetadata возвращает пустую Meta.
+// Кэш на стороне клиента не используется, так как он не хранит метаданные.
+func (s *redisStorage[T]) GetWithMeta(ctx context.Context, key string) (T, Meta, bool, error) {
 	var zero T
 
+	key, err := s.checkKey(key)
+	if err != nil {
+		return zero, Meta{}, false, err
+	}
+	s.hot.record(key)
+
 	ctx, cancel := context.WithTimeout(ctx, 1*time.Second)
 	defer cancel()
 
-	// Используем LIndex с индексом 0 для получения первого элемента
-	val, err := s.client.LIndex(ctx, queueName, 0).Result()
+	val, err := s.client.Get(ctx, key).Result()
 	if err == redis.Nil {
-		return zero, false, nil // Очередь пуста - это не ошибка
+		return zero, Meta{}, false, nil // Ключ не найден - это не ошибка
 	}
 	if err != nil {
-		return zero, false, fmt.Errorf("redis lindex failed: %w", err)
+		return zero, Meta{}, false, wrapRedisErr("get", err)
 	}
 
-	var out T
-	if err := json.Unmarshal([]byte(val), &out); err != nil {
-		return zero, false, fmt.Errorf("unmarshal failed: %w", err)
+	return s.decodeValue(ctx, "get", key, val, true)
+}
+
+// Delete удаляет значение из Redis по ключу.
+// Возвращает ошибку, если операция не удалась.
+func (s *redisStorage[T]) Delete(ctx context.Context, key string) error {
+	key, err := s.checkKey(key)
+	if err != nil {
+		return err
 	}
+	s.hot.record(key)
 
-	return out, true, nil
+	ctx, cancel := context.WithTimeout(ctx, 1*time.Second)
+	defer cancel()
+
+	pipe := s.client.Pipeline()
+	del := pipe.Del(ctx, key)
+	take := s.takeKeyTags(ctx, pipe, key)
+	_, _ = pipe.Exec(ctx) // Ошибка удаления проверяется ниже
+	if err := del.Err(); err != nil {
+		return fmt.Errorf("redis delete failed: %w", err)
+	}
+	s.untag(ctx, []string{key}, []*redis.Cmd{take})
+	s.invalidate(key)
+	return nil
 }
 
-// Remove удаляет один элемент из начала очереди без возврата его значения.
-// Возвращает флаг успешности операции и ошибку.
-// Если очередь пуста, возвращает false в первом возвращаемом значении.
-func (s *redisStorage[T]) Remove(ctx context.Context, queueName string) (bool, error) {
+// DeleteIf сравнивает сериализованное кодеком значение с хранимым и удаляет ключ
+// Lua-скриптом (см. deleteIfScript). В режиме WithMetadata хранимое значение
+// обернуто в конверт, поэтому сравнение выполняется в оптимистичной транзакции.
+func (s *redisStorage[T]) DeleteIf(ctx context.Context, key string, expected T) (bool, error) {
+	if s.opts.metadata {
+		return deleteIfViaTransact[T](ctx, s, s.opts.codec, key, expected)
+	}
+	key, err := s.checkKey(key)
+	if err != nil {
+		return false, err
+	}
+	s.hot.record(key)
+
+	data, err := s.opts.codec.Marshal(expected)
+	if err != nil {
+		return false, fmt.Errorf("marshal failed: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 1*time.Second)
+	defer cancel()
+
+	deleted, err := deleteIfScript.Run(ctx, s.client, []string{key}, data).Int64()
+	if err != nil {
+		return false, wrapRedisErr("delete if", err)
+	}
+	if deleted == 1 {
+		s.dropKeyTags(ctx, key)
+		s.invalidate(key)
+	}
+	return deleted == 1, nil
+}
+
+// GetDelete атомарно получает значение по ключу и удаляет его командой GETDEL.
+// Требует Redis 6.2 или новее.
+// Если ключ не найден, возвращает false во втором возвращаемом значении.
+func (s *redisStorage[T]) GetDelete(ctx context.Context, key string) (T, bool, error) {
+	var zero T
+
+	key, err := s.checkKey(key)
+	if err != nil {
+		return zero, false, err
+	}
+	s.hot.record(key)
+
 	ctx, cancel := context.WithTimeout(ctx, 1*time.Second)
 	defer cancel()
 
-	// Используем LPop, но игнорируем возвращаемое значение
-	_, err := s.client.LPop(ctx, queueName).Result()
+	pipe := s.client.Pipeline()
+	getdel := pipe.GetDel(ctx, key)
+	take := s.takeKeyTags(ctx, pipe, key)
+	_, _ = pipe.Exec(ctx) // Ошибка GETDEL проверяется ниже
+	val, err := getdel.Result()
 	if err == redis.Nil {
-		return false, nil // Очередь пуста - считаем это успешной операцией
+		return zero, false, nil // Ключ не найден - это не ошибка
 	}
 	if err != nil {
-		return false, fmt.Errorf("redis lpop failed: %w", err)
+		return zero, false, wrapRedisErr("getdel", err)
 	}
+	s.untag(ctx, []string{key}, []*redis.Cmd{take})
+	s.invalidate(key)
 
-	return true, nil
+	out, _, found, err := s.decodeValue(ctx, "getdel", key, val, false) // Значение уже удалено
+	return out, found, err
 }
 
-// QueueLen возвращает текущую длину очереди.
-// Возвращает количество элементов в очереди и ошибку, если операция не удалась.
-func (s *redisStorage[T]) QueueLen(ctx context.Context, queueName string) (int64, error) {
+// ClaimOnce записывает сериализованное нулевое значение командой SET NX (со временем жизни при ttl > 0).
+// В режиме WithMetadata маркер записывается в конверте с временем этого процесса.
+// Время жизни не смещается опцией WithTTLJitter: маркер должен истечь точно в срок.
+func (s *redisStorage[T]) ClaimOnce(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	key, origKey, err := s.resolveKey(key)
+	if err != nil {
+		return false, err
+	}
+	s.hot.record(key)
+
+	var marker T
+	data, err := s.opts.codec.Marshal(marker)
+	if err != nil {
+		return false, fmt.Errorf("marshal failed: %w", err)
+	}
+	if s.opts.metadata {
+		now := time.Now().UTC()
+		if data, err = json.Marshal(metaEnvelope{V: data, C: now, U: now, K: origKey}); err != nil {
+			return false, fmt.Errorf("marshal failed: %w", err)
+		}
+	}
+
 	ctx, cancel := context.WithTimeout(ctx, 1*time.Second)
 	defer cancel()
 
-	length, err := s.client.LLen(ctx, queueName).Result()
+	claimed, err := s.client.SetNX(ctx, key, data, max(ttl, 0)).Result()
 	if err != nil {
-		return 0, fmt.Errorf("redis llen failed: %w", err)
+		return false, wrapRedisErr("set nx", err)
+	}
+	if claimed {
+		s.invalidate(key)
 	}
+	return claimed, nil
+}
 
-	return length, nil
+// CopyKey копирует запись на стороне Redis командой COPY (Redis 6.2+)
+// с опцией REPLACE, если replace равен true. Время жизни копируется вместе со значением,
+// метаданные (WithMetadata) - без изменений. Теги замененного dst (SetWithTags)
+// удаляются после копирования: копия не наследует теги src.
+func (s *redisStorage[T]) CopyKey(ctx context.Context, src, dst string, replace bool) (bool, error) {
+	src, err := s.checkKey(src)
+	if err != nil {
+		return false, err
+	}
+	dst, _, err = s.resolveKey(dst)
+	if err != nil {
+		return false, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 1*time.Second)
+	defer cancel()
+
+	args := []any{"COPY", src, dst}
+	if replace {
+		args = append(args, "REPLACE")
+	}
+	copied, err := s.client.Do(ctx, args...).Int64()
+	if err != nil {
+		return false, wrapRedisErr("copy", err)
+	}
+	if copied == 1 {
+		if replace {
+			s.dropKeyTags(ctx, dst)
+		}
+		s.invalidate(dst)
+	}
+	return copied == 1, nil
 }
 
-// Close закрывает соединение с Redis.
-// Должен вызываться при завершении работы с хранилищем.
-func (s *redisStorage[T]) Close() error {
-	return s.client.Close()
+// SetVersioned записывает значение и добавляет его в начало списка истории командами
+// SET, LPUSH и LTRIM в одной транзакции MULTI/EXEC, поэтому значение и история
+// меняются атомарно. Время жизни записи сохраняется (KEEPTTL), как при Set с ttl 0.
+// Список истории лежит в слоте ключа (см. historyKey).
+func (s *redisStorage[T]) SetVersioned(ctx context.Context, key string, value T, keep int) error {
+	if keep <= 0 {
+		return ErrInvalidMaxLen
+	}
+	key, origKey, err := s.resolveKey(key)
+	if err != nil {
+		return err
+	}
+	s.hot.record(key)
+
+	ctx, cancel := context.WithTimeout(ctx, 1*time.Second)
+	defer cancel()
+
+	data, err := s.opts.marshalValue(value)
+	if err != nil {
+		return err
+	}
+
+	history := s.historyKey(key)
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		if s.opts.metadata {
+			setMetaScript.Eval(ctx, pipe, []string{key}, setMetaArgs(data, 0, origKey)...) // EVALSHA в конвейере не повторяется при NOSCRIPT
+		} else {
+			pipe.Set(ctx, key, data, redis.KeepTTL)
+		}
+		pipe.LPush(ctx, history, data)
+		pipe.LTrim(ctx, history, 0, int64(keep-1))
+		return nil
+	})
+	if err != nil {
+		return wrapRedisErr("set versioned", err)
+	}
+	s.invalidate(key)
+	return nil
+}
+
+// GetVersion читает версию из списка истории командой LINDEX.
+func (s *redisStorage[T]) GetVersion(ctx context.Context, key string, n int) (T, bool, error) {
+	var zero T
+	if n < 0 {
+		return zero, false, ErrInvalidOffset
+	}
+	key, err := s.checkKey(key)
+	if err != nil {
+		return zero, false, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 1*time.Second)
+	defer cancel()
+
+	data, err := s.reader().LIndex(ctx, s.historyKey(key), int64(n)).Bytes()
+	if err == redis.Nil {
+		return zero, false, nil // Версии нет
+	}
+	if err != nil {
+		return zero, false, wrapRedisErr("history get", err)
+	}
+
+	var value T
+	if err := s.opts.codec.Unmarshal(data, &value); err != nil {
+		return zero, false, fmt.Errorf("unmarshal failed: %w", err)
+	}
+	return value, true, nil
+}
+
+// Toggle инвертирует логическое значение оптимистичной транзакцией (WATCH/MULTI/EXEC),
+// поэтому работает с любым кодеком. Время жизни записи сохраняется (KEEPTTL).
+func (s *redisStorage[T]) Toggle(ctx context.Context, key string) (bool, error) {
+	return toggleViaTransact[T](ctx, s, key)
+}
+
+// IncrementField увеличивает поле скриптом Lua, заменяющим число в JSON на стороне Redis.
+// Скрипт работает только с JSONCodec без метаданных; в остальных случаях поле
+// увеличивается оптимистичной транзакцией. Время жизни записи сохраняется.
+// Поле проверяется по типу T до вызова скрипта так же, как в транзакции,
+// а скрипт проверяет диапазон типа поля, но не шире точных целых чисел Lua (±2^53).
+func (s *redisStorage[T]) IncrementField(ctx context.Context, key, field string, delta int64) (int64, error) {
+	if _, ok := s.opts.codec.(JSONCodec); !ok || s.opts.metadata {
+		return incrementFieldViaTransact[T](ctx, s, key, field, delta)
+	}
+	f, err := structIntField(reflect.New(reflect.TypeFor[T]()).Elem(), field)
+	if err != nil {
+		return 0, err
+	}
+	lo, hi := intFieldRange(f)
+
+	key, err = s.checkKey(key)
+	if err != nil {
+		return 0, err
+	}
+	s.hot.record(key)
+
+	name, err := json.Marshal(field)
+	if err != nil {
+		return 0, fmt.Errorf("marshal failed: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 1*time.Second)
+	defer cancel()
+
+	n, err := incrementFieldScript.Run(ctx, s.client, []string{key}, string(name)+":", delta, lo, hi).Int64()
+	if err != nil {
+		return 0, wrapRedisErr("increment field", err)
+	}
+	s.invalidate(key)
+	return n, nil
+}
+
+// DeletePattern удаляет все записи, ключи которых соответствуют шаблону.
+// Ключи перебираются командой SCAN с MATCH (блокирующая KEYS не используется),
+// найденные на каждой странице ключи удаляются одним конвейером DEL.
+// Учитываются только строковые ключи, поэтому очереди не затрагиваются.
+// Таймаут применяется к каждому сетевому вызову отдельно.
+func (s *redisStorage[T]) DeletePattern(ctx context.Context, pattern string) (int64, error) {
+	if s.cache != nil {
+		defer s.cache.flush()
+	}
+
+	var deleted int64
+	var cursor uint64
+	for {
+		keys, next, err := s.scanPage(ctx, cursor, pattern, s.opts.scanCount)
+		if err != nil {
+			return deleted, err
+		}
+
+		if len(keys) > 0 {
+			n, err := s.deleteKeys(ctx, keys)
+			deleted += n
+			if err != nil {
+				return deleted, err
+			}
+		}
+
+		if next == 0 {
+			return deleted, nil
+		}
+		cursor = next
+	}
+}
+
+// CountPattern подсчитывает ключи, перебирая их командой SCAN с MATCH,
+// без чтения значений. Как и SCAN, при изменении ключей во время обхода
+// может учесть ключ дважды или пропустить добавленный: результат приблизителен.
+// Таймаут применяется к каждому сетевому вызову отдельно.
+func (s *redisStorage[T]) CountPattern(ctx context.Context, pattern string) (int64, error) {
+	var n int64
+	var cursor uint64
+	for {
+		keys, next, err := s.scanPage(ctx, cursor, pattern, s.opts.scanCount)
+		if err != nil {
+			return n, err
+		}
+		n += int64(len(keys))
+		if cursor = next; cursor == 0 {
+			return n, nil
+		}
+	}
+}
+
+// ScanPage возвращает страницу ключей, соответствующих шаблону.
+// Курсор передается в команду SCAN без изменений, count используется как подсказка COUNT.
+// Как и SCAN, может вернуть пустую страницу с ненулевым курсором.
+func (s *redisStorage[T]) ScanPage(ctx context.Context, cursor uint64, match string, count int) ([]string, uint64, error) {
+	if match == "" {
+		match = "*"
+	}
+	if count <= 0 {
+		count = s.opts.scanCount
+	}
+	keys, next, err := s.scanPage(ctx, cursor, match, count)
+	for i, key := range keys {
+		keys[i] = s.userKey(key)
+	}
+	return keys, next, err
+}
+
+// scanPage возвращает одну страницу строковых ключей, соответствующих шаблону.
+// Ключи возвращаются с префиксом пространства имен (см. WithNamespaces),
+// служебные ключи (см. internalPrefixes) пропускаются.
+func (s *redisStorage[T]) scanPage(ctx context.Context, cursor uint64, pattern string, count int) ([]string, uint64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 1*time.Second)
+	defer cancel()
+
+	pattern = s.namespace(keyNamespace) + pattern
+	keys, next, err := s.client.ScanType(ctx, cursor, pattern, int64(count), "string").Result()
+	if err != nil {
+		return nil, 0, wrapRedisErr("scan", err)
+	}
+	return slices.DeleteFunc(keys, isInternalKey), next, nil
+}
+
+// GetByPrefix обходит ключи командой SCAN MATCH <prefix>* и читает каждую страницу
+// конвейером GET за один сетевой вызов. Ключи, удаленные между SCAN и GET, пропускаются.
+// Поврежденные значения обрабатываются так же, как в Get.
+func (s *redisStorage[T]) GetByPrefix(ctx context.Context, prefix string) (map[string]T, error) {
+	values := make(map[string]T)
+	pattern := escapePattern(prefix) + "*"
+
+	var cursor uint64
+	for {
+		keys, next, err := s.scanPage(ctx, cursor, pattern, s.opts.scanCount)
+		if err != nil {
+			return nil, err
+		}
+		if len(keys) > 0 {
+			if err := s.getPage(ctx, keys, values); err != nil {
+				return nil, err
+			}
+		}
+		if cursor = next; cursor == 0 {
+			return values, nil
+		}
+	}
+}
+
+// getPage читает значения ключей конвейером GET и добавляет найденные в values.
+func (s *redisStorage[T]) getPage(ctx context.Context, keys []string, values map[string]T) error {
+	ctx, cancel := context.WithTimeout(ctx, 1*time.Second)
+	defer cancel()
+
+	cmds := make([]*redis.StringCmd, len(keys))
+	pipe := s.client.Pipeline()
+	for i, key := range keys {
+		cmds[i] = pipe.Get(ctx, key)
+	}
+	_, _ = pipe.Exec(ctx) // Ошибки разбираются по командам: redis.Nil означает удаленный ключ
+
+	for i, cmd := range cmds {
+		val, err := cmd.Result()
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			return wrapRedisErr("get", err)
+		}
+		out, _, found, err := s.decodeValue(ctx, "get", keys[i], val, true)
+		if err != nil {
+			return fmt.Errorf("key %q: %w", keys[i], err)
+		}
+		if found {
+			values[s.userKey(keys[i])] = out
+		}
+	}
+	return nil
+}
+
+// Inspect выполняет OBJECT ENCODING, OBJECT IDLETIME и OBJECT REFCOUNT одним конвейером.
+// Ошибка OBJECT IDLETIME (Redis не отслеживает время обращений при политике LFU)
+// не считается ошибкой: IdleTime остается равным 0.
+func (s *redisStorage[T]) Inspect(ctx context.Context, key string) (ObjectInfo, bool, error) {
+	key, err := s.checkKey(key)
+	if err != nil {
+		return ObjectInfo{}, false, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 1*time.Second)
+	defer cancel()
+
+	pipe := s.client.Pipeline()
+	encoding := pipe.ObjectEncoding(ctx, key)
+	idle := pipe.ObjectIdleTime(ctx, key)
+	refs := pipe.ObjectRefCount(ctx, key)
+	_, _ = pipe.Exec(ctx) // Ошибки разбираются по командам
+
+	if err := encoding.Err(); err == redis.Nil {
+		return ObjectInfo{}, false, nil // Ключ не найден - это не ошибка
+	} else if err != nil {
+		return ObjectInfo{}, false, wrapRedisErr("object encoding", err)
+	}
+	if err := refs.Err(); err != nil && err != redis.Nil {
+		return ObjectInfo{}, false, wrapRedisErr("object refcount", err)
+	}
+	return ObjectInfo{Encoding: encoding.Val(), IdleTime: idle.Val(), RefCount: refs.Val()}, true, nil
+}
+
+// TTL возвращает оставшееся время жизни записи (0 - бессрочная).
+func (s *redisStorage[T]) TTL(ctx context.Context, key string) (time.Duration, bool, error) {
+	key, err := s.checkKey(key)
+	if err != nil {
+		return 0, false, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 1*time.Second)
+	defer cancel()
+
+	// PTTL возвращает -2 для отсутствующего ключа и -1 для ключа без времени жизни
+	ttl, err := s.client.PTTL(ctx, key).Result()
+	if err != nil {
+		return 0, false, wrapRedisErr("pttl", err)
+	}
+	switch {
+	case ttl == -2:
+		return 0, false, nil
+	case ttl < 0:
+		return 0, true, nil
+	default:
+		return ttl, true, nil
+	}
+}
+
+// QueueNames возвращает имена очередей: списков Redis, кроме служебных
+// списков зарезервированных элементов (см. PeekBatchReserve).
+func (s *redisStorage[T]) QueueNames(ctx context.Context) ([]string, error) {
+	var names []string
+	var cursor uint64
+	for {
+		keys, next, err := s.scanLists(ctx, cursor)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range keys {
+			if !isInflightItemsKey(key) {
+				names = append(names, strings.TrimPrefix(key, s.namespace(queueNamespace)))
+			}
+		}
+		if cursor = next; cursor == 0 {
+			return names, nil
+		}
+	}
+}
+
+// scanLists возвращает одну страницу ключей-списков.
+func (s *redisStorage[T]) scanLists(ctx context.Context, cursor uint64) ([]string, uint64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 1*time.Second)
+	defer cancel()
+
+	keys, next, err := s.client.ScanType(ctx, cursor, s.namespace(queueNamespace)+"*", int64(s.opts.scanCount), "list").Result()
+	if err != nil {
+		return nil, 0, wrapRedisErr("scan", err)
+	}
+	return keys, next, nil
+}
+
+// deleteKeys удаляет пакет ключей конвейером и возвращает количество удаленных.
+func (s *redisStorage[T]) deleteKeys(ctx context.Context, keys []string) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 1*time.Second)
+	defer cancel()
+
+	cmds := make([]*redis.IntCmd, len(keys))
+	takes := make([]*redis.Cmd, len(keys))
+	pipe := s.client.Pipeline()
+	for i, key := range keys {
+		cmds[i] = pipe.Del(ctx, key)
+		takes[i] = s.takeKeyTags(ctx, pipe, key)
+	}
+	_, err := pipe.Exec(ctx)
+
+	var deleted int64
+	for _, cmd := range cmds {
+		deleted += cmd.Val()
+	}
+	s.untag(ctx, keys, takes)
+	if err != nil {
+		return deleted, wrapRedisErr("del pipeline", err)
+	}
+	return deleted, nil
+}
+
+// Enqueue добавляет элемент в конец очереди (списка) Redis.
+// Принимает имя очереди и значение для добавления.
+// Значение сериализуется кодеком хранилища перед добавлением.
+func (s *redisStorage[T]) Enqueue(ctx context.Context, queueName string, value T) error {
+	ctx, cancel := context.WithTimeout(ctx, 1*time.Second)
+	defer cancel()
+
+	data, err := s.opts.marshalValue(value)
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.push(ctx, queueName, value, data); err != nil {
+		return err
+	}
+
+	s.rates.enqueued(queueName, 1)
+	return nil
+}
+
+// push добавляет сериализованное значение в конец очереди и возвращает ее длину:
+// командой RPUSH или, с WithQueueTypeCheck, скриптом enqueueTaggedScript.
+func (s *redisStorage[T]) push(ctx context.Context, queueName string, value T, data []byte) (int64, error) {
+	if !s.opts.queueTypeCheck {
+		// Используем RPush для добавления в конец списка
+		length, err := s.client.RPush(ctx, s.queueKey(queueName), data).Result()
+		if err != nil {
+			return 0, wrapRedisErr("rpush", err)
+		}
+		return length, nil
+	}
+
+	tag := s.valueTag(value)
+	keys := []string{s.queueKey(queueName), s.queueTagKey(queueName)}
+	res, err := enqueueTaggedScript.Run(ctx, s.client, keys, tag, data).Result()
+	if err != nil {
+		return 0, wrapRedisErr("enqueue tagged", err)
+	}
+	return taggedReply(queueName, tag, res)
+}
+
+// EnqueueFanout отправляет RPUSH во все очереди одним конвейером (pipeline).
+// Значение сериализуется один раз; ошибки отдельных команд (например, WRONGTYPE)
+// собираются в *BatchError.
+func (s *redisStorage[T]) EnqueueFanout(ctx context.Context, queueNames []string, value T) error {
+	if len(queueNames) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 1*time.Second)
+	defer cancel()
+
+	data, err := s.opts.marshalValue(value)
+	if err != nil {
+		return err
+	}
+
+	tag := s.valueTag(value)
+	cmds := make([]redis.Cmder, len(queueNames))
+	pipe := s.client.Pipeline()
+	for i, name := range queueNames {
+		if s.opts.queueTypeCheck {
+			// EVALSHA в конвейере не повторяется при NOSCRIPT
+			keys := []string{s.queueKey(name), s.queueTagKey(name)}
+			cmds[i] = enqueueTaggedScript.Eval(ctx, pipe, keys, tag, data)
+		} else {
+			cmds[i] = pipe.RPush(ctx, s.queueKey(name), data)
+		}
+	}
+	_, _ = pipe.Exec(ctx) // Ошибки разбираются по командам
+
+	batchErr := &BatchError{Errs: make(map[string]error)}
+	for i, name := range queueNames {
+		if err := cmds[i].Err(); err != nil {
+			batchErr.Errs[name] = wrapRedisErr("rpush", err)
+			continue
+		}
+		if cmd, ok := cmds[i].(*redis.Cmd); ok {
+			if _, err := taggedReply(name, tag, cmd.Val()); err != nil {
+				batchErr.Errs[name] = err
+				continue
+			}
+		}
+		s.rates.enqueued(name, 1)
+	}
+	if len(batchErr.Errs) > 0 {
+		return batchErr
+	}
+	return nil
+}
+
+// EnqueueCapped добавляет элемент в конец очереди и обрезает ее до maxLen последних
+// элементов: RPUSH и LTRIM key -maxLen -1 выполняются атомарно Lua-скриптом.
+// Значение сериализуется кодеком хранилища перед добавлением.
+// Обрезанная очередь помечается разобранной (см. ErrQueueConsumed).
+func (s *redisStorage[T]) EnqueueCapped(ctx context.Context, queueName string, value T, maxLen int64) error {
+	if maxLen <= 0 {
+		return ErrInvalidMaxLen
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 1*time.Second)
+	defer cancel()
+
+	data, err := s.opts.marshalValue(value)
+	if err != nil {
+		return err
+	}
+
+	tag := s.valueTag(value)
+	keys := []string{s.queueKey(queueName), s.queueTagKey(queueName), s.queueConsumedKey(queueName)}
+	res, err := cappedEnqueueScript.Run(ctx, s.client, keys, tag, data, maxLen).Result()
+	if err != nil {
+		return wrapRedisErr("capped enqueue", err)
+	}
+	if _, err := taggedReply(queueName, tag, res); err != nil {
+		return err
+	}
+	s.rates.enqueued(queueName, 1)
+	return nil
+}
+
+// EnqueueBounded добавляет элемент в конец очереди, только если в ней меньше maxLen
+// элементов. Проверка длины и RPUSH выполняются атомарно Lua-скриптом,
+// поэтому ограничение соблюдается при любом числе конкурентных производителей.
+// Значение сериализуется кодеком хранилища перед добавлением.
+func (s *redisStorage[T]) EnqueueBounded(ctx context.Context, queueName string, value T, maxLen int64) (bool, error) {
+	if maxLen <= 0 {
+		return false, ErrInvalidMaxLen
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 1*time.Second)
+	defer cancel()
+
+	data, err := s.opts.marshalValue(value)
+	if err != nil {
+		return false, err
+	}
+
+	tag := s.valueTag(value)
+	keys := []string{s.queueKey(queueName), s.queueTagKey(queueName)}
+	res, err := boundedEnqueueScript.Run(ctx, s.client, keys, tag, data, maxLen).Result()
+	if err != nil {
+		return false, wrapRedisErr("bounded enqueue", err)
+	}
+	length, err := taggedReply(queueName, tag, res)
+	if err != nil {
+		return false, err
+	}
+	if length < 0 {
+		return false, nil // Очередь заполнена
+	}
+	s.rates.enqueued(queueName, 1)
+	return true, nil
+}
+
+// TryEnqueue добавляет элемент тем же скриптом, что и EnqueueBounded, и возвращает причину отказа.
+// Закрытое хранилище или клиент (redis.ErrClosed) дают ReasonClosed.
+func (s *redisStorage[T]) TryEnqueue(ctx context.Context, queueName string, value T, maxLen int64) (bool, EnqueueReason, error) {
+	select {
+	case <-s.stop:
+		return false, ReasonClosed, nil
+	default:
+	}
+	ok, err := s.EnqueueBounded(ctx, queueName, value, maxLen)
+	switch {
+	case errors.Is(err, redis.ErrClosed):
+		return false, ReasonClosed, nil
+	case err != nil:
+		return false, ReasonError, err
+	case !ok:
+		return false, ReasonFull, nil
+	default:
+		return true, ReasonOK, nil
+	}
+}
+
+// EnqueueDelayed добавляет элемент в сортированное множество отложенных элементов
+// очереди с временем готовности в качестве веса (по часам Redis).
+// Готовые элементы переносятся в конец очереди перед каждым извлечением из очереди
+// (Dequeue, Remove, PeekBatchReserve и т.д.) любым процессом, а также фоновой горутиной
+// каждого хранилища, которое добавляло элементы в очередь или читало ее: чтения без
+// извлечения (Peek, QueueTail и т.д.) видят их не позже чем через maintenanceInterval. Элементы
+// не теряются при перезапуске производителя: множество хранится в Redis.
+// Если delay не положительна, элемент добавляется сразу.
+func (s *redisStorage[T]) EnqueueDelayed(ctx context.Context, queueName string, value T, delay time.Duration) error {
+	if delay <= 0 {
+		return s.Enqueue(ctx, queueName, value)
+	}
+	s.watchQueue(&s.delayed, queueName)
+
+	ctx, cancel := context.WithTimeout(ctx, 1*time.Second)
+	defer cancel()
+
+	data, err := s.opts.marshalValue(value)
+	if err != nil {
+		return err
+	}
+
+	delayMs := max(delay.Milliseconds(), 1)
+	tag := s.valueTag(value)
+	keys := []string{delayedKey(s.queueKey(queueName)), s.queueTagKey(queueName)}
+	res, err := enqueueDelayedScript.Run(ctx, s.client, keys, tag, delayedMember(data), delayMs).Result()
+	if err != nil {
+		return wrapRedisErr("enqueue delayed", err)
+	}
+	if _, err := taggedReply(queueName, tag, res); err != nil {
+		return err
+	}
+	s.rates.enqueued(queueName, 1)
+	return nil
+}
+
+// promoteDelayed переносит готовые отложенные элементы в конец очереди.
+func (s *redisStorage[T]) promoteDelayed(ctx context.Context, queueName string) error {
+	key := s.queueKey(queueName)
+	keys := []string{key, delayedKey(key)}
+	if err := promoteDelayedScript.Run(ctx, s.client, keys).Err(); err != nil {
+		return wrapRedisErr("promote delayed", err)
+	}
+	return nil
+}
+
+// EnqueueN добавляет элемент в конец очереди (списка) Redis и возвращает ее новую длину.
+// Длина берется из ответа RPUSH, отдельный вызов LLEN не нужен.
+// Значение сериализуется кодеком хранилища перед добавлением.
+func (s *redisStorage[T]) EnqueueN(ctx context.Context, queueName string, value T) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 1*time.Second)
+	defer cancel()
+
+	data, err := s.opts.marshalValue(value)
+	if err != nil {
+		return 0, err
+	}
+
+	length, err := s.push(ctx, queueName, value, data)
+	if err != nil {
+		return 0, err
+	}
+
+	s.rates.enqueued(queueName, 1)
+	return length, nil
+}
+
+// Dequeue извлекает и удаляет элемент из начала очереди (списка) Redis.
+// Возвращает элемент, флаг наличия элемента и ошибку.
+// Если очередь пуста, возвращает false во втором возвращаемом значении.
+// Значение десериализуется кодеком хранилища перед возвратом.
+func (s *redisStorage[T]) Dequeue(ctx context.Context, queueName string) (T, bool, error) {
+	var zero T
+
+	ctx, cancel := context.WithTimeout(ctx, 1*time.Second)
+	defer cancel()
+
+	keys := s.queuePrepareKeys(queueName)
+	var want string
+	if s.opts.queueTypeCheck {
+		want, _ = expectedQueueTag[T](&s.opts)
+		keys = append(keys, s.queueTagKey(queueName))
+	}
+	res, err := s.runPrepared(ctx, "dequeue", dequeueScript, queueName, keys, want)
+	if err != nil {
+		return zero, false, err
+	}
+
+	switch res[0].(int64) {
+	case 0:
+		return zero, false, nil // Очередь пуста - это не ошибка
+	case 2:
+		have, _ := res[1].(string)
+		return zero, false, typeMismatch(queueName, have, want)
+	}
+	val, _ := res[1].(string)
+	s.rates.dequeued(queueName, 1)
+	return s.decode(ctx, "dequeue", queueName, val, false) // Элемент уже извлечен
+}
+
+// DequeueTracked извлекает элемент и записывает его по ключу одним Lua-скриптом
+// (см. dequeueTrackedScript). Время жизни ключа не смещается опцией WithTTLJitter.
+// В Redis Cluster очередь и ключ должны находиться в одном слоте (hash tag).
+// В режиме WithMetadata не поддерживается: скрипт не может сформировать конверт
+// метаданных, а значение без конверта нельзя было бы прочитать через Get.
+func (s *redisStorage[T]) DequeueTracked(ctx context.Context, queueName, trackingKey string, ttl time.Duration) (T, bool, error) {
+	var zero T
+	if s.opts.metadata {
+		return zero, false, fmt.Errorf("%w: DequeueTracked with metadata", ErrUnsupported)
+	}
+	key, _, err := s.resolveKey(trackingKey)
+	if err != nil {
+		return zero, false, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 1*time.Second)
+	defer cancel()
+
+	keys := s.queuePrepareKeys(queueName, key)
+	var want string
+	if s.opts.queueTypeCheck {
+		want, _ = expectedQueueTag[T](&s.opts)
+		keys = append(keys, s.queueTagKey(queueName))
+	}
+	res, err := s.runPrepared(ctx, "dequeue tracked", dequeueTrackedScript, queueName, keys, max(ttl, 0).Milliseconds(), want)
+	if err != nil {
+		return zero, false, err
+	}
+	switch res[0].(int64) {
+	case 0:
+		return zero, false, nil // Очередь пуста - это не ошибка
+	case 2:
+		have, _ := res[1].(string)
+		return zero, false, typeMismatch(queueName, have, want)
+	}
+	val, _ := res[1].(string)
+
+	trackTTL := ttl
+	if trackTTL <= 0 {
+		trackTTL = PersistTTL // Скрипт записывает ключ без KEEPTTL
+	}
+	s.expireKeyTags(ctx, s.client, key, trackTTL)
+	s.invalidate(key)
+	s.rates.dequeued(queueName, 1)
+	return s.decode(ctx, "dequeue", queueName, val, false) // Элемент уже извлечен
+}
+
+// blockingSlice - максимальное время ожидания одного вызова BLPOP в BlockingDequeueAny.
+// Между вызовами проверяются контекст, закрытие хранилища и готовые отложенные элементы.
+const blockingSlice = 1 * time.Second
+
+// BlockingDequeueAny ждет элемент командой BLPOP, которая проверяет очереди в порядке
+// перечисления. Ожидание разбивается на вызовы не длиннее blockingSlice (секунды с долями,
+// Redis 6+): go-redis не прерывает блокирующую команду по контексту.
+// В Redis Cluster все очереди должны находиться в одном слоте (hash tag).
+// Закрытие хранилища прерывает ожидание не позже чем через blockingSlice.
+// С WithQueueTypeCheck элемент очереди с чужим тегом возвращается в ее начало
+// и возвращается ErrTypeMismatch (см. checkPoppedTag).
+func (s *redisStorage[T]) BlockingDequeueAny(ctx context.Context, queueNames []string, timeout time.Duration) (string, T, bool, error) {
+	var zero T
+	if len(queueNames) == 0 {
+		return "", zero, false, nil
+	}
+
+	args := make([]any, 0, len(queueNames)+2)
+	args = append(args, "BLPOP")
+	names := make(map[string]string, len(queueNames)) // Ключ Redis -> имя очереди
+	for _, name := range queueNames {
+		key := s.queueKey(name)
+		names[key] = name
+		args = append(args, key)
+	}
+
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+	for {
+		wait := blockingSlice
+		if !deadline.IsZero() {
+			left := time.Until(deadline)
+			if left <= 0 {
+				return "", zero, false, nil
+			}
+			wait = max(min(wait, left), time.Millisecond) // BLPOP с 0 ждал бы бесконечно
+		}
+		if err := ctx.Err(); err != nil {
+			return "", zero, false, err
+		}
+		select {
+		case <-s.stop:
+			return "", zero, false, nil
+		default:
+		}
+		for _, name := range queueNames {
+			if err := s.prepareQueue(ctx, name, true); err != nil {
+				return "", zero, false, err
+			}
+		}
+
+		seconds := strconv.FormatFloat(wait.Seconds(), 'f', 3, 64)
+		reply, err := s.client.Do(ctx, append(args, seconds)...).StringSlice()
+		if err == redis.Nil {
+			continue // Время вызова истекло
+		}
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return "", zero, false, ctxErr
+			}
+			return "", zero, false, wrapRedisErr("blpop", err)
+		}
+
+		name := names[reply[0]]
+		if err := s.checkPoppedTag(ctx, name, reply[1]); err != nil {
+			return "", zero, false, err
+		}
+		s.rates.dequeued(name, 1)
+		value, found, err := s.decode(ctx, "dequeue", name, reply[1], false) // Элемент уже извлечен
+		return name, value, found, err
+	}
+}
+
+// Peek получает элемент из начала очереди без его удаления.
+// Возвращает элемент, флаг наличия элемента и ошибку.
+// Если очередь пуста, возвращает false во втором возвращаемом значении.
+// Значение десериализуется кодеком хранилища перед возвратом.
+func (s *redisStorage[T]) Peek(ctx context.Context, queueName string) (T, bool, error) {
+	var zero T
+
+	ctx, cancel := context.WithTimeout(ctx, 1*time.Second)
+	defer cancel()
+
+	s.watchQueues(queueName)
+
+	// Используем LIndex с индексом 0 для получения первого элемента
+	val, err := s.reader().LIndex(ctx, s.queueKey(queueName), 0).Result()
+	if err == redis.Nil {
+		return zero, false, nil // Очередь пуста - это не ошибка
+	}
+	if err != nil {
+		return zero, false, wrapRedisErr("lindex", err)
+	}
+
+	return s.decode(ctx, "peek", queueName, val, false) // Элемент остается в очереди
+}
+
+// Remove удаляет один элемент из начала очереди без возврата его значения.
+// Возвращает флаг успешности операции и ошибку.
+// Если очередь пуста или ключ удален, возвращает false в первом возвращаемом значении.
+// Если по ключу хранится значение другого типа, возвращает ошибку ErrWrongType.
+func (s *redisStorage[T]) Remove(ctx context.Context, queueName string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 1*time.Second)
+	defer cancel()
+
+	// Значение не десериализуется, поэтому тег типа очереди не проверяется
+	keys := s.queuePrepareKeys(queueName)
+	if s.opts.queueTypeCheck {
+		keys = append(keys, s.queueTagKey(queueName))
+	}
+	res, err := s.runPrepared(ctx, "lpop", dequeueScript, queueName, keys, "")
+	if err != nil {
+		return false, err
+	}
+	if res[0].(int64) == 0 {
+		return false, nil // Очередь пуста - считаем это успешной операцией
+	}
+
+	s.rates.dequeued(queueName, 1)
+	return true, nil
+}
+
+// removedSentinel - префикс метки, которой RemoveAt заменяет удаляемый элемент.
+// Случайный суффикс исключает совпадение с настоящими элементами очереди.
+const removedSentinel = "storage:removed:"
+
+// removeAtScript удаляет элемент подготовленной очереди по позиции ARGV[1]: заменяет его
+// меткой ARGV[2] командой LSET и удаляет метку командой LREM. Если передан тег очереди
+// (KEYS[5], только с WithQueueTypeCheck), опустевшая очередь теряет тег.
+// Возвращает {1} при удалении, {0} для позиции вне очереди или {2} для ключа другого типа.
+var removeAtScript = redis.NewScript(queuePrepareLua + `
+local kind = redis.call('TYPE', KEYS[1])['ok']
+if kind ~= 'list' and kind ~= 'none' then
+	return {2}
+end
+local n = redis.call('LLEN', KEYS[1])
+local i = tonumber(ARGV[1])
+if i < 0 then
+	i = i + n
+end
+if i < 0 or i >= n then
+	return {0}
+end
+redis.call('LSET', KEYS[1], i, ARGV[2])
+redis.call('LREM', KEYS[1], 1, ARGV[2])
+redis.call('SET', KEYS[4], 1)
+if KEYS[5] and n == 1 then
+	redis.call('DEL', KEYS[5])
+end
+return {1}
+`)
+
+// RemoveAt удаляет элемент очереди по позиции одним скриптом removeAtScript.
+// Отрицательная позиция отсчитывается от конца очереди, позиция вне очереди возвращает false.
+// Если по ключу хранится значение другого типа, возвращает ошибку ErrWrongType.
+func (s *redisStorage[T]) RemoveAt(ctx context.Context, queueName string, index int64) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 1*time.Second)
+	defer cancel()
+
+	// Значение не десериализуется, поэтому тег типа очереди не проверяется
+	keys := s.queuePrepareKeys(queueName)
+	if s.opts.queueTypeCheck {
+		keys = append(keys, s.queueTagKey(queueName))
+	}
+	sentinel := removedSentinel + newReservationToken()
+	res, err := s.runPrepared(ctx, "remove at", removeAtScript, queueName, keys, index, sentinel)
+	if err != nil {
+		return false, err
+	}
+
+	switch res[0].(int64) {
+	case 1:
+		return true, nil
+	case 2:
+		return false, fmt.Errorf("%w: remove at of queue %q", ErrWrongType, queueName)
+	default:
+		return false, nil
+	}
+}
+
+// ReplaceQueue заменяет содержимое очереди командами DEL и RPUSH в транзакции MULTI/EXEC,
+// поэтому другие клиенты не видят пустую или частично заполненную очередь.
+// Значения сериализуются кодеком хранилища до начала транзакции.
+func (s *redisStorage[T]) ReplaceQueue(ctx context.Context, queueName string, values []T) error {
+	items := make([]any, len(values))
+	for i, value := range values {
+		data, err := s.opts.marshalValue(value)
+		if err != nil {
+			return err
+		}
+		items[i] = data
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 1*time.Second)
+	defer cancel()
+
+	key := s.queueKey(queueName)
+	if s.opts.queueTypeCheck {
+		tag, err := s.valuesTag(queueName, values)
+		if err != nil {
+			return err
+		}
+		keys := []string{key, s.queueTagKey(queueName), s.queueConsumedKey(queueName)}
+		res, err := replaceQueueTaggedScript.Run(ctx, s.client, keys, append([]any{tag}, items...)...).Result()
+		if err != nil {
+			return wrapRedisErr("replace queue", err)
+		}
+		_, err = taggedReply(queueName, tag, res)
+		return err
+	}
+
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, key)
+		if len(items) > 0 {
+			pipe.RPush(ctx, key, items...)
+		}
+		pipe.Set(ctx, s.queueConsumedKey(queueName), 1, 0)
+		return nil
+	})
+	if err != nil {
+		return wrapRedisErr("replace queue", err)
+	}
+	return nil
+}
+
+// rotateQueueScript переносит первый элемент подготовленной очереди (см. queuePrepareLua)
+// в ее конец и помечает очередь разобранной.
+// Возвращает {1, элемент} или {0} для пустой очереди.
+var rotateQueueScript = redis.NewScript(queuePrepareLua + `
+local v = redis.call('LMOVE', KEYS[1], KEYS[1], 'LEFT', 'RIGHT')
+if not v then
+	return {0}
+end
+redis.call('SET', KEYS[4], 1)
+return {1, v}
+`)
+
+// RotateQueue переносит первый элемент очереди в ее конец командой LMOVE key key LEFT RIGHT
+// в Lua-скрипте (см. rotateQueueScript).
+// Значение десериализуется кодеком хранилища перед возвратом.
+func (s *redisStorage[T]) RotateQueue(ctx context.Context, queueName string) (T, bool, error) {
+	var zero T
+
+	ctx, cancel := context.WithTimeout(ctx, 1*time.Second)
+	defer cancel()
+
+	res, err := s.runPrepared(ctx, "lmove", rotateQueueScript, queueName, s.queuePrepareKeys(queueName))
+	if err != nil {
+		return zero, false, err
+	}
+	if res[0].(int64) == 0 {
+		return zero, false, nil // Очередь пуста - это не ошибка
+	}
+	val, _ := res[1].(string)
+	return s.decode(ctx, "rotate", queueName, val, false) // Элемент остается в очереди
+}
+
+// SetHead читает первый элемент очереди командой LINDEX под WATCH и заменяет его
+// командой LSET в транзакции MULTI/EXEC. Если очередь изменилась до EXEC, попытка
+// повторяется (до maxTxAttempts раз, затем возвращается ErrTxConflict).
+// Прежнее значение десериализуется кодеком хранилища перед возвратом.
+func (s *redisStorage[T]) SetHead(ctx context.Context, queueName string, value T) (T, bool, error) {
+	var zero T
+
+	data, err := s.opts.marshalValue(value)
+	if err != nil {
+		return zero, false, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 1*time.Second)
+	defer cancel()
+
+	if err := s.prepareQueue(ctx, queueName, false); err != nil {
+		return zero, false, err
+	}
+
+	key := s.queueKey(queueName)
+	for range maxTxAttempts {
+		var old string
+		err := s.client.Watch(ctx, func(rtx *redis.Tx) error {
+			var err error
+			if old, err = rtx.LIndex(ctx, key, 0).Result(); err != nil {
+				return err
+			}
+			_, err = rtx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				pipe.LSet(ctx, key, 0, data)
+				return nil
+			})
+			return err
+		}, key)
+		if err == redis.TxFailedErr {
+			continue // Очередь изменилась - повторяем
+		}
+		if err == redis.Nil {
+			return zero, false, nil // Очередь пуста - это не ошибка
+		}
+		if err != nil {
+			return zero, false, wrapRedisErr("set head", err)
+		}
+		return s.decode(ctx, "set head", queueName, old, false) // Новое значение уже в очереди
+	}
+	return zero, false, ErrTxConflict
+}
+
+// QueueTail возвращает последние n элементов очереди (от старых к новым)
+// одной командой LRANGE key -n -1.
+// Значения десериализуются кодеком хранилища перед возвратом.
+func (s *redisStorage[T]) QueueTail(ctx context.Context, queueName string, n int) ([]T, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+
+	s.watchQueues(queueName)
+	vals, err := s.queueRange(ctx, queueName, -int64(n), -1)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.decodeQueue(ctx, "queue tail", queueName, vals)
+}
+
+// QueueReadFrom читает до n элементов очереди с позиции offset командой
+// LRANGE key offset offset+n-1, которая отправляется в одном конвейере с проверкой
+// метки разобранной очереди (EXISTS). Значения десериализуются кодеком хранилища.
+func (s *redisStorage[T]) QueueReadFrom(ctx context.Context, queueName string, offset int64, n int) ([]T, int64, error) {
+	if offset < 0 {
+		return nil, offset, ErrInvalidOffset
+	}
+	if n <= 0 {
+		return nil, offset, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 1*time.Second)
+	defer cancel()
+
+	s.watchQueues(queueName)
+	var consumed *redis.IntCmd
+	var page *redis.StringSliceCmd
+	_, err := s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		consumed = pipe.Exists(ctx, s.queueConsumedKey(queueName))
+		page = pipe.LRange(ctx, s.queueKey(queueName), offset, offset+int64(n)-1)
+		return nil
+	})
+	if err != nil {
+		return nil, offset, wrapRedisErr("lrange", err)
+	}
+	if consumed.Val() > 0 {
+		return nil, offset, fmt.Errorf("%w: %q", ErrQueueConsumed, queueName)
+	}
+	vals := page.Val()
+
+	items, err := s.decodeQueue(ctx, "queue read from", queueName, vals)
+	if err != nil {
+		return nil, offset, err
+	}
+	return items, offset + int64(len(vals)), nil // Пропущенные элементы тоже сдвигают смещение
+}
+
+// queueIteratePage - количество элементов, читаемых QueueIterate одной командой LRANGE.
+const queueIteratePage = 1000
+
+// QueueIterate читает очередь окнами по queueIteratePage элементов командами LRANGE
+// и передает элементы fn, поэтому в памяти находится не больше одного окна.
+// Окна выбираются по позиции: Dequeue во время обхода сдвигает элементы к началу,
+// и часть их пропускается, а добавление в начало (например, возврат резервирований)
+// приводит к повторной передаче.
+// Значения десериализуются кодеком хранилища; ошибка десериализации прекращает обход
+// (с WithSkipCorrupt поврежденный элемент пропускается).
+func (s *redisStorage[T]) QueueIterate(ctx context.Context, queueName string, fn func(T) error) error {
+	s.watchQueues(queueName)
+
+	for offset := int64(0); ; offset += queueIteratePage {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		vals, err := s.queueRange(ctx, queueName, offset, offset+queueIteratePage-1)
+		if err != nil {
+			return err
+		}
+		for _, val := range vals {
+			out, found, err := s.decode(ctx, "queue iterate", queueName, val, false)
+			if err != nil {
+				return err
+			}
+			if !found {
+				continue // Поврежденный элемент пропущен (WithSkipCorrupt)
+			}
+			if err := fn(out); err != nil {
+				return err
+			}
+		}
+		if len(vals) < queueIteratePage {
+			return nil
+		}
+	}
+}
+
+// QueueLen возвращает текущую длину очереди.
+// Возвращает количество элементов в очереди и ошибку, если операция не удалась.
+// Для несуществующей очереди возвращает 0, для ключа другого типа - ErrWrongType.
+func (s *redisStorage[T]) QueueLen(ctx context.Context, queueName string) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 1*time.Second)
+	defer cancel()
+
+	length, err := s.reader().LLen(ctx, s.queueKey(queueName)).Result()
+	if err != nil {
+		return 0, wrapRedisErr("llen", err)
+	}
+
+	return length, nil
+}
+
+// QueueStats возвращает длину очереди (LLEN) и скорости, измеренные этим процессом.
+// Операции других клиентов Redis в скоростях не учитываются.
+func (s *redisStorage[T]) QueueStats(ctx context.Context, queueName string) (QueueStats, error) {
+	length, err := s.QueueLen(ctx, queueName)
+	if err != nil {
+		return QueueStats{}, err
+	}
+	return s.rates.stats(queueName, length), nil
+}
+
+// QueueLenMany возвращает длины нескольких очередей.
+// Команды LLEN отправляются одним конвейером (pipeline) за один сетевой вызов.
+// Несуществующие очереди отображаются в 0.
+func (s *redisStorage[T]) QueueLenMany(ctx context.Context, queueNames []string) (map[string]int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 1*time.Second)
+	defer cancel()
+
+	lengths := make(map[string]int64, len(queueNames))
+	if len(queueNames) == 0 {
+		return lengths, nil
+	}
+
+	cmds := make([]*redis.IntCmd, len(queueNames))
+	pipe := s.client.Pipeline()
+	for i, name := range queueNames {
+		cmds[i] = pipe.LLen(ctx, s.queueKey(name))
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, wrapRedisErr("llen pipeline", err)
+	}
+
+	for i, name := range queueNames {
+		lengths[name] = cmds[i].Val()
+	}
+	return lengths, nil
+}
+
+// decodeQueue десериализует элементы очереди queueName через decode: в режиме
+// WithSkipCorrupt поврежденные элементы пропускаются и остаются в очереди.
+func (s *redisStorage[T]) decodeQueue(ctx context.Context, op, queueName string, vals []string) ([]T, error) {
+	items := make([]T, 0, len(vals))
+	for _, val := range vals {
+		out, found, err := s.decode(ctx, op, queueName, val, false)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			items = append(items, out)
+		}
+	}
+	return items, nil
+}
+
+// QueueIndexOf возвращает позицию первого элемента очереди, удовлетворяющего условию.
+// Очередь читается страницами командой LRANGE, каждый элемент десериализуется
+// и проверяется условием. Таймаут применяется к каждой странице отдельно.
+// Если очередь изменяется во время поиска, индекс может оказаться неточным.
+func (s *redisStorage[T]) QueueIndexOf(ctx context.Context, queueName string, match func(T) bool) (int64, bool, error) {
+	for start := int64(0); ; start += scanCount {
+		page, err := s.queueRange(ctx, queueName, start, start+scanCount-1)
+		if err != nil {
+			return 0, false, err
+		}
+
+		for i, val := range page {
+			out, found, err := s.decode(ctx, "queue index of", queueName, val, false)
+			if err != nil {
+				return 0, false, err
+			}
+			if found && match(out) {
+				return start + int64(i), true, nil
+			}
+		}
+
+		if len(page) < scanCount {
+			return 0, false, nil // Достигнут конец очереди
+		}
+	}
+}
+
+// QueueIndexOfValue возвращает позицию первого элемента очереди, равного value.
+// Значение сериализуется и ищется на стороне Redis командой LPOS (Redis 6.0.6+).
+func (s *redisStorage[T]) QueueIndexOfValue(ctx context.Context, queueName string, value T) (int64, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 1*time.Second)
+	defer cancel()
+
+	data, err := s.opts.codec.Marshal(value)
+	if err != nil {
+		return 0, false, fmt.Errorf("marshal failed: %w", err)
+	}
+
+	idx, err := s.client.LPos(ctx, s.queueKey(queueName), string(data), redis.LPosArgs{}).Result()
+	if err == redis.Nil {
+		return 0, false, nil // Элемент не найден - это не ошибка
+	}
+	if err != nil {
+		return 0, false, wrapRedisErr("lpos", err)
+	}
+	return idx, true, nil
+}
+
+// queueRange возвращает сериализованные элементы очереди с индексами от start до stop.
+func (s *redisStorage[T]) queueRange(ctx context.Context, queueName string, start, stop int64) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, 1*time.Second)
+	defer cancel()
+
+	page, err := s.client.LRange(ctx, s.queueKey(queueName), start, stop).Result()
+	if err != nil {
+		return nil, wrapRedisErr("lrange", err)
+	}
+	return page, nil
+}
+
+// PeekBatchReserve атомарно (Lua-скриптом) переносит до n элементов из начала очереди
+// в резервирование со временем видимости vt. Время истечения отсчитывается по часам Redis.
+// Истекшие резервирования очереди возвращаются в ее начало перед каждым извлечением
+// из очереди любым процессом, а также фоновой горутиной каждого хранилища, которое
+// читало очередь.
+// Если элемент не удалось десериализовать, возвращается ошибка, а пачка
+// остается зарезервированной и вернется в очередь по истечении vt; с WithSkipCorrupt
+// поврежденный элемент пропускается и удаляется вместе с пачкой в AckBatch.
+func (s *redisStorage[T]) PeekBatchReserve(ctx context.Context, queueName string, n int, vt time.Duration) ([]T, string, error) {
+	if n <= 0 {
+		return nil, "", nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 1*time.Second)
+	defer cancel()
+
+	token := newReservationToken()
+	key := s.queueKey(queueName)
+	keys := s.queuePrepareKeys(queueName, inflightItemsKey(key, token))
+	res, err := s.runPrepared(ctx, "reserve", reserveScript, queueName, keys, n, token, max(vt.Milliseconds(), 1))
+	if err != nil {
+		return nil, "", err
+	}
+	vals := res[1:]
+	if len(vals) == 0 {
+		return nil, "", nil // Очередь пуста - это не ошибка
+	}
+	s.releaseQueueTag(ctx, queueName)
+	s.rates.dequeued(queueName, len(vals))
+
+	data := make([]string, len(vals))
+	for i, val := range vals {
+		data[i], _ = val.(string)
+	}
+	items, err := s.decodeQueue(ctx, "reserve", queueName, data)
+	if err != nil {
+		return nil, "", err
+	}
+	return items, token, nil
+}
+
+// AckBatch удаляет резервирование вместе с его элементами.
+// Если резервирование уже истекло и элементы вернулись в очередь,
+// возвращает ErrReservationNotFound.
+func (s *redisStorage[T]) AckBatch(ctx context.Context, queueName, token string) error {
+	ctx, cancel := context.WithTimeout(ctx, 1*time.Second)
+	defer cancel()
+
+	key := s.queueKey(queueName)
+	keys := []string{inflightKey(key), inflightItemsKey(key, token)}
+	acked, err := ackScript.Run(ctx, s.client, keys, token).Int64()
+	if err != nil {
+		return wrapRedisErr("ack", err)
+	}
+	if acked == 0 {
+		return ErrReservationNotFound
+	}
+	return nil
+}
+
+// IncrWindow увеличивает счетчик окна Lua-скриптом (INCR и PEXPIRE при первом увеличении),
+// поэтому конкурентные вызовы из разных процессов не сбрасывают окно и не теряют увеличений.
+// Окно отсчитывается по часам Redis.
+func (s *redisStorage[T]) IncrWindow(ctx context.Context, key string, window time.Duration, limit int64) (int64, bool, error) {
+	if window <= 0 || limit <= 0 {
+		return 0, false, ErrInvalidRateLimit
+	}
+	key, err := s.opts.checkKey(key)
+	if err != nil {
+		return 0, false, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 1*time.Second)
+	defer cancel()
+
+	windowMs := max(window.Milliseconds(), 1)
+	count, err := incrWindowScript.Run(ctx, s.client, []string{s.windowKey(key)}, windowMs).Int64()
+	if err != nil {
+		return 0, false, wrapRedisErr("incr window", err)
+	}
+	return count, count <= limit, nil
+}
+
+// SAddEx добавляет элемент в сортированное множество с временем истечения в качестве веса.
+// Истекшие элементы удаляются, а ключу назначается время истечения самого позднего элемента.
+// Время отсчитывается по часам Redis.
+func (s *redisStorage[T]) SAddEx(ctx context.Context, setName string, member T, ttl time.Duration) error {
+	data, err := s.opts.codec.Marshal(member)
+	if err != nil {
+		return fmt.Errorf("marshal failed: %w", err)
+	}
+
+	var ttlMs int64
+	if ttl > 0 {
+		ttlMs = max(ttl.Milliseconds(), 1)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 1*time.Second)
+	defer cancel()
+
+	if err := saddExScript.Run(ctx, s.client, []string{s.setKey(setName)}, data, ttlMs).Err(); err != nil {
+		return wrapRedisErr("saddex", err)
+	}
+	return nil
+}
+
+// SCount удаляет истекшие элементы множества и возвращает количество оставшихся.
+func (s *redisStorage[T]) SCount(ctx context.Context, setName string) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 1*time.Second)
+	defer cancel()
+
+	n, err := scountScript.Run(ctx, s.client, []string{s.setKey(setName)}).Int64()
+	if err != nil {
+		return 0, wrapRedisErr("scount", err)
+	}
+	return n, nil
+}
+
+// watchQueue добавляет очередь в список (reserved или delayed), обслуживаемый
+// фоновой горутиной, и запускает ее при первом вызове.
+func (s *redisStorage[T]) watchQueue(list *sync.Map, queueName string) {
+	if _, ok := list.Load(queueName); !ok {
+		list.Store(queueName, struct{}{})
+	}
+	s.maintainOnce.Do(func() {
+		go s.runMaintenance()
+	})
+}
+
+// runMaintenance периодически возвращает в очереди истекшие резервирования
+// и переносит в них готовые отложенные элементы.
+// Работает в фоновой горутине до закрытия хранилища.
+func (s *redisStorage[T]) runMaintenance() {
+	ticker := time.NewTicker(maintenanceInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.maintain(&s.reserved, "requeue expired reservations", s.requeueExpired)
+			s.maintain(&s.delayed, "promote delayed items", s.promoteDelayed)
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// maintain выполняет task для каждой очереди из списка, записывая ошибки в лог.
+func (s *redisStorage[T]) maintain(list *sync.Map, what string, task func(ctx context.Context, queueName string) error) {
+	list.Range(func(key, _ any) bool {
+		ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+		defer cancel()
+
+		if err := task(ctx, key.(string)); err != nil {
+			s.opts.logger.WarnContext(ctx, "storage: failed to "+what,
+				slog.String("queue", key.(string)), slog.Any("error", err))
+		}
+		return true
+	})
+}
+
+// requeueExpired возвращает элементы истекших резервирований в начало очереди.
+func (s *redisStorage[T]) requeueExpired(ctx context.Context, queueName string) error {
+	key := s.queueKey(queueName)
+	tokens, err := expiredTokensScript.Run(ctx, s.client, []string{inflightKey(key)}).StringSlice()
+	if err != nil {
+		return wrapRedisErr("expired reservations", err)
+	}
+	return s.requeueTokens(ctx, key, tokens)
+}
+
+// requeueTokens возвращает резервирования tokens (от раньше истекших к позже истекшим)
+// в начало очереди key по одному скрипту на резервирование, объявляя все ключи в KEYS.
+// Позже истекшие пачки возвращаются первыми, поэтому раньше зарезервированные
+// оказываются ближе к началу очереди.
+func (s *redisStorage[T]) requeueTokens(ctx context.Context, key string, tokens []string) error {
+	for _, token := range slices.Backward(tokens) {
+		keys := []string{key, inflightKey(key), inflightItemsKey(key, token)}
+		if err := requeueTokenScript.Run(ctx, s.client, keys, token).Err(); err != nil {
+			return wrapRedisErr("requeue", err)
+		}
+	}
+	return nil
+}
+
+// decode десериализует значение, прочитанное из Redis.
+// В режиме WithSkipCorrupt поврежденное значение пропускается: возвращается found=false
+// без ошибки, а deletable определяет, можно ли удалить значение по ключу.
+func (s *redisStorage[T]) decode(ctx context.Context, op, key, val string, deletable bool) (T, bool, error) {
+	var out T
+	if err := s.opts.codec.Unmarshal([]byte(val), &out); err != nil {
+		return s.corrupt(ctx, op, key, err, deletable)
+	}
+
+	return out, true, nil
+}
+
+// Transact выполняет fn как оптимистичную транзакцию: ключи, прочитанные через Tx,
+// отслеживаются командой WATCH, а буферизованные записи применяются в MULTI/EXEC.
+// Если отслеживаемый ключ изменился до EXEC, транзакция повторяется заново
+// (до maxTxAttempts раз, затем возвращается ErrTxConflict), поэтому fn может
+// быть вызвана несколько раз и не должна иметь внешних побочных эффектов.
+// В Redis Cluster все ключи транзакции должны находиться в одном слоте (hash tag).
+func (s *redisStorage[T]) Transact(ctx context.Context, fn func(tx Tx[T]) error) error {
+	ctx, cancel := context.WithTimeout(ctx, 1*time.Second)
+	defer cancel()
+
+	for range maxTxAttempts {
+		var written []string
+		err := s.client.Watch(ctx, func(rtx *redis.Tx) error {
+			tx := &redisTx[T]{ctx: ctx, s: s, rtx: rtx}
+			if err := fn(tx); err != nil {
+				return err
+			}
+			written = tx.writes.keys
+			return tx.commit()
+		})
+		if err == redis.TxFailedErr {
+			continue // Прочитанные ключи изменились - повторяем транзакцию
+		}
+		if err != nil {
+			return err
+		}
+		s.invalidate(written...)
+		return nil
+	}
+	return ErrTxConflict
+}
+
+// redisTx - оптимистичная транзакция Redis на выделенном соединении.
+type redisTx[T any] struct {
+	ctx    context.Context
+	s      *redisStorage[T]
+	rtx    *redis.Tx
+	writes txWrites[T]
+}
+
+// Get отслеживает ключ командой WATCH и читает значение с учетом буфера транзакции.
+func (tx *redisTx[T]) Get(key string) (T, bool, error) {
+	var zero T
+
+	key, err := tx.s.checkKey(key)
+	if err != nil {
+		return zero, false, err
+	}
+	if value, written, found := tx.writes.get(key); written {
+		return value, found, nil
+	}
+
+	if err := tx.rtx.Watch(tx.ctx, key).Err(); err != nil {
+		return zero, false, wrapRedisErr("watch", err)
+	}
+	val, err := tx.rtx.Get(tx.ctx, key).Result()
+	if err == redis.Nil {
+		return zero, false, nil
+	}
+	if err != nil {
+		return zero, false, wrapRedisErr("get", err)
+	}
+	out, _, found, err := tx.s.decodeValue(tx.ctx, "get", key, val, false)
+	return out, found, err
+}
+
+// Set добавляет запись в буфер транзакции.
+func (tx *redisTx[T]) Set(key string, value T, ttl time.Duration) error {
+	key, origKey, err := tx.s.resolveKey(key)
+	if err != nil {
+		return err
+	}
+	tx.writes.put(key, txWrite[T]{value: value, ttl: tx.s.opts.jitterTTL(ttl), origKey: origKey})
+	return nil
+}
+
+// Delete добавляет удаление в буфер транзакции.
+func (tx *redisTx[T]) Delete(key string) error {
+	key, err := tx.s.checkKey(key)
+	if err != nil {
+		return err
+	}
+	tx.writes.put(key, txWrite[T]{deleted: true})
+	return nil
+}
+
+// Increment читает значение (с WATCH), увеличивает его и добавляет результат в буфер.
+func (tx *redisTx[T]) Increment(key string, delta int64) (int64, error) {
+	current, _, err := tx.Get(key)
+	if err != nil {
+		return 0, err
+	}
+	next, n, err := addInt(current, delta)
+	if err != nil {
+		return 0, err
+	}
+	return n, tx.setKeepTTL(key, next)
+}
+
+// setKeepTTL добавляет в буфер транзакции запись, сохраняющую время жизни ключа (KEEPTTL).
+func (tx *redisTx[T]) setKeepTTL(key string, value T) error {
+	key, origKey, err := tx.s.resolveKey(key)
+	if err != nil {
+		return err
+	}
+	tx.writes.putKeepTTL(key, origKey, value)
+	return nil
+}
+
+// commit применяет буферизованные записи в MULTI/EXEC.
+func (tx *redisTx[T]) commit() error {
+	if len(tx.writes.keys) == 0 {
+		return nil
+	}
+
+	payloads := make(map[string][]byte, len(tx.writes.keys))
+	for _, key := range tx.writes.keys {
+		write := tx.writes.writes[key]
+		if write.deleted {
+			continue
+		}
+		data, err := tx.s.opts.marshalValue(write.value)
+		if err != nil {
+			return err
+		}
+		payloads[key] = data
+	}
+
+	_, err := tx.rtx.TxPipelined(tx.ctx, func(pipe redis.Pipeliner) error {
+		for _, key := range tx.writes.keys {
+			write := tx.writes.writes[key]
+			switch data := payloads[key]; {
+			case write.deleted:
+				pipe.Del(tx.ctx, key)
+			case tx.s.opts.metadata:
+				setMetaScript.Eval(tx.ctx, pipe, []string{key}, setMetaArgs(data, write.ttl, write.origKey)...)
+			case write.ttl > 0:
+				pipe.Set(tx.ctx, key, data, write.ttl)
+			default:
+				pipe.Set(tx.ctx, key, data, redis.KeepTTL)
+			}
+		}
+		return nil
+	})
+	if err == redis.TxFailedErr {
+		return err
+	}
+	if err != nil {
+		return wrapRedisErr("exec", err)
+	}
+
+	// Теги ключей (SetWithTags) обновляются после EXEC: в Redis Cluster
+	// они находятся в других слотах и не могут участвовать в транзакции
+	var deleted []string
+	pipe := tx.s.client.Pipeline()
+	for _, key := range tx.writes.keys {
+		if write := tx.writes.writes[key]; write.deleted {
+			deleted = append(deleted, key)
+		} else {
+			tx.s.expireKeyTags(tx.ctx, pipe, key, max(write.ttl, 0))
+		}
+	}
+	if pipe.Len() > 0 {
+		_, _ = pipe.Exec(tx.ctx)
+	}
+	if len(deleted) > 0 {
+		tx.s.dropKeyTags(tx.ctx, deleted...)
+	}
+	return nil
+}
+
+// decodeValue десериализует значение записи ключ-значение.
+// В режиме WithMetadata значение извлекается из конверта вместе с метаданными.
+func (s *redisStorage[T]) decodeValue(ctx context.Context, op, key, val string, deletable bool) (T, Meta, bool, error) {
+	if !s.opts.metadata {
+		out, found, err := s.decode(ctx, op, key, val, deletable)
+		return out, Meta{}, found, err
+	}
+
+	var env metaEnvelope
+	if err := json.Unmarshal([]byte(val), &env); err != nil {
+		out, found, err := s.corrupt(ctx, op, key, err, deletable)
+		return out, Meta{}, found, err
+	}
+
+	var out T
+	if err := s.opts.codec.Unmarshal(env.V, &out); err != nil {
+		out, found, err := s.corrupt(ctx, op, key, err, deletable)
+		return out, Meta{}, found, err
+	}
+	return out, Meta{CreatedAt: env.C, UpdatedAt: env.U, Key: env.K}, true, nil
+}
+
+// corrupt формирует результат чтения поврежденного значения.
+// В режиме WithSkipCorrupt возвращает found=false без ошибки, иначе - ошибку десериализации.
+func (s *redisStorage[T]) corrupt(ctx context.Context, op, key string, cause error, deletable bool) (T, bool, error) {
+	var zero T
+	if s.opts.skipCorrupt {
+		s.skipCorrupt(ctx, op, key, cause, deletable)
+		return zero, false, nil
+	}
+	return zero, false, fmt.Errorf("unmarshal failed: %w", cause)
+}
+
+// skipCorrupt обрабатывает поврежденное значение в режиме WithSkipCorrupt.
+// Записывает предупреждение в логгер и, если разрешено опцией WithDeleteCorrupt
+// и значение хранится по ключу (deletable), удаляет его.
+func (s *redisStorage[T]) skipCorrupt(ctx context.Context, op, key string, cause error, deletable bool) {
+	s.opts.logger.WarnContext(ctx, "storage: skipping corrupt value",
+		slog.String("op", op), slog.String("key", key), slog.Any("error", cause))
+
+	if !deletable || !s.opts.deleteCorrupt {
+		return
+	}
+	if err := s.client.Del(ctx, key).Err(); err != nil {
+		s.opts.logger.WarnContext(ctx, "storage: failed to delete corrupt value",
+			slog.String("key", key), slog.Any("error", err))
+		return
+	}
+	s.dropKeyTags(ctx, key)
+}
+
+// HotKeys возвращает до n самых часто используемых ключей.
+// Учитываются обращения через этот экземпляр хранилища (на стороне клиента).
+// Если отслеживание не включено опцией WithHotKeyTracking, возвращает nil.
+func (s *redisStorage[T]) HotKeys(n int) []KeyCount {
+	top := s.hot.top(n)
+	for i := range top {
+		top[i].Key = s.userKey(top[i].Key)
+	}
+	return top
+}
+
+// invalidate удаляет ключи из кэша на стороне клиента (если он включен).
+// Позволяет сразу видеть собственные изменения, не дожидаясь сообщения от Redis.
+func (s *redisStorage[T]) invalidate(keys ...string) {
+	if s.cache != nil {
+		s.cache.invalidate(keys...)
+	}
+}
+
+// Capabilities возвращает возможности хранилища Redis.
+// Метаданные, популярные ключи и кэш клиента учитываются, только если включены.
+func (s *redisStorage[T]) Capabilities() Capability {
+	caps := CapTTL | CapQueues | CapPipeline | CapPatternDelete | CapScan | CapPersistence | CapShared | CapTransactions
+	if s.opts.metadata {
+		caps |= CapMetadata
+	}
+	if s.hot != nil {
+		caps |= CapHotKeys
+	}
+	if s.cache != nil {
+		caps |= CapClientCache
+	}
+	return caps
+}
+
+// Close закрывает соединение с Redis.
+// Перед этим вызывает Flush хуков WithHook. Также останавливает фоновый возврат
+// резервирований и, если включен кэш на стороне клиента, подписчика инвалидаций.
+// Клиенты реплик закрываются всегда, клиент, переданный в NewRedisWithClient, - нет.
+// Должен вызываться при завершении работы с хранилищем.
+// Ошибки закрытия объединяются и оборачиваются; их причины доступны через errors.Is.
+// Повторный вызов безопасен и возвращает ErrClosed.
+func (s *redisStorage[T]) Close() error {
+	if !s.closing.CompareAndSwap(false, true) {
+		return ErrClosed
+	}
+	hookErr := flushHooks(s.opts.hooks, nil)
+	close(s.stop) // Останавливаем фоновые горутины
+
+	var cacheErr error
+	if s.cache != nil {
+		cacheErr = s.cache.close()
+	}
+	errs := []error{hookErr, cacheErr}
+	for _, replica := range s.replicas {
+		errs = append(errs, replica.Close())
+	}
+	if s.owned {
+		errs = append(errs, s.client.Close())
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("redis close failed: %w", err)
+	}
+	return nil
+}
+
+// Pipeline создает пакет команд, отправляемых в Redis одним сетевым вызовом.
+func (s *redisStorage[T]) Pipeline() Pipeline[T] {
+	return &redisPipeline[T]{s: s}
+}
+
+// redisPipeline накапливает команды и отправляет их через redis.Pipeliner.
+type redisPipeline[T any] struct {
+	pipelineOps[T]
+	s *redisStorage[T]
+}
+
+// Exec отправляет накопленные команды в Redis за один сетевой вызов.
+// Ошибки сериализации и ошибки отдельных команд возвращаются в результатах,
+// отсутствие ключа для Get ошибкой не считается.
+func (p *redisPipeline[T]) Exec(ctx context.Context) ([]PipelineResult[T], error) {
+	ops := p.take()
+	results := make([]PipelineResult[T], len(ops))
+	if len(ops) == 0 {
+		return results, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 1*time.Second)
+	defer cancel()
+
+	cmds := make([]redis.Cmder, len(ops))
+	pttls := make([]*redis.DurationCmd, len(ops)) // Оставшееся время жизни ключей Get
+	takes := make([]*redis.Cmd, len(ops))         // Теги удаляемых ключей (см. takeKeyTags)
+	pipe := p.s.client.Pipeline()
+	for i, op := range ops {
+		var origKey string
+		if op.kind != pipelineEnqueue {
+			key, orig, err := p.s.resolveKey(op.key)
+			if err != nil {
+				results[i].Err = err
+				continue
+			}
+			ops[i].key, op.key, origKey = key, key, orig // Нормализованный ключ нужен и при разборе ответов
+		}
+
+		switch op.kind {
+		case pipelineSet, pipelineEnqueue:
+			data, err := p.s.opts.marshalValue(op.value)
+			if err != nil {
+				results[i].Err = err
+				continue
+			}
+			ttl := p.s.opts.jitterTTL(op.ttl)
+			if op.kind == pipelineEnqueue && p.s.opts.queueTypeCheck {
+				keys := []string{p.s.queueKey(op.key), p.s.queueTagKey(op.key)}
+				cmds[i] = enqueueTaggedScript.Eval(ctx, pipe, keys, p.s.valueTag(op.value), data)
+			} else if op.kind == pipelineEnqueue {
+				cmds[i] = pipe.RPush(ctx, p.s.queueKey(op.key), data)
+			} else if p.s.opts.metadata {
+				cmds[i] = setMetaScript.Eval(ctx, pipe, []string{op.key}, setMetaArgs(data, ttl, origKey)...)
+			} else if ttl > 0 {
+				cmds[i] = pipe.Set(ctx, op.key, data, ttl)
+			} else {
+				cmds[i] = pipe.Set(ctx, op.key, data, redis.KeepTTL)
+			}
+			if op.kind == pipelineSet {
+				p.s.expireKeyTags(ctx, pipe, op.key, max(ttl, 0))
+			}
+		case pipelineGet:
+			cmds[i] = pipe.Get(ctx, op.key)
+			pttls[i] = pipe.PTTL(ctx, op.key)
+		case pipelineDelete:
+			cmds[i] = pipe.Del(ctx, op.key)
+			takes[i] = p.s.takeKeyTags(ctx, pipe, op.key)
+		}
+	}
+
+	// Ошибка Exec дублирует ошибку первой неудачной команды (включая redis.Nil),
+	// поэтому результаты разбираются по каждой команде отдельно
+	_, _ = pipe.Exec(ctx)
+
+	for i, cmd := range cmds {
+		if cmd == nil {
+			continue // Команда не отправлялась из-за ошибки сериализации
+		}
+		err := cmd.Err()
+		if ops[i].kind == pipelineGet {
+			if err == redis.Nil {
+				continue // Ключ не найден - это не ошибка
+			}
+			if err == nil {
+				results[i].Value, _, results[i].Found, results[i].Err =
+					p.s.decodeValue(ctx, "get", ops[i].key, cmd.(*redis.StringCmd).Val(), true)
+				if results[i].Found {
+					// PTTL возвращает -1 для ключа без времени жизни
+					results[i].TTL = max(pttls[i].Val(), 0)
+				}
+				continue
+			}
+		}
+		if err != nil {
+			results[i].Err = wrapRedisErr("pipeline "+cmd.Name(), err)
+			continue
+		}
+		switch ops[i].kind {
+		case pipelineSet, pipelineDelete:
+			p.s.invalidate(ops[i].key)
+		case pipelineEnqueue:
+			if cmd, ok := cmd.(*redis.Cmd); ok {
+				if _, err := taggedReply(ops[i].key, p.s.valueTag(ops[i].value), cmd.Val()); err != nil {
+					results[i].Err = err
+					continue
+				}
+			}
+			p.s.rates.enqueued(ops[i].key, 1)
+		}
+	}
+
+	var deleted []string
+	var deletedTags []*redis.Cmd
+	for i, take := range takes {
+		if take != nil {
+			deleted = append(deleted, ops[i].key)
+			deletedTags = append(deletedTags, take)
+		}
+	}
+	p.s.untag(ctx, deleted, deletedTags)
+
+	return results, firstErr(results)
 }
@@ -1,12 +1,19 @@
 package storage_test
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/alfzs/go-storage"
+	"github.com/redis/go-redis/v9"
 	"github.com/stretchr/testify/require"
 )
 
@@ -92,3 +99,1573 @@ func TestRedisStorage_ConcurrentAccess(t *testing.T) {
 
 	wg.Wait()
 }
+
+func TestRedisStorage_QueueLenMany(t *testing.T) {
+	ctx := context.Background()
+	s := newTestRedisStorage[string](t)
+	defer s.Close()
+
+	for _, name := range []string{"lenmany:q1", "lenmany:q2", "lenmany:missing"} {
+		require.NoError(t, s.Delete(ctx, name))
+	}
+
+	require.NoError(t, s.Enqueue(ctx, "lenmany:q1", "a"))
+	require.NoError(t, s.Enqueue(ctx, "lenmany:q1", "b"))
+	require.NoError(t, s.Enqueue(ctx, "lenmany:q2", "c"))
+
+	lengths, err := s.QueueLenMany(ctx, []string{"lenmany:q1", "lenmany:q2", "lenmany:missing"})
+	require.NoError(t, err)
+	require.Equal(t, map[string]int64{"lenmany:q1": 2, "lenmany:q2": 1, "lenmany:missing": 0}, lengths)
+}
+
+func TestRedisStorage_QueueDeletedBetweenOperations(t *testing.T) {
+	ctx := context.Background()
+	s := newTestRedisStorage[string](t)
+	defer s.Close()
+
+	require.NoError(t, s.Enqueue(ctx, "vanishing", "item"))
+	require.NoError(t, s.Delete(ctx, "vanishing"))
+
+	length, err := s.QueueLen(ctx, "vanishing")
+	require.NoError(t, err)
+	require.Zero(t, length)
+
+	removed, err := s.Remove(ctx, "vanishing")
+	require.NoError(t, err)
+	require.False(t, removed)
+
+	_, found, err := s.Peek(ctx, "vanishing")
+	require.NoError(t, err)
+	require.False(t, found)
+}
+
+func TestRedisStorage_QueueTypeCollision(t *testing.T) {
+	ctx := context.Background()
+	s := newTestRedisStorage[string](t)
+	defer s.Close()
+
+	require.NoError(t, s.Set(ctx, "collision", "plain value", 0))
+	defer s.Delete(ctx, "collision")
+
+	_, err := s.QueueLen(ctx, "collision")
+	require.ErrorIs(t, err, storage.ErrWrongType)
+
+	_, err = s.Remove(ctx, "collision")
+	require.ErrorIs(t, err, storage.ErrWrongType)
+
+	err = s.Enqueue(ctx, "collision", "item")
+	require.ErrorIs(t, err, storage.ErrWrongType)
+}
+
+func TestRedisStorage_SkipCorrupt(t *testing.T) {
+	ctx := context.Background()
+	writer := newTestRedisStorage[string](t)
+	defer writer.Close()
+
+	reader, err := storage.NewRedis[int](storage.RedisConfig{Addr: "localhost:6379"},
+		storage.WithSkipCorrupt(true), storage.WithDeleteCorrupt(true))
+	require.NoError(t, err)
+	defer reader.Close()
+
+	require.NoError(t, writer.Set(ctx, "corrupt", "not a number", 0))
+
+	_, found, err := reader.Get(ctx, "corrupt")
+	require.NoError(t, err)
+	require.False(t, found)
+
+	// Поврежденное значение удалено
+	_, found, err = writer.Get(ctx, "corrupt")
+	require.NoError(t, err)
+	require.False(t, found)
+
+	require.NoError(t, writer.Delete(ctx, "corrupt:queue"))
+	require.NoError(t, writer.Enqueue(ctx, "corrupt:queue", "not a number"))
+
+	_, found, err = reader.Dequeue(ctx, "corrupt:queue")
+	require.NoError(t, err)
+	require.False(t, found)
+
+	// Чтения без извлечения пропускают поврежденный элемент, оставляя его в очереди
+	require.NoError(t, writer.Enqueue(ctx, "corrupt:queue", "not a number"))
+	require.NoError(t, reader.Enqueue(ctx, "corrupt:queue", 42))
+	_, found, err = reader.Peek(ctx, "corrupt:queue")
+	require.NoError(t, err)
+	require.False(t, found)
+	tail, err := reader.QueueTail(ctx, "corrupt:queue", 10)
+	require.NoError(t, err)
+	require.Equal(t, []int{42}, tail)
+	n, err := reader.QueueLen(ctx, "corrupt:queue")
+	require.NoError(t, err)
+	require.Equal(t, int64(2), n)
+	require.NoError(t, writer.Delete(ctx, "corrupt:queue"))
+}
+
+func TestRedisStorage_CorruptReturnsErrorByDefault(t *testing.T) {
+	ctx := context.Background()
+	writer := newTestRedisStorage[string](t)
+	defer writer.Close()
+	reader := newTestRedisStorage[int](t)
+	defer reader.Close()
+
+	require.NoError(t, writer.Set(ctx, "corrupt", "not a number", 0))
+	defer writer.Delete(ctx, "corrupt")
+
+	_, _, err := reader.Get(ctx, "corrupt")
+	require.Error(t, err)
+}
+
+func TestRedisStorage_CredentialsProvider(t *testing.T) {
+	var calls atomic.Int32
+	s, err := storage.NewRedis[string](storage.RedisConfig{
+		Addr: "localhost:6379",
+		CredentialsProvider: func(ctx context.Context) (string, string, error) {
+			calls.Add(1)
+			return "", "", nil
+		},
+	})
+	require.NoError(t, err)
+	defer s.Close()
+
+	require.Positive(t, calls.Load())
+}
+
+func TestRedisStorage_EnqueueN(t *testing.T) {
+	ctx := context.Background()
+	s := newTestRedisStorage[string](t)
+	defer s.Close()
+
+	require.NoError(t, s.Delete(ctx, "enqueuen"))
+
+	n, err := s.EnqueueN(ctx, "enqueuen", "a")
+	require.NoError(t, err)
+	require.Equal(t, int64(1), n)
+
+	n, err = s.EnqueueN(ctx, "enqueuen", "b")
+	require.NoError(t, err)
+	require.Equal(t, int64(2), n)
+}
+
+func TestRedisStorage_EnqueueFanout(t *testing.T) {
+	ctx := context.Background()
+	s := newTestRedisStorage[string](t)
+	defer s.Close()
+
+	require.NoError(t, s.Delete(ctx, "fanout:a"))
+	require.NoError(t, s.Delete(ctx, "fanout:b"))
+	require.NoError(t, s.Set(ctx, "fanout:plain", "value", 0))
+	defer s.Delete(ctx, "fanout:plain")
+
+	// Ошибка одной очереди не мешает добавлению в остальные
+	err := s.EnqueueFanout(ctx, []string{"fanout:a", "fanout:plain", "fanout:b"}, "event")
+	var batchErr *storage.BatchError
+	require.ErrorAs(t, err, &batchErr)
+	require.Len(t, batchErr.Errs, 1)
+	require.ErrorIs(t, batchErr.Errs["fanout:plain"], storage.ErrWrongType)
+	require.ErrorIs(t, err, storage.ErrWrongType)
+
+	lengths, err := s.QueueLenMany(ctx, []string{"fanout:a", "fanout:b"})
+	require.NoError(t, err)
+	require.Equal(t, map[string]int64{"fanout:a": 1, "fanout:b": 1}, lengths)
+}
+
+func TestRedisStorage_DequeueTracked(t *testing.T) {
+	ctx := context.Background()
+	s := newTestRedisStorage[string](t)
+	defer s.Close()
+
+	require.NoError(t, s.Delete(ctx, "tracked:q"))
+	defer s.Delete(ctx, "tracked:processing")
+
+	require.NoError(t, s.Enqueue(ctx, "tracked:q", "job-1"))
+	val, found, err := s.DequeueTracked(ctx, "tracked:q", "tracked:processing", time.Minute)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "job-1", val)
+
+	tracked, found, err := s.Get(ctx, "tracked:processing")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "job-1", tracked)
+
+	_, found, err = s.DequeueTracked(ctx, "tracked:q", "tracked:processing", time.Minute)
+	require.NoError(t, err)
+	require.False(t, found)
+}
+
+func TestRedisStorage_Pipeline(t *testing.T) {
+	ctx := context.Background()
+	s := newTestRedisStorage[string](t)
+	defer s.Close()
+
+	require.NoError(t, s.Delete(ctx, "pipeline:q"))
+	require.NoError(t, s.Delete(ctx, "pipeline:missing"))
+
+	pipe := s.Pipeline()
+	pipe.Set("pipeline:a", "1", 0)
+	pipe.Get("pipeline:a")
+	pipe.Get("pipeline:missing")
+	pipe.Enqueue("pipeline:q", "item")
+	pipe.Delete("pipeline:a")
+
+	results, err := pipe.Exec(ctx)
+	require.NoError(t, err)
+	require.Len(t, results, 5)
+	require.True(t, results[1].Found)
+	require.Equal(t, "1", results[1].Value)
+	require.False(t, results[2].Found)
+
+	length, err := s.QueueLen(ctx, "pipeline:q")
+	require.NoError(t, err)
+	require.Equal(t, int64(1), length)
+}
+
+func TestRedisStorage_GetDelete(t *testing.T) {
+	ctx := context.Background()
+	s := newTestRedisStorage[string](t)
+	defer s.Close()
+
+	require.NoError(t, s.Set(ctx, "token", "secret", 0))
+
+	val, found, err := s.GetDelete(ctx, "token")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "secret", val)
+
+	_, found, err = s.GetDelete(ctx, "token")
+	require.NoError(t, err)
+	require.False(t, found)
+}
+
+func TestRedisStorage_DeletePattern(t *testing.T) {
+	ctx := context.Background()
+	s := newTestRedisStorage[string](t)
+	defer s.Close()
+
+	_, err := s.DeletePattern(ctx, "purge:*")
+	require.NoError(t, err)
+
+	for i := range 250 {
+		require.NoError(t, s.Set(ctx, fmt.Sprintf("purge:1:%d", i), "x", 0))
+	}
+	require.NoError(t, s.Set(ctx, "purge:2:keep", "x", 0))
+	defer s.Delete(ctx, "purge:2:keep")
+
+	deleted, err := s.DeletePattern(ctx, "purge:1:*")
+	require.NoError(t, err)
+	require.Equal(t, int64(250), deleted)
+
+	_, found, _ := s.Get(ctx, "purge:2:keep")
+	require.True(t, found)
+}
+
+func TestRedisStorage_ScanCount(t *testing.T) {
+	ctx := context.Background()
+	s, err := storage.NewRedis[string](storage.RedisConfig{Addr: "localhost:6379"}, storage.WithScanCount(1000))
+	require.NoError(t, err)
+	defer s.Close()
+
+	for i := range 250 {
+		require.NoError(t, s.Set(ctx, fmt.Sprintf("scancount:%d", i), "x", 0))
+	}
+
+	n, err := s.CountPattern(ctx, "scancount:*")
+	require.NoError(t, err)
+	require.Equal(t, int64(250), n)
+
+	deleted, err := s.DeletePattern(ctx, "scancount:*")
+	require.NoError(t, err)
+	require.Equal(t, int64(250), deleted)
+}
+
+func TestRedisStorage_ClientCacheInvalidation(t *testing.T) {
+	ctx := context.Background()
+	cached, err := storage.NewRedis[string](storage.RedisConfig{
+		Addr:           "localhost:6379",
+		ClientCacheTTL: time.Minute,
+	})
+	require.NoError(t, err)
+	defer cached.Close()
+
+	writer := newTestRedisStorage[string](t)
+	defer writer.Close()
+
+	require.NoError(t, writer.Set(ctx, "tracked", "v1", 0))
+	defer writer.Delete(ctx, "tracked")
+
+	val, found, err := cached.Get(ctx, "tracked")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "v1", val)
+
+	// Изменение другим клиентом приходит сообщением инвалидации
+	require.NoError(t, writer.Set(ctx, "tracked", "v2", 0))
+	require.Eventually(t, func() bool {
+		val, _, err := cached.Get(ctx, "tracked")
+		return err == nil && val == "v2"
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestRedisStorage_ConnectRetryUntilDeadline(t *testing.T) {
+	start := time.Now()
+	_, err := storage.NewRedis[string](storage.RedisConfig{
+		Addr:           "127.0.0.1:1", // Заведомо недоступный адрес
+		ConnectMaxWait: 300 * time.Millisecond,
+		ConnectBackoff: 50 * time.Millisecond,
+	})
+	require.Error(t, err)
+	require.GreaterOrEqual(t, time.Since(start), 150*time.Millisecond)
+}
+
+func TestRedisStorage_ConnectSingleAttemptByDefault(t *testing.T) {
+	start := time.Now()
+	_, err := storage.NewRedis[string](storage.RedisConfig{Addr: "127.0.0.1:1"})
+	require.Error(t, err)
+	require.Less(t, time.Since(start), 200*time.Millisecond)
+}
+
+func TestRedisStorage_ScanPage(t *testing.T) {
+	ctx := context.Background()
+	s := newTestRedisStorage[int](t)
+	defer s.Close()
+
+	_, err := s.DeletePattern(ctx, "scanpage:*")
+	require.NoError(t, err)
+	for i := range 25 {
+		require.NoError(t, s.Set(ctx, fmt.Sprintf("scanpage:%02d", i), i, 0))
+	}
+
+	seen := make(map[string]bool)
+	var cursor uint64
+	for {
+		keys, next, err := s.ScanPage(ctx, cursor, "scanpage:*", 10)
+		require.NoError(t, err)
+		for _, key := range keys {
+			seen[key] = true
+		}
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+	require.Len(t, seen, 25)
+}
+
+func TestRedisStorage_GetEx(t *testing.T) {
+	ctx := context.Background()
+	s := newTestRedisStorage[string](t)
+	defer s.Close()
+
+	require.NoError(t, s.Set(ctx, "getex", "data", time.Second))
+	defer s.Delete(ctx, "getex")
+
+	val, found, err := s.GetEx(ctx, "getex", 3*time.Second)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "data", val)
+
+	time.Sleep(1500 * time.Millisecond)
+	_, found, _ = s.Get(ctx, "getex")
+	require.True(t, found)
+
+	_, found, err = s.GetEx(ctx, "getex", storage.PersistTTL)
+	require.NoError(t, err)
+	require.True(t, found)
+}
+
+func TestRedisStorage_GetWithTTL(t *testing.T) {
+	ctx := context.Background()
+	s := newTestRedisStorage[string](t)
+	defer s.Close()
+
+	require.NoError(t, s.Set(ctx, "getttl:session", "data", time.Minute))
+	defer s.Delete(ctx, "getttl:session")
+	val, ttl, found, err := s.GetWithTTL(ctx, "getttl:session")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "data", val)
+	require.Greater(t, ttl, 59*time.Second)
+
+	require.NoError(t, s.Set(ctx, "getttl:forever", "x", 0))
+	defer s.Delete(ctx, "getttl:forever")
+	_, ttl, found, _ = s.GetWithTTL(ctx, "getttl:forever")
+	require.True(t, found)
+	require.Zero(t, ttl)
+
+	_, _, found, err = s.GetWithTTL(ctx, "getttl:missing")
+	require.NoError(t, err)
+	require.False(t, found)
+}
+
+func TestRedisStorage_Append(t *testing.T) {
+	ctx := context.Background()
+	s := newTestRedisStorage[[]float64](t)
+	defer s.Close()
+	defer s.Delete(ctx, "append:list")
+
+	n, err := storage.Append(ctx, s, "append:list", 1.5)
+	require.NoError(t, err)
+	require.Equal(t, 1, n)
+	_, _, err = s.GetEx(ctx, "append:list", time.Minute)
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := storage.Append(ctx, s, "append:list", float64(i), 1e20)
+			require.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	value, ttl, found, err := s.GetWithTTL(ctx, "append:list")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Len(t, value, 41)
+	require.Equal(t, 1.5, value[0])
+	require.Contains(t, value, 1e20)
+	require.Greater(t, ttl, 59*time.Second)
+
+	raw := newTestRedisStorage[string](t)
+	defer raw.Close()
+	require.NoError(t, raw.Set(ctx, "append:object", "text", 0))
+	defer raw.Delete(ctx, "append:object")
+	_, err = storage.Append(ctx, s, "append:object", 1)
+	require.ErrorIs(t, err, storage.ErrWrongType)
+}
+
+func TestRedisStorage_GetWithMeta(t *testing.T) {
+	ctx := context.Background()
+	s, err := storage.NewRedis[string](storage.RedisConfig{Addr: "localhost:6379"}, storage.WithMetadata(true))
+	require.NoError(t, err)
+	defer s.Close()
+
+	require.NoError(t, s.Delete(ctx, "audit"))
+	require.NoError(t, s.Set(ctx, "audit", "v1", 0))
+	defer s.Delete(ctx, "audit")
+
+	_, first, found, err := s.GetWithMeta(ctx, "audit")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.False(t, first.CreatedAt.IsZero())
+
+	time.Sleep(2 * time.Millisecond)
+	require.NoError(t, s.Set(ctx, "audit", "v2", 0))
+
+	val, second, found, err := s.GetWithMeta(ctx, "audit")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "v2", val)
+	require.True(t, first.CreatedAt.Equal(second.CreatedAt))
+	require.True(t, second.UpdatedAt.After(first.UpdatedAt))
+
+	// Обычное чтение извлекает значение из конверта
+	val, found, err = s.Get(ctx, "audit")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "v2", val)
+}
+
+func TestRedisStorage_QueueIndexOf(t *testing.T) {
+	ctx := context.Background()
+	s := newTestRedisStorage[string](t)
+	defer s.Close()
+
+	require.NoError(t, s.Delete(ctx, "waiting"))
+	for i := range 250 {
+		require.NoError(t, s.Enqueue(ctx, "waiting", fmt.Sprintf("job-%d", i)))
+	}
+
+	idx, found, err := s.QueueIndexOf(ctx, "waiting", func(v string) bool { return v == "job-205" })
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, int64(205), idx)
+
+	idx, found, err = s.QueueIndexOfValue(ctx, "waiting", "job-5")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, int64(5), idx)
+
+	_, found, err = s.QueueIndexOfValue(ctx, "waiting", "job-999")
+	require.NoError(t, err)
+	require.False(t, found)
+}
+
+func TestRedisStorage_PeekBatchReserve(t *testing.T) {
+	ctx := context.Background()
+	s := newTestRedisStorage[int](t)
+	defer s.Close()
+
+	require.NoError(t, s.Delete(ctx, "reserve_jobs"))
+	for i := 1; i <= 4; i++ {
+		require.NoError(t, s.Enqueue(ctx, "reserve_jobs", i))
+	}
+
+	items, token, err := s.PeekBatchReserve(ctx, "reserve_jobs", 2, time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, []int{1, 2}, items)
+
+	length, _ := s.QueueLen(ctx, "reserve_jobs")
+	require.Equal(t, int64(2), length)
+	require.NoError(t, s.AckBatch(ctx, "reserve_jobs", token))
+	require.ErrorIs(t, s.AckBatch(ctx, "reserve_jobs", token), storage.ErrReservationNotFound)
+
+	// Неподтвержденная пачка возвращается в очередь по истечении vt
+	_, _, err = s.PeekBatchReserve(ctx, "reserve_jobs", 1, 50*time.Millisecond)
+	require.NoError(t, err)
+	time.Sleep(100 * time.Millisecond)
+
+	items, _, err = s.PeekBatchReserve(ctx, "reserve_jobs", 10, time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, []int{3, 4}, items)
+}
+
+func TestRedisStorage_ReservationExpiresForOtherProcess(t *testing.T) {
+	ctx := context.Background()
+	reserver := newTestRedisStorage[int](t)
+	require.NoError(t, reserver.Delete(ctx, "reserve_shared"))
+	for i := 1; i <= 3; i++ {
+		require.NoError(t, reserver.Enqueue(ctx, "reserve_shared", i))
+	}
+	_, _, err := reserver.PeekBatchReserve(ctx, "reserve_shared", 1, 50*time.Millisecond)
+	require.NoError(t, err)
+	_, _, err = reserver.PeekBatchReserve(ctx, "reserve_shared", 1, 50*time.Millisecond)
+	require.NoError(t, err)
+	require.NoError(t, reserver.Close()) // Резервировавший процесс завершился
+
+	consumer := newTestRedisStorage[int](t)
+	defer consumer.Close()
+	defer consumer.Delete(ctx, "reserve_shared")
+	time.Sleep(100 * time.Millisecond)
+
+	// Истекшие пачки возвращаются в начало очереди в порядке резервирования
+	for _, want := range []int{1, 2, 3} {
+		value, found, err := consumer.Dequeue(ctx, "reserve_shared")
+		require.NoError(t, err)
+		require.True(t, found)
+		require.Equal(t, want, value)
+	}
+}
+
+func TestRedisStorage_EnqueueDelayed(t *testing.T) {
+	ctx := context.Background()
+	s := newTestRedisStorage[string](t)
+	defer s.Close()
+
+	require.NoError(t, s.Delete(ctx, "delayed_jobs"))
+	require.NoError(t, s.EnqueueDelayed(ctx, "delayed_jobs", "job", 100*time.Millisecond))
+	require.NoError(t, s.EnqueueDelayed(ctx, "delayed_jobs", "job", 100*time.Millisecond))
+
+	_, found, err := s.Dequeue(ctx, "delayed_jobs")
+	require.NoError(t, err)
+	require.False(t, found)
+
+	time.Sleep(150 * time.Millisecond)
+
+	// Одинаковые значения не схлопываются
+	for range 2 {
+		value, found, err := s.Dequeue(ctx, "delayed_jobs")
+		require.NoError(t, err)
+		require.True(t, found)
+		require.Equal(t, "job", value)
+	}
+}
+
+func TestRedisStorage_EnqueueDelayedOtherProcess(t *testing.T) {
+	ctx := context.Background()
+	producer := newTestRedisStorage[string](t)
+	require.NoError(t, producer.Delete(ctx, "delayed_shared"))
+	require.NoError(t, producer.EnqueueDelayed(ctx, "delayed_shared", "job", 50*time.Millisecond))
+	require.NoError(t, producer.Close()) // Производитель перезапускается
+
+	// Потребитель в другом процессе не добавлял отложенных элементов
+	consumer := newTestRedisStorage[string](t)
+	defer consumer.Close()
+	time.Sleep(100 * time.Millisecond)
+
+	value, found, err := consumer.Dequeue(ctx, "delayed_shared")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "job", value)
+}
+
+func TestRedisStorage_QueueTail(t *testing.T) {
+	ctx := context.Background()
+	s := newTestRedisStorage[int](t)
+	defer s.Close()
+
+	require.NoError(t, s.Delete(ctx, "tail_events"))
+	for i := 1; i <= 5; i++ {
+		require.NoError(t, s.Enqueue(ctx, "tail_events", i))
+	}
+
+	tail, err := s.QueueTail(ctx, "tail_events", 2)
+	require.NoError(t, err)
+	require.Equal(t, []int{4, 5}, tail)
+
+	tail, err = s.QueueTail(ctx, "tail_events", 10)
+	require.NoError(t, err)
+	require.Equal(t, []int{1, 2, 3, 4, 5}, tail)
+}
+
+func TestRedisStorage_NewRedisWithClient(t *testing.T) {
+	_, err := storage.NewRedisWithClient[string](nil)
+	require.Error(t, err)
+
+	ctx := context.Background()
+	client := redis.NewClient(&redis.Options{Addr: "localhost:6379"})
+	defer client.Close()
+
+	s, err := storage.NewRedisWithClient[string](client)
+	require.NoError(t, err)
+	require.NoError(t, s.Set(ctx, "with_client", "value", 0))
+
+	// Close хранилища не закрывает чужой клиент
+	require.NoError(t, s.Close())
+	require.NoError(t, client.Ping(ctx).Err())
+	require.ErrorIs(t, s.Close(), storage.ErrClosed)
+}
+
+func TestRedisStorage_EnqueueCapped(t *testing.T) {
+	ctx := context.Background()
+	s := newTestRedisStorage[int](t)
+	defer s.Close()
+
+	require.NoError(t, s.Delete(ctx, "capped_recent"))
+	for i := 1; i <= 5; i++ {
+		require.NoError(t, s.EnqueueCapped(ctx, "capped_recent", i, 2))
+	}
+
+	tail, err := s.QueueTail(ctx, "capped_recent", 10)
+	require.NoError(t, err)
+	require.Equal(t, []int{4, 5}, tail)
+}
+
+func TestRedisStorage_Transact(t *testing.T) {
+	ctx := context.Background()
+	s := newTestRedisStorage[int](t)
+	defer s.Close()
+
+	require.NoError(t, s.Set(ctx, "tx_alice", 100, 0))
+	require.NoError(t, s.Set(ctx, "tx_bob", 10, 0))
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := s.Transact(ctx, func(tx storage.Tx[int]) error {
+				if _, err := tx.Increment("tx_alice", -5); err != nil {
+					return err
+				}
+				_, err := tx.Increment("tx_bob", 5)
+				return err
+			})
+			require.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	alice, _, _ := s.Get(ctx, "tx_alice")
+	bob, _, _ := s.Get(ctx, "tx_bob")
+	require.Equal(t, 50, alice)
+	require.Equal(t, 60, bob)
+}
+
+func TestRedisStorage_EnqueueBounded(t *testing.T) {
+	ctx := context.Background()
+	s := newTestRedisStorage[int](t)
+	defer s.Close()
+
+	require.NoError(t, s.Delete(ctx, "bounded_jobs"))
+
+	var wg sync.WaitGroup
+	var accepted atomic.Int64
+	for i := range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ok, err := s.EnqueueBounded(ctx, "bounded_jobs", i, 10)
+			require.NoError(t, err)
+			if ok {
+				accepted.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	require.Equal(t, int64(10), accepted.Load())
+	length, _ := s.QueueLen(ctx, "bounded_jobs")
+	require.Equal(t, int64(10), length)
+}
+
+func TestRedisStorage_Inspect(t *testing.T) {
+	ctx := context.Background()
+	s := newTestRedisStorage[int](t)
+	defer s.Close()
+
+	require.NoError(t, s.Set(ctx, "inspect:n", 12345, 0))
+	defer s.Delete(ctx, "inspect:n")
+
+	info, found, err := s.Inspect(ctx, "inspect:n")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "int", info.Encoding)
+
+	_, found, err = s.Inspect(ctx, "inspect:missing")
+	require.NoError(t, err)
+	require.False(t, found)
+}
+
+func TestRedisStorage_ReplicaReads(t *testing.T) {
+	ctx := context.Background()
+	// Основной сервер в роли собственной реплики: чтение и запись видят одни данные
+	s, err := storage.NewRedis[string](storage.RedisConfig{
+		Addr:         "localhost:6379",
+		ReplicaAddrs: []string{"localhost:6379"},
+	})
+	require.NoError(t, err)
+	defer s.Close()
+
+	require.NoError(t, s.Set(ctx, "replica:k", "v", 0))
+	defer s.Delete(ctx, "replica:k")
+
+	val, found, err := s.Get(ctx, "replica:k")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "v", val)
+}
+
+func TestRedisStorage_ReplicaUnreachable(t *testing.T) {
+	_, err := storage.NewRedis[string](storage.RedisConfig{
+		Addr:         "localhost:6379",
+		ReplicaAddrs: []string{"127.0.0.1:1"},
+	})
+	require.Error(t, err)
+}
+
+func TestRedisStorage_ConnectionCallbacks(t *testing.T) {
+	var connects atomic.Int64
+	s, err := storage.NewRedis[string](storage.RedisConfig{
+		Addr: "localhost:6379",
+		OnConnect: func(_ context.Context, addr string) {
+			if addr == "localhost:6379" {
+				connects.Add(1)
+			}
+		},
+		OnDisconnect:        func(string, error) {},
+		HealthCheckInterval: 10 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	defer s.Close()
+
+	require.Positive(t, connects.Load()) // Соединение для проверки PING при создании
+}
+
+func TestRedisStorage_Namespaces(t *testing.T) {
+	ctx := context.Background()
+	s, err := storage.NewRedis[string](storage.RedisConfig{Addr: "localhost:6379"}, storage.WithNamespaces(true))
+	require.NoError(t, err)
+	defer s.Close()
+
+	// Ключ и очередь с одним именем не конфликтуют
+	require.NoError(t, s.Set(ctx, "ns:same", "value", 0))
+	defer s.Delete(ctx, "ns:same")
+	require.NoError(t, s.Enqueue(ctx, "ns:same", "item"))
+
+	val, found, err := s.Get(ctx, "ns:same")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "value", val)
+
+	keys, _, err := s.ScanPage(ctx, 0, "ns:*", 1000)
+	require.NoError(t, err)
+	require.Contains(t, keys, "ns:same")
+
+	names, err := s.(storage.QueueLister).QueueNames(ctx)
+	require.NoError(t, err)
+	require.Contains(t, names, "ns:same")
+
+	item, found, err := s.Dequeue(ctx, "ns:same")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "item", item)
+
+	// Без опции записи с префиксами видны под именами Redis
+	raw := newTestRedisStorage[string](t)
+	defer raw.Close()
+	_, found, err = raw.Get(ctx, "kv:ns:same")
+	require.NoError(t, err)
+	require.True(t, found)
+}
+
+func TestRedisStorage_IncrementField(t *testing.T) {
+	type page struct {
+		Title string `json:"title"`
+		Views int64  `json:"views,omitempty"`
+		ID    int64  `json:"id"`
+		Tags  map[string]int
+	}
+	ctx := context.Background()
+	s := newTestRedisStorage[page](t)
+	defer s.Close()
+	defer s.Delete(ctx, "incrfield:page")
+
+	// Строки и вложенные объекты с тем же именем поля не затрагиваются,
+	// а большие числа не теряют точность
+	orig := page{Title: `"views":1`, ID: 1 << 60, Tags: map[string]int{"views": 7}}
+	require.NoError(t, s.Set(ctx, "incrfield:page", orig, time.Minute))
+
+	n, err := s.IncrementField(ctx, "incrfield:page", "views", 3) // Поле опущено (omitempty)
+	require.NoError(t, err)
+	require.Equal(t, int64(3), n)
+	n, err = s.IncrementField(ctx, "incrfield:page", "views", 4)
+	require.NoError(t, err)
+	require.Equal(t, int64(7), n)
+
+	val, _, _ := s.Get(ctx, "incrfield:page")
+	orig.Views = 7
+	require.Equal(t, orig, val)
+	ttl, _, _ := s.(storage.TTLReader).TTL(ctx, "incrfield:page")
+	require.Greater(t, ttl, 50*time.Second)
+
+	_, err = s.IncrementField(ctx, "incrfield:page", "title", 1)
+	require.ErrorIs(t, err, storage.ErrWrongType)
+
+	// Поле проверяется по типу T: отсутствующее поле и вложенный путь не добавляются в JSON
+	_, err = s.IncrementField(ctx, "incrfield:page", "missing", 1)
+	require.ErrorIs(t, err, storage.ErrWrongType)
+	_, err = s.IncrementField(ctx, "incrfield:page", "Tags.views", 1)
+	require.ErrorIs(t, err, storage.ErrWrongType)
+	val, _, _ = s.Get(ctx, "incrfield:page")
+	require.Equal(t, orig, val)
+}
+
+func TestRedisStorage_SAddEx(t *testing.T) {
+	ctx := context.Background()
+	s := newTestRedisStorage[string](t)
+	defer s.Close()
+	defer s.Delete(ctx, "sets:recent")
+
+	require.NoError(t, s.SAddEx(ctx, "sets:recent", "a", 50*time.Millisecond))
+	require.NoError(t, s.SAddEx(ctx, "sets:recent", "b", time.Minute))
+	require.NoError(t, s.SAddEx(ctx, "sets:recent", "b", time.Minute))
+
+	n, err := s.SCount(ctx, "sets:recent")
+	require.NoError(t, err)
+	require.Equal(t, int64(2), n)
+
+	time.Sleep(80 * time.Millisecond)
+	n, err = s.SCount(ctx, "sets:recent")
+	require.NoError(t, err)
+	require.Equal(t, int64(1), n)
+}
+
+func TestRedisStorage_RemoveAt(t *testing.T) {
+	ctx := context.Background()
+	s := newTestRedisStorage[string](t)
+	defer s.Close()
+	defer s.Delete(ctx, "removeat:jobs")
+
+	for _, v := range []string{"a", "b", "c"} {
+		require.NoError(t, s.Enqueue(ctx, "removeat:jobs", v))
+	}
+
+	ok, err := s.RemoveAt(ctx, "removeat:jobs", 1)
+	require.NoError(t, err)
+	require.True(t, ok)
+	ok, err = s.RemoveAt(ctx, "removeat:jobs", 5)
+	require.NoError(t, err)
+	require.False(t, ok)
+	ok, err = s.RemoveAt(ctx, "removeat:missing", 0)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	tail, _ := s.QueueTail(ctx, "removeat:jobs", 10)
+	require.Equal(t, []string{"a", "c"}, tail)
+
+	ok, err = s.RemoveAt(ctx, "removeat:jobs", -1)
+	require.NoError(t, err)
+	require.True(t, ok)
+	tail, _ = s.QueueTail(ctx, "removeat:jobs", 10)
+	require.Equal(t, []string{"a"}, tail)
+
+	// Позиция в ключе другого типа - ошибка ErrWrongType
+	require.NoError(t, s.Set(ctx, "removeat:plain", "v", 0))
+	defer s.Delete(ctx, "removeat:plain")
+	_, err = s.RemoveAt(ctx, "removeat:plain", 0)
+	require.ErrorIs(t, err, storage.ErrWrongType)
+}
+
+func TestRedisStorage_BlockingDequeueAny(t *testing.T) {
+	ctx := context.Background()
+	s := newTestRedisStorage[string](t)
+	defer s.Close()
+	queues := []string{"bpop:high", "bpop:low"}
+	defer s.Delete(ctx, "bpop:high")
+	defer s.Delete(ctx, "bpop:low")
+
+	require.NoError(t, s.Enqueue(ctx, "bpop:low", "l1"))
+	require.NoError(t, s.Enqueue(ctx, "bpop:high", "h1"))
+	name, val, found, err := s.BlockingDequeueAny(ctx, queues, time.Second)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "bpop:high", name)
+	require.Equal(t, "h1", val)
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		_ = s.Enqueue(ctx, "bpop:high", "h2")
+	}()
+	_, _, _, _ = s.BlockingDequeueAny(ctx, queues, time.Second) // l1
+	name, val, found, err = s.BlockingDequeueAny(ctx, queues, 2*time.Second)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "bpop:high", name)
+	require.Equal(t, "h2", val)
+
+	_, _, found, err = s.BlockingDequeueAny(ctx, queues, 100*time.Millisecond)
+	require.NoError(t, err)
+	require.False(t, found)
+}
+
+func TestRedisStorage_GetInto(t *testing.T) {
+	ctx := context.Background()
+	s := newTestRedisStorage[[]int](t)
+	defer s.Close()
+	defer s.Delete(ctx, "getinto:k")
+
+	require.NoError(t, s.Set(ctx, "getinto:k", []int{1, 2, 3}, 0))
+	dst := make([]int, 0, 8)
+	found, err := s.GetInto(ctx, "getinto:k", &dst)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, []int{1, 2, 3}, dst)
+	require.Equal(t, 8, cap(dst)) // Буфер dst переиспользован
+
+	found, err = s.GetInto(ctx, "getinto:missing", &dst)
+	require.NoError(t, err)
+	require.False(t, found)
+}
+
+func TestRedisStorage_SetVersioned(t *testing.T) {
+	ctx := context.Background()
+	s := newTestRedisStorage[string](t)
+	defer s.Close()
+	defer s.DeletePattern(ctx, "h:{versioned:doc}")
+	defer s.Delete(ctx, "versioned:doc")
+
+	for _, v := range []string{"v1", "v2", "v3"} {
+		require.NoError(t, s.SetVersioned(ctx, "versioned:doc", v, 2))
+	}
+	val, found, err := s.Get(ctx, "versioned:doc")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "v3", val)
+
+	val, found, err = s.GetVersion(ctx, "versioned:doc", 1)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "v2", val)
+
+	_, found, err = s.GetVersion(ctx, "versioned:doc", 2)
+	require.NoError(t, err)
+	require.False(t, found)
+}
+
+func TestRedisStorage_WaitForKey(t *testing.T) {
+	ctx := context.Background()
+	s := newTestRedisStorage[string](t)
+	defer s.Close()
+
+	require.NoError(t, s.Delete(ctx, "wait:key"))
+	defer s.Delete(ctx, "wait:key")
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		_ = s.Set(ctx, "wait:key", "published", 0)
+	}()
+	val, found, err := s.WaitForKey(ctx, "wait:key", 2*time.Second)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "published", val)
+
+	_, found, err = s.WaitForKey(ctx, "wait:missing", 50*time.Millisecond)
+	require.NoError(t, err)
+	require.False(t, found)
+}
+
+func TestRedisStorage_IncrWindow(t *testing.T) {
+	ctx := context.Background()
+	s := newTestRedisStorage[string](t)
+	defer s.Close()
+
+	require.NoError(t, s.Delete(ctx, "rate:window"))
+	defer s.Delete(ctx, "rate:window")
+
+	for i := int64(1); i <= 3; i++ {
+		count, allowed, err := s.IncrWindow(ctx, "rate:window", 100*time.Millisecond, 2)
+		require.NoError(t, err)
+		require.Equal(t, i, count)
+		require.Equal(t, i <= 2, allowed)
+	}
+
+	ttl, found, err := s.(storage.TTLReader).TTL(ctx, "rate:window")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.LessOrEqual(t, ttl, 100*time.Millisecond)
+
+	time.Sleep(150 * time.Millisecond)
+	count, allowed, err := s.IncrWindow(ctx, "rate:window", 100*time.Millisecond, 2)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), count)
+	require.True(t, allowed)
+}
+
+func TestRedisStorage_QueueTypeCheck(t *testing.T) {
+	ctx := context.Background()
+	type order struct{ ID int }
+	type invoice struct{ ID int }
+	cfg := storage.RedisConfig{Addr: "localhost:6379"}
+
+	orders, err := storage.NewRedis[order](cfg, storage.WithQueueTypeCheck(""))
+	require.NoError(t, err)
+	defer orders.Close()
+	invoices, err := storage.NewRedis[invoice](cfg, storage.WithQueueTypeCheck(""))
+	require.NoError(t, err)
+	defer invoices.Close()
+
+	require.NoError(t, orders.Enqueue(ctx, "typed:jobs", order{ID: 1}))
+	require.ErrorIs(t, invoices.Enqueue(ctx, "typed:jobs", invoice{ID: 2}), storage.ErrTypeMismatch)
+
+	// Потребитель другого типа получает ошибку, а элемент остается в очереди
+	_, _, err = invoices.Dequeue(ctx, "typed:jobs")
+	require.ErrorIs(t, err, storage.ErrTypeMismatch)
+	_, _, err = invoices.DequeueTracked(ctx, "typed:jobs", "typed:processing", time.Minute)
+	require.ErrorIs(t, err, storage.ErrTypeMismatch)
+	_, _, _, err = invoices.BlockingDequeueAny(ctx, []string{"typed:jobs"}, time.Second)
+	require.ErrorIs(t, err, storage.ErrTypeMismatch)
+	n, err := orders.QueueLen(ctx, "typed:jobs")
+	require.NoError(t, err)
+	require.Equal(t, int64(1), n)
+
+	value, found, err := orders.Dequeue(ctx, "typed:jobs")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, order{ID: 1}, value)
+
+	// Опустевшая очередь теряет тег
+	require.NoError(t, invoices.Enqueue(ctx, "typed:jobs", invoice{ID: 3}))
+
+	// Тег не виден обходам ключей
+	n, err = invoices.CountPattern(ctx, "*typed:jobs")
+	require.NoError(t, err)
+	require.Zero(t, n)
+
+	// Тег теряют и очереди, опустошенные RemoveAt, Remove и ReplaceQueue
+	removed, err := invoices.RemoveAt(ctx, "typed:jobs", 0)
+	require.NoError(t, err)
+	require.True(t, removed)
+	require.NoError(t, orders.Enqueue(ctx, "typed:jobs", order{ID: 4}))
+	removed, err = orders.Remove(ctx, "typed:jobs")
+	require.NoError(t, err)
+	require.True(t, removed)
+	require.NoError(t, invoices.Enqueue(ctx, "typed:jobs", invoice{ID: 5}))
+	require.NoError(t, invoices.ReplaceQueue(ctx, "typed:jobs", nil))
+	require.NoError(t, orders.Enqueue(ctx, "typed:jobs", order{ID: 6}))
+	_, _, err = orders.Dequeue(ctx, "typed:jobs")
+	require.NoError(t, err)
+
+	// Хранилище с пространствами имен работает с другим списком и не видит его тег
+	spaced, err := storage.NewRedis[invoice](cfg, storage.WithQueueTypeCheck(""), storage.WithNamespaces(true))
+	require.NoError(t, err)
+	defer spaced.Close()
+	require.NoError(t, orders.Enqueue(ctx, "typed:jobs", order{ID: 7}))
+	require.NoError(t, spaced.Enqueue(ctx, "typed:jobs", invoice{ID: 8}))
+	_, _, err = spaced.Dequeue(ctx, "typed:jobs")
+	require.NoError(t, err)
+	_, _, err = orders.Dequeue(ctx, "typed:jobs")
+	require.NoError(t, err)
+
+	// Остальные операции записи проверяют тот же тег
+	require.NoError(t, orders.Enqueue(ctx, "typed:jobs", order{ID: 9}))
+	_, err = invoices.EnqueueN(ctx, "typed:jobs", invoice{ID: 10})
+	require.ErrorIs(t, err, storage.ErrTypeMismatch)
+	require.ErrorIs(t, invoices.EnqueueCapped(ctx, "typed:jobs", invoice{ID: 11}, 10), storage.ErrTypeMismatch)
+	require.ErrorIs(t, invoices.ReplaceQueue(ctx, "typed:jobs", []invoice{{ID: 12}}), storage.ErrTypeMismatch)
+	n, err = orders.QueueLen(ctx, "typed:jobs")
+	require.NoError(t, err)
+	require.Equal(t, int64(1), n)
+	length, err := orders.EnqueueN(ctx, "typed:jobs", order{ID: 13})
+	require.NoError(t, err)
+	require.Equal(t, int64(2), length)
+	require.NoError(t, orders.EnqueueCapped(ctx, "typed:jobs", order{ID: 14}, 10))
+
+	// Извлечение своим типом опустошает очередь и снимает тег
+	_, _, err = orders.DequeueTracked(ctx, "typed:jobs", "typed:processing", time.Minute)
+	require.NoError(t, err)
+	_, _, _, err = orders.BlockingDequeueAny(ctx, []string{"typed:jobs"}, time.Second)
+	require.NoError(t, err)
+	_, found, err = orders.Dequeue(ctx, "typed:jobs")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.NoError(t, invoices.Enqueue(ctx, "typed:jobs", invoice{ID: 15}))
+	require.NoError(t, invoices.ReplaceQueue(ctx, "typed:jobs", nil))
+	_ = orders.Delete(ctx, "typed:processing")
+}
+
+func TestRedisStorage_SetX(t *testing.T) {
+	ctx := context.Background()
+	s, err := storage.NewRedis[string](storage.RedisConfig{Addr: "localhost:6379"}, storage.WithTTLJitter(5*time.Second))
+	require.NoError(t, err)
+	defer s.Close()
+
+	expiresAt, err := s.SetX(ctx, "setx:key", "v", time.Minute)
+	require.NoError(t, err)
+	require.WithinRange(t, expiresAt, time.Now().Add(54*time.Second), time.Now().Add(66*time.Second))
+
+	// С нулевым TTL Redis сохраняет текущее время истечения ключа
+	kept, err := s.SetX(ctx, "setx:key", "v2", 0)
+	require.NoError(t, err)
+	require.WithinDuration(t, expiresAt, kept, 50*time.Millisecond)
+
+	require.NoError(t, s.Delete(ctx, "setx:key"))
+	kept, err = s.SetX(ctx, "setx:key", "v3", 0)
+	require.NoError(t, err)
+	require.True(t, kept.IsZero())
+}
+
+func TestRedisStorage_DeleteIf(t *testing.T) {
+	ctx := context.Background()
+	type lock struct{ Owner string }
+	for _, metadata := range []bool{false, true} {
+		s, err := storage.NewRedis[lock](storage.RedisConfig{Addr: "localhost:6379"}, storage.WithMetadata(metadata))
+		require.NoError(t, err)
+		defer s.Close()
+
+		require.NoError(t, s.Set(ctx, "deleteif:lock", lock{Owner: "a"}, time.Minute))
+
+		deleted, err := s.DeleteIf(ctx, "deleteif:lock", lock{Owner: "b"})
+		require.NoError(t, err)
+		require.False(t, deleted)
+
+		deleted, err = s.DeleteIf(ctx, "deleteif:lock", lock{Owner: "a"})
+		require.NoError(t, err)
+		require.True(t, deleted)
+		_, found, _ := s.Get(ctx, "deleteif:lock")
+		require.False(t, found)
+	}
+}
+
+func TestRedisStorage_ClaimOnce(t *testing.T) {
+	ctx := context.Background()
+	s := newTestRedisStorage[string](t)
+	defer s.Close()
+	defer s.Delete(ctx, "claim:job")
+
+	claimed, err := s.ClaimOnce(ctx, "claim:job", time.Hour)
+	require.NoError(t, err)
+	require.True(t, claimed)
+	claimed, err = s.ClaimOnce(ctx, "claim:job", time.Hour)
+	require.NoError(t, err)
+	require.False(t, claimed)
+
+	val, found, err := s.Get(ctx, "claim:job")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Empty(t, val)
+}
+
+func TestRedisStorage_CopyKey(t *testing.T) {
+	ctx := context.Background()
+	s := newTestRedisStorage[string](t)
+	defer s.Close()
+	defer s.DeletePattern(ctx, "copykey:*")
+
+	require.NoError(t, s.Set(ctx, "copykey:src", "v1", time.Hour))
+	require.NoError(t, s.Set(ctx, "copykey:taken", "v2", 0))
+
+	copied, err := s.CopyKey(ctx, "copykey:src", "copykey:dst", false)
+	require.NoError(t, err)
+	require.True(t, copied)
+	ttl, _, _ := s.(storage.TTLReader).TTL(ctx, "copykey:dst")
+	require.Greater(t, ttl, 59*time.Minute)
+
+	copied, err = s.CopyKey(ctx, "copykey:src", "copykey:taken", false)
+	require.NoError(t, err)
+	require.False(t, copied)
+	copied, _ = s.CopyKey(ctx, "copykey:src", "copykey:taken", true)
+	require.True(t, copied)
+	val, _, _ := s.Get(ctx, "copykey:taken")
+	require.Equal(t, "v1", val)
+}
+
+func TestRedisStorage_CountPattern(t *testing.T) {
+	ctx := context.Background()
+	s := newTestRedisStorage[int](t)
+	defer s.Close()
+	defer s.DeletePattern(ctx, "count:*")
+
+	for i := range 250 {
+		require.NoError(t, s.Set(ctx, fmt.Sprintf("count:%d", i), i, 0))
+	}
+
+	n, err := s.CountPattern(ctx, "count:*")
+	require.NoError(t, err)
+	require.Equal(t, int64(250), n)
+}
+
+func TestRedisStorage_ReplaceQueue(t *testing.T) {
+	ctx := context.Background()
+	s := newTestRedisStorage[string](t)
+	defer s.Close()
+	defer s.Delete(ctx, "replace:schedule")
+
+	require.NoError(t, s.Enqueue(ctx, "replace:schedule", "old"))
+	require.NoError(t, s.ReplaceQueue(ctx, "replace:schedule", []string{"a", "b"}))
+	tail, _ := s.QueueTail(ctx, "replace:schedule", 10)
+	require.Equal(t, []string{"a", "b"}, tail)
+
+	require.NoError(t, s.ReplaceQueue(ctx, "replace:schedule", nil))
+	n, _ := s.QueueLen(ctx, "replace:schedule")
+	require.Zero(t, n)
+}
+
+func TestRedisStorage_RotateQueue(t *testing.T) {
+	ctx := context.Background()
+	s := newTestRedisStorage[string](t)
+	defer s.Close()
+	defer s.Delete(ctx, "rotate:workers")
+
+	_, found, err := s.RotateQueue(ctx, "rotate:workers")
+	require.NoError(t, err)
+	require.False(t, found)
+
+	require.NoError(t, s.ReplaceQueue(ctx, "rotate:workers", []string{"a", "b"}))
+	v, found, err := s.RotateQueue(ctx, "rotate:workers")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "a", v)
+
+	tail, _ := s.QueueTail(ctx, "rotate:workers", 10)
+	require.Equal(t, []string{"b", "a"}, tail)
+}
+
+func TestRedisStorage_SetHead(t *testing.T) {
+	ctx := context.Background()
+	s := newTestRedisStorage[string](t)
+	defer s.Close()
+	defer s.Delete(ctx, "sethead:jobs")
+
+	_, found, err := s.SetHead(ctx, "sethead:jobs", "x")
+	require.NoError(t, err)
+	require.False(t, found)
+
+	require.NoError(t, s.ReplaceQueue(ctx, "sethead:jobs", []string{"a", "b"}))
+	old, found, err := s.SetHead(ctx, "sethead:jobs", "a2")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "a", old)
+
+	tail, _ := s.QueueTail(ctx, "sethead:jobs", 10)
+	require.Equal(t, []string{"a2", "b"}, tail)
+}
+
+func TestRedisStorage_MaxValueBytes(t *testing.T) {
+	ctx := context.Background()
+	s, err := storage.NewRedis[string](storage.RedisConfig{Addr: "localhost:6379"}, storage.WithMaxValueBytes(10))
+	require.NoError(t, err)
+	defer s.Close()
+
+	require.ErrorIs(t, s.Set(ctx, "maxvalue:big", "abcdefghijk", 0), storage.ErrValueTooLarge)
+	require.ErrorIs(t, s.Enqueue(ctx, "maxvalue:jobs", "abcdefghijk"), storage.ErrValueTooLarge)
+	_, found, _ := s.Get(ctx, "maxvalue:big")
+	require.False(t, found)
+}
+
+func TestRedisStorage_TryEnqueue(t *testing.T) {
+	ctx := context.Background()
+	s := newTestRedisStorage[int](t)
+
+	ok, reason, err := s.TryEnqueue(ctx, "tryenqueue:jobs", 1, 1)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, storage.ReasonOK, reason)
+
+	ok, reason, err = s.TryEnqueue(ctx, "tryenqueue:jobs", 2, 1)
+	require.NoError(t, err)
+	require.False(t, ok)
+	require.Equal(t, storage.ReasonFull, reason)
+
+	require.NoError(t, s.Delete(ctx, "tryenqueue:jobs"))
+	require.NoError(t, s.Close())
+	_, reason, _ = s.TryEnqueue(ctx, "tryenqueue:jobs", 3, 10)
+	require.Equal(t, storage.ReasonClosed, reason)
+}
+
+func TestRedisStorage_QueueReadFrom(t *testing.T) {
+	ctx := context.Background()
+	s := newTestRedisStorage[int](t)
+	defer s.Close()
+	defer s.Delete(ctx, "readfrom:log")
+
+	for i := range 5 {
+		require.NoError(t, s.Enqueue(ctx, "readfrom:log", i))
+	}
+
+	items, next, err := s.QueueReadFrom(ctx, "readfrom:log", 3, 10)
+	require.NoError(t, err)
+	require.Equal(t, []int{3, 4}, items)
+	require.Equal(t, int64(5), next)
+
+	items, next, err = s.QueueReadFrom(ctx, "readfrom:log", next, 10)
+	require.NoError(t, err)
+	require.Empty(t, items)
+	require.Equal(t, int64(5), next)
+
+	// Извлечение сдвигает смещения, поэтому разобранная очередь по ним не читается
+	client := redis.NewClient(&redis.Options{Addr: "localhost:6379"})
+	defer client.Close()
+	defer client.Del(ctx, "qd:{readfrom:log}")
+	_, _, err = s.Dequeue(ctx, "readfrom:log")
+	require.NoError(t, err)
+	_, _, err = s.QueueReadFrom(ctx, "readfrom:log", 0, 10)
+	require.ErrorIs(t, err, storage.ErrQueueConsumed)
+
+	// Метка записывается каждой операцией вместе с удалением элементов
+	require.NoError(t, client.Del(ctx, "qd:{readfrom:log}").Err())
+	_, _, err = s.RotateQueue(ctx, "readfrom:log")
+	require.NoError(t, err)
+	_, _, err = s.QueueReadFrom(ctx, "readfrom:log", 0, 10)
+	require.ErrorIs(t, err, storage.ErrQueueConsumed)
+
+	// Метка не видна обходам ключей
+	n, err := s.CountPattern(ctx, "*readfrom:log")
+	require.NoError(t, err)
+	require.Zero(t, n)
+}
+
+func TestRedisStorage_QueueIterate(t *testing.T) {
+	ctx := context.Background()
+	s := newTestRedisStorage[int](t)
+	defer s.Close()
+	defer s.Delete(ctx, "iterate:log")
+
+	// Больше одного окна LRANGE
+	const total = 2500
+	for i := range total {
+		require.NoError(t, s.Enqueue(ctx, "iterate:log", i))
+	}
+
+	var seen []int
+	require.NoError(t, s.QueueIterate(ctx, "iterate:log", func(v int) error {
+		seen = append(seen, v)
+		return nil
+	}))
+	require.Len(t, seen, total)
+	for i, v := range seen {
+		require.Equal(t, i, v)
+	}
+
+	errStop := errors.New("stop")
+	calls := 0
+	err := s.QueueIterate(ctx, "iterate:log", func(int) error {
+		calls++
+		if calls == 1500 {
+			return errStop
+		}
+		return nil
+	})
+	require.ErrorIs(t, err, errStop)
+	require.Equal(t, 1500, calls)
+
+	n, err := s.QueueLen(ctx, "iterate:log")
+	require.NoError(t, err)
+	require.Equal(t, int64(total), n)
+}
+
+func TestRedisStorage_WithDB(t *testing.T) {
+	ctx := context.Background()
+	s := newTestRedisStorage[string](t)
+
+	other, err := s.(storage.DBSelector[string]).WithDB(1)
+	require.NoError(t, err)
+	defer other.Close()
+
+	require.NoError(t, other.Set(ctx, "withdb:key", "db1", 0))
+	defer other.Delete(ctx, "withdb:key")
+
+	// Ключ виден только в своей базе
+	_, found, err := s.Get(ctx, "withdb:key")
+	require.NoError(t, err)
+	require.False(t, found)
+
+	val, found, err := other.Get(ctx, "withdb:key")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "db1", val)
+
+	_, err = s.(storage.DBSelector[string]).WithDB(100000)
+	require.Error(t, err)
+}
+
+func TestRedisStorage_Chunked(t *testing.T) {
+	ctx := context.Background()
+	s := newTestRedisStorage[string](t)
+	cs := s.(storage.ChunkedStore)
+	defer cs.DeleteChunked(ctx, "chunked:blob")
+
+	blob := bytes.Repeat([]byte("0123456789"), 1000)
+	require.NoError(t, cs.SetChunked(ctx, "chunked:blob", bytes.NewReader(blob), 4096, time.Minute))
+
+	r, found, err := cs.GetChunked(ctx, "chunked:blob")
+	require.NoError(t, err)
+	require.True(t, found)
+	data, err := io.ReadAll(r)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+	require.Equal(t, blob, data)
+
+	// Перезапись меньшим значением заменяет все части
+	require.NoError(t, cs.SetChunked(ctx, "chunked:blob", strings.NewReader("short"), 4096, time.Minute))
+	r, found, err = cs.GetChunked(ctx, "chunked:blob")
+	require.NoError(t, err)
+	require.True(t, found)
+	data, err = io.ReadAll(r)
+	require.NoError(t, err)
+	require.Equal(t, "short", string(data))
+
+	// Части не видны через обычные ключи
+	_, found, err = s.Get(ctx, "chunked:blob")
+	require.NoError(t, err)
+	require.False(t, found)
+
+	require.NoError(t, cs.DeleteChunked(ctx, "chunked:blob"))
+	_, found, err = cs.GetChunked(ctx, "chunked:blob")
+	require.NoError(t, err)
+	require.False(t, found)
+	require.NoError(t, cs.DeleteChunked(ctx, "chunked:blob"))
+
+	err = cs.SetChunked(ctx, "chunked:blob", strings.NewReader("x"), 0, 0)
+	require.ErrorIs(t, err, storage.ErrInvalidChunkSize)
+}
+
+func TestRedisStorage_ChunkedHiddenFromScans(t *testing.T) {
+	ctx := context.Background()
+	s := newTestRedisStorage[string](t)
+	cs := s.(storage.ChunkedStore)
+	defer cs.DeleteChunked(ctx, "chunkscan:blob")
+	defer s.Delete(ctx, "chunkscan:plain")
+
+	blob := bytes.Repeat([]byte("0123456789"), 1000)
+	require.NoError(t, cs.SetChunked(ctx, "chunkscan:blob", bytes.NewReader(blob), 4096, time.Minute))
+	require.NoError(t, s.Set(ctx, "chunkscan:plain", "v", time.Minute))
+
+	// Манифест и части не попадают в обходы ключей
+	values, err := s.GetByPrefix(ctx, "c:chunkscan:")
+	require.NoError(t, err)
+	require.Empty(t, values)
+
+	n, err := s.CountPattern(ctx, "*chunkscan:*")
+	require.NoError(t, err)
+	require.EqualValues(t, 1, n)
+
+	dst, _ := storage.NewMemory[string](time.Minute)
+	defer dst.Close()
+	stats, err := storage.Copy(ctx, s, dst, storage.CopyOptions{Match: "*chunkscan:*", Queues: []string{}})
+	require.NoError(t, err)
+	require.EqualValues(t, 1, stats.Keys)
+	val, found, _ := dst.Get(ctx, "chunkscan:plain")
+	require.True(t, found)
+	require.Equal(t, "v", val)
+}
+
+func TestRedisStorage_Tags(t *testing.T) {
+	ctx := context.Background()
+	s := newTestRedisStorage[string](t)
+	defer s.Close()
+	defer s.Delete(ctx, "tags:other")
+
+	require.NoError(t, s.SetWithTags(ctx, "tags:price", "10", time.Minute, []string{"tags:product"}))
+	require.NoError(t, s.SetWithTags(ctx, "tags:page", "<html>", 0, []string{"tags:product", "tags:pages"}))
+	require.NoError(t, s.Set(ctx, "tags:other", "x", 0))
+
+	n, err := s.InvalidateTag(ctx, "tags:product")
+	require.NoError(t, err)
+	require.Equal(t, int64(2), n)
+
+	_, found, _ := s.Get(ctx, "tags:price")
+	require.False(t, found)
+	_, found, _ = s.Get(ctx, "tags:other")
+	require.True(t, found)
+
+	// Ключ уже удален: тег pages ничего не удаляет
+	n, err = s.InvalidateTag(ctx, "tags:pages")
+	require.NoError(t, err)
+	require.Zero(t, n)
+
+	// Удаленный и записанный заново без тегов ключ исключен из тега
+	require.NoError(t, s.SetWithTags(ctx, "tags:price", "10", time.Minute, []string{"tags:product"}))
+	require.NoError(t, s.Delete(ctx, "tags:price"))
+	require.NoError(t, s.Set(ctx, "tags:price", "11", time.Minute))
+	defer s.Delete(ctx, "tags:price")
+
+	// Ключ, теги которого заменены, исключен из прежнего тега
+	require.NoError(t, s.SetWithTags(ctx, "tags:page", "<html>", time.Minute, []string{"tags:product"}))
+	require.NoError(t, s.SetWithTags(ctx, "tags:page", "<html>", time.Minute, []string{"tags:pages"}))
+	defer s.Delete(ctx, "tags:page")
+
+	n, err = s.InvalidateTag(ctx, "tags:product")
+	require.NoError(t, err)
+	require.Zero(t, n)
+	_, found, _ = s.Get(ctx, "tags:price")
+	require.True(t, found)
+
+	n, err = s.InvalidateTag(ctx, "tags:pages")
+	require.NoError(t, err)
+	require.Equal(t, int64(1), n)
+}
+
+func TestRedisStorage_SetWithCodec(t *testing.T) {
+	ctx := context.Background()
+	codec := storage.NewMarkedCodec(nil)
+	codec.Register(0x01, storage.BinaryCodec{})
+	s, err := storage.NewRedis[version](storage.RedisConfig{Addr: "localhost:6379"}, storage.WithCodec(codec))
+	require.NoError(t, err)
+	defer s.Close()
+	defer s.Delete(ctx, "codec:hot")
+
+	require.NoError(t, s.SetWithCodec(ctx, "codec:hot", version{1, 2, 3}, time.Minute, storage.BinaryCodec{}))
+	raw, _, _ := s.GetRaw(ctx, "codec:hot")
+	require.Equal(t, []byte{0x01, 1, 2, 3}, raw)
+
+	v, found, err := s.Get(ctx, "codec:hot")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, version{1, 2, 3}, v)
+
+	v, found, err = s.GetWithCodec(ctx, "codec:hot", nil)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, version{1, 2, 3}, v)
+}
+
+func TestRedisStorage_MoveMatching(t *testing.T) {
+	ctx := context.Background()
+	s := newTestRedisStorage[int](t)
+	defer s.Close()
+	defer s.Delete(ctx, "move:dlq")
+	defer s.Delete(ctx, "move:work")
+
+	require.NoError(t, s.ReplaceQueue(ctx, "move:dlq", []int{1, 2, 3, 4}))
+	require.NoError(t, s.ReplaceQueue(ctx, "move:work", []int{100}))
+
+	n, err := s.MoveMatching(ctx, "move:dlq", "move:work", func(v int) bool { return v%2 == 0 }, 0)
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+	tail, _ := s.QueueTail(ctx, "move:dlq", 10)
+	require.Equal(t, []int{1, 3}, tail)
+	tail, _ = s.QueueTail(ctx, "move:work", 10)
+	require.Equal(t, []int{100, 2, 4}, tail)
+}
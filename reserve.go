@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrReservationNotFound возвращается AckBatch, если резервирование не найдено:
+// токен неизвестен, уже подтвержден или истек, и элементы вернулись в очередь.
+var ErrReservationNotFound = errors.New("storage: reservation not found")
+
+// reservation - зарезервированная пачка элементов in-memory очереди.
+type reservation[T any] struct {
+	items    []T   // Элементы в порядке очереди
+	deadline int64 // Время возврата в очередь в наносекундах
+}
+
+// newReservationToken генерирует случайный токен резервирования.
+func newReservationToken() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:]) // crypto/rand.Read не возвращает ошибок
+	return hex.EncodeToString(b[:])
+}
+
+// inflightKey возвращает ключ сортированного множества токенов очереди
+// с временем истечения резервирования в качестве веса.
+func inflightKey(queueName string) string {
+	return queueName + ":inflight"
+}
+
+// inflightItemsKey возвращает ключ списка элементов резервирования.
+func inflightItemsKey(queueName, token string) string {
+	return queueName + ":inflight:" + token
+}
+
+// isInflightItemsKey проверяет, что key - список элементов резервирования
+// вида inflightItemsKey: суффикс ":inflight:" и токен из 32 шестнадцатеричных символов.
+func isInflightItemsKey(key string) bool {
+	i := strings.LastIndex(key, ":inflight:")
+	if i < 0 {
+		return false
+	}
+	token := key[i+len(":inflight:"):]
+	if len(token) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(token)
+	return err == nil
+}
+
+// reserveScript атомарно переносит до n элементов из начала подготовленной очереди
+// (см. queuePrepareLua) в резервирование. Время берется с сервера Redis, чтобы не зависеть
+// от часов клиентов. KEYS[1..4] - ключи очереди (см. queuePrepareKeys); KEYS[5] - список
+// элементов; ARGV[1] - n; ARGV[2] - токен; ARGV[3] - время видимости в миллисекундах.
+// Возвращает {1, элементы...} или {0} для пустой очереди.
+var reserveScript = redis.NewScript(queuePrepareLua + `
+local items = redis.call('LRANGE', KEYS[1], 0, tonumber(ARGV[1]) - 1)
+if #items == 0 then
+	return {0}
+end
+redis.call('LTRIM', KEYS[1], #items, -1)
+redis.call('SET', KEYS[4], 1)
+redis.call('RPUSH', KEYS[5], unpack(items))
+redis.call('ZADD', KEYS[3], now + tonumber(ARGV[3]), ARGV[2])
+return {1, unpack(items)}
+`)
+
+// ackScript удаляет резервирование. Возвращает 0, если токен не найден
+// или резервирование истекло (его элементы вернутся в очередь).
+// KEYS[1] - множество резервирований; KEYS[2] - список элементов; ARGV[1] - токен.
+var ackScript = redis.NewScript(`
+local deadline = redis.call('ZSCORE', KEYS[1], ARGV[1])
+if not deadline then
+	return 0
+end
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+if tonumber(deadline) <= now then
+	return 0
+end
+redis.call('ZREM', KEYS[1], ARGV[1])
+redis.call('DEL', KEYS[2])
+return 1
+`)
+
+// expiredTokensScript возвращает токены истекших резервирований (по часам Redis),
+// начиная с раньше истекших.
+// KEYS[1] - множество резервирований.
+var expiredTokensScript = redis.NewScript(`
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+return redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', now)
+`)
+
+// requeueTokenScript возвращает элементы истекшего резервирования в начало очереди
+// с сохранением их порядка. Резервирование, которое уже вернул другой процесс
+// или которое еще не истекло, не затрагивается.
+// Возвращает количество возвращенных элементов.
+// KEYS[1] - очередь; KEYS[2] - множество резервирований; KEYS[3] - список элементов;
+// ARGV[1] - токен.
+var requeueTokenScript = redis.NewScript(`
+local deadline = redis.call('ZSCORE', KEYS[2], ARGV[1])
+if not deadline then
+	return 0
+end
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+if tonumber(deadline) > now then
+	return 0
+end
+local items = redis.call('LRANGE', KEYS[3], 0, -1)
+for i = #items, 1, -1 do
+	redis.call('LPUSH', KEYS[1], items[i])
+end
+redis.call('DEL', KEYS[3])
+redis.call('ZREM', KEYS[2], ARGV[1])
+return #items
+`)
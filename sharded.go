@@ -0,0 +1,662 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"hash/maphash"
+	"slices"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// hashRing реализует консистентное хеширование ключей по шардам.
+// Каждый шард представлен набором виртуальных узлов на кольце,
+// поэтому при добавлении или удалении шарда перемещается лишь
+// небольшая доля ключей.
+type hashRing struct {
+	points []uint64  // Отсортированные позиции виртуальных узлов на кольце
+	owners []int     // Индекс шарда для каждой позиции из points
+	hash   ShardHash // Хеш ключей и виртуальных узлов
+}
+
+// newHashRing строит кольцо для шардов с указанными идентификаторами.
+// ids - стабильные идентификаторы шардов (например, адрес и номер БД)
+// virtualNodes - количество виртуальных узлов на шард
+// hash - хеш ключей и виртуальных узлов
+func newHashRing(ids []string, virtualNodes int, hash ShardHash) *hashRing {
+	type node struct {
+		point uint64
+		owner int
+	}
+	nodes := make([]node, 0, len(ids)*virtualNodes)
+	for owner, id := range ids {
+		for i := range virtualNodes {
+			nodes = append(nodes, node{point: hash(id + "#" + strconv.Itoa(i)), owner: owner})
+		}
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].point < nodes[j].point })
+
+	r := &hashRing{
+		points: make([]uint64, len(nodes)),
+		owners: make([]int, len(nodes)),
+		hash:   hash,
+	}
+	for i, n := range nodes {
+		r.points[i] = n.point
+		r.owners[i] = n.owner
+	}
+	return r
+}
+
+// shardFor возвращает индекс шарда, отвечающего за ключ.
+// Выбирается первый виртуальный узел по часовой стрелке от хеша ключа.
+func (r *hashRing) shardFor(key string) int {
+	h := r.hash(key)
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= h })
+	if i == len(r.points) {
+		i = 0 // Переходим через начало кольца
+	}
+	return r.owners[i]
+}
+
+// ShardHash вычисляет 64-битный хеш ключа или имени очереди для выбора шарда
+// (см. WithShardHash). Должен быть детерминированным: одинаковые строки
+// всегда дают одинаковый хеш.
+type ShardHash func(key string) uint64
+
+// FNVHash вычисляет 64-битный хеш FNV-1a строки. Используется по умолчанию:
+// хеш не зависит от процесса, поэтому все клиенты шардированного Redis
+// направляют ключ на один и тот же шард.
+func FNVHash(key string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	return h.Sum64()
+}
+
+// NewMapHash возвращает хеш hash/maphash со случайным для процесса seed.
+// Он быстрее FNVHash на длинных ключах и устойчив к подбору ключей, но в каждом
+// процессе распределяет ключи по-своему. Подходит, только если данные шардов
+// читает и пишет один процесс; с NewShardedRedis, к которому подключаются
+// несколько процессов, используйте FNVHash или другой хеш без seed.
+func NewMapHash() ShardHash {
+	seed := maphash.MakeSeed()
+	return func(key string) uint64 {
+		return maphash.String(seed, key)
+	}
+}
+
+// shardCursorBits - количество старших бит курсора ScanPage, кодирующих индекс шарда.
+// Ограничивает количество шардов значением 1<<shardCursorBits.
+const shardCursorBits = 8
+
+// shardedStorage распределяет данные между несколькими хранилищами.
+// Ключи и очереди направляются на шард по консистентному хешу,
+// очередь целиком хранится на одном шарде (по имени очереди).
+type shardedStorage[T any] struct {
+	shards  []Storage[T] // Хранилища-шарды
+	ring    *hashRing    // Кольцо консистентного хеширования
+	opts    options      // Дополнительные параметры (нормализатор ключей)
+	closing atomic.Bool  // Close уже вызывался
+}
+
+// newShardedRedisStorage создает шардированное хранилище поверх нескольких Redis.
+// Для каждой конфигурации создается отдельное подключение; при ошибке
+// уже открытые подключения закрываются.
+func newShardedRedisStorage[T any](configs []RedisConfig, opts []Option) (Storage[T], error) {
+	if len(configs) == 0 {
+		return nil, errors.New("sharded redis: no shards configured")
+	}
+	if len(configs) > 1<<shardCursorBits {
+		return nil, fmt.Errorf("sharded redis: at most %d shards supported", 1<<shardCursorBits)
+	}
+	o := defaultOptions(opts)
+	shardOpts := append(slices.Clip(opts), withoutHooks()) // Хуки сбрасывает Close шардированного хранилища
+
+	shards := make([]Storage[T], 0, len(configs))
+	ids := make([]string, 0, len(configs))
+	for _, cfg := range configs {
+		shard, err := newRedisStorage[T](cfg, shardOpts)
+		if err != nil {
+			for _, opened := range shards {
+				_ = opened.Close()
+			}
+			return nil, fmt.Errorf("sharded redis: shard %s: %w", cfg.Addr, err)
+		}
+		shards = append(shards, shard)
+		ids = append(ids, cfg.Addr+"/"+strconv.Itoa(cfg.DB))
+	}
+
+	return &shardedStorage[T]{
+		shards: shards,
+		ring:   newHashRing(ids, o.virtualNodes, o.shardHash),
+		opts:   o,
+	}, nil
+}
+
+// shard возвращает хранилище, отвечающее за ключ или имя очереди.
+func (s *shardedStorage[T]) shard(key string) Storage[T] {
+	return s.shards[s.ring.shardFor(key)]
+}
+
+// Set сохраняет значение на шарде, отвечающем за ключ.
+func (s *shardedStorage[T]) Set(ctx context.Context, key string, value T, ttl time.Duration) error {
+	key = s.opts.normalizeKey(key) // Одинаковые после нормализации ключи попадают на один шард
+	return s.shard(key).Set(ctx, key, value, ttl)
+}
+
+// SetX сохраняет значение на шарде, отвечающем за ключ, и возвращает время истечения.
+func (s *shardedStorage[T]) SetX(ctx context.Context, key string, value T, ttl time.Duration) (time.Time, error) {
+	key = s.opts.normalizeKey(key)
+	return s.shard(key).SetX(ctx, key, value, ttl)
+}
+
+// Get получает значение с шарда, отвечающего за ключ.
+func (s *shardedStorage[T]) Get(ctx context.Context, key string) (T, bool, error) {
+	key = s.opts.normalizeKey(key)
+	return s.shard(key).Get(ctx, key)
+}
+
+// GetEx получает значение и обновляет TTL на шарде, отвечающем за ключ.
+func (s *shardedStorage[T]) GetEx(ctx context.Context, key string, ttl time.Duration) (T, bool, error) {
+	key = s.opts.normalizeKey(key)
+	return s.shard(key).GetEx(ctx, key, ttl)
+}
+
+// WaitForKey ждет появления ключа на шарде, отвечающем за него.
+func (s *shardedStorage[T]) WaitForKey(ctx context.Context, key string, timeout time.Duration) (T, bool, error) {
+	key = s.opts.normalizeKey(key)
+	return s.shard(key).WaitForKey(ctx, key, timeout)
+}
+
+// GetInto получает значение в dst с шарда, отвечающего за ключ.
+func (s *shardedStorage[T]) GetInto(ctx context.Context, key string, dst *T) (bool, error) {
+	key = s.opts.normalizeKey(key)
+	return s.shard(key).GetInto(ctx, key, dst)
+}
+
+// SetWithCodec сохраняет значение, сериализованное кодеком codec, на шарде, отвечающем за ключ.
+func (s *shardedStorage[T]) SetWithCodec(ctx context.Context, key string, value T, ttl time.Duration, codec Codec) error {
+	key = s.opts.normalizeKey(key)
+	return s.shard(key).SetWithCodec(ctx, key, value, ttl, codec)
+}
+
+// GetWithCodec получает значение с шарда, отвечающего за ключ, с кодеком codec.
+func (s *shardedStorage[T]) GetWithCodec(ctx context.Context, key string, codec Codec) (T, bool, error) {
+	key = s.opts.normalizeKey(key)
+	return s.shard(key).GetWithCodec(ctx, key, codec)
+}
+
+// GetRaw получает сериализованное значение с шарда, отвечающего за ключ.
+func (s *shardedStorage[T]) GetRaw(ctx context.Context, key string) ([]byte, bool, error) {
+	key = s.opts.normalizeKey(key)
+	return s.shard(key).GetRaw(ctx, key)
+}
+
+// GetWithMeta получает значение с метаданными с шарда, отвечающего за ключ.
+func (s *shardedStorage[T]) GetWithMeta(ctx context.Context, key string) (T, Meta, bool, error) {
+	key = s.opts.normalizeKey(key)
+	return s.shard(key).GetWithMeta(ctx, key)
+}
+
+// GetWithTTL получает значение и время жизни с шарда, отвечающего за ключ.
+func (s *shardedStorage[T]) GetWithTTL(ctx context.Context, key string) (T, time.Duration, bool, error) {
+	key = s.opts.normalizeKey(key)
+	return s.shard(key).GetWithTTL(ctx, key)
+}
+
+// Delete удаляет значение с шарда, отвечающего за ключ.
+func (s *shardedStorage[T]) Delete(ctx context.Context, key string) error {
+	key = s.opts.normalizeKey(key)
+	return s.shard(key).Delete(ctx, key)
+}
+
+// DeleteIf удаляет запись с ожидаемым значением на шарде, отвечающем за ключ.
+func (s *shardedStorage[T]) DeleteIf(ctx context.Context, key string, expected T) (bool, error) {
+	key = s.opts.normalizeKey(key)
+	return s.shard(key).DeleteIf(ctx, key, expected)
+}
+
+// GetDelete атомарно получает и удаляет значение на шарде, отвечающем за ключ.
+func (s *shardedStorage[T]) GetDelete(ctx context.Context, key string) (T, bool, error) {
+	key = s.opts.normalizeKey(key)
+	return s.shard(key).GetDelete(ctx, key)
+}
+
+// Toggle инвертирует значение на шарде, отвечающем за ключ.
+func (s *shardedStorage[T]) Toggle(ctx context.Context, key string) (bool, error) {
+	key = s.opts.normalizeKey(key)
+	return s.shard(key).Toggle(ctx, key)
+}
+
+// IncrementField увеличивает поле на шарде, отвечающем за ключ.
+func (s *shardedStorage[T]) IncrementField(ctx context.Context, key, field string, delta int64) (int64, error) {
+	key = s.opts.normalizeKey(key)
+	return s.shard(key).IncrementField(ctx, key, field, delta)
+}
+
+// ClaimOnce записывает маркер на шарде, отвечающем за ключ.
+func (s *shardedStorage[T]) ClaimOnce(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	key = s.opts.normalizeKey(key)
+	return s.shard(key).ClaimOnce(ctx, key, ttl)
+}
+
+// SetVersioned записывает значение и историю на шарде, отвечающем за ключ.
+func (s *shardedStorage[T]) SetVersioned(ctx context.Context, key string, value T, keep int) error {
+	key = s.opts.normalizeKey(key)
+	return s.shard(key).SetVersioned(ctx, key, value, keep)
+}
+
+// GetVersion читает историю на шарде, отвечающем за ключ.
+func (s *shardedStorage[T]) GetVersion(ctx context.Context, key string, n int) (T, bool, error) {
+	key = s.opts.normalizeKey(key)
+	return s.shard(key).GetVersion(ctx, key, n)
+}
+
+// IncrWindow увеличивает счетчик окна на шарде, отвечающем за ключ.
+func (s *shardedStorage[T]) IncrWindow(ctx context.Context, key string, window time.Duration, limit int64) (int64, bool, error) {
+	key = s.opts.normalizeKey(key)
+	return s.shard(key).IncrWindow(ctx, key, window, limit)
+}
+
+// CopyKey копирует запись, если src и dst находятся на одном шарде.
+// Копирование между шардами не может быть атомарным и возвращает ErrUnsupported.
+func (s *shardedStorage[T]) CopyKey(ctx context.Context, src, dst string, replace bool) (bool, error) {
+	src, dst = s.opts.normalizeKey(src), s.opts.normalizeKey(dst)
+	shard := s.shard(src)
+	if s.shard(dst) != shard {
+		return false, fmt.Errorf("%w: CopyKey between shards", ErrUnsupported)
+	}
+	return shard.CopyKey(ctx, src, dst, replace)
+}
+
+// DeletePattern удаляет записи по шаблону на всех шардах.
+// Возвращает суммарное количество удаленных записей.
+func (s *shardedStorage[T]) DeletePattern(ctx context.Context, pattern string) (int64, error) {
+	var deleted int64
+	for _, shard := range s.shards {
+		n, err := shard.DeletePattern(ctx, pattern)
+		deleted += n
+		if err != nil {
+			return deleted, err
+		}
+	}
+	return deleted, nil
+}
+
+// SetWithTags сохраняет значение с тегами на шарде, отвечающем за ключ.
+// Теги ведутся каждым шардом отдельно.
+func (s *shardedStorage[T]) SetWithTags(ctx context.Context, key string, value T, ttl time.Duration, tags []string) error {
+	key = s.opts.normalizeKey(key)
+	return s.shard(key).SetWithTags(ctx, key, value, ttl, tags)
+}
+
+// InvalidateTag удаляет записи тега на всех шардах.
+// Возвращает суммарное количество удаленных записей.
+func (s *shardedStorage[T]) InvalidateTag(ctx context.Context, tag string) (int64, error) {
+	var deleted int64
+	for _, shard := range s.shards {
+		n, err := shard.InvalidateTag(ctx, tag)
+		deleted += n
+		if err != nil {
+			return deleted, err
+		}
+	}
+	return deleted, nil
+}
+
+// CountPattern суммирует количество записей по шаблону на всех шардах.
+func (s *shardedStorage[T]) CountPattern(ctx context.Context, pattern string) (int64, error) {
+	var total int64
+	for _, shard := range s.shards {
+		n, err := shard.CountPattern(ctx, pattern)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// GetByPrefix объединяет записи с префиксом со всех шардов.
+func (s *shardedStorage[T]) GetByPrefix(ctx context.Context, prefix string) (map[string]T, error) {
+	values := make(map[string]T)
+	for _, shard := range s.shards {
+		part, err := shard.GetByPrefix(ctx, prefix)
+		if err != nil {
+			return nil, err
+		}
+		for key, value := range part {
+			values[key] = value
+		}
+	}
+	return values, nil
+}
+
+// ScanPage перебирает шарды по очереди.
+// Старшие shardCursorBits бит курсора содержат индекс шарда, младшие - курсор шарда.
+func (s *shardedStorage[T]) ScanPage(ctx context.Context, cursor uint64, match string, count int) ([]string, uint64, error) {
+	idx := int(cursor >> (64 - shardCursorBits))
+	inner := cursor & (1<<(64-shardCursorBits) - 1)
+	if idx >= len(s.shards) {
+		return nil, 0, nil
+	}
+
+	keys, next, err := s.shards[idx].ScanPage(ctx, inner, match, count)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	switch {
+	case next != 0:
+		return keys, uint64(idx)<<(64-shardCursorBits) | next, nil
+	case idx+1 < len(s.shards):
+		return keys, uint64(idx+1) << (64 - shardCursorBits), nil // Переходим к следующему шарду
+	default:
+		return keys, 0, nil
+	}
+}
+
+// Inspect возвращает сведения о записи с шарда, отвечающего за ключ.
+func (s *shardedStorage[T]) Inspect(ctx context.Context, key string) (ObjectInfo, bool, error) {
+	key = s.opts.normalizeKey(key)
+	return s.shard(key).Inspect(ctx, key)
+}
+
+// Enqueue добавляет элемент в очередь на шарде, отвечающем за имя очереди.
+func (s *shardedStorage[T]) Enqueue(ctx context.Context, queueName string, value T) error {
+	return s.shard(queueName).Enqueue(ctx, queueName, value)
+}
+
+// EnqueueFanout группирует очереди по шардам и добавляет значение на каждом шарде.
+// Ошибки шардов собираются в общий *BatchError: ошибка, не относящаяся
+// к отдельной очереди, приписывается всем очередям шарда.
+func (s *shardedStorage[T]) EnqueueFanout(ctx context.Context, queueNames []string, value T) error {
+	groups := make(map[int][]string)
+	for _, name := range queueNames {
+		idx := s.ring.shardFor(name)
+		groups[idx] = append(groups[idx], name)
+	}
+
+	batchErr := &BatchError{Errs: make(map[string]error)}
+	for idx, names := range groups {
+		err := s.shards[idx].EnqueueFanout(ctx, names, value)
+		var partErr *BatchError
+		switch {
+		case err == nil:
+		case errors.As(err, &partErr):
+			for name, err := range partErr.Errs {
+				batchErr.Errs[name] = err
+			}
+		default:
+			for _, name := range names {
+				batchErr.Errs[name] = err
+			}
+		}
+	}
+	if len(batchErr.Errs) > 0 {
+		return batchErr
+	}
+	return nil
+}
+
+// DequeueTracked извлекает элемент, если очередь и ключ отслеживания находятся на одном шарде.
+// Иначе запись не может быть атомарной и возвращается ErrUnsupported.
+func (s *shardedStorage[T]) DequeueTracked(ctx context.Context, queueName, trackingKey string, ttl time.Duration) (T, bool, error) {
+	trackingKey = s.opts.normalizeKey(trackingKey)
+	shard := s.shard(queueName)
+	if s.shard(trackingKey) != shard {
+		var zero T
+		return zero, false, fmt.Errorf("%w: DequeueTracked across shards", ErrUnsupported)
+	}
+	return shard.DequeueTracked(ctx, queueName, trackingKey, ttl)
+}
+
+// EnqueueN добавляет элемент в очередь на шарде и возвращает ее новую длину.
+func (s *shardedStorage[T]) EnqueueN(ctx context.Context, queueName string, value T) (int64, error) {
+	return s.shard(queueName).EnqueueN(ctx, queueName, value)
+}
+
+// EnqueueCapped добавляет элемент в ограниченную очередь на шарде.
+func (s *shardedStorage[T]) EnqueueCapped(ctx context.Context, queueName string, value T, maxLen int64) error {
+	return s.shard(queueName).EnqueueCapped(ctx, queueName, value, maxLen)
+}
+
+// TryEnqueue добавляет элемент в ограниченную очередь на шарде и сообщает причину отказа.
+func (s *shardedStorage[T]) TryEnqueue(ctx context.Context, queueName string, value T, maxLen int64) (bool, EnqueueReason, error) {
+	return s.shard(queueName).TryEnqueue(ctx, queueName, value, maxLen)
+}
+
+// EnqueueBounded добавляет элемент в ограниченную очередь на шарде, если в ней есть место.
+func (s *shardedStorage[T]) EnqueueBounded(ctx context.Context, queueName string, value T, maxLen int64) (bool, error) {
+	return s.shard(queueName).EnqueueBounded(ctx, queueName, value, maxLen)
+}
+
+// EnqueueDelayed добавляет отложенный элемент в очередь на шарде.
+func (s *shardedStorage[T]) EnqueueDelayed(ctx context.Context, queueName string, value T, delay time.Duration) error {
+	return s.shard(queueName).EnqueueDelayed(ctx, queueName, value, delay)
+}
+
+// Dequeue извлекает элемент из очереди на шарде, отвечающем за имя очереди.
+func (s *shardedStorage[T]) Dequeue(ctx context.Context, queueName string) (T, bool, error) {
+	return s.shard(queueName).Dequeue(ctx, queueName)
+}
+
+// Peek просматривает первый элемент очереди на шарде, отвечающем за имя очереди.
+func (s *shardedStorage[T]) Peek(ctx context.Context, queueName string) (T, bool, error) {
+	return s.shard(queueName).Peek(ctx, queueName)
+}
+
+// Remove удаляет первый элемент очереди на шарде, отвечающем за имя очереди.
+func (s *shardedStorage[T]) Remove(ctx context.Context, queueName string) (bool, error) {
+	return s.shard(queueName).Remove(ctx, queueName)
+}
+
+// RemoveAt удаляет элемент по позиции в очереди на шарде.
+func (s *shardedStorage[T]) RemoveAt(ctx context.Context, queueName string, index int64) (bool, error) {
+	return s.shard(queueName).RemoveAt(ctx, queueName, index)
+}
+
+// BlockingDequeueAny ждет элемент на шарде, если все очереди находятся на одном.
+// Ожидание на нескольких шардах не может соблюдать приоритет и возвращает ErrUnsupported.
+func (s *shardedStorage[T]) BlockingDequeueAny(ctx context.Context, queueNames []string, timeout time.Duration) (string, T, bool, error) {
+	var zero T
+	if len(queueNames) == 0 {
+		return "", zero, false, nil
+	}
+	shard := s.shard(queueNames[0])
+	for _, name := range queueNames[1:] {
+		if s.shard(name) != shard {
+			return "", zero, false, fmt.Errorf("%w: BlockingDequeueAny across shards", ErrUnsupported)
+		}
+	}
+	return shard.BlockingDequeueAny(ctx, queueNames, timeout)
+}
+
+// ReplaceQueue заменяет содержимое очереди на шарде, отвечающем за имя очереди.
+func (s *shardedStorage[T]) ReplaceQueue(ctx context.Context, queueName string, values []T) error {
+	return s.shard(queueName).ReplaceQueue(ctx, queueName, values)
+}
+
+// RotateQueue переносит первый элемент очереди в ее конец на шарде, отвечающем за имя очереди.
+func (s *shardedStorage[T]) RotateQueue(ctx context.Context, queueName string) (T, bool, error) {
+	return s.shard(queueName).RotateQueue(ctx, queueName)
+}
+
+// SetHead заменяет первый элемент очереди на шарде, отвечающем за имя очереди.
+func (s *shardedStorage[T]) SetHead(ctx context.Context, queueName string, value T) (T, bool, error) {
+	return s.shard(queueName).SetHead(ctx, queueName, value)
+}
+
+// MoveMatching переносит элементы, если обе очереди находятся на одном шарде.
+// Перенос между шардами не может быть атомарным и возвращает ErrUnsupported.
+func (s *shardedStorage[T]) MoveMatching(ctx context.Context, from, to string, match func(T) bool, limit int) (int, error) {
+	shard := s.shard(from)
+	if s.shard(to) != shard {
+		return 0, fmt.Errorf("%w: MoveMatching between shards", ErrUnsupported)
+	}
+	return shard.MoveMatching(ctx, from, to, match, limit)
+}
+
+// QueueLen возвращает длину очереди на шарде, отвечающем за имя очереди.
+func (s *shardedStorage[T]) QueueLen(ctx context.Context, queueName string) (int64, error) {
+	return s.shard(queueName).QueueLen(ctx, queueName)
+}
+
+// QueueReadFrom читает элементы очереди по смещению с шарда, отвечающего за имя очереди.
+func (s *shardedStorage[T]) QueueReadFrom(ctx context.Context, queueName string, offset int64, n int) ([]T, int64, error) {
+	return s.shard(queueName).QueueReadFrom(ctx, queueName, offset, n)
+}
+
+// QueueIterate обходит очередь на шарде, отвечающем за имя очереди.
+func (s *shardedStorage[T]) QueueIterate(ctx context.Context, queueName string, fn func(T) error) error {
+	return s.shard(queueName).QueueIterate(ctx, queueName, fn)
+}
+
+// QueueTail возвращает последние элементы очереди с шарда, отвечающего за имя очереди.
+func (s *shardedStorage[T]) QueueTail(ctx context.Context, queueName string, n int) ([]T, error) {
+	return s.shard(queueName).QueueTail(ctx, queueName, n)
+}
+
+// QueueStats возвращает статистику очереди с шарда, отвечающего за имя очереди.
+func (s *shardedStorage[T]) QueueStats(ctx context.Context, queueName string) (QueueStats, error) {
+	return s.shard(queueName).QueueStats(ctx, queueName)
+}
+
+// QueueLenMany группирует очереди по шардам и запрашивает каждый шард один раз.
+func (s *shardedStorage[T]) QueueLenMany(ctx context.Context, queueNames []string) (map[string]int64, error) {
+	groups := make(map[int][]string)
+	for _, name := range queueNames {
+		idx := s.ring.shardFor(name)
+		groups[idx] = append(groups[idx], name)
+	}
+
+	lengths := make(map[string]int64, len(queueNames))
+	for idx, names := range groups {
+		part, err := s.shards[idx].QueueLenMany(ctx, names)
+		if err != nil {
+			return nil, err
+		}
+		for name, n := range part {
+			lengths[name] = n
+		}
+	}
+	return lengths, nil
+}
+
+// QueueIndexOf ищет элемент в очереди на шарде, отвечающем за имя очереди.
+func (s *shardedStorage[T]) QueueIndexOf(ctx context.Context, queueName string, match func(T) bool) (int64, bool, error) {
+	return s.shard(queueName).QueueIndexOf(ctx, queueName, match)
+}
+
+// QueueIndexOfValue ищет значение в очереди на шарде, отвечающем за имя очереди.
+func (s *shardedStorage[T]) QueueIndexOfValue(ctx context.Context, queueName string, value T) (int64, bool, error) {
+	return s.shard(queueName).QueueIndexOfValue(ctx, queueName, value)
+}
+
+// PeekBatchReserve резервирует элементы очереди на шарде, отвечающем за имя очереди.
+func (s *shardedStorage[T]) PeekBatchReserve(ctx context.Context, queueName string, n int, vt time.Duration) ([]T, string, error) {
+	return s.shard(queueName).PeekBatchReserve(ctx, queueName, n, vt)
+}
+
+// AckBatch подтверждает резервирование на шарде, отвечающем за имя очереди.
+func (s *shardedStorage[T]) AckBatch(ctx context.Context, queueName, token string) error {
+	return s.shard(queueName).AckBatch(ctx, queueName, token)
+}
+
+// SAddEx добавляет элемент в множество на шарде, отвечающем за имя множества.
+func (s *shardedStorage[T]) SAddEx(ctx context.Context, setName string, member T, ttl time.Duration) error {
+	return s.shard(setName).SAddEx(ctx, setName, member, ttl)
+}
+
+// SCount возвращает количество элементов множества на шарде.
+func (s *shardedStorage[T]) SCount(ctx context.Context, setName string) (int64, error) {
+	return s.shard(setName).SCount(ctx, setName)
+}
+
+// Pipeline создает пакет команд, распределяемых по шардам при выполнении.
+func (s *shardedStorage[T]) Pipeline() Pipeline[T] {
+	return &shardedPipeline[T]{s: s}
+}
+
+// shardedPipeline группирует команды по шардам и выполняет
+// по одному пакету на каждый затронутый шард.
+type shardedPipeline[T any] struct {
+	pipelineOps[T]
+	s *shardedStorage[T]
+}
+
+// Exec выполняет команды пакетами по шардам и собирает результаты
+// в исходном порядке команд.
+func (p *shardedPipeline[T]) Exec(ctx context.Context) ([]PipelineResult[T], error) {
+	ops := p.take()
+	results := make([]PipelineResult[T], len(ops))
+
+	pipes := make(map[int]Pipeline[T])
+	positions := make(map[int][]int) // Шард -> индексы его команд в ops
+	for i, op := range ops {
+		if op.kind != pipelineEnqueue {
+			op.key = p.s.opts.normalizeKey(op.key)
+		}
+		idx := p.s.ring.shardFor(op.key)
+		pipe, ok := pipes[idx]
+		if !ok {
+			pipe = p.s.shards[idx].Pipeline()
+			pipes[idx] = pipe
+		}
+		op.apply(pipe)
+		positions[idx] = append(positions[idx], i)
+	}
+
+	for idx, pipe := range pipes {
+		part, err := pipe.Exec(ctx)
+		for j, pos := range positions[idx] {
+			if j < len(part) {
+				results[pos] = part[j]
+			} else {
+				results[pos].Err = err // Шард не вернул результатов
+			}
+		}
+	}
+
+	return results, firstErr(results)
+}
+
+// Transact не поддерживается и возвращает ErrUnsupported: ключи транзакции
+// могут оказаться на разных шардах, а атомарной фиксации между шардами нет.
+func (s *shardedStorage[T]) Transact(ctx context.Context, fn func(tx Tx[T]) error) error {
+	return unsupported("Transact")
+}
+
+// Capabilities возвращает возможности, общие для всех шардов.
+// Диагностика отдельных шардов (популярные ключи, статистика) через обертку недоступна,
+// а транзакции не поддерживаются: ключи транзакции могут оказаться на разных шардах.
+func (s *shardedStorage[T]) Capabilities() Capability {
+	caps := ^Capability(0)
+	for _, shard := range s.shards {
+		caps &= shard.Capabilities()
+	}
+	return caps &^ (CapHotKeys | CapStats | CapTransactions)
+}
+
+// Close сбрасывает хуки WithHook и закрывает все шарды.
+// Возвращает объединенную ошибку всех шардов, закрыть которые не удалось.
+// Повторный вызов возвращает ErrClosed.
+func (s *shardedStorage[T]) Close() error {
+	if !s.closing.CompareAndSwap(false, true) {
+		return ErrClosed
+	}
+	var errs []error
+	if err := flushHooks(s.opts.hooks, nil); err != nil {
+		errs = append(errs, err)
+	}
+	for _, shard := range s.shards {
+		if err := shard.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
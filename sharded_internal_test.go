@@ -0,0 +1,44 @@
+package storage
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHashRing_MinimalMovementOnAddShard(t *testing.T) {
+	before := newHashRing([]string{"a", "b", "c"}, 160, FNVHash)
+	after := newHashRing([]string{"a", "b", "c", "d"}, 160, FNVHash)
+
+	moved := 0
+	const total = 10000
+	for i := range total {
+		key := "key:" + strconv.Itoa(i)
+		from, to := before.shardFor(key), after.shardFor(key)
+		if from != to {
+			moved++
+			require.Equal(t, 3, to, "key %s moved between old shards", key)
+		}
+	}
+
+	// Новому шарду должна достаться примерно четверть ключей
+	require.Greater(t, moved, total/8)
+	require.Less(t, moved, total/2)
+}
+
+func TestHashRing_MapHashDistribution(t *testing.T) {
+	ring := newHashRing([]string{"a", "b", "c", "d"}, 160, NewMapHash())
+
+	counts := make([]int, 4)
+	const total = 10000
+	for i := range total {
+		key := "user:" + strconv.Itoa(i)
+		shard := ring.shardFor(key)
+		require.Equal(t, shard, ring.shardFor(key), "хеш детерминирован в пределах процесса")
+		counts[shard]++
+	}
+	for shard, n := range counts {
+		require.Greater(t, n, total/8, "shard %d", shard)
+	}
+}
@@ -0,0 +1,53 @@
+package storage_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alfzs/go-storage"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestShardedRedisStorage[T any](t *testing.T) storage.Storage[T] {
+	s, err := storage.NewShardedRedis[T]([]storage.RedisConfig{
+		{Addr: "localhost:6379", DB: 1},
+		{Addr: "localhost:6379", DB: 2},
+	})
+	require.NoError(t, err)
+	return s
+}
+
+func TestShardedRedisStorage_NoShards(t *testing.T) {
+	_, err := storage.NewShardedRedis[string](nil)
+	require.Error(t, err)
+}
+
+func TestShardedRedisStorage_Operations(t *testing.T) {
+	ctx := context.Background()
+	s := newTestShardedRedisStorage[string](t)
+	defer s.Close()
+
+	for _, key := range []string{"alpha", "beta", "gamma", "delta"} {
+		require.NoError(t, s.Set(ctx, key, key+"-value", 0))
+	}
+	for _, key := range []string{"alpha", "beta", "gamma", "delta"} {
+		val, found, err := s.Get(ctx, key)
+		require.NoError(t, err)
+		require.True(t, found)
+		require.Equal(t, key+"-value", val)
+		require.NoError(t, s.Delete(ctx, key))
+	}
+
+	require.NoError(t, s.Delete(ctx, "sharded:queue"))
+	require.NoError(t, s.Enqueue(ctx, "sharded:queue", "first"))
+	require.NoError(t, s.Enqueue(ctx, "sharded:queue", "second"))
+
+	lengths, err := s.QueueLenMany(ctx, []string{"sharded:queue", "sharded:missing"})
+	require.NoError(t, err)
+	require.Equal(t, map[string]int64{"sharded:queue": 2, "sharded:missing": 0}, lengths)
+
+	val, found, err := s.Dequeue(ctx, "sharded:queue")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "first", val)
+}
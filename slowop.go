@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// slowOpHook - хук go-redis, сообщающий о командах, выполнявшихся дольше порога
+// (см. WithSlowOpThreshold).
+type slowOpHook struct {
+	threshold time.Duration
+	report    func(method, key string, d time.Duration)
+}
+
+// newSlowOpHook возвращает хук медленных команд или nil, если WithSlowOpThreshold не задан.
+func newSlowOpHook(o options) redis.Hook {
+	if o.slowOpThreshold <= 0 || o.onSlowOp == nil {
+		return nil
+	}
+	return NewSlowOpHook(o.slowOpThreshold, o.onSlowOp)
+}
+
+// NewSlowOpHook возвращает хук go-redis, вызывающий onSlowOp для команд, выполнявшихся
+// дольше threshold (параметры те же, что у WithSlowOpThreshold). Хранилище NewRedisWithClient
+// не изменяет переданный клиент, поэтому хук добавляет владелец клиента, один раз на клиент:
+//
+//	client.AddHook(storage.NewSlowOpHook(100*time.Millisecond, report))
+//	s, err := storage.NewRedisWithClient[Item](client)
+//
+// nil onSlowOp выключает наблюдение.
+func NewSlowOpHook(threshold time.Duration, onSlowOp func(method, key string, d time.Duration)) redis.Hook {
+	if onSlowOp == nil {
+		onSlowOp = func(string, string, time.Duration) {}
+	}
+	return slowOpHook{threshold: threshold, report: onSlowOp}
+}
+
+// DialHook не изменяет установку соединений.
+func (h slowOpHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+// ProcessHook измеряет время выполнения отдельной команды.
+func (h slowOpHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmd)
+		if d := time.Since(start); d > h.threshold {
+			h.report(cmd.Name(), commandKey(cmd), d)
+		}
+		return err
+	}
+}
+
+// ProcessPipelineHook измеряет время выполнения конвейера или транзакции целиком.
+func (h slowOpHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmds)
+		if d := time.Since(start); d > h.threshold {
+			h.report("pipeline", "", d)
+		}
+		return err
+	}
+}
+
+// commandKey возвращает первый ключ команды: первый аргумент для обычных команд
+// и первый элемент KEYS для скриптов. Для команд без ключа возвращает "".
+func commandKey(cmd redis.Cmder) string {
+	args := cmd.Args()
+	first := 1
+	switch cmd.Name() {
+	case "eval", "evalsha", "eval_ro", "evalsha_ro":
+		// EVALSHA sha numkeys key [key ...] arg [arg ...]
+		if n, _ := args[2].(int); len(args) < 4 || n == 0 {
+			return ""
+		}
+		first = 3
+	}
+	if len(args) <= first {
+		return ""
+	}
+	key, _ := args[first].(string)
+	return key
+}
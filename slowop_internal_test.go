@@ -0,0 +1,41 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlowOpHook(t *testing.T) {
+	type slowOp struct{ method, key string }
+	var reported []slowOp
+	hook := newSlowOpHook(defaultOptions([]Option{
+		WithSlowOpThreshold(time.Nanosecond, func(method, key string, _ time.Duration) {
+			reported = append(reported, slowOp{method, key})
+		}),
+	}))
+	require.NotNil(t, hook)
+
+	// Недоступный сервер: команды завершаются ошибкой, но время все равно измеряется
+	client := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	defer client.Close()
+	client.AddHook(hook)
+
+	ctx := context.Background()
+	_ = client.Get(ctx, "k").Err()
+	_ = scountScript.Run(ctx, client, []string{"set"}).Err() // Ошибка соединения не приводит к EVAL
+	_ = client.Ping(ctx).Err()
+
+	require.Equal(t, []slowOp{{"get", "k"}, {"evalsha", "set"}, {"ping", ""}}, reported)
+
+	require.Nil(t, newSlowOpHook(defaultOptions(nil)))
+
+	// Хук для чужого клиента без обработчика ничего не сообщает
+	other := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	defer other.Close()
+	other.AddHook(NewSlowOpHook(time.Nanosecond, nil))
+	require.NotPanics(t, func() { _ = other.Ping(ctx).Err() })
+}
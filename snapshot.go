@@ -0,0 +1,216 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"iter"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// snapshotFile - формат снимка in-memory хранилища на диске.
+// Значения сериализуются кодеком хранилища, структура файла - JSON.
+type snapshotFile struct {
+	Items   map[string]snapshotItem      `json:"items"`
+	Queues  map[string][][]byte          `json:"queues,omitempty"`
+	Delayed map[string][]snapshotDelayed `json:"delayed,omitempty"`
+}
+
+// snapshotItem - запись ключ-значение в снимке.
+type snapshotItem struct {
+	V []byte `json:"v"`           // Значение, сериализованное кодеком
+	E int64  `json:"e,omitempty"` // Время истечения в наносекундах (0 - бессрочно)
+	C int64  `json:"c,omitempty"` // Время создания (только в режиме WithMetadata)
+	U int64  `json:"u,omitempty"` // Время обновления (только в режиме WithMetadata)
+	K string `json:"k,omitempty"` // Исходный ключ, если он был заменен хешем
+}
+
+// snapshotDelayed - отложенный элемент очереди в снимке.
+type snapshotDelayed struct {
+	V []byte `json:"v"` // Значение, сериализованное кодеком
+	R int64  `json:"r"` // Время готовности в наносекундах
+}
+
+// snapshot собирает снимок хранилища под блокировками на чтение.
+// Зарезервированные элементы сохраняются в начале своих очередей:
+// после восстановления они будут доставлены повторно.
+// Если включен журнал очередей (WithQueueWAL), очереди в снимок не попадают.
+func (s *memoryStorage[T]) snapshot() (*snapshotFile, error) {
+	s.itemMu.RLock()
+	defer s.itemMu.RUnlock()
+	s.queueMu.RLock()
+	defer s.queueMu.RUnlock()
+
+	snap := &snapshotFile{
+		Items:   make(map[string]snapshotItem, len(s.items)),
+		Queues:  make(map[string][][]byte, len(s.queues)),
+		Delayed: make(map[string][]snapshotDelayed, len(s.delayed)),
+	}
+	for key, it := range s.items {
+		if it.isExpired() {
+			continue
+		}
+		data, err := s.opts.codec.Marshal(it.value)
+		if err != nil {
+			return nil, fmt.Errorf("marshal %q failed: %w", key, err)
+		}
+		snap.Items[key] = snapshotItem{V: data, E: it.expiration, C: it.created, U: it.updated, K: it.origKey}
+	}
+	if s.opts.queueWALPath != "" {
+		return snap, nil // Очереди восстанавливаются из журнала
+	}
+
+	encodeQueue := func(name string, values iter.Seq2[int, T]) error {
+		for _, value := range values {
+			data, err := s.opts.codec.Marshal(value)
+			if err != nil {
+				return fmt.Errorf("marshal queue %q failed: %w", name, err)
+			}
+			snap.Queues[name] = append(snap.Queues[name], data)
+		}
+		return nil
+	}
+	for name, reserved := range s.reservations {
+		for _, r := range reserved {
+			if err := encodeQueue(name, slices.All(r.items)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for name, queue := range s.queues {
+		if err := encodeQueue(name, queue.All()); err != nil {
+			return nil, err
+		}
+	}
+
+	for name, pending := range s.delayed {
+		for _, d := range pending {
+			data, err := s.opts.codec.Marshal(d.value)
+			if err != nil {
+				return nil, fmt.Errorf("marshal queue %q failed: %w", name, err)
+			}
+			snap.Delayed[name] = append(snap.Delayed[name], snapshotDelayed{V: data, R: d.ready})
+		}
+	}
+	return snap, nil
+}
+
+// writeSnapshot атомарно записывает снимок в файл: данные пишутся во временный
+// файл в том же каталоге, который затем переименовывается в целевой.
+func (s *memoryStorage[T]) writeSnapshot() error {
+	snap, err := s.snapshot()
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return fmt.Errorf("snapshot encode failed: %w", err)
+	}
+
+	path := s.opts.snapshotPath
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("snapshot create failed: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // Удаляем временный файл, если переименование не состоялось
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("snapshot write failed: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("snapshot sync failed: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("snapshot write failed: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("snapshot rename failed: %w", err)
+	}
+	return nil
+}
+
+// loadSnapshot загружает снимок из файла, если он существует.
+// Истекшие записи пропускаются. Вызывается до запуска фоновых горутин.
+func (s *memoryStorage[T]) loadSnapshot() error {
+	data, err := os.ReadFile(s.opts.snapshotPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil // Снимка еще нет - начинаем с пустого хранилища
+	}
+	if err != nil {
+		return fmt.Errorf("snapshot read failed: %w", err)
+	}
+
+	var snap snapshotFile
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return fmt.Errorf("snapshot decode failed: %w", err)
+	}
+
+	for key, si := range snap.Items {
+		it := item[T]{expiration: si.E, created: si.C, updated: si.U, origKey: si.K}
+		if it.isExpired() {
+			continue
+		}
+		if err := s.opts.codec.Unmarshal(si.V, &it.value); err != nil {
+			return fmt.Errorf("snapshot unmarshal %q failed: %w", key, err)
+		}
+		s.items[key] = it
+		s.expiry.schedule(key, it.expiration)
+		s.track(key, it.value)
+	}
+	if s.opts.queueWALPath != "" {
+		return nil // Очереди восстанавливаются из журнала
+	}
+	for name, queue := range snap.Queues {
+		for _, raw := range queue {
+			var value T
+			if err := s.opts.codec.Unmarshal(raw, &value); err != nil {
+				return fmt.Errorf("snapshot unmarshal queue %q failed: %w", name, err)
+			}
+			s.queue(name).PushBack(value)
+		}
+	}
+	for name, pending := range snap.Delayed {
+		for _, sd := range pending {
+			d := delayedItem[T]{ready: sd.R}
+			if err := s.opts.codec.Unmarshal(sd.V, &d.value); err != nil {
+				return fmt.Errorf("snapshot unmarshal queue %q failed: %w", name, err)
+			}
+			s.delayed[name] = append(s.delayed[name], d)
+		}
+	}
+	return nil
+}
+
+// runSnapshots периодически записывает снимок хранилища.
+// При отмене ctx записывает последний снимок, как Close.
+// Работает в фоновой горутине до получения сигнала остановки.
+func (s *memoryStorage[T]) runSnapshots(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.writeSnapshot(); err != nil {
+				s.opts.logger.WarnContext(ctx, "storage: snapshot failed",
+					slog.String("path", s.opts.snapshotPath), slog.Any("error", err))
+			}
+		case <-s.stop:
+			return // Последний снимок записывает Close
+		case <-ctx.Done():
+			if err := s.writeSnapshot(); err != nil {
+				s.opts.logger.Warn("storage: final snapshot failed",
+					slog.String("path", s.opts.snapshotPath), slog.Any("error", err))
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// StaleReader реализуется многоуровневым хранилищем NewLayeredStaleOnError.
+// Позволяет узнать, что значение отдано из копии последних прочитанных значений
+// из-за ошибки основного хранилища, а не прочитано из него.
+type StaleReader[T any] interface {
+	// GetStale получает значение так же, как Get
+	// ctx - контекст для управления временем выполнения
+	// key - ключ для получения значения
+	// Возвращает:
+	//   - значение (или нулевое значение типа T, если не найдено)
+	//   - флаг наличия значения
+	//   - флаг устаревания (true - L2 вернул ошибку, значение взято из копии)
+	//   - ошибку L2, если устаревшей копии нет
+	GetStale(ctx context.Context, key string) (value T, found, stale bool, err error)
+}
+
+// staleSweepMin - минимальное количество записей в копию между очистками истекших записей.
+const staleSweepMin = 1024
+
+// staleCache хранит последние известные значения ключей многоуровневого хранилища
+// для выдачи при ошибках L2. Живет дольше L1 и не отвечает на обычные чтения.
+// Истекшие записи удаляются при чтении и периодически при записи.
+// Методы допускают nil-получатель: копия выключена.
+type staleCache[T any] struct {
+	mu      sync.Mutex
+	ttl     time.Duration            // Время хранения копии после последнего чтения или записи
+	entries map[string]staleEntry[T] // Ключ -> последнее известное значение
+	puts    int                      // Записей в копию после последней очистки
+}
+
+// staleEntry - последнее известное значение ключа.
+type staleEntry[T any] struct {
+	value      T
+	expiration int64 // Время истечения в наносекундах
+}
+
+// newStaleCache создает копию последних значений со временем хранения ttl.
+func newStaleCache[T any](ttl time.Duration) *staleCache[T] {
+	return &staleCache[T]{ttl: ttl, entries: make(map[string]staleEntry[T])}
+}
+
+// remember сохраняет последнее известное значение ключа.
+func (c *staleCache[T]) remember(key string, value T) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	c.entries[key] = staleEntry[T]{value: value, expiration: now.Add(c.ttl).UnixNano()}
+
+	// Очистка раз в len(entries) записей дает амортизированное O(1) на запись
+	if c.puts++; c.puts >= max(len(c.entries), staleSweepMin) {
+		c.puts = 0
+		for k, e := range c.entries {
+			if now.UnixNano() > e.expiration {
+				delete(c.entries, k)
+			}
+		}
+	}
+}
+
+// get возвращает неистекшее последнее известное значение ключа.
+func (c *staleCache[T]) get(key string) (T, bool) {
+	var zero T
+	if c == nil {
+		return zero, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return zero, false
+	}
+	if time.Now().UnixNano() > e.expiration {
+		delete(c.entries, key)
+		return zero, false
+	}
+	return e.value, true
+}
+
+// forget удаляет копии ключей, удаленных или измененных в обход копии.
+func (c *staleCache[T]) forget(keys ...string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, key := range keys {
+		delete(c.entries, key)
+	}
+}
+
+// forgetPattern удаляет копии ключей, соответствующих glob-шаблону.
+func (c *staleCache[T]) forgetPattern(pattern string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key := range c.entries {
+		if matchPattern(pattern, key) {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// servesStale сообщает, можно ли при ошибке err отдать устаревшее значение.
+// Ошибки, которые повторятся и на свежих данных (неверный ключ, тип, неподдерживаемая
+// операция), и отмена контекста самим вызывающим кодом возвращаются как есть.
+func servesStale(ctx context.Context, err error) bool {
+	switch {
+	case ctx.Err() != nil:
+		return false
+	case errors.Is(err, ErrInvalidKey), errors.Is(err, ErrWrongType), errors.Is(err, ErrUnsupported):
+		return false
+	default:
+		return true
+	}
+}
@@ -3,6 +3,8 @@ package storage
 import (
 	"context"
 	"time"
+
+	"github.com/redis/go-redis/v9"
 )
 
 // Storage - это обобщенный интерфейс хранилища данных, поддерживающий операции
@@ -19,6 +21,19 @@ type Storage[T any] interface {
 	// Возвращает ошибку в случае неудачи
 	Set(ctx context.Context, key string, value T, ttl time.Duration) error
 
+	// SetX сохраняет значение так же, как Set, и возвращает время истечения,
+	// фактически примененное после случайного отклонения WithTTLJitter.
+	// Если ttl не положителен, возвращается время истечения, которое запись имеет после
+	// записи: в Redis текущий TTL ключа сохраняется, in-memory запись становится бессрочной
+	// ctx - контекст для управления временем выполнения
+	// key - ключ для сохранения значения
+	// value - сохраняемое значение
+	// ttl - время жизни записи (0 - бессрочно)
+	// Возвращает:
+	//   - время истечения записи (нулевое время - бессрочно)
+	//   - ошибку в случае неудачи
+	SetX(ctx context.Context, key string, value T, ttl time.Duration) (time.Time, error)
+
 	// Get получает значение по ключу
 	// ctx - контекст для управления временем выполнения
 	// key - ключ для получения значения
@@ -28,18 +43,274 @@ type Storage[T any] interface {
 	//   - ошибку (если возникла)
 	Get(ctx context.Context, key string) (T, bool, error)
 
+	// GetInto получает значение по ключу и записывает его в dst
+	// Десериализует значение прямо в dst, переиспользуя его срезы и карты там, где это
+	// поддерживает кодек, что позволяет не создавать новое значение на каждое чтение
+	// ctx - контекст для управления временем выполнения
+	// key - ключ для получения значения
+	// dst - получатель значения (не изменяется, если ключ не найден; при ошибке может быть изменен частично)
+	// Возвращает:
+	//   - флаг наличия значения (true - найдено, false - не найдено)
+	//   - ошибку (если возникла)
+	GetInto(ctx context.Context, key string, dst *T) (bool, error)
+
+	// SetWithCodec сохраняет значение так же, как Set, сериализуя его кодеком codec
+	// вместо кодека хранилища. Чтобы запись читалась обычными операциями, кодек
+	// регистрируется в MarkedCodec хранилища (WithCodec) и запись помечается его маркером
+	// ctx - контекст для управления временем выполнения
+	// key - ключ для сохранения значения
+	// value - сохраняемое значение
+	// ttl - время жизни записи (0 - бессрочно)
+	// codec - кодек записи (nil - кодек хранилища)
+	// Возвращает ошибку ErrCodecNotRegistered, если кодек не зарегистрирован, или ошибку записи
+	SetWithCodec(ctx context.Context, key string, value T, ttl time.Duration, codec Codec) error
+
+	// GetWithCodec получает значение по ключу, десериализуя запись без маркера кодеком codec,
+	// а помеченную SetWithCodec - кодеком ее маркера
+	// ctx - контекст для управления временем выполнения
+	// key - ключ для получения значения
+	// codec - кодек записей без маркера (nil - кодек хранилища)
+	// Возвращает:
+	//   - значение (или нулевое значение типа T, если не найдено)
+	//   - флаг наличия значения (true - найдено, false - не найдено)
+	//   - ошибку (если возникла)
+	GetWithCodec(ctx context.Context, key string, codec Codec) (T, bool, error)
+
+	// GetEx получает значение по ключу и атомарно обновляет его время жизни
+	// ctx - контекст для управления временем выполнения
+	// key - ключ для получения значения
+	// ttl - новое время жизни (0 - не менять, PersistTTL или любое отрицательное - сделать бессрочным)
+	// Возвращает:
+	//   - значение (или нулевое значение типа T, если не найдено)
+	//   - флаг наличия значения (true - найдено, false - не найдено)
+	//   - ошибку (если возникла)
+	GetEx(ctx context.Context, key string, ttl time.Duration) (T, bool, error)
+
+	// WaitForKey ждет появления ключа и возвращает его значение
+	// Если ключ уже существует, значение возвращается сразу
+	// ctx - контекст для управления временем выполнения (отмена прерывает ожидание)
+	// key - ключ, запись которого ожидается
+	// timeout - максимальное время ожидания (0 - ждать до отмены ctx или закрытия хранилища)
+	// Возвращает:
+	//   - значение (или нулевое значение типа T, если ключ не появился)
+	//   - флаг наличия значения (false - истек timeout или хранилище закрыто)
+	//   - ошибку (ошибку ctx при отмене)
+	WaitForKey(ctx context.Context, key string, timeout time.Duration) (T, bool, error)
+
+	// GetRaw получает значение по ключу в сериализованном виде, без десериализации в T
+	// Для Redis возвращается строка в том виде, в котором хранится (в режиме WithMetadata -
+	// JSON-конверт), для in-memory хранилища - значение, сериализованное кодеком
+	// ctx - контекст для управления временем выполнения
+	// key - ключ для получения значения
+	// Возвращает:
+	//   - байты значения (nil, если не найдено)
+	//   - флаг наличия значения (true - найдено, false - не найдено)
+	//   - ошибку (если возникла)
+	GetRaw(ctx context.Context, key string) ([]byte, bool, error)
+
+	// GetWithMeta получает значение по ключу вместе с его метаданными
+	// ctx - контекст для управления временем выполнения
+	// key - ключ для получения значения
+	// Метаданные заполняются только в режиме WithMetadata, иначе возвращается пустая Meta.
+	// Возвращает:
+	//   - значение (или нулевое значение типа T, если не найдено)
+	//   - метаданные записи
+	//   - флаг наличия значения (true - найдено, false - не найдено)
+	//   - ошибку (если возникла)
+	GetWithMeta(ctx context.Context, key string) (T, Meta, bool, error)
+
+	// GetWithTTL получает значение по ключу вместе с оставшимся временем жизни записи
+	// за одно обращение к хранилищу
+	// ctx - контекст для управления временем выполнения
+	// key - ключ для получения значения
+	// Возвращает:
+	//   - значение (или нулевое значение типа T, если не найдено)
+	//   - оставшееся время жизни (0 - запись бессрочная или не найдена)
+	//   - флаг наличия значения (true - найдено, false - не найдено)
+	//   - ошибку (если возникла)
+	GetWithTTL(ctx context.Context, key string) (T, time.Duration, bool, error)
+
 	// Delete удаляет значение по ключу
 	// ctx - контекст для управления временем выполнения
 	// key - ключ для удаления
 	// Возвращает ошибку в случае неудачи
 	Delete(ctx context.Context, key string) error
 
+	// DeleteIf удаляет запись, только если она хранит значение expected
+	// Сравнение и удаление выполняются атомарно, поэтому запись, перезаписанная
+	// другим процессом, не удаляется (безопасное освобождение блокировки)
+	// ctx - контекст для управления временем выполнения
+	// key - ключ для удаления
+	// expected - ожидаемое значение
+	// Возвращает:
+	//   - флаг удаления (false - ключа нет или он хранит другое значение)
+	//   - ошибку (если возникла)
+	DeleteIf(ctx context.Context, key string, expected T) (bool, error)
+
+	// GetDelete атомарно получает значение по ключу и удаляет его
+	// ctx - контекст для управления временем выполнения
+	// key - ключ для получения и удаления
+	// Возвращает:
+	//   - значение (или нулевое значение типа T, если не найдено)
+	//   - флаг наличия значения (true - найдено и удалено, false - не найдено)
+	//   - ошибку (если возникла)
+	GetDelete(ctx context.Context, key string) (T, bool, error)
+
+	// Toggle атомарно инвертирует логическое значение по ключу (для Storage[bool])
+	// Отсутствующий ключ считается равным false, поэтому первый вызов записывает true
+	// В Redis и in-memory хранилище время жизни существующей записи сохраняется
+	// ctx - контекст для управления временем выполнения
+	// key - ключ флага
+	// Возвращает:
+	//   - новое значение
+	//   - ошибку (ErrWrongType, если T не логический тип)
+	Toggle(ctx context.Context, key string) (bool, error)
+
+	// IncrementField атомарно увеличивает целочисленное поле структуры, хранимой по ключу
+	// Поле указывается по имени в JSON (тег json или имя поля Go), вложенные поля не поддерживаются
+	// Отсутствующий ключ считается нулевым значением T; время жизни записи сохраняется
+	// ctx - контекст для управления временем выполнения
+	// key - ключ записи
+	// field - имя поля
+	// delta - величина приращения (может быть отрицательной)
+	// Возвращает:
+	//   - новое значение поля
+	//   - ошибку (ErrWrongType, если значение не структура или поле не целочисленное)
+	IncrementField(ctx context.Context, key, field string, delta int64) (int64, error)
+
+	// ClaimOnce атомарно записывает маркер по ключу, если ключа нет
+	// Предназначен для дедупликации работы: выполнить задачу должен только получивший маркер
+	// Маркер - нулевое значение типа T, поэтому Get по ключу вернет его как обычную запись
+	// ctx - контекст для управления временем выполнения
+	// key - ключ маркера
+	// ttl - время жизни маркера (0 - бессрочно)
+	// Возвращает:
+	//   - флаг захвата (true - маркер записан, false - ключ уже существует)
+	//   - ошибку (если возникла)
+	ClaimOnce(ctx context.Context, key string, ttl time.Duration) (claimed bool, err error)
+
+	// CopyKey копирует запись src в ключ dst вместе с временем жизни
+	// ctx - контекст для управления временем выполнения
+	// src - ключ исходной записи
+	// dst - ключ копии
+	// replace - перезаписать dst, если он существует
+	// Возвращает:
+	//   - флаг успешности (false - src не найден или dst существует, а replace равен false)
+	//   - ошибку (если возникла)
+	CopyKey(ctx context.Context, src, dst string, replace bool) (bool, error)
+
+	// SetVersioned записывает значение по ключу, как Set без времени жизни,
+	// и добавляет его в историю ключа, оставляя в ней keep последних версий
+	// История хранится отдельно от значения: Delete и истечение ключа ее не удаляют
+	// ctx - контекст для управления временем выполнения
+	// key - ключ для сохранения значения
+	// value - значение для сохранения
+	// keep - количество хранимых версий (должно быть положительным)
+	// Возвращает ошибку ErrInvalidMaxLen при keep <= 0 или ошибку записи
+	SetVersioned(ctx context.Context, key string, value T, keep int) error
+
+	// GetVersion получает версию из истории ключа, записанной SetVersioned
+	// ctx - контекст для управления временем выполнения
+	// key - ключ
+	// n - номер версии от последней (0 - последняя записанная версия)
+	// Возвращает:
+	//   - значение версии (или нулевое значение типа T, если ее нет)
+	//   - флаг наличия версии
+	//   - ошибку ErrInvalidOffset при n < 0 или ошибку чтения
+	GetVersion(ctx context.Context, key string, n int) (T, bool, error)
+
+	// DeletePattern удаляет все записи, ключи которых соответствуют glob-шаблону
+	// ctx - контекст для управления временем выполнения
+	// pattern - шаблон в стиле Redis (*, ?, [abc], \ для экранирования)
+	// Очереди не затрагиваются.
+	// Возвращает:
+	//   - количество удаленных записей
+	//   - ошибку (если возникла)
+	DeletePattern(ctx context.Context, pattern string) (int64, error)
+
+	// SetWithTags сохраняет значение так же, как Set, и отмечает ключ тегами
+	// для группового удаления через InvalidateTag
+	// Теги ключа заменяются, Set без тегов их не меняет, а удаленный или истекший ключ
+	// исключается из тегов. В Redis теги обновляются отдельными командами после записи,
+	// поэтому при ошибке значение остается записанным с прежними тегами
+	// ctx - контекст для управления временем выполнения
+	// key - ключ для сохранения значения
+	// value - сохраняемое значение
+	// ttl - время жизни записи (0 - бессрочно)
+	// tags - теги ключа
+	// Возвращает ошибку в случае неудачи
+	SetWithTags(ctx context.Context, key string, value T, ttl time.Duration, tags []string) error
+
+	// InvalidateTag удаляет все записи, отмеченные тегом, и сам тег
+	// ctx - контекст для управления временем выполнения
+	// tag - тег
+	// Возвращает:
+	//   - количество удаленных записей
+	//   - ошибку (если возникла)
+	InvalidateTag(ctx context.Context, tag string) (int64, error)
+
+	// CountPattern возвращает количество записей, ключи которых соответствуют glob-шаблону
+	// ctx - контекст для управления временем выполнения
+	// pattern - шаблон в стиле Redis (*, ?, [abc], \ для экранирования)
+	// Очереди не учитываются.
+	// Возвращает:
+	//   - количество записей
+	//   - ошибку (если возникла)
+	CountPattern(ctx context.Context, pattern string) (int64, error)
+
+	// GetByPrefix получает все записи, ключи которых начинаются с prefix
+	// ctx - контекст для управления временем выполнения
+	// prefix - префикс ключей (сопоставляется буквально, пустая строка - все ключи)
+	// Префикс не нормализуется; ключи, замененные хешем (WithHashLongKeys), ему не соответствуют.
+	// Очереди не возвращаются.
+	// Возвращает:
+	//   - карту полный ключ -> значение
+	//   - ошибку (если возникла)
+	GetByPrefix(ctx context.Context, prefix string) (map[string]T, error)
+
+	// ScanPage возвращает одну страницу ключей, соответствующих шаблону
+	// ctx - контекст для управления временем выполнения
+	// cursor - курсор страницы (0 - начать сначала)
+	// match - glob-шаблон ключей (пустая строка - все ключи)
+	// count - желаемый размер страницы (подсказка, фактический размер может отличаться)
+	// Очереди не возвращаются.
+	// Возвращает:
+	//   - ключи страницы
+	//   - курсор следующей страницы (0 - страниц больше нет)
+	//   - ошибку (если возникла)
+	ScanPage(ctx context.Context, cursor uint64, match string, count int) (keys []string, next uint64, err error)
+
+	// Inspect возвращает отладочные сведения о внутреннем представлении записи
+	// ctx - контекст для управления временем выполнения
+	// key - ключ записи
+	// Возвращает:
+	//   - сведения о записи
+	//   - флаг наличия значения (true - найдено, false - не найдено)
+	//   - ошибку (если возникла)
+	Inspect(ctx context.Context, key string) (ObjectInfo, bool, error)
+
 	// Close освобождает ресурсы хранилища
 	// Должен вызываться при завершении работы
 	// Возвращает ошибку в случае неудачи
 	Close() error
 
+	// Capabilities возвращает набор возможностей хранилища
+	// Позволяет проверить поддержку операции до ее вызова
+	Capabilities() Capability
+
 	// Операции с очередями
+	//
+	// Очереди упорядочены строго FIFO: каждая операция добавления и извлечения атомарна,
+	// поэтому при конкурентной работе элементы не теряются и не дублируются,
+	// а элементы одного производителя извлекаются в порядке добавления.
+	// Элементы очередей не имеют собственного времени жизни и не удаляются
+	// по истечении времени: извлечь их можно только явно (Dequeue, Remove и т.п.).
+	//
+	// Отмена ctx не приводит к потере элементов: каждая операция извлекает элементы
+	// атомарно, а многошаговые операции над очередями (Copy) при отмене возвращают
+	// накопленный результат вместе с ошибкой ctx и удаляют элемент из исходной очереди
+	// только после его записи. Для извлечения пачки с подтверждением используйте
+	// PeekBatchReserve и AckBatch: неподтвержденная пачка вернется в очередь.
 
 	// Enqueue добавляет элемент в конец очереди
 	// ctx - контекст для управления временем выполнения
@@ -48,6 +319,66 @@ type Storage[T any] interface {
 	// Возвращает ошибку в случае неудачи
 	Enqueue(ctx context.Context, queueName string, value T) error
 
+	// EnqueueN добавляет элемент в конец очереди и возвращает новую длину очереди
+	// ctx - контекст для управления временем выполнения
+	// queueName - имя очереди
+	// value - значение для добавления
+	// Возвращает:
+	//   - количество элементов в очереди после добавления
+	//   - ошибку (если возникла)
+	EnqueueN(ctx context.Context, queueName string, value T) (int64, error)
+
+	// EnqueueFanout добавляет одно значение в конец каждой из очередей
+	// Добавление в разные очереди не атомарно: часть очередей может получить значение,
+	// даже если в остальные его добавить не удалось
+	// ctx - контекст для управления временем выполнения
+	// queueNames - имена очередей
+	// value - значение для добавления
+	// Возвращает *BatchError с ошибками по именам очередей, в которые значение
+	// не добавлено, или ошибку, из-за которой не выполнено ни одно добавление
+	EnqueueFanout(ctx context.Context, queueNames []string, value T) error
+
+	// EnqueueCapped добавляет элемент в конец очереди и обрезает ее до maxLen последних элементов
+	// Самые старые элементы отбрасываются, поэтому очередь работает как кольцевой буфер
+	// ctx - контекст для управления временем выполнения
+	// queueName - имя очереди
+	// value - добавляемое значение
+	// maxLen - максимальная длина очереди (должна быть положительной)
+	// Возвращает ошибку в случае неудачи
+	EnqueueCapped(ctx context.Context, queueName string, value T, maxLen int64) error
+
+	// EnqueueBounded добавляет элемент в конец очереди, только если в ней меньше maxLen элементов
+	// Проверка длины и добавление выполняются атомарно
+	// ctx - контекст для управления временем выполнения
+	// queueName - имя очереди
+	// value - добавляемое значение
+	// maxLen - максимальная длина очереди (должна быть положительной)
+	// Возвращает:
+	//   - флаг добавления (false - очередь заполнена)
+	//   - ошибку (если возникла)
+	EnqueueBounded(ctx context.Context, queueName string, value T, maxLen int64) (bool, error)
+
+	// TryEnqueue добавляет элемент в очередь, как EnqueueBounded, и сообщает причину отказа
+	// Проверка длины и добавление выполняются атомарно
+	// ctx - контекст для управления временем выполнения
+	// queueName - имя очереди
+	// value - добавляемое значение
+	// maxLen - максимальная длина очереди (должна быть положительной)
+	// Возвращает:
+	//   - флаг добавления
+	//   - причину (ReasonOK, ReasonFull, ReasonClosed или ReasonError)
+	//   - ошибку (только для ReasonError)
+	TryEnqueue(ctx context.Context, queueName string, value T, maxLen int64) (ok bool, reason EnqueueReason, err error)
+
+	// EnqueueDelayed добавляет элемент в конец очереди по истечении задержки
+	// До этого момента элемент не возвращается Dequeue, Peek и другими операциями чтения
+	// ctx - контекст для управления временем выполнения
+	// queueName - имя очереди
+	// value - добавляемое значение
+	// delay - задержка (0 или отрицательная - добавить сразу)
+	// Возвращает ошибку в случае неудачи
+	EnqueueDelayed(ctx context.Context, queueName string, value T, delay time.Duration) error
+
 	// Dequeue извлекает и удаляет элемент из начала очереди
 	// ctx - контекст для управления временем выполнения
 	// queueName - имя очереди
@@ -57,6 +388,32 @@ type Storage[T any] interface {
 	//   - ошибку (если возникла)
 	Dequeue(ctx context.Context, queueName string) (T, bool, error)
 
+	// DequeueTracked извлекает элемент из начала очереди и в той же атомарной операции
+	// записывает его по ключу отслеживания, чтобы обрабатываемые элементы были видны
+	// мониторингу, а зависшие - обнаруживались по оставшимся ключам
+	// Ключ удаляет обработчик после завершения работы (Delete) или он истекает через ttl
+	// ctx - контекст для управления временем выполнения
+	// queueName - имя очереди
+	// trackingKey - ключ, по которому записывается извлеченный элемент
+	// ttl - время жизни ключа отслеживания (0 - бессрочно)
+	// Возвращает:
+	//   - извлеченное значение (или нулевое значение типа T, если очередь пуста)
+	//   - флаг наличия элемента (false - очередь пуста, ключ не записан)
+	//   - ошибку (если возникла)
+	DequeueTracked(ctx context.Context, queueName, trackingKey string, ttl time.Duration) (T, bool, error)
+
+	// BlockingDequeueAny извлекает элемент из первой непустой очереди, ожидая его появления
+	// Очереди проверяются в порядке перечисления, поэтому первая имеет наивысший приоритет
+	// ctx - контекст для управления временем выполнения (отмена прерывает ожидание)
+	// queueNames - имена очередей в порядке убывания приоритета
+	// timeout - максимальное время ожидания (0 - ждать до отмены ctx или закрытия хранилища)
+	// Возвращает:
+	//   - имя очереди, из которой извлечен элемент
+	//   - элемент (или нулевое значение типа T, если не найден)
+	//   - флаг наличия элемента (false - истек timeout или хранилище закрыто)
+	//   - ошибку (ошибку ctx при отмене)
+	BlockingDequeueAny(ctx context.Context, queueNames []string, timeout time.Duration) (queue string, value T, found bool, err error)
+
 	// Peek просматривает элемент в начале очереди без его удаления
 	// ctx - контекст для управления временем выполнения
 	// queueName - имя очереди
@@ -74,6 +431,94 @@ type Storage[T any] interface {
 	//   - ошибку (если возникла)
 	Remove(ctx context.Context, queueName string) (bool, error)
 
+	// RemoveAt удаляет элемент очереди по его позиции
+	// ctx - контекст для управления временем выполнения
+	// queueName - имя очереди
+	// index - позиция элемента (0 - первый; отрицательные отсчитываются с конца, -1 - последний)
+	// Возвращает:
+	//   - флаг успешности операции (false - позиция вне очереди)
+	//   - ошибку (если возникла)
+	RemoveAt(ctx context.Context, queueName string, index int64) (bool, error)
+
+	// ReplaceQueue атомарно заменяет содержимое очереди элементами values
+	// Читатели видят либо прежнюю очередь, либо новую целиком, без промежуточного состояния
+	// Отложенные и зарезервированные элементы очереди не затрагиваются
+	// ctx - контекст для управления временем выполнения
+	// queueName - имя очереди
+	// values - новые элементы от первого к последнему (пустой срез очищает очередь)
+	// Возвращает ошибку в случае неудачи
+	ReplaceQueue(ctx context.Context, queueName string, values []T) error
+
+	// RotateQueue атомарно переносит первый элемент очереди в ее конец
+	// Позволяет обходить очередь по кругу (round-robin) без внешнего учета позиции
+	// ctx - контекст для управления временем выполнения
+	// queueName - имя очереди
+	// Возвращает:
+	//   - перенесенный элемент
+	//   - флаг наличия элемента (false - очередь пуста)
+	//   - ошибку (если возникла)
+	RotateQueue(ctx context.Context, queueName string) (T, bool, error)
+
+	// SetHead атомарно заменяет первый элемент очереди и возвращает прежний
+	// Позволяет обновить ожидающую задачу на месте, не меняя ее позицию
+	// ctx - контекст для управления временем выполнения
+	// queueName - имя очереди
+	// value - новый первый элемент
+	// Возвращает:
+	//   - прежний первый элемент
+	//   - флаг наличия элемента (false - очередь пуста и не изменена)
+	//   - ошибку (если возникла)
+	SetHead(ctx context.Context, queueName string, value T) (T, bool, error)
+
+	// MoveMatching атомарно переносит элементы очереди from, для которых match
+	// возвращает true, в конец очереди to с сохранением их порядка
+	// Например, возвращает сообщения из очереди недоставленных в рабочую очередь
+	// ctx - контекст для управления временем выполнения
+	// from - исходная очередь
+	// to - целевая очередь
+	// match - условие переноса элемента
+	// limit - максимальное количество переносимых элементов (0 - без ограничения)
+	// Возвращает:
+	//   - количество перенесенных элементов
+	//   - ошибку (если возникла)
+	MoveMatching(ctx context.Context, from, to string, match func(T) bool, limit int) (int, error)
+
+	// QueueTail возвращает последние n элементов очереди без их удаления
+	// ctx - контекст для управления временем выполнения
+	// queueName - имя очереди
+	// n - количество элементов
+	// Возвращает:
+	//   - элементы от старых к новым (меньше n, если очередь короче)
+	//   - ошибку (если возникла)
+	QueueTail(ctx context.Context, queueName string, n int) ([]T, error)
+
+	// QueueReadFrom читает элементы очереди по смещению без их удаления
+	// Смещение - позиция от начала очереди, поэтому оно стабильно, только пока очередь
+	// используется как журнал: элементы лишь добавляются. После первой операции, удалившей
+	// или переставившей элементы (Dequeue, Remove, RemoveAt, EnqueueCapped с обрезкой,
+	// RotateQueue, ReplaceQueue, MoveMatching, PeekBatchReserve и т.д.), очередь бессрочно
+	// помечается разобранной, и QueueReadFrom возвращает ErrQueueConsumed
+	// Каждый потребитель хранит свое смещение и может продолжить чтение после перезапуска.
+	// ctx - контекст для управления временем выполнения
+	// queueName - имя очереди
+	// offset - позиция первого читаемого элемента (0 - начало очереди)
+	// n - максимальное количество элементов
+	// Возвращает:
+	//   - элементы от старых к новым (пусто, если новых элементов нет)
+	//   - смещение для следующего чтения
+	//   - ошибку (ErrInvalidOffset при отрицательном offset, ErrQueueConsumed для разобранной очереди)
+	QueueReadFrom(ctx context.Context, queueName string, offset int64, n int) ([]T, int64, error)
+
+	// QueueIterate передает fn элементы очереди от первого к последнему без их удаления,
+	// не загружая в память Redis-очередь целиком
+	// Изменения очереди во время обхода не блокируются, поэтому обход дает
+	// приблизительный снимок: элементы могут быть пропущены или переданы повторно
+	// ctx - контекст для управления временем выполнения (проверяется во время обхода)
+	// queueName - имя очереди
+	// fn - обработчик элемента; ошибка обработчика прекращает обход
+	// Возвращает ошибку fn, ошибку ctx или ошибку чтения
+	QueueIterate(ctx context.Context, queueName string, fn func(T) error) error
+
 	// QueueLen возвращает текущее количество элементов в очереди
 	// ctx - контекст для управления временем выполнения
 	// queueName - имя очереди
@@ -81,30 +526,367 @@ type Storage[T any] interface {
 	//   - количество элементов в очереди (0 если очередь пуста или не существует)
 	//   - ошибку (если возникла)
 	QueueLen(ctx context.Context, queueName string) (int64, error)
+
+	// QueueLenMany возвращает количество элементов сразу в нескольких очередях
+	// ctx - контекст для управления временем выполнения
+	// queueNames - имена очередей
+	// Возвращает:
+	//   - карту имя очереди -> количество элементов (0 для несуществующих очередей)
+	//   - ошибку (если возникла)
+	QueueLenMany(ctx context.Context, queueNames []string) (map[string]int64, error)
+
+	// QueueStats возвращает длину очереди и скорости ее пополнения и разбора
+	// Скорости измеряются в текущем процессе за скользящее окно (QueueStats.Window)
+	// и учитывают только операции через это хранилище; EnqueueDelayed учитывается в момент вызова
+	// ctx - контекст для управления временем выполнения
+	// queueName - имя очереди
+	// Возвращает:
+	//   - статистику очереди
+	//   - ошибку (если возникла)
+	QueueStats(ctx context.Context, queueName string) (QueueStats, error)
+
+	// QueueIndexOf возвращает позицию первого элемента очереди, удовлетворяющего условию
+	// ctx - контекст для управления временем выполнения
+	// queueName - имя очереди
+	// match - условие поиска
+	// Возвращает:
+	//   - индекс элемента от начала очереди (0 - первый элемент)
+	//   - флаг наличия элемента (true - найден, false - не найден)
+	//   - ошибку (если возникла)
+	QueueIndexOf(ctx context.Context, queueName string, match func(T) bool) (int64, bool, error)
+
+	// QueueIndexOfValue возвращает позицию первого элемента очереди, равного value
+	// Элементы сравниваются по сериализованному представлению
+	// (в in-memory хранилище - через reflect.DeepEqual).
+	// Возвращает индекс, флаг наличия элемента и ошибку, как QueueIndexOf.
+	QueueIndexOfValue(ctx context.Context, queueName string, value T) (int64, bool, error)
+
+	// PeekBatchReserve извлекает до n элементов из начала очереди в резервирование
+	// Зарезервированные элементы не видны другим потребителям в течение vt;
+	// если пачка не подтверждена AckBatch за это время, элементы возвращаются
+	// в начало очереди в исходном порядке (доставка "хотя бы один раз")
+	// ctx - контекст для управления временем выполнения
+	// queueName - имя очереди
+	// n - максимальное количество элементов
+	// vt - время видимости резервирования
+	// Возвращает:
+	//   - элементы в порядке очереди (пустой срез, если очередь пуста)
+	//   - токен резервирования для AckBatch (пустой, если элементов нет)
+	//   - ошибку (если возникла)
+	PeekBatchReserve(ctx context.Context, queueName string, n int, vt time.Duration) (items []T, token string, err error)
+
+	// AckBatch подтверждает обработку пачки и окончательно удаляет ее элементы
+	// ctx - контекст для управления временем выполнения
+	// queueName - имя очереди
+	// token - токен, полученный от PeekBatchReserve
+	// Возвращает ErrReservationNotFound, если резервирование истекло или уже подтверждено
+	AckBatch(ctx context.Context, queueName, token string) error
+
+	// Счетчики
+
+	// IncrWindow увеличивает счетчик фиксированного окна и проверяет лимит
+	// Окно начинается с первого увеличения и длится window, затем счетчик сбрасывается
+	// Счетчик не является записью Get/Set; в Redis без WithNamespaces он
+	// занимает ключ с тем же именем, как очереди и множества
+	// ctx - контекст для управления временем выполнения
+	// key - ключ счетчика
+	// window - длительность окна (должна быть положительной)
+	// limit - допустимое количество увеличений за окно (должно быть положительным)
+	// Возвращает:
+	//   - значение счетчика после увеличения
+	//   - флаг допуска (false - лимит окна превышен)
+	//   - ошибку ErrInvalidRateLimit или ошибку хранилища
+	IncrWindow(ctx context.Context, key string, window time.Duration, limit int64) (count int64, allowed bool, err error)
+
+	// Операции с множествами
+
+	// SAddEx добавляет элемент в множество с собственным временем жизни
+	// Повторное добавление элемента заменяет его время жизни
+	// Элементы сравниваются по сериализованному кодеком значению
+	// ctx - контекст для управления временем выполнения
+	// setName - имя множества
+	// member - добавляемый элемент
+	// ttl - время жизни элемента (0 - бессрочно)
+	// Возвращает ошибку в случае неудачи
+	SAddEx(ctx context.Context, setName string, member T, ttl time.Duration) error
+
+	// SCount возвращает количество неистекших элементов множества
+	// Истекшие элементы при этом удаляются
+	// ctx - контекст для управления временем выполнения
+	// setName - имя множества
+	// Возвращает:
+	//   - количество элементов (0, если множество не существует)
+	//   - ошибку (если возникла)
+	SCount(ctx context.Context, setName string) (int64, error)
+
+	// Транзакции
+
+	// Transact атомарно выполняет операции с несколькими ключами
+	// Записи, сделанные через tx, применяются все вместе, только если fn вернула nil;
+	// иначе ни одна из них не применяется и возвращается ошибка fn
+	// ctx - контекст для управления временем выполнения
+	// fn - функция транзакции (в Redis может вызываться повторно при конфликте)
+	// Возвращает ошибку fn, ErrTxConflict или ошибку хранилища
+	Transact(ctx context.Context, fn func(tx Tx[T]) error) error
+
+	// Пакетные операции
+
+	// Pipeline создает пакет команд, выполняемых одним вызовом Exec
+	// Для Redis пакет отправляется за один сетевой вызов,
+	// для in-memory хранилища выполняется под одним захватом блокировок
+	Pipeline() Pipeline[T]
 }
 
+// PersistTTL передается в GetEx, чтобы сделать запись бессрочной
+const PersistTTL time.Duration = -1
+
 // RedisConfig содержит параметры подключения к Redis
 type RedisConfig struct {
 	Addr     string // Адрес сервера Redis (например, "localhost:6379")
 	Username string // Имя пользователя
 	Password string // Пароль для аутентификации (пустая строка если не требуется)
 	DB       int    // Номер базы данных
+
+	// CredentialsProvider возвращает актуальные имя пользователя и пароль.
+	// Вызывается перед каждым (пере)подключением к Redis, что позволяет
+	// использовать ротируемые учетные данные. Если задан, Username и Password игнорируются.
+	CredentialsProvider func(ctx context.Context) (username, password string, err error)
+
+	// ClientCacheTTL включает кэш на стороне клиента (CLIENT TRACKING, Redis 6+).
+	// Значения, прочитанные Get, хранятся в памяти процесса и удаляются
+	// по сообщениям инвалидации от Redis при изменении ключа.
+	// Значение задает максимальное время жизни записи в кэше (0 - кэш выключен).
+	ClientCacheTTL time.Duration
+
+	// ConnectMaxWait - максимальное время ожидания доступности Redis при создании хранилища.
+	// Пока оно не истекло, неудачная проверка соединения (PING) повторяется
+	// с экспоненциально растущей задержкой. 0 - одна попытка без повторов.
+	ConnectMaxWait time.Duration
+
+	// ConnectBackoff - начальная задержка между попытками подключения
+	// (по умолчанию 100 мс). Удваивается после каждой попытки, но не превышает 5 с.
+	ConnectBackoff time.Duration
+
+	// ReplicaAddrs - адреса реплик Redis для команд чтения (Get, Peek, QueueLen).
+	// Реплики выбираются по кругу, запись и остальные команды выполняются на Addr.
+	// Репликация асинхронна: чтение с реплики может не видеть только что записанное
+	// значение или вернуть уже удаленное, поэтому опция подходит только для данных,
+	// допускающих устаревание. При включенном ClientCacheTTL Get читает с Addr,
+	// так как инвалидации кэша приходят только от основного сервера.
+	ReplicaAddrs []string
+
+	// OnConnect вызывается после установки каждого нового соединения пула
+	// с сервером addr (Addr или одной из ReplicaAddrs). Частые вызовы означают,
+	// что соединения рвутся и устанавливаются заново. Функция вызывается синхронно
+	// при подключении и должна быстро возвращать управление. nil - не вызывать.
+	OnConnect func(ctx context.Context, addr string)
+
+	// OnDisconnect вызывается фоновой проверкой соединения (PING раз в HealthCheckInterval),
+	// когда сервер addr перестает отвечать; err - ошибка проверки. Вызывается один раз
+	// на каждый период недоступности, восстановление обычно видно по последующим
+	// вызовам OnConnect. nil - проверка не запускается.
+	OnDisconnect func(addr string, err error)
+
+	// HealthCheckInterval - период проверки соединения для OnDisconnect (по умолчанию 5 с).
+	HealthCheckInterval time.Duration
+}
+
+// MemoryStats содержит счетчики операций in-memory хранилища
+type MemoryStats struct {
+	Hits      int64 // Количество успешных чтений (значение найдено)
+	Misses    int64 // Количество чтений без результата (ключ не найден или истек)
+	Sets      int64 // Количество операций записи
+	Deletes   int64 // Количество операций удаления
+	Evictions int64 // Количество записей, удаленных сборщиком мусора по истечении TTL
+	Items     int64 // Текущее количество записей (включая истекшие, но еще не удаленные)
+	Queues    int64 // Текущее количество непустых очередей
+
+	PressureEvictions int64 // Количество записей, вытесненных при превышении WithMaxBytes
+	Bytes             int64 // Оценка размера записей (только с WithMaxBytes)
+}
+
+// MemoryStatsProvider реализуется хранилищами, предоставляющими статистику.
+// Хранилище, созданное NewMemory, можно привести к этому интерфейсу:
+//
+//	if sp, ok := store.(storage.MemoryStatsProvider); ok {
+//		stats := sp.Stats()
+//	}
+type MemoryStatsProvider interface {
+	// Stats возвращает текущие значения счетчиков
+	Stats() MemoryStats
+}
+
+// ExpiryPurger реализуется хранилищами, позволяющими удалить истекшие записи по запросу.
+// Хранилище, созданное NewMemory, можно привести к этому интерфейсу,
+// чтобы проверять истечение записей в тестах без ожидания сборщика мусора.
+type ExpiryPurger interface {
+	// PurgeExpired немедленно удаляет истекшие записи
+	// ctx - контекст для управления временем выполнения
+	// Возвращает:
+	//   - количество удаленных записей
+	//   - ошибку (если возникла)
+	PurgeExpired(ctx context.Context) (int, error)
+}
+
+// QueueCapacityReporter реализуется хранилищами, умеющими показать объем памяти очереди.
+// Хранилище, созданное NewMemory, можно привести к этому интерфейсу, чтобы сравнить
+// выделенную под очередь память с количеством элементов и убедиться, что
+// извлечение элементов освобождает блоки.
+type QueueCapacityReporter interface {
+	// QueueCapacity возвращает длину очереди и количество выделенных под нее ячеек
+	// Ячейки выделяются блоками, поэтому capacity кратна размеру блока
+	// Для несуществующей очереди возвращает 0, 0
+	QueueCapacity(queueName string) (length, capacity int, err error)
+}
+
+// Dumper реализуется хранилищами, умеющими вывести свое содержимое для отладки.
+// Хранилище, созданное NewMemory, можно привести к этому интерфейсу,
+// чтобы показать состояние хранилища при падении теста:
+//
+//	if d, ok := store.(storage.Dumper); ok {
+//		t.Log(d.Dump())
+//	}
+type Dumper interface {
+	// Dump возвращает ключи с временем жизни и содержимое очередей в читаемом виде
+	Dump() string
+}
+
+// DBSelector реализуется хранилищами Redis, умеющими обращаться к другой логической базе.
+// Хранилище, созданное NewRedis или NewRedisWithClient поверх *redis.Client,
+// можно привести к этому интерфейсу:
+//
+//	if sel, ok := store.(storage.DBSelector[T]); ok {
+//		other, err := sel.WithDB(2)
+//		...
+//		defer other.Close()
+//	}
+type DBSelector[T any] interface {
+	// WithDB возвращает хранилище с теми же параметрами, команды которого
+	// выполняются в логической базе db
+	// SELECT меняет базу соединения, а не клиента, поэтому смешивать базы в одном пуле
+	// нельзя: производное хранилище использует отдельное закрепленное соединение,
+	// команды через него выполняются по одной. Его нужно закрыть вызовом Close.
+	// Возвращает ошибку ErrUnsupported для Redis Cluster, где есть только база 0
+	WithDB(db int) (Storage[T], error)
 }
 
 // NewMemory создает новое in-memory хранилище
 // cleanupInterval - интервал очистки устаревших записей
+// opts - дополнительные параметры (например, WithHotKeyTracking)
+// Возвращает:
+//   - реализацию интерфейса Storage[T]
+//   - ошибку ErrInvalidInterval, если cleanupInterval не положителен,
+//     или ошибку загрузки снимка (WithSnapshot)
+func NewMemory[T any](cleanupInterval time.Duration, opts ...Option) (Storage[T], error) {
+	return newMemoryStorage[T](context.Background(), cleanupInterval, opts)
+}
+
+// NewMemoryWithContext создает in-memory хранилище, время жизни которого
+// привязано к контексту: при отмене ctx фоновый сборщик мусора останавливается,
+// как при вызове Close. Вызывать Close после отмены не обязательно, но безопасно.
+// ctx - контекст владельца (например, корневой контекст приложения)
+// cleanupInterval - интервал очистки устаревших записей
+// opts - дополнительные параметры
 // Возвращает:
 //   - реализацию интерфейса Storage[T]
-//   - ошибку (в текущей реализации всегда nil)
-func NewMemory[T any](cleanupInterval time.Duration) (Storage[T], error) {
-	return newMemoryStorage[T](cleanupInterval), nil
+//   - ошибку ErrInvalidInterval, если cleanupInterval не положителен,
+//     или ошибку загрузки снимка (WithSnapshot)
+func NewMemoryWithContext[T any](ctx context.Context, cleanupInterval time.Duration, opts ...Option) (Storage[T], error) {
+	return newMemoryStorage[T](ctx, cleanupInterval, opts)
 }
 
 // NewRedis создает новое хранилище на основе Redis
 // config - конфигурация подключения к Redis
+// opts - дополнительные параметры (например, WithSkipCorrupt)
 // Возвращает:
 //   - реализацию интерфейса Storage[T]
 //   - ошибку, если подключение не удалось
-func NewRedis[T any](config RedisConfig) (Storage[T], error) {
-	return newRedisStorage[T](config)
+func NewRedis[T any](config RedisConfig, opts ...Option) (Storage[T], error) {
+	return newRedisStorage[T](config, opts)
+}
+
+// NewRedisWithClient создает хранилище поверх готового клиента go-redis
+// (redis.Client, redis.ClusterClient, redis.Ring или клиента с Sentinel),
+// не создавая собственный пул соединений.
+// client - клиент, которым владеет вызывающий код: хранилище не добавляет к нему хуки,
+// а Close хранилища его не закрывает (для наблюдения за медленными командами см. NewSlowOpHook)
+// opts - дополнительные параметры (например, WithCodec)
+// В Redis Cluster операции, затрагивающие несколько ключей очереди (PeekBatchReserve,
+// EnqueueDelayed), требуют hash tag в имени очереди (например, "{jobs}"),
+// а DeletePattern и ScanPage обходят ключи только одного узла.
+// Возвращает:
+//   - реализацию интерфейса Storage[T]
+//   - ошибку, если client равен nil или Redis недоступен
+func NewRedisWithClient[T any](client redis.UniversalClient, opts ...Option) (Storage[T], error) {
+	return newRedisStorageWithClient[T](client, opts)
+}
+
+// NewRedisWithFallback создает хранилище, которое работает с Redis, пока он доступен,
+// и с in-memory хранилищем, пока нет. Если Redis недоступен при создании, хранилище
+// начинает работу в памяти и подключается к Redis в фоне. Доступность проверяется
+// командой PING каждые cfg.HealthCheckInterval (по умолчанию 5 с); переключение
+// происходит по результату проверки, а не по ошибкам отдельных операций.
+// Текущее хранилище можно узнать через FallbackReporter.
+// cfg - конфигурация подключения к Redis
+// memInterval - интервал очистки устаревших записей in-memory хранилища
+// opts - дополнительные параметры обоих хранилищ
+//
+// Согласованность при переключении не гарантируется:
+//   - данные не переносятся: записанное в память не попадает в Redis после восстановления,
+//     а записанное в Redis не видно в памяти; после возврата к Redis читаются его данные
+//   - в памяти данные видны только этому процессу и теряются при его завершении
+//   - между отказом Redis и ближайшей проверкой операции возвращают ошибки Redis
+//   - операция, начатая в одном хранилище (Pipeline, ожидание BlockingDequeueAny,
+//     резервирование PeekBatchReserve), завершается в нем же
+//   - необязательные интерфейсы хранилищ (TTLReader, DBSelector и т.д.) недоступны
+//
+// Возвращает:
+//   - реализацию интерфейса Storage[T]
+//   - ошибку ErrInvalidInterval, если memInterval не положителен
+func NewRedisWithFallback[T any](cfg RedisConfig, memInterval time.Duration, opts ...Option) (Storage[T], error) {
+	return newFallbackStorage[T](cfg, memInterval, opts)
+}
+
+// NewShardedRedis создает хранилище, распределяющее данные между несколькими Redis
+// с помощью консистентного хеширования на стороне клиента.
+// configs - конфигурации подключения к шардам
+// opts - дополнительные параметры (например, WithVirtualNodes)
+// Ключи направляются на шард по хешу ключа, очереди - по хешу имени очереди.
+// Возвращает:
+//   - реализацию интерфейса Storage[T]
+//   - ошибку, если список шардов пуст или подключение к любому из них не удалось
+func NewShardedRedis[T any](configs []RedisConfig, opts ...Option) (Storage[T], error) {
+	return newShardedRedisStorage[T](configs, opts)
+}
+
+// NewLayered создает многоуровневое хранилище: быстрый кэш l1 поверх основного хранилища l2
+// l1 - кэш первого уровня (обычно NewMemory)
+// l2 - основное хранилище (обычно NewRedis)
+// l1TTL - максимальное время жизни записи в l1 (0 - ограничено только TTL записи)
+// Чтение в обход l1 выполняется с контекстом WithFreshRead.
+// Close закрывает оба уровня.
+func NewLayered[T any](l1, l2 Storage[T], l1TTL time.Duration) Storage[T] {
+	return newLayeredStorage(l1, l2, l1TTL, 0)
+}
+
+// NewLayeredStaleOnError создает многоуровневое хранилище, которое при ошибке l2
+// (но не при промахе) отдает из Get последнее известное значение ключа
+// l1, l2, l1TTL - как в NewLayered
+// staleTTL - сколько хранить последнее прочитанное или записанное значение ключа
+// Последние значения хранятся в памяти процесса отдельно от l1 и обычно дольше него.
+// Устаревшее значение может не отражать изменения, сделанные другими процессами
+// или в обход хранилища, вплоть до staleTTL; поэтому режим подходит только для данных,
+// которые допустимо показывать устаревшими во время недоступности l2.
+// Отличить устаревшее значение можно через StaleReader.GetStale.
+func NewLayeredStaleOnError[T any](l1, l2 Storage[T], l1TTL, staleTTL time.Duration) Storage[T] {
+	return newLayeredStorage(l1, l2, l1TTL, staleTTL)
+}
+
+// Typed создает типизированное хранилище поверх общего байтового хранилища
+// base - байтовое хранилище (например, NewRedis[[]byte]), разделяемое между обертками
+// codec - кодек для сериализации значений (nil - JSONCodec)
+// Позволяет использовать одно подключение для значений разных типов.
+// Close обертки не закрывает base: его нужно закрыть отдельно.
+func Typed[T any](base Storage[[]byte], codec Codec) Storage[T] {
+	return newTypedStorage[T](base, codec)
 }
@@ -0,0 +1,292 @@
+package storage
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Теги ключей (SetWithTags) позволяют удалить группу зависимых записей одним вызовом
+// InvalidateTag. In-memory хранилище ведет индекс тегов под itemMu и исключает ключ
+// из тегов при любом удалении и истечении. В Redis каждому тегу соответствует множество
+// ключей с префиксом tagNamespace, а каждому ключу - множество его тегов с префиксом
+// keyTagsNamespace, которое истекает вместе с ключом и удаляется вместе с ним.
+// InvalidateTag удаляет только ключи, в тегах которых тег все еще есть.
+
+// tagIndex связывает ключи in-memory хранилища с их тегами.
+// Нулевое значение готово к использованию. Защищен itemMu хранилища.
+type tagIndex struct {
+	keys map[string]map[string]struct{} // Тег -> ключи
+	tags map[string][]string            // Ключ -> теги
+}
+
+// set заменяет теги ключа.
+func (t *tagIndex) set(key string, tags []string) {
+	t.remove(key)
+	if len(tags) == 0 {
+		return
+	}
+	if t.keys == nil {
+		t.keys = make(map[string]map[string]struct{})
+		t.tags = make(map[string][]string)
+	}
+	for _, tag := range tags {
+		keys := t.keys[tag]
+		if keys == nil {
+			keys = make(map[string]struct{})
+			t.keys[tag] = keys
+		}
+		keys[key] = struct{}{}
+	}
+	t.tags[key] = tags
+}
+
+// remove исключает ключ из всех его тегов.
+func (t *tagIndex) remove(key string) {
+	tags, ok := t.tags[key]
+	if !ok {
+		return
+	}
+	for _, tag := range tags {
+		delete(t.keys[tag], key)
+		if len(t.keys[tag]) == 0 {
+			delete(t.keys, tag)
+		}
+	}
+	delete(t.tags, key)
+}
+
+// take возвращает ключи тега и исключает их из индекса.
+func (t *tagIndex) take(tag string) []string {
+	keys := make([]string, 0, len(t.keys[tag]))
+	for key := range t.keys[tag] {
+		keys = append(keys, key)
+	}
+	for _, key := range keys {
+		t.remove(key)
+	}
+	return keys
+}
+
+// SetWithTags сохраняет значение как SetX и заменяет теги ключа под той же блокировкой.
+func (s *memoryStorage[T]) SetWithTags(ctx context.Context, key string, value T, ttl time.Duration, tags []string) error {
+	key, origKey, err := s.opts.resolveKey(key)
+	if err != nil {
+		return err
+	}
+	s.hot.record(key)
+	if err := s.opts.checkValueSize(value); err != nil {
+		return err
+	}
+
+	var expiration int64
+	if ttl = s.opts.jitterTTL(ttl); ttl > 0 {
+		expiration = time.Now().Add(ttl).UnixNano()
+	}
+
+	s.itemMu.Lock()         // Блокируем на запись
+	defer s.itemMu.Unlock() // Гарантируем разблокировку
+
+	s.items[key] = s.newItem(key, origKey, value, expiration)
+	s.tags.set(key, tags)
+	s.track(key, value)
+	s.stats.sets.Add(1)
+	return nil
+}
+
+// InvalidateTag удаляет записи тега под блокировкой на запись.
+// Возвращает количество удаленных записей (без учета уже истекших).
+func (s *memoryStorage[T]) InvalidateTag(ctx context.Context, tag string) (int64, error) {
+	s.itemMu.Lock()         // Блокируем на запись
+	defer s.itemMu.Unlock() // Гарантируем разблокировку
+
+	var deleted int64
+	for _, key := range s.tags.take(tag) {
+		if item, found := s.items[key]; found && !item.isExpired() {
+			deleted++
+		}
+		s.deleteItem(key)
+	}
+	s.stats.deletes.Add(deleted)
+	return deleted, nil
+}
+
+// tagAddScript добавляет ключ в множество тега и продлевает множество
+// до времени жизни ключа, если оно живет меньше.
+// KEYS[1] - множество тега; ARGV[1] - ключ; ARGV[2] - время жизни ключа в мс (0 - бессрочно).
+var tagAddScript = redis.NewScript(`
+local pttl = redis.call('PTTL', KEYS[1])
+redis.call('SADD', KEYS[1], ARGV[1])
+local ttl = tonumber(ARGV[2])
+if ttl == 0 then
+	redis.call('PERSIST', KEYS[1])
+elseif pttl == -2 or (pttl >= 0 and pttl < ttl) then
+	redis.call('PEXPIRE', KEYS[1], ttl)
+end
+return 1
+`)
+
+// tagTakeScript возвращает элементы множества и удаляет его:
+// ключи тега (InvalidateTag) или теги удаляемого ключа (takeKeyTags).
+// KEYS[1] - множество.
+var tagTakeScript = redis.NewScript(`
+local members = redis.call('SMEMBERS', KEYS[1])
+redis.call('DEL', KEYS[1])
+return members
+`)
+
+// keyTagsReplaceScript заменяет теги ключа и возвращает прежние.
+// KEYS[1] - множество тегов ключа; ARGV[1] - время жизни ключа в мс (0 - бессрочно);
+// ARGV[2..] - новые теги.
+var keyTagsReplaceScript = redis.NewScript(`
+local old = redis.call('SMEMBERS', KEYS[1])
+redis.call('DEL', KEYS[1])
+if #ARGV > 1 then
+	redis.call('SADD', KEYS[1], unpack(ARGV, 2))
+	if tonumber(ARGV[1]) > 0 then
+		redis.call('PEXPIRE', KEYS[1], ARGV[1])
+	end
+end
+return old
+`)
+
+// SetWithTags сохраняет значение как Set, заменяет теги ключа скриптом keyTagsReplaceScript,
+// затем одним конвейером исключает ключ из прежних тегов и добавляет в новые (tagAddScript).
+// Каждый скрипт обращается к одному ключу, поэтому теги могут находиться в разных слотах
+// Redis Cluster. Запись не отменяется, если обновить теги не удалось.
+// С ttl == 0 ключ сохраняет прежнее время жизни, поэтому оно запрашивается командой PTTL.
+func (s *redisStorage[T]) SetWithTags(ctx context.Context, key string, value T, ttl time.Duration, tags []string) error {
+	key, ttl, err := s.set(ctx, key, value, ttl)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 1*time.Second)
+	defer cancel()
+
+	if ttl <= 0 {
+		if ttl, err = s.client.PTTL(ctx, key).Result(); err != nil {
+			return wrapRedisErr("pttl", err)
+		}
+	}
+	var ms int64
+	if ttl > 0 {
+		ms = max(ttl.Milliseconds(), 1)
+	}
+
+	args := make([]any, 0, len(tags)+1)
+	args = append(args, ms)
+	for _, tag := range tags {
+		args = append(args, tag)
+	}
+	old, err := keyTagsReplaceScript.Run(ctx, s.client, []string{s.keyTagsKey(key)}, args...).StringSlice()
+	if err != nil {
+		return wrapRedisErr("tag replace", err)
+	}
+
+	pipe := s.client.Pipeline()
+	for _, tag := range old {
+		if !slices.Contains(tags, tag) {
+			pipe.SRem(ctx, s.tagKey(tag), key)
+		}
+	}
+	for _, tag := range tags {
+		_ = tagAddScript.Eval(ctx, pipe, []string{s.tagKey(tag)}, key, ms) // EVALSHA в конвейере не повторяется при NOSCRIPT
+	}
+	if pipe.Len() == 0 {
+		return nil
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return wrapRedisErr("tag add", err)
+	}
+	return nil
+}
+
+// InvalidateTag атомарно забирает ключи тега скриптом tagTakeScript, оставляет те,
+// в тегах которых тег все еще есть (ключ мог быть удален, истечь или получить другие теги),
+// и удаляет их конвейером. Возвращает количество удаленных ключей.
+func (s *redisStorage[T]) InvalidateTag(ctx context.Context, tag string) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 1*time.Second)
+	defer cancel()
+
+	keys, err := tagTakeScript.Run(ctx, s.client, []string{s.tagKey(tag)}).StringSlice()
+	if err != nil {
+		return 0, wrapRedisErr("tag take", err)
+	}
+	if len(keys) == 0 {
+		return 0, nil
+	}
+
+	cmds := make([]*redis.BoolCmd, len(keys))
+	pipe := s.client.Pipeline()
+	for i, key := range keys {
+		cmds[i] = pipe.SIsMember(ctx, s.keyTagsKey(key), tag)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, wrapRedisErr("tag check", err)
+	}
+	tagged := keys[:0]
+	for i, key := range keys {
+		if cmds[i].Val() {
+			tagged = append(tagged, key)
+		}
+	}
+	if len(tagged) == 0 {
+		return 0, nil
+	}
+
+	deleted, err := s.deleteKeys(ctx, tagged)
+	for _, key := range tagged {
+		s.invalidate(key)
+	}
+	return deleted, err
+}
+
+// takeKeyTags добавляет в конвейер удаления ключа извлечение его тегов (см. tagTakeScript).
+// Результат после выполнения конвейера передается в untag.
+func (s *redisStorage[T]) takeKeyTags(ctx context.Context, pipe redis.Pipeliner, key string) *redis.Cmd {
+	return tagTakeScript.Eval(ctx, pipe, []string{s.keyTagsKey(key)}) // EVALSHA в конвейере не повторяется при NOSCRIPT
+}
+
+// untag исключает удаленные ключи из множеств тегов, извлеченных takeKeyTags.
+// Ошибки не возвращаются: ключи уже удалены, а оставшиеся в множествах тегов
+// ключи InvalidateTag не удалит, так как их теги уже удалены.
+func (s *redisStorage[T]) untag(ctx context.Context, keys []string, takes []*redis.Cmd) {
+	pipe := s.client.Pipeline()
+	for i, take := range takes {
+		tags, _ := take.StringSlice()
+		for _, tag := range tags {
+			pipe.SRem(ctx, s.tagKey(tag), keys[i])
+		}
+	}
+	if pipe.Len() > 0 {
+		_, _ = pipe.Exec(ctx)
+	}
+}
+
+// dropKeyTags удаляет теги ключей, уже удаленных операцией без конвейера
+// (DeleteIf, Transact и т.д.), и исключает ключи из множеств их тегов.
+func (s *redisStorage[T]) dropKeyTags(ctx context.Context, keys ...string) {
+	takes := make([]*redis.Cmd, len(keys))
+	pipe := s.client.Pipeline()
+	for i, key := range keys {
+		takes[i] = s.takeKeyTags(ctx, pipe, key)
+	}
+	_, _ = pipe.Exec(ctx)
+	s.untag(ctx, keys, takes)
+}
+
+// expireKeyTags приводит время жизни тегов ключа к новому времени жизни ключа:
+// ttl > 0 - PEXPIRE, отрицательный ttl - PERSIST, ttl == 0 - без изменений (KEEPTTL).
+// Команда добавляется в c (клиент или конвейер записи); ошибка не проверяется:
+// отсутствие тегов у ключа - обычная ситуация.
+func (s *redisStorage[T]) expireKeyTags(ctx context.Context, c redis.Cmdable, key string, ttl time.Duration) {
+	switch {
+	case ttl > 0:
+		c.PExpire(ctx, s.keyTagsKey(key), ttl)
+	case ttl < 0:
+		c.Persist(ctx, s.keyTagsKey(key))
+	}
+}
@@ -0,0 +1,39 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+// toggleBool инвертирует логическое значение произвольного типа T.
+// Возвращает новое значение типа T и его представление в bool.
+// Для нелогических типов возвращает ErrWrongType.
+func toggleBool[T any](value T) (T, bool, error) {
+	v := reflect.ValueOf(&value).Elem()
+	if v.Kind() != reflect.Bool {
+		return value, false, fmt.Errorf("%w: toggle of %s", ErrWrongType, v.Type())
+	}
+	v.SetBool(!v.Bool())
+	return value, v.Bool(), nil
+}
+
+// toggleViaTransact инвертирует значение внутри транзакции хранилища s.
+// Запись выполняется Tx.Set с ttl 0, то есть по правилам Set хранилища s
+// (в Redis время жизни сохраняется).
+func toggleViaTransact[T any](ctx context.Context, s Storage[T], key string) (bool, error) {
+	var toggled bool
+	err := s.Transact(ctx, func(tx Tx[T]) error {
+		current, _, err := tx.Get(key)
+		if err != nil {
+			return err
+		}
+		next, b, err := toggleBool(current)
+		if err != nil {
+			return err
+		}
+		toggled = b
+		return tx.Set(key, next, 0)
+	})
+	return toggled, err
+}
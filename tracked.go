@@ -0,0 +1,32 @@
+package storage
+
+import "github.com/redis/go-redis/v9"
+
+// dequeueTrackedScript извлекает первый элемент подготовленной очереди (см. queuePrepareLua)
+// и записывает его по ключу отслеживания, чтобы извлеченный, но еще не обработанный элемент
+// был виден снаружи. Тег типа очереди проверяется как в dequeueScript.
+// KEYS[1..4] - ключи очереди (см. queuePrepareKeys); KEYS[5] - ключ отслеживания;
+// KEYS[6] - тег очереди (только с WithQueueTypeCheck);
+// ARGV[1] - TTL в миллисекундах (0 - бессрочно); ARGV[2] - ожидаемый тег (пустой - без проверки).
+// Возвращает {1, элемент}, {0} для пустой очереди или {2, тег очереди} при несовпадении.
+var dequeueTrackedScript = redis.NewScript(queuePrepareLua + `
+local tag = KEYS[6] and redis.call('GET', KEYS[6])
+if tag and ARGV[2] ~= '' and tag ~= ARGV[2] then
+	return {2, tag}
+end
+local value = redis.call('LPOP', KEYS[1])
+if KEYS[6] and (not value or redis.call('LLEN', KEYS[1]) == 0) then
+	redis.call('DEL', KEYS[6])
+end
+if not value then
+	return {0}
+end
+redis.call('SET', KEYS[4], 1)
+local ttl = tonumber(ARGV[1])
+if ttl > 0 then
+	redis.call('SET', KEYS[5], value, 'PX', ttl)
+else
+	redis.call('SET', KEYS[5], value)
+end
+return {1, value}
+`)
@@ -0,0 +1,198 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// invalidateChannel - канал, в который Redis отправляет сообщения инвалидации.
+const invalidateChannel = "__redis__:invalidate"
+
+// clientCache реализует кэш на стороне клиента с инвалидацией через CLIENT TRACKING.
+//
+// go-redis v9.8 не обрабатывает push-сообщения RESP3 на обычных соединениях пула,
+// поэтому используется режим REDIRECT: отдельное соединение подписывается на
+// канал __redis__:invalidate, а каждое соединение пула при подключении включает
+// CLIENT TRACKING с перенаправлением сообщений на это соединение.
+//
+// Если подписчик переподключился, часть сообщений инвалидации могла быть потеряна,
+// поэтому кэш полностью очищается. Соединения пула, открытые до переподключения,
+// продолжают отправлять сообщения на старый идентификатор, поэтому время жизни
+// записи в кэше дополнительно ограничено ttl.
+type clientCache[T any] struct {
+	mu      sync.Mutex         // Мьютекс для доступа к items
+	items   map[string]item[T] // Закэшированные значения
+	version uint64             // Счетчик инвалидаций (защищен mu)
+	ttl     time.Duration      // Максимальное время жизни записи в кэше
+
+	client *redis.Client // Отдельный клиент для подписки на инвалидации
+	pubsub *redis.PubSub // Подписка на канал инвалидации
+	id     atomic.Int64  // CLIENT ID соединения подписчика
+	logger *slog.Logger  // Логгер для диагностических сообщений
+
+	cancel context.CancelFunc // Останавливает горутину подписчика
+	done   chan struct{}      // Закрывается при завершении горутины подписчика
+}
+
+// newClientCache создает кэш и подписывается на сообщения инвалидации.
+// base - параметры основного клиента; подписчик подключается с теми же параметрами.
+func newClientCache[T any](base *redis.Options, ttl time.Duration, logger *slog.Logger) (*clientCache[T], error) {
+	c := &clientCache[T]{
+		items:  make(map[string]item[T]),
+		ttl:    ttl,
+		logger: logger,
+		done:   make(chan struct{}),
+	}
+
+	subOpts := *base
+	subOpts.OnConnect = func(ctx context.Context, cn *redis.Conn) error {
+		id, err := cn.ClientID(ctx).Result()
+		if err != nil {
+			return err
+		}
+		c.id.Store(id) // Запоминаем идентификатор для REDIRECT
+		return nil
+	}
+	c.client = redis.NewClient(&subOpts)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	defer cancel()
+
+	c.pubsub = c.client.Subscribe(ctx, invalidateChannel)
+	if _, err := c.pubsub.Receive(ctx); err != nil { // Ждем подтверждения подписки
+		_ = c.pubsub.Close()
+		_ = c.client.Close()
+		return nil, wrapRedisErr("subscribe", err)
+	}
+
+	runCtx, runCancel := context.WithCancel(context.Background())
+	c.cancel = runCancel
+	go c.run(runCtx)
+	return c, nil
+}
+
+// enableTracking включает CLIENT TRACKING на новом соединении пула.
+// Используется как OnConnect основного клиента.
+func (c *clientCache[T]) enableTracking(ctx context.Context, cn *redis.Conn) error {
+	id := c.id.Load()
+	if id == 0 {
+		return errors.New("client tracking: invalidation subscriber is not connected")
+	}
+	return cn.Do(ctx, "CLIENT", "TRACKING", "ON", "REDIRECT", id).Err()
+}
+
+// run получает сообщения инвалидации до остановки кэша.
+// По таймауту ожидания удаляет истекшие записи.
+func (c *clientCache[T]) run(ctx context.Context) {
+	defer close(c.done)
+
+	for {
+		msg, err := c.pubsub.ReceiveTimeout(ctx, c.ttl)
+		if ctx.Err() != nil {
+			return // Кэш закрыт
+		}
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				c.deleteExpired()
+				continue
+			}
+
+			// Ошибка соединения или сообщение о полной очистке (FLUSHALL):
+			// сообщения могли быть потеряны, поэтому очищаем кэш целиком
+			c.logger.WarnContext(ctx, "storage: client tracking receive failed", slog.Any("error", err))
+			c.flush()
+			select {
+			case <-time.After(100 * time.Millisecond):
+			case <-ctx.Done():
+				return
+			}
+			continue
+		}
+
+		switch m := msg.(type) {
+		case *redis.Subscription:
+			c.flush() // Переподписка: сообщения за время разрыва потеряны
+		case *redis.Message:
+			if len(m.PayloadSlice) == 0 {
+				c.flush()
+			} else {
+				c.invalidate(m.PayloadSlice...)
+			}
+		}
+	}
+}
+
+// get возвращает значение из кэша, если оно есть и не истекло.
+// Также возвращает текущую версию кэша для последующего вызова store.
+func (c *clientCache[T]) get(key string) (T, bool, uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	it, found := c.items[key]
+	if !found || it.isExpired() {
+		var zero T
+		return zero, false, c.version
+	}
+	return it.value, true, c.version
+}
+
+// store сохраняет прочитанное из Redis значение в кэше.
+// Если с момента чтения версии since пришла инвалидация, значение могло
+// устареть еще до сохранения, поэтому оно не кэшируется.
+func (c *clientCache[T]) store(key string, value T, since uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.version != since {
+		return
+	}
+	c.items[key] = item[T]{value: value, expiration: time.Now().Add(c.ttl).UnixNano()}
+}
+
+// invalidate удаляет ключи из кэша.
+func (c *clientCache[T]) invalidate(keys ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.version++
+	for _, key := range keys {
+		delete(c.items, key)
+	}
+}
+
+// flush полностью очищает кэш.
+func (c *clientCache[T]) flush() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.version++
+	clear(c.items)
+}
+
+// deleteExpired удаляет записи с истекшим временем жизни.
+func (c *clientCache[T]) deleteExpired() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, it := range c.items {
+		if it.isExpired() {
+			delete(c.items, key)
+		}
+	}
+}
+
+// close останавливает подписчика и закрывает его клиент.
+func (c *clientCache[T]) close() error {
+	c.cancel()
+	err := c.pubsub.Close() // Прерывает ожидание сообщения в run
+	<-c.done
+	return errors.Join(err, c.client.Close())
+}
@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"time"
+)
+
+// ErrTxConflict возвращается Transact, если транзакцию не удалось зафиксировать,
+// потому что прочитанные ключи изменялись конкурентно на всех попытках.
+var ErrTxConflict = errors.New("storage: transaction conflict")
+
+// maxTxAttempts - максимальное количество попыток выполнить транзакцию Redis
+// при конкурентном изменении прочитанных ключей.
+const maxTxAttempts = 10
+
+// Tx - операции с ключами внутри транзакции Transact.
+// Записи буферизуются и применяются атомарно после успешного завершения функции
+// транзакции; если она вернула ошибку, ни одна запись не применяется.
+// Чтения видят записи, сделанные ранее в этой же транзакции.
+// Tx действует только внутри функции транзакции и не предназначен
+// для использования из нескольких горутин.
+type Tx[T any] interface {
+	// Get получает значение по ключу с учетом записей транзакции
+	// Возвращает значение, флаг наличия и ошибку
+	Get(key string) (T, bool, error)
+
+	// Set записывает значение с заданным временем жизни (по тем же правилам, что Storage.Set)
+	Set(key string, value T, ttl time.Duration) error
+
+	// Delete удаляет значение по ключу
+	Delete(key string) error
+
+	// Increment увеличивает числовое значение на delta и возвращает новое значение
+	// Отсутствующий ключ считается равным 0, время жизни существующего ключа сохраняется
+	// Если T не числовой тип, возвращает ErrWrongType
+	Increment(key string, delta int64) (int64, error)
+}
+
+// txWrite - буферизованная запись транзакции.
+type txWrite[T any] struct {
+	value   T             // Новое значение
+	ttl     time.Duration // Время жизни
+	keepTTL bool          // Сохранить время жизни ключа в хранилище (ttl не используется)
+	deleted bool          // Запись удаляет ключ
+	origKey string        // Исходный ключ, если он был заменен хешем
+}
+
+// keepTTLSetter - транзакция, которая умеет записать значение с сохранением времени
+// жизни ключа. Используется Increment, в том числе через обертки (typedTx, layeredTx).
+type keepTTLSetter[T any] interface {
+	setKeepTTL(key string, value T) error
+}
+
+// txWrites - буфер записей транзакции в порядке их выполнения.
+// Для каждого ключа хранится только последняя запись.
+type txWrites[T any] struct {
+	keys   []string              // Ключи в порядке первой записи
+	writes map[string]txWrite[T] // Последняя запись по ключу
+}
+
+// put добавляет запись в буфер.
+func (w *txWrites[T]) put(key string, write txWrite[T]) {
+	if w.writes == nil {
+		w.writes = make(map[string]txWrite[T])
+	}
+	if _, ok := w.writes[key]; !ok {
+		w.keys = append(w.keys, key)
+	}
+	w.writes[key] = write
+}
+
+// putKeepTTL добавляет запись, сохраняющую время жизни ключа: время жизни
+// буферизованной записи ключа или, если ее нет, время жизни ключа в хранилище.
+func (w *txWrites[T]) putKeepTTL(key, origKey string, value T) {
+	write := txWrite[T]{value: value, keepTTL: true, origKey: origKey}
+	if prev, ok := w.writes[key]; ok {
+		write.ttl, write.keepTTL = prev.ttl, prev.keepTTL && !prev.deleted
+	}
+	w.put(key, write)
+}
+
+// get возвращает значение ключа из буфера.
+// Второе значение сообщает, есть ли запись, третье - не удален ли ключ.
+func (w *txWrites[T]) get(key string) (T, bool, bool) {
+	write, ok := w.writes[key]
+	return write.value, ok, ok && !write.deleted
+}
+
+// addInt прибавляет delta к числовому значению произвольного типа T.
+// Возвращает новое значение типа T и его представление в int64.
+// Для нечисловых типов и при переполнении возвращает ошибку.
+func addInt[T any](value T, delta int64) (T, int64, error) {
+	n, err := addIntValue(reflect.ValueOf(&value).Elem(), delta)
+	return value, n, err
+}
+
+// addIntValue прибавляет delta к изменяемому числовому значению v.
+// Возвращает новое значение в int64; при ошибке v не изменяется.
+func addIntValue(v reflect.Value, delta int64) (int64, error) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		cur := v.Int()
+		if (delta > 0 && cur > math.MaxInt64-delta) || (delta < 0 && cur < math.MinInt64-delta) || v.OverflowInt(cur+delta) {
+			return 0, fmt.Errorf("increment overflows %s", v.Type())
+		}
+		v.SetInt(cur + delta)
+		return cur + delta, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		cur := v.Uint()
+		next := cur + uint64(delta) // Отрицательная delta вычитается за счет переполнения
+		if (delta > 0 && next < cur) || (delta < 0 && next > cur) || v.OverflowUint(next) || next > math.MaxInt64 {
+			return 0, fmt.Errorf("increment overflows %s", v.Type())
+		}
+		v.SetUint(next)
+		return int64(next), nil
+	case reflect.Float32, reflect.Float64:
+		next := v.Float() + float64(delta)
+		v.SetFloat(next)
+		return int64(next), nil
+	default:
+		return 0, fmt.Errorf("%w: increment of %s", ErrWrongType, v.Type())
+	}
+}
@@ -0,0 +1,648 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// typedStorage реализует Storage[T] поверх байтового хранилища Storage[[]byte].
+// Значения сериализуются кодеком при записи и десериализуются при чтении,
+// что позволяет нескольким типизированным хранилищам делить одно подключение.
+type typedStorage[T any] struct {
+	base  Storage[[]byte] // Общее байтовое хранилище
+	codec Codec           // Кодек для преобразования T <-> []byte
+}
+
+// newTypedStorage создает типизированную обертку над байтовым хранилищем.
+// Если codec равен nil, используется JSONCodec.
+func newTypedStorage[T any](base Storage[[]byte], codec Codec) Storage[T] {
+	if codec == nil {
+		codec = JSONCodec{}
+	}
+	return &typedStorage[T]{base: base, codec: codec}
+}
+
+// encode сериализует значение кодеком.
+func (s *typedStorage[T]) encode(value T) ([]byte, error) {
+	data, err := s.codec.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("marshal failed: %w", err)
+	}
+	return data, nil
+}
+
+// decode десериализует значение кодеком.
+// Если found равен false или произошла ошибка, возвращает нулевое значение.
+func (s *typedStorage[T]) decode(data []byte, found bool, err error) (T, bool, error) {
+	var out T
+	if err != nil || !found {
+		return out, false, err
+	}
+	if err := s.codec.Unmarshal(data, &out); err != nil {
+		var zero T
+		return zero, false, fmt.Errorf("unmarshal failed: %w", err)
+	}
+	return out, true, nil
+}
+
+// Set сериализует значение и сохраняет его в байтовом хранилище.
+func (s *typedStorage[T]) Set(ctx context.Context, key string, value T, ttl time.Duration) error {
+	data, err := s.encode(value)
+	if err != nil {
+		return err
+	}
+	return s.base.Set(ctx, key, data, ttl)
+}
+
+// SetX сериализует значение, сохраняет байты и возвращает время истечения.
+func (s *typedStorage[T]) SetX(ctx context.Context, key string, value T, ttl time.Duration) (time.Time, error) {
+	data, err := s.encode(value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return s.base.SetX(ctx, key, data, ttl)
+}
+
+// Get получает байты из хранилища и десериализует их в T.
+func (s *typedStorage[T]) Get(ctx context.Context, key string) (T, bool, error) {
+	return s.decode(s.base.Get(ctx, key))
+}
+
+// SetWithCodec сериализует значение кодеком codec (см. MarkedCodec) и сохраняет байты.
+func (s *typedStorage[T]) SetWithCodec(ctx context.Context, key string, value T, ttl time.Duration, codec Codec) error {
+	data, err := marshalWithCodec(s.codec, codec, value)
+	if err != nil {
+		return fmt.Errorf("marshal failed: %w", err)
+	}
+	return s.base.Set(ctx, key, data, ttl)
+}
+
+// GetWithCodec получает байты из хранилища и десериализует их кодеком маркера или codec.
+func (s *typedStorage[T]) GetWithCodec(ctx context.Context, key string, codec Codec) (T, bool, error) {
+	var out T
+	data, found, err := s.base.Get(ctx, key)
+	if err != nil || !found {
+		return out, false, err
+	}
+	if err := unmarshalWithCodec(s.codec, codec, data, &out); err != nil {
+		var zero T
+		return zero, false, fmt.Errorf("unmarshal failed: %w", err)
+	}
+	return out, true, nil
+}
+
+// GetInto получает байты из хранилища и десериализует их прямо в dst.
+func (s *typedStorage[T]) GetInto(ctx context.Context, key string, dst *T) (bool, error) {
+	data, found, err := s.base.Get(ctx, key)
+	if err != nil || !found {
+		return false, err
+	}
+	if err := s.codec.Unmarshal(data, dst); err != nil {
+		return false, fmt.Errorf("unmarshal failed: %w", err)
+	}
+	return true, nil
+}
+
+// GetEx получает байты с обновлением TTL и десериализует их в T.
+func (s *typedStorage[T]) GetEx(ctx context.Context, key string, ttl time.Duration) (T, bool, error) {
+	return s.decode(s.base.GetEx(ctx, key, ttl))
+}
+
+// WaitForKey ждет появления ключа в байтовом хранилище и десериализует значение в T.
+func (s *typedStorage[T]) WaitForKey(ctx context.Context, key string, timeout time.Duration) (T, bool, error) {
+	return s.decode(s.base.WaitForKey(ctx, key, timeout))
+}
+
+// GetRaw возвращает байты значения, сериализованные кодеком обертки.
+func (s *typedStorage[T]) GetRaw(ctx context.Context, key string) ([]byte, bool, error) {
+	return s.base.Get(ctx, key)
+}
+
+// Inspect возвращает сведения о записи байтового хранилища.
+func (s *typedStorage[T]) Inspect(ctx context.Context, key string) (ObjectInfo, bool, error) {
+	return s.base.Inspect(ctx, key)
+}
+
+// GetWithMeta получает байты с метаданными и десериализует их в T.
+func (s *typedStorage[T]) GetWithMeta(ctx context.Context, key string) (T, Meta, bool, error) {
+	data, meta, found, err := s.base.GetWithMeta(ctx, key)
+	out, found, err := s.decode(data, found, err)
+	if !found {
+		meta = Meta{}
+	}
+	return out, meta, found, err
+}
+
+// GetWithTTL получает байты со временем жизни и десериализует их в T.
+func (s *typedStorage[T]) GetWithTTL(ctx context.Context, key string) (T, time.Duration, bool, error) {
+	data, ttl, found, err := s.base.GetWithTTL(ctx, key)
+	out, found, err := s.decode(data, found, err)
+	if !found {
+		ttl = 0
+	}
+	return out, ttl, found, err
+}
+
+// Delete удаляет значение из байтового хранилища.
+func (s *typedStorage[T]) Delete(ctx context.Context, key string) error {
+	return s.base.Delete(ctx, key)
+}
+
+// DeleteIf сериализует ожидаемое значение и сравнивает байты в байтовом хранилище.
+func (s *typedStorage[T]) DeleteIf(ctx context.Context, key string, expected T) (bool, error) {
+	data, err := s.encode(expected)
+	if err != nil {
+		return false, err
+	}
+	return s.base.DeleteIf(ctx, key, data)
+}
+
+// GetDelete атомарно получает и удаляет байты, затем десериализует их в T.
+func (s *typedStorage[T]) GetDelete(ctx context.Context, key string) (T, bool, error) {
+	return s.decode(s.base.GetDelete(ctx, key))
+}
+
+// Toggle инвертирует значение в транзакции байтового хранилища.
+func (s *typedStorage[T]) Toggle(ctx context.Context, key string) (bool, error) {
+	return toggleViaTransact[T](ctx, s, key)
+}
+
+// IncrementField десериализует значение, увеличивает поле и записывает его обратно
+// в транзакции байтового хранилища: base хранит непрозрачные байты.
+func (s *typedStorage[T]) IncrementField(ctx context.Context, key, field string, delta int64) (int64, error) {
+	return incrementFieldViaTransact[T](ctx, s, key, field, delta)
+}
+
+// ClaimOnce записывает сериализованный маркер в транзакции байтового хранилища:
+// маркер base (nil) не десериализовался бы в T.
+func (s *typedStorage[T]) ClaimOnce(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	var claimed bool
+	err := s.Transact(ctx, func(tx Tx[T]) error {
+		_, found, err := tx.Get(key)
+		if claimed = err == nil && !found; !claimed {
+			return err
+		}
+		var marker T
+		return tx.Set(key, marker, ttl)
+	})
+	return claimed && err == nil, err
+}
+
+// SetVersioned сериализует значение и записывает его с историей в байтовое хранилище.
+func (s *typedStorage[T]) SetVersioned(ctx context.Context, key string, value T, keep int) error {
+	data, err := s.encode(value)
+	if err != nil {
+		return err
+	}
+	return s.base.SetVersioned(ctx, key, data, keep)
+}
+
+// GetVersion получает байты версии и десериализует их в T.
+func (s *typedStorage[T]) GetVersion(ctx context.Context, key string, n int) (T, bool, error) {
+	return s.decode(s.base.GetVersion(ctx, key, n))
+}
+
+// IncrWindow увеличивает счетчик окна байтового хранилища.
+func (s *typedStorage[T]) IncrWindow(ctx context.Context, key string, window time.Duration, limit int64) (int64, bool, error) {
+	return s.base.IncrWindow(ctx, key, window, limit)
+}
+
+// CopyKey копирует запись без десериализации.
+func (s *typedStorage[T]) CopyKey(ctx context.Context, src, dst string, replace bool) (bool, error) {
+	return s.base.CopyKey(ctx, src, dst, replace)
+}
+
+// DeletePattern удаляет записи по шаблону.
+func (s *typedStorage[T]) DeletePattern(ctx context.Context, pattern string) (int64, error) {
+	return s.base.DeletePattern(ctx, pattern)
+}
+
+// SetWithTags сериализует значение и сохраняет байты с тегами.
+func (s *typedStorage[T]) SetWithTags(ctx context.Context, key string, value T, ttl time.Duration, tags []string) error {
+	data, err := s.encode(value)
+	if err != nil {
+		return err
+	}
+	return s.base.SetWithTags(ctx, key, data, ttl, tags)
+}
+
+// InvalidateTag удаляет записи тега.
+func (s *typedStorage[T]) InvalidateTag(ctx context.Context, tag string) (int64, error) {
+	return s.base.InvalidateTag(ctx, tag)
+}
+
+// CountPattern подсчитывает записи по шаблону.
+func (s *typedStorage[T]) CountPattern(ctx context.Context, pattern string) (int64, error) {
+	return s.base.CountPattern(ctx, pattern)
+}
+
+// GetByPrefix получает байты записей с префиксом и десериализует их в T.
+// Ошибка десериализации любой записи возвращается вызывающему коду.
+func (s *typedStorage[T]) GetByPrefix(ctx context.Context, prefix string) (map[string]T, error) {
+	raw, err := s.base.GetByPrefix(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]T, len(raw))
+	for key, data := range raw {
+		value, _, err := s.decode(data, true, nil)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", key, err)
+		}
+		values[key] = value
+	}
+	return values, nil
+}
+
+// ScanPage возвращает страницу ключей байтового хранилища.
+func (s *typedStorage[T]) ScanPage(ctx context.Context, cursor uint64, match string, count int) ([]string, uint64, error) {
+	return s.base.ScanPage(ctx, cursor, match, count)
+}
+
+// Enqueue сериализует значение и добавляет его в очередь.
+func (s *typedStorage[T]) Enqueue(ctx context.Context, queueName string, value T) error {
+	data, err := s.encode(value)
+	if err != nil {
+		return err
+	}
+	return s.base.Enqueue(ctx, queueName, data)
+}
+
+// EnqueueFanout сериализует значение один раз и добавляет его во все очереди.
+func (s *typedStorage[T]) EnqueueFanout(ctx context.Context, queueNames []string, value T) error {
+	data, err := s.encode(value)
+	if err != nil {
+		return err
+	}
+	return s.base.EnqueueFanout(ctx, queueNames, data)
+}
+
+// DequeueTracked извлекает байты с записью ключа отслеживания и десериализует их в T.
+func (s *typedStorage[T]) DequeueTracked(ctx context.Context, queueName, trackingKey string, ttl time.Duration) (T, bool, error) {
+	return s.decode(s.base.DequeueTracked(ctx, queueName, trackingKey, ttl))
+}
+
+// EnqueueN сериализует значение, добавляет его в очередь и возвращает новую длину.
+func (s *typedStorage[T]) EnqueueN(ctx context.Context, queueName string, value T) (int64, error) {
+	data, err := s.encode(value)
+	if err != nil {
+		return 0, err
+	}
+	return s.base.EnqueueN(ctx, queueName, data)
+}
+
+// EnqueueCapped сериализует значение и добавляет его в ограниченную очередь.
+func (s *typedStorage[T]) EnqueueCapped(ctx context.Context, queueName string, value T, maxLen int64) error {
+	data, err := s.encode(value)
+	if err != nil {
+		return err
+	}
+	return s.base.EnqueueCapped(ctx, queueName, data, maxLen)
+}
+
+// TryEnqueue сериализует значение и добавляет его, если в очереди есть место.
+func (s *typedStorage[T]) TryEnqueue(ctx context.Context, queueName string, value T, maxLen int64) (bool, EnqueueReason, error) {
+	data, err := s.encode(value)
+	if err != nil {
+		return false, ReasonError, err
+	}
+	return s.base.TryEnqueue(ctx, queueName, data, maxLen)
+}
+
+// EnqueueBounded сериализует значение и добавляет его, если в очереди есть место.
+func (s *typedStorage[T]) EnqueueBounded(ctx context.Context, queueName string, value T, maxLen int64) (bool, error) {
+	data, err := s.encode(value)
+	if err != nil {
+		return false, err
+	}
+	return s.base.EnqueueBounded(ctx, queueName, data, maxLen)
+}
+
+// EnqueueDelayed сериализует значение и добавляет его в очередь с задержкой.
+func (s *typedStorage[T]) EnqueueDelayed(ctx context.Context, queueName string, value T, delay time.Duration) error {
+	data, err := s.encode(value)
+	if err != nil {
+		return err
+	}
+	return s.base.EnqueueDelayed(ctx, queueName, data, delay)
+}
+
+// Dequeue извлекает элемент из очереди и десериализует его в T.
+func (s *typedStorage[T]) Dequeue(ctx context.Context, queueName string) (T, bool, error) {
+	return s.decode(s.base.Dequeue(ctx, queueName))
+}
+
+// BlockingDequeueAny ждет элемент в очередях и десериализует его в T.
+func (s *typedStorage[T]) BlockingDequeueAny(ctx context.Context, queueNames []string, timeout time.Duration) (string, T, bool, error) {
+	name, data, found, err := s.base.BlockingDequeueAny(ctx, queueNames, timeout)
+	value, found, err := s.decode(data, found, err)
+	return name, value, found, err
+}
+
+// Peek просматривает первый элемент очереди и десериализует его в T.
+func (s *typedStorage[T]) Peek(ctx context.Context, queueName string) (T, bool, error) {
+	return s.decode(s.base.Peek(ctx, queueName))
+}
+
+// Remove удаляет первый элемент очереди.
+func (s *typedStorage[T]) Remove(ctx context.Context, queueName string) (bool, error) {
+	return s.base.Remove(ctx, queueName)
+}
+
+// RemoveAt удаляет элемент по позиции в очереди.
+func (s *typedStorage[T]) RemoveAt(ctx context.Context, queueName string, index int64) (bool, error) {
+	return s.base.RemoveAt(ctx, queueName, index)
+}
+
+// ReplaceQueue сериализует значения и заменяет ими содержимое очереди.
+func (s *typedStorage[T]) ReplaceQueue(ctx context.Context, queueName string, values []T) error {
+	items := make([][]byte, len(values))
+	for i, value := range values {
+		data, err := s.encode(value)
+		if err != nil {
+			return err
+		}
+		items[i] = data
+	}
+	return s.base.ReplaceQueue(ctx, queueName, items)
+}
+
+// RotateQueue переносит первый элемент очереди в ее конец и десериализует его в T.
+func (s *typedStorage[T]) RotateQueue(ctx context.Context, queueName string) (T, bool, error) {
+	return s.decode(s.base.RotateQueue(ctx, queueName))
+}
+
+// SetHead сериализует значение, заменяет им первый элемент очереди
+// и десериализует прежний элемент в T.
+func (s *typedStorage[T]) SetHead(ctx context.Context, queueName string, value T) (T, bool, error) {
+	data, err := s.encode(value)
+	if err != nil {
+		var zero T
+		return zero, false, err
+	}
+	return s.decode(s.base.SetHead(ctx, queueName, data))
+}
+
+// MoveMatching переносит элементы между очередями, десериализуя их в T для проверки match.
+// Элементы, которые не удалось десериализовать, не переносятся.
+func (s *typedStorage[T]) MoveMatching(ctx context.Context, from, to string, match func(T) bool, limit int) (int, error) {
+	return s.base.MoveMatching(ctx, from, to, func(data []byte) bool {
+		value, _, err := s.decode(data, true, nil)
+		return err == nil && match(value)
+	}, limit)
+}
+
+// QueueLen возвращает длину очереди.
+func (s *typedStorage[T]) QueueLen(ctx context.Context, queueName string) (int64, error) {
+	return s.base.QueueLen(ctx, queueName)
+}
+
+// QueueTail возвращает последние элементы очереди, десериализованные в T.
+func (s *typedStorage[T]) QueueTail(ctx context.Context, queueName string, n int) ([]T, error) {
+	tail, err := s.base.QueueTail(ctx, queueName, n)
+	if err != nil || len(tail) == 0 {
+		return nil, err
+	}
+
+	items := make([]T, 0, len(tail))
+	for _, data := range tail {
+		value, _, err := s.decode(data, true, nil)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, value)
+	}
+	return items, nil
+}
+
+// QueueIterate обходит очередь, десериализуя элементы в T перед вызовом fn.
+// Ошибка десериализации прекращает обход.
+func (s *typedStorage[T]) QueueIterate(ctx context.Context, queueName string, fn func(T) error) error {
+	return s.base.QueueIterate(ctx, queueName, func(data []byte) error {
+		value, _, err := s.decode(data, true, nil)
+		if err != nil {
+			return err
+		}
+		return fn(value)
+	})
+}
+
+// QueueReadFrom читает элементы очереди по смещению и десериализует их в T.
+func (s *typedStorage[T]) QueueReadFrom(ctx context.Context, queueName string, offset int64, n int) ([]T, int64, error) {
+	raw, next, err := s.base.QueueReadFrom(ctx, queueName, offset, n)
+	if err != nil || len(raw) == 0 {
+		return nil, next, err
+	}
+
+	items := make([]T, 0, len(raw))
+	for _, data := range raw {
+		value, _, err := s.decode(data, true, nil)
+		if err != nil {
+			return nil, offset, err
+		}
+		items = append(items, value)
+	}
+	return items, next, nil
+}
+
+// QueueStats возвращает статистику очереди.
+func (s *typedStorage[T]) QueueStats(ctx context.Context, queueName string) (QueueStats, error) {
+	return s.base.QueueStats(ctx, queueName)
+}
+
+// QueueLenMany возвращает длины нескольких очередей.
+func (s *typedStorage[T]) QueueLenMany(ctx context.Context, queueNames []string) (map[string]int64, error) {
+	return s.base.QueueLenMany(ctx, queueNames)
+}
+
+// QueueIndexOf десериализует элементы очереди и проверяет их условием.
+// Ошибка десериализации прерывает поиск и возвращается вызывающему коду.
+func (s *typedStorage[T]) QueueIndexOf(ctx context.Context, queueName string, match func(T) bool) (int64, bool, error) {
+	var decodeErr error
+	idx, found, err := s.base.QueueIndexOf(ctx, queueName, func(data []byte) bool {
+		value, _, err := s.decode(data, true, nil)
+		if err != nil {
+			decodeErr = err
+			return true // Останавливаем поиск
+		}
+		return match(value)
+	})
+	if err != nil {
+		return 0, false, err
+	}
+	if decodeErr != nil {
+		return 0, false, decodeErr
+	}
+	return idx, found, nil
+}
+
+// QueueIndexOfValue сериализует значение и ищет его в очереди по байтам.
+func (s *typedStorage[T]) QueueIndexOfValue(ctx context.Context, queueName string, value T) (int64, bool, error) {
+	data, err := s.encode(value)
+	if err != nil {
+		return 0, false, err
+	}
+	return s.base.QueueIndexOfValue(ctx, queueName, data)
+}
+
+// PeekBatchReserve резервирует элементы в base и десериализует их.
+// При ошибке десериализации пачка остается зарезервированной и вернется в очередь по истечении vt.
+func (s *typedStorage[T]) PeekBatchReserve(ctx context.Context, queueName string, n int, vt time.Duration) ([]T, string, error) {
+	batch, token, err := s.base.PeekBatchReserve(ctx, queueName, n, vt)
+	if err != nil || len(batch) == 0 {
+		return nil, token, err
+	}
+
+	items := make([]T, 0, len(batch))
+	for _, data := range batch {
+		value, _, err := s.decode(data, true, nil)
+		if err != nil {
+			return nil, "", err
+		}
+		items = append(items, value)
+	}
+	return items, token, nil
+}
+
+// AckBatch подтверждает резервирование в base.
+func (s *typedStorage[T]) AckBatch(ctx context.Context, queueName, token string) error {
+	return s.base.AckBatch(ctx, queueName, token)
+}
+
+// SAddEx сериализует элемент и добавляет его в множество.
+func (s *typedStorage[T]) SAddEx(ctx context.Context, setName string, member T, ttl time.Duration) error {
+	data, err := s.encode(member)
+	if err != nil {
+		return err
+	}
+	return s.base.SAddEx(ctx, setName, data, ttl)
+}
+
+// SCount возвращает количество элементов множества.
+func (s *typedStorage[T]) SCount(ctx context.Context, setName string) (int64, error) {
+	return s.base.SCount(ctx, setName)
+}
+
+// Transact выполняет транзакцию в base, сериализуя значения кодеком обертки.
+func (s *typedStorage[T]) Transact(ctx context.Context, fn func(tx Tx[T]) error) error {
+	return s.base.Transact(ctx, func(tx Tx[[]byte]) error {
+		return fn(&typedTx[T]{s: s, base: tx})
+	})
+}
+
+// typedTx преобразует значения транзакции байтового хранилища в T.
+type typedTx[T any] struct {
+	s    *typedStorage[T]
+	base Tx[[]byte]
+}
+
+// Get получает значение из транзакции base и десериализует его.
+func (tx *typedTx[T]) Get(key string) (T, bool, error) {
+	return tx.s.decode(tx.base.Get(key))
+}
+
+// Set сериализует значение и записывает его в транзакции base.
+func (tx *typedTx[T]) Set(key string, value T, ttl time.Duration) error {
+	data, err := tx.s.encode(value)
+	if err != nil {
+		return err
+	}
+	return tx.base.Set(key, data, ttl)
+}
+
+// Delete удаляет значение в транзакции base.
+func (tx *typedTx[T]) Delete(key string) error {
+	return tx.base.Delete(key)
+}
+
+// Increment десериализует значение, увеличивает его и записывает обратно
+// с сохранением времени жизни ключа, если base это поддерживает (keepTTLSetter).
+// Увеличение выполняется над T, так как base хранит непрозрачные байты.
+func (tx *typedTx[T]) Increment(key string, delta int64) (int64, error) {
+	current, _, err := tx.Get(key)
+	if err != nil {
+		return 0, err
+	}
+	next, n, err := addInt(current, delta)
+	if err != nil {
+		return 0, err
+	}
+	base, ok := tx.base.(keepTTLSetter[[]byte])
+	if !ok {
+		return n, tx.Set(key, next, 0)
+	}
+	data, err := tx.s.encode(next)
+	if err != nil {
+		return 0, err
+	}
+	return n, base.setKeepTTL(key, data)
+}
+
+// Pipeline создает пакет команд поверх пакета байтового хранилища.
+func (s *typedStorage[T]) Pipeline() Pipeline[T] {
+	return &typedPipeline[T]{s: s}
+}
+
+// typedPipeline сериализует значения команд и передает их пакету байтового хранилища.
+type typedPipeline[T any] struct {
+	pipelineOps[T]
+	s *typedStorage[T]
+}
+
+// Exec сериализует значения, выполняет пакет байтового хранилища
+// и десериализует результаты Get.
+func (p *typedPipeline[T]) Exec(ctx context.Context) ([]PipelineResult[T], error) {
+	ops := p.take()
+	results := make([]PipelineResult[T], len(ops))
+
+	base := p.s.base.Pipeline()
+	positions := make([]int, 0, len(ops)) // Индексы команд, переданных в base
+	for i, op := range ops {
+		switch op.kind {
+		case pipelineSet, pipelineEnqueue:
+			data, err := p.s.encode(op.value)
+			if err != nil {
+				results[i].Err = err
+				continue
+			}
+			if op.kind == pipelineSet {
+				base.Set(op.key, data, op.ttl)
+			} else {
+				base.Enqueue(op.key, data)
+			}
+		case pipelineGet:
+			base.Get(op.key)
+		case pipelineDelete:
+			base.Delete(op.key)
+		}
+		positions = append(positions, i)
+	}
+
+	part, err := base.Exec(ctx)
+	for j, pos := range positions {
+		if j >= len(part) {
+			results[pos].Err = err // Байтовое хранилище не вернуло результатов
+			continue
+		}
+		r := part[j]
+		results[pos].Value, results[pos].Found, results[pos].Err = p.s.decode(r.Value, r.Found, r.Err)
+		results[pos].TTL = r.TTL
+	}
+
+	return results, firstErr(results)
+}
+
+// Capabilities возвращает возможности байтового хранилища.
+// Диагностика base (популярные ключи, статистика) через обертку недоступна.
+func (s *typedStorage[T]) Capabilities() Capability {
+	return s.base.Capabilities() &^ (CapHotKeys | CapStats)
+}
+
+// Close ничего не делает: байтовое хранилище разделяется между обертками,
+// и его жизненным циклом управляет вызывающий код. Поэтому и повторный вызов
+// возвращает nil, а не ErrClosed.
+func (s *typedStorage[T]) Close() error {
+	return nil
+}
@@ -0,0 +1,216 @@
+package storage_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alfzs/go-storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTypedStorage_SharedBase(t *testing.T) {
+	base, _ := storage.NewMemory[[]byte](50 * time.Millisecond)
+	defer base.Close()
+	ctx := context.Background()
+
+	type user struct {
+		Name string
+		Age  int
+	}
+
+	users := storage.Typed[user](base, storage.JSONCodec{})
+	counters := storage.Typed[int](base, nil)
+
+	require.NoError(t, users.Set(ctx, "user:1", user{Name: "Bob", Age: 30}, 0))
+	require.NoError(t, counters.Set(ctx, "counter", 42, 0))
+
+	u, found, err := users.Get(ctx, "user:1")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, user{Name: "Bob", Age: 30}, u)
+
+	n, found, err := counters.Get(ctx, "counter")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, 42, n)
+
+	raw, found, _ := base.Get(ctx, "counter")
+	require.True(t, found)
+	require.Equal(t, []byte("42"), raw)
+}
+
+func TestTypedStorage_Queue(t *testing.T) {
+	base, _ := storage.NewMemory[[]byte](50 * time.Millisecond)
+	defer base.Close()
+	ctx := context.Background()
+
+	s := storage.Typed[string](base, nil)
+	require.NoError(t, s.Enqueue(ctx, "q", "first"))
+	n, err := s.EnqueueN(ctx, "q", "second")
+	require.NoError(t, err)
+	require.Equal(t, int64(2), n)
+
+	val, found, err := s.Peek(ctx, "q")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "first", val)
+
+	val, found, err = s.Dequeue(ctx, "q")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, "first", val)
+}
+
+func TestTypedStorage_CorruptValue(t *testing.T) {
+	base, _ := storage.NewMemory[[]byte](50 * time.Millisecond)
+	defer base.Close()
+	ctx := context.Background()
+
+	require.NoError(t, base.Set(ctx, "bad", []byte("{not json"), 0))
+
+	_, found, err := storage.Typed[int](base, nil).Get(ctx, "bad")
+	require.Error(t, err)
+	require.False(t, found)
+}
+
+func TestTypedStorage_Pipeline(t *testing.T) {
+	base, _ := storage.NewMemory[[]byte](50 * time.Millisecond)
+	defer base.Close()
+	ctx := context.Background()
+
+	s := storage.Typed[int](base, nil)
+	pipe := s.Pipeline()
+	pipe.Set("n", 7, 0)
+	pipe.Get("n")
+	pipe.Enqueue("q", 8)
+
+	results, err := pipe.Exec(ctx)
+	require.NoError(t, err)
+	require.True(t, results[1].Found)
+	require.Equal(t, 7, results[1].Value)
+
+	val, found, _ := s.Dequeue(ctx, "q")
+	require.True(t, found)
+	require.Equal(t, 8, val)
+}
+
+func TestTypedStorage_QueueIndexOf(t *testing.T) {
+	base, _ := storage.NewMemory[[]byte](50 * time.Millisecond)
+	defer base.Close()
+	ctx := context.Background()
+
+	s := storage.Typed[int](base, nil)
+	for _, v := range []int{10, 20, 30} {
+		require.NoError(t, s.Enqueue(ctx, "q", v))
+	}
+
+	idx, found, err := s.QueueIndexOf(ctx, "q", func(v int) bool { return v > 15 })
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, int64(1), idx)
+
+	idx, found, err = s.QueueIndexOfValue(ctx, "q", 30)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, int64(2), idx)
+}
+
+func TestTypedStorage_Transact(t *testing.T) {
+	base, _ := storage.NewMemory[[]byte](time.Hour)
+	defer base.Close()
+	ctx := context.Background()
+
+	s := storage.Typed[int64](base, nil)
+	err := s.Transact(ctx, func(tx storage.Tx[int64]) error {
+		n, err := tx.Increment("counter", 3)
+		require.Equal(t, int64(3), n)
+		return err
+	})
+	require.NoError(t, err)
+
+	value, found, err := s.Get(ctx, "counter")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, int64(3), value)
+
+	// Increment сохраняет время жизни ключа в base
+	require.NoError(t, s.Set(ctx, "counter", 3, time.Minute))
+	require.NoError(t, s.Transact(ctx, func(tx storage.Tx[int64]) error {
+		_, err := tx.Increment("counter", 1)
+		return err
+	}))
+	_, ttl, found, err := s.GetWithTTL(ctx, "counter")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.InDelta(t, time.Minute, ttl, float64(time.Second))
+}
+
+func TestTypedStorage_GetRaw(t *testing.T) {
+	base, _ := storage.NewMemory[[]byte](time.Hour)
+	defer base.Close()
+	ctx := context.Background()
+
+	counters := storage.Typed[int](base, nil)
+	require.NoError(t, counters.Set(ctx, "counter", 42, 0))
+
+	raw, found, err := counters.GetRaw(ctx, "counter")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, []byte("42"), raw)
+}
+
+func TestTypedStorage_Toggle(t *testing.T) {
+	base, _ := storage.NewMemory[[]byte](time.Hour)
+	defer base.Close()
+	ctx := context.Background()
+
+	flags := storage.Typed[bool](base, nil)
+	on, err := flags.Toggle(ctx, "flag")
+	require.NoError(t, err)
+	require.True(t, on)
+
+	raw, _, _ := base.Get(ctx, "flag")
+	require.Equal(t, []byte("true"), raw)
+
+	on, err = flags.Toggle(ctx, "flag")
+	require.NoError(t, err)
+	require.False(t, on)
+}
+
+func TestTypedStorage_ClaimOnce(t *testing.T) {
+	base, _ := storage.NewMemory[[]byte](time.Hour)
+	defer base.Close()
+	ctx := context.Background()
+
+	type job struct{ ID int }
+	jobs := storage.Typed[job](base, nil)
+	claimed, err := jobs.ClaimOnce(ctx, "job:1", 0)
+	require.NoError(t, err)
+	require.True(t, claimed)
+	claimed, err = jobs.ClaimOnce(ctx, "job:1", 0)
+	require.NoError(t, err)
+	require.False(t, claimed)
+
+	// Маркер десериализуется в нулевое значение
+	val, found, err := jobs.Get(ctx, "job:1")
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, job{}, val)
+}
+
+func TestTypedStorage_GetInto(t *testing.T) {
+	base, _ := storage.NewMemory[[]byte](time.Hour)
+	defer base.Close()
+	ctx := context.Background()
+
+	lists := storage.Typed[[]string](base, nil)
+	require.NoError(t, lists.Set(ctx, "list", []string{"a", "b"}, 0))
+
+	dst := make([]string, 0, 4)
+	found, err := lists.GetInto(ctx, "list", &dst)
+	require.NoError(t, err)
+	require.True(t, found)
+	require.Equal(t, []string{"a", "b"}, dst)
+	require.Equal(t, 4, cap(dst)) // Буфер dst переиспользован
+}
@@ -0,0 +1,190 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Тег типа очереди (WithQueueTypeCheck) задается первой записью в очередь без тега
+// и проверяется последующими операциями записи и извлечения. В Redis тег хранится
+// отдельной строкой с префиксом queueTagNamespace (не возвращается обходами ключей)
+// в слоте списка очереди (см. sameSlotKey) и удаляется любой операцией, опустошившей
+// очередь (см. releaseQueueTag).
+
+// queueTagNamespace - префикс строки Redis с тегом типа очереди.
+// Применяется всегда: тег не может разделять имя с очередью.
+const queueTagNamespace = "qt:"
+
+// queueTagKey возвращает имя строки Redis с тегом типа очереди. Имя строится
+// из имени списка очереди, поэтому учитывает WithNamespaces и лежит в слоте списка.
+func (s *redisStorage[T]) queueTagKey(queueName string) string {
+	return sameSlotKey(queueTagNamespace, s.queueKey(queueName))
+}
+
+// releaseQueueTag удаляет тег типа очереди, если очередь пуста (см. releaseQueueTagScript).
+// Вызывается после каждой операции, которая может опустошить очередь; скрипты извлечения
+// (dequeueScript, dequeueTrackedScript, removeAtScript, poppedTagScript) удаляют тег сами.
+// Ошибка не возвращается: элементы уже извлечены, а оставшийся тег удалит следующий Dequeue.
+func (s *redisStorage[T]) releaseQueueTag(ctx context.Context, queueName string) {
+	if !s.opts.queueTypeCheck {
+		return
+	}
+	keys := []string{s.queueKey(queueName), s.queueTagKey(queueName)}
+	_ = releaseQueueTagScript.Run(ctx, s.client, keys).Err()
+}
+
+// checkPoppedTag проверяет тег очереди после BLPOP (см. poppedTagScript).
+// При несовпадении элемент val уже возвращен в начало очереди.
+func (s *redisStorage[T]) checkPoppedTag(ctx context.Context, queueName, val string) error {
+	if !s.opts.queueTypeCheck {
+		return nil
+	}
+	want, _ := expectedQueueTag[T](&s.opts)
+	keys := []string{s.queueKey(queueName), s.queueTagKey(queueName)}
+	res, err := poppedTagScript.Run(ctx, s.client, keys, want, val).Result()
+	if err != nil {
+		return wrapRedisErr("check queue tag", err)
+	}
+	_, err = taggedReply(queueName, want, res)
+	return err
+}
+
+// queueTag возвращает тег типа значения: тег WithQueueTypeCheck
+// или имя динамического типа значения.
+func (o *options) queueTag(value any) string {
+	if o.queueTypeTag != "" {
+		return o.queueTypeTag
+	}
+	if value == nil {
+		return "<nil>" // Нулевое значение интерфейсного типа
+	}
+	return reflect.TypeOf(value).String()
+}
+
+// expectedQueueTag возвращает тег, который ожидает Dequeue хранилища с типом T.
+// Если тег не задан, а T - интерфейс, ожидаемый тип неизвестен и false.
+func expectedQueueTag[T any](o *options) (string, bool) {
+	if o.queueTypeTag != "" {
+		return o.queueTypeTag, true
+	}
+	t := reflect.TypeFor[T]()
+	if t.Kind() == reflect.Interface {
+		return "", false
+	}
+	return t.String(), true
+}
+
+// typeMismatch возвращает ErrTypeMismatch с тегами очереди и операции.
+func typeMismatch(queueName, have, want string) error {
+	return fmt.Errorf("%w: queue %q holds %s, got %s", ErrTypeMismatch, queueName, have, want)
+}
+
+// valueTag возвращает тег типа value для скриптов записи в очередь
+// или "", если WithQueueTypeCheck не задан.
+func (s *redisStorage[T]) valueTag(value T) string {
+	if !s.opts.queueTypeCheck {
+		return ""
+	}
+	return s.opts.queueTag(value)
+}
+
+// valuesTag возвращает общий тег типа values (см. valueTag) или ErrTypeMismatch,
+// если теги значений различаются.
+func (s *redisStorage[T]) valuesTag(queueName string, values []T) (string, error) {
+	if !s.opts.queueTypeCheck || len(values) == 0 {
+		return "", nil
+	}
+	tag := s.opts.queueTag(values[0])
+	for _, value := range values[1:] {
+		if other := s.opts.queueTag(value); other != tag {
+			return "", typeMismatch(queueName, tag, other)
+		}
+	}
+	return tag, nil
+}
+
+// taggedReply разбирает ответ скрипта записи в очередь: тег очереди (строка)
+// означает несовпадение типов, иначе возвращается число из ответа.
+func taggedReply(queueName, tag string, res any) (int64, error) {
+	if have, ok := res.(string); ok {
+		return 0, typeMismatch(queueName, have, tag)
+	}
+	n, _ := res.(int64)
+	return n, nil
+}
+
+// Скрипты записи в очередь начинаются с queueTagCheckLua и после записи выполняют
+// queueTagSetLua, поэтому тег проверяется и задается атомарно с записью.
+// Во всех таких скриптах KEYS[2] - тег очереди, ARGV[1] - тег значения (пустой - без проверки).
+
+// queueTagCheckLua возвращает тег очереди, если он задан и не совпадает с тегом значения.
+const queueTagCheckLua = `
+local tag = redis.call('GET', KEYS[2])
+if ARGV[1] ~= '' and tag and tag ~= ARGV[1] then
+	return tag
+end
+`
+
+// queueTagSetLua задает тег очереди без тега.
+const queueTagSetLua = `
+if ARGV[1] ~= '' and not tag then
+	redis.call('SET', KEYS[2], ARGV[1])
+end
+`
+
+// enqueueTaggedScript добавляет значение в конец очереди, если тег очереди
+// совпадает с тегом значения или еще не задан.
+// KEYS[1] - очередь; KEYS[2] - тег очереди; ARGV[1] - тег значения; ARGV[2] - значение.
+// Возвращает длину очереди или тег очереди при несовпадении.
+var enqueueTaggedScript = redis.NewScript(queueTagCheckLua + `
+local n = redis.call('RPUSH', KEYS[1], ARGV[2])
+` + queueTagSetLua + `
+return n
+`)
+
+// replaceQueueTaggedScript заменяет содержимое очереди значениями, если тег очереди
+// совпадает с их тегом или еще не задан, и помечает очередь разобранной.
+// Опустевшая очередь теряет тег.
+// KEYS[1] - очередь; KEYS[2] - тег очереди; KEYS[3] - метка разобранной очереди;
+// ARGV[1] - тег значений; ARGV[2..] - значения.
+// Возвращает количество значений или тег очереди при несовпадении.
+var replaceQueueTaggedScript = redis.NewScript(queueTagCheckLua + `
+redis.call('DEL', KEYS[1])
+redis.call('SET', KEYS[3], 1)
+if #ARGV < 2 then
+	redis.call('DEL', KEYS[2])
+	return 0
+end
+redis.call('RPUSH', KEYS[1], unpack(ARGV, 2))
+` + queueTagSetLua + `
+return #ARGV - 1
+`)
+
+// poppedTagScript проверяет тег очереди после извлечения элемента командой BLPOP,
+// которая не может проверить тег сама. При несовпадении элемент возвращается
+// в начало очереди, иначе опустевшая очередь теряет тег.
+// KEYS[1] - очередь; KEYS[2] - тег очереди; ARGV[1] - ожидаемый тег; ARGV[2] - элемент.
+// Возвращает тег очереди при несовпадении или 0.
+var poppedTagScript = redis.NewScript(`
+local tag = redis.call('GET', KEYS[2])
+if tag and ARGV[1] ~= '' and tag ~= ARGV[1] then
+	redis.call('LPUSH', KEYS[1], ARGV[2])
+	return tag
+end
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	redis.call('DEL', KEYS[2])
+end
+return 0
+`)
+
+// releaseQueueTagScript удаляет тег типа очереди, если очередь пуста.
+// KEYS[1] - очередь; KEYS[2] - тег очереди.
+var releaseQueueTagScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	redis.call('DEL', KEYS[2])
+end
+return 0
+`)
@@ -0,0 +1,293 @@
+package storage
+
+import (
+	"context"
+	"time"
+)
+
+// unsupportedStorage реализует Storage[T], возвращая ErrUnsupported из каждого метода.
+// Предназначен для хранилищ, которые сознательно не поддерживают большую часть
+// интерфейса и переопределяют лишь несколько методов. Хранилища, реализующие интерфейс,
+// его не встраивают, чтобы компилятор сообщал о каждом новом методе интерфейса;
+// отдельные сознательно неподдерживаемые методы (например, Transact у NewSharded)
+// явно возвращают unsupported(имя метода).
+type unsupportedStorage[T any] struct{}
+
+// Проверяем, что unsupportedStorage покрывает весь интерфейс.
+var _ Storage[any] = unsupportedStorage[any]{}
+
+func (unsupportedStorage[T]) Set(ctx context.Context, key string, value T, ttl time.Duration) error {
+	return unsupported("Set")
+}
+
+func (unsupportedStorage[T]) Get(ctx context.Context, key string) (T, bool, error) {
+	var zero T
+	return zero, false, unsupported("Get")
+}
+
+func (unsupportedStorage[T]) GetEx(ctx context.Context, key string, ttl time.Duration) (T, bool, error) {
+	var zero T
+	return zero, false, unsupported("GetEx")
+}
+
+func (unsupportedStorage[T]) GetInto(ctx context.Context, key string, dst *T) (bool, error) {
+	return false, unsupported("GetInto")
+}
+
+func (unsupportedStorage[T]) SetWithCodec(ctx context.Context, key string, value T, ttl time.Duration, codec Codec) error {
+	return unsupported("SetWithCodec")
+}
+
+func (unsupportedStorage[T]) GetWithCodec(ctx context.Context, key string, codec Codec) (T, bool, error) {
+	var zero T
+	return zero, false, unsupported("GetWithCodec")
+}
+
+func (unsupportedStorage[T]) WaitForKey(ctx context.Context, key string, timeout time.Duration) (T, bool, error) {
+	var zero T
+	return zero, false, unsupported("WaitForKey")
+}
+
+func (unsupportedStorage[T]) GetRaw(ctx context.Context, key string) ([]byte, bool, error) {
+	return nil, false, unsupported("GetRaw")
+}
+
+func (unsupportedStorage[T]) GetWithMeta(ctx context.Context, key string) (T, Meta, bool, error) {
+	var zero T
+	return zero, Meta{}, false, unsupported("GetWithMeta")
+}
+
+func (unsupportedStorage[T]) GetWithTTL(ctx context.Context, key string) (T, time.Duration, bool, error) {
+	var zero T
+	return zero, 0, false, unsupported("GetWithTTL")
+}
+
+func (unsupportedStorage[T]) Delete(ctx context.Context, key string) error {
+	return unsupported("Delete")
+}
+
+func (unsupportedStorage[T]) SetX(ctx context.Context, key string, value T, ttl time.Duration) (time.Time, error) {
+	return time.Time{}, unsupported("SetX")
+}
+
+func (unsupportedStorage[T]) DeleteIf(ctx context.Context, key string, expected T) (bool, error) {
+	return false, unsupported("DeleteIf")
+}
+
+func (unsupportedStorage[T]) GetDelete(ctx context.Context, key string) (T, bool, error) {
+	var zero T
+	return zero, false, unsupported("GetDelete")
+}
+
+func (unsupportedStorage[T]) Toggle(ctx context.Context, key string) (bool, error) {
+	return false, unsupported("Toggle")
+}
+
+func (unsupportedStorage[T]) IncrementField(ctx context.Context, key, field string, delta int64) (int64, error) {
+	return 0, unsupported("IncrementField")
+}
+
+func (unsupportedStorage[T]) SetVersioned(ctx context.Context, key string, value T, keep int) error {
+	return unsupported("SetVersioned")
+}
+
+func (unsupportedStorage[T]) GetVersion(ctx context.Context, key string, n int) (T, bool, error) {
+	var zero T
+	return zero, false, unsupported("GetVersion")
+}
+
+func (unsupportedStorage[T]) DeletePattern(ctx context.Context, pattern string) (int64, error) {
+	return 0, unsupported("DeletePattern")
+}
+
+func (unsupportedStorage[T]) SetWithTags(ctx context.Context, key string, value T, ttl time.Duration, tags []string) error {
+	return unsupported("SetWithTags")
+}
+
+func (unsupportedStorage[T]) InvalidateTag(ctx context.Context, tag string) (int64, error) {
+	return 0, unsupported("InvalidateTag")
+}
+
+func (unsupportedStorage[T]) GetByPrefix(ctx context.Context, prefix string) (map[string]T, error) {
+	return nil, unsupported("GetByPrefix")
+}
+
+func (unsupportedStorage[T]) ScanPage(ctx context.Context, cursor uint64, match string, count int) ([]string, uint64, error) {
+	return nil, 0, unsupported("ScanPage")
+}
+
+// Close ничего не делает: у встроенной заглушки нет ресурсов.
+func (unsupportedStorage[T]) Inspect(ctx context.Context, key string) (ObjectInfo, bool, error) {
+	return ObjectInfo{}, false, unsupported("Inspect")
+}
+
+func (unsupportedStorage[T]) Close() error {
+	return nil
+}
+
+// Capabilities возвращает пустой набор возможностей.
+func (unsupportedStorage[T]) Capabilities() Capability {
+	return 0
+}
+
+func (unsupportedStorage[T]) Enqueue(ctx context.Context, queueName string, value T) error {
+	return unsupported("Enqueue")
+}
+
+func (unsupportedStorage[T]) EnqueueN(ctx context.Context, queueName string, value T) (int64, error) {
+	return 0, unsupported("EnqueueN")
+}
+
+func (unsupportedStorage[T]) EnqueueFanout(ctx context.Context, queueNames []string, value T) error {
+	return unsupported("EnqueueFanout")
+}
+
+func (unsupportedStorage[T]) EnqueueCapped(ctx context.Context, queueName string, value T, maxLen int64) error {
+	return unsupported("EnqueueCapped")
+}
+
+func (unsupportedStorage[T]) TryEnqueue(ctx context.Context, queueName string, value T, maxLen int64) (bool, EnqueueReason, error) {
+	return false, ReasonError, unsupported("TryEnqueue")
+}
+
+func (unsupportedStorage[T]) EnqueueBounded(ctx context.Context, queueName string, value T, maxLen int64) (bool, error) {
+	return false, unsupported("EnqueueBounded")
+}
+
+func (unsupportedStorage[T]) EnqueueDelayed(ctx context.Context, queueName string, value T, delay time.Duration) error {
+	return unsupported("EnqueueDelayed")
+}
+
+func (unsupportedStorage[T]) Dequeue(ctx context.Context, queueName string) (T, bool, error) {
+	var zero T
+	return zero, false, unsupported("Dequeue")
+}
+
+func (unsupportedStorage[T]) Peek(ctx context.Context, queueName string) (T, bool, error) {
+	var zero T
+	return zero, false, unsupported("Peek")
+}
+
+func (unsupportedStorage[T]) RemoveAt(ctx context.Context, queueName string, index int64) (bool, error) {
+	return false, unsupported("RemoveAt")
+}
+
+func (unsupportedStorage[T]) ReplaceQueue(ctx context.Context, queueName string, values []T) error {
+	return unsupported("ReplaceQueue")
+}
+
+func (unsupportedStorage[T]) RotateQueue(ctx context.Context, queueName string) (T, bool, error) {
+	var zero T
+	return zero, false, unsupported("RotateQueue")
+}
+
+func (unsupportedStorage[T]) SetHead(ctx context.Context, queueName string, value T) (T, bool, error) {
+	var zero T
+	return zero, false, unsupported("SetHead")
+}
+
+func (unsupportedStorage[T]) MoveMatching(ctx context.Context, from, to string, match func(T) bool, limit int) (int, error) {
+	return 0, unsupported("MoveMatching")
+}
+
+func (unsupportedStorage[T]) CountPattern(ctx context.Context, pattern string) (int64, error) {
+	return 0, unsupported("CountPattern")
+}
+
+func (unsupportedStorage[T]) ClaimOnce(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	return false, unsupported("ClaimOnce")
+}
+
+func (unsupportedStorage[T]) CopyKey(ctx context.Context, src, dst string, replace bool) (bool, error) {
+	return false, unsupported("CopyKey")
+}
+
+func (unsupportedStorage[T]) DequeueTracked(ctx context.Context, queueName, trackingKey string, ttl time.Duration) (T, bool, error) {
+	var zero T
+	return zero, false, unsupported("DequeueTracked")
+}
+
+func (unsupportedStorage[T]) BlockingDequeueAny(ctx context.Context, queueNames []string, timeout time.Duration) (string, T, bool, error) {
+	var zero T
+	return "", zero, false, unsupported("BlockingDequeueAny")
+}
+
+func (unsupportedStorage[T]) Remove(ctx context.Context, queueName string) (bool, error) {
+	return false, unsupported("Remove")
+}
+
+func (unsupportedStorage[T]) QueueReadFrom(ctx context.Context, queueName string, offset int64, n int) ([]T, int64, error) {
+	return nil, offset, unsupported("QueueReadFrom")
+}
+
+func (unsupportedStorage[T]) QueueIterate(ctx context.Context, queueName string, fn func(T) error) error {
+	return unsupported("QueueIterate")
+}
+
+func (unsupportedStorage[T]) QueueTail(ctx context.Context, queueName string, n int) ([]T, error) {
+	return nil, unsupported("QueueTail")
+}
+
+func (unsupportedStorage[T]) QueueLen(ctx context.Context, queueName string) (int64, error) {
+	return 0, unsupported("QueueLen")
+}
+
+func (unsupportedStorage[T]) QueueLenMany(ctx context.Context, queueNames []string) (map[string]int64, error) {
+	return nil, unsupported("QueueLenMany")
+}
+
+func (unsupportedStorage[T]) QueueStats(ctx context.Context, queueName string) (QueueStats, error) {
+	return QueueStats{}, unsupported("QueueStats")
+}
+
+func (unsupportedStorage[T]) QueueIndexOf(ctx context.Context, queueName string, match func(T) bool) (int64, bool, error) {
+	return 0, false, unsupported("QueueIndexOf")
+}
+
+func (unsupportedStorage[T]) QueueIndexOfValue(ctx context.Context, queueName string, value T) (int64, bool, error) {
+	return 0, false, unsupported("QueueIndexOfValue")
+}
+
+func (unsupportedStorage[T]) PeekBatchReserve(ctx context.Context, queueName string, n int, vt time.Duration) ([]T, string, error) {
+	return nil, "", unsupported("PeekBatchReserve")
+}
+
+func (unsupportedStorage[T]) AckBatch(ctx context.Context, queueName, token string) error {
+	return unsupported("AckBatch")
+}
+
+func (unsupportedStorage[T]) IncrWindow(ctx context.Context, key string, window time.Duration, limit int64) (int64, bool, error) {
+	return 0, false, unsupported("IncrWindow")
+}
+
+func (unsupportedStorage[T]) SAddEx(ctx context.Context, setName string, member T, ttl time.Duration) error {
+	return unsupported("SAddEx")
+}
+
+func (unsupportedStorage[T]) SCount(ctx context.Context, setName string) (int64, error) {
+	return 0, unsupported("SCount")
+}
+
+func (unsupportedStorage[T]) Transact(ctx context.Context, fn func(tx Tx[T]) error) error {
+	return unsupported("Transact")
+}
+
+// Pipeline возвращает пакет, Exec которого помечает каждую команду ErrUnsupported.
+func (unsupportedStorage[T]) Pipeline() Pipeline[T] {
+	return &unsupportedPipeline[T]{}
+}
+
+// unsupportedPipeline - пакет хранилища без поддержки пакетных операций.
+type unsupportedPipeline[T any] struct {
+	pipelineOps[T]
+}
+
+// Exec возвращает ErrUnsupported для каждой накопленной команды.
+func (p *unsupportedPipeline[T]) Exec(ctx context.Context) ([]PipelineResult[T], error) {
+	ops := p.take()
+	results := make([]PipelineResult[T], len(ops))
+	for i := range results {
+		results[i].Err = unsupported("Pipeline")
+	}
+	return results, unsupported("Pipeline")
+}
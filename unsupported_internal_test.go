@@ -0,0 +1,30 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnsupportedStorage(t *testing.T) {
+	ctx := context.Background()
+	var s Storage[string] = unsupportedStorage[string]{}
+
+	err := s.Set(ctx, "key", "value", 0)
+	require.ErrorIs(t, err, ErrUnsupported)
+	require.Contains(t, err.Error(), "Set")
+
+	_, _, err = s.QueueIndexOf(ctx, "queue", func(string) bool { return true })
+	require.ErrorIs(t, err, ErrUnsupported)
+
+	pipe := s.Pipeline()
+	pipe.Get("key")
+	results, err := pipe.Exec(ctx)
+	require.ErrorIs(t, err, ErrUnsupported)
+	require.Len(t, results, 1)
+	require.ErrorIs(t, results[0].Err, ErrUnsupported)
+
+	require.Zero(t, s.Capabilities())
+	require.NoError(t, s.Close())
+}
@@ -0,0 +1,370 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+)
+
+// Журнал очередей (WithQueueWAL) - файл из JSON-строк, по одной на изменение очередей
+// in-memory хранилища. Запись добавляется в журнал до изменения очереди в памяти,
+// при создании хранилища журнал воспроизводится, а сборщик мусора сжимает его,
+// заменяя историю изменений текущим содержимым очередей.
+
+// Операции журнала очередей.
+const (
+	walPush    = "push"    // Добавление значений V в конец очереди
+	walDrop    = "drop"    // Удаление N первых элементов
+	walRemove  = "remove"  // Удаление элемента с позицией N
+	walHead    = "head"    // Замена первого элемента значением V[0]
+	walReplace = "replace" // Замена очереди значениями V
+	walDelay   = "delay"   // Отложенный элемент V[0] со временем готовности R
+	walPromote = "promote" // Перенос N первых отложенных элементов в конец очереди
+	walReserve = "reserve" // Резервирование T[0] из N первых элементов
+	walAck     = "ack"     // Подтверждение резервирования T[0]
+	walRequeue = "requeue" // Возврат резервирований T в начало очереди в порядке перечисления
+)
+
+// walCompactMin - минимальное количество записей журнала, с которого он сжимается.
+const walCompactMin = 1024
+
+// walRecord - запись журнала очередей.
+// Значения сериализуются кодеком хранилища.
+type walRecord struct {
+	Op    string   `json:"op"`
+	Queue string   `json:"q"`
+	V     [][]byte `json:"v,omitempty"`
+	N     int      `json:"n,omitempty"`
+	R     int64    `json:"r,omitempty"`
+	T     []string `json:"t,omitempty"`
+}
+
+// queueWAL - открытый файл журнала очередей. Защищен queueMu хранилища.
+type queueWAL struct {
+	path    string
+	file    *os.File
+	records int // Записей в файле
+}
+
+// append дописывает записи в конец журнала одним вызовом write.
+// Файл не синхронизируется с диском: журнал переживает падение процесса,
+// но не отключение питания.
+func (w *queueWAL) append(records ...walRecord) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, r := range records {
+		if err := enc.Encode(r); err != nil {
+			return fmt.Errorf("wal encode failed: %w", err)
+		}
+	}
+	if _, err := w.file.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf("wal write failed: %w", err)
+	}
+	w.records += len(records)
+	return nil
+}
+
+// close синхронизирует журнал с диском и закрывает файл.
+func (w *queueWAL) close() error {
+	if err := w.file.Sync(); err != nil {
+		_ = w.file.Close()
+		return fmt.Errorf("wal sync failed: %w", err)
+	}
+	if err := w.file.Close(); err != nil {
+		return fmt.Errorf("wal close failed: %w", err)
+	}
+	return nil
+}
+
+// walEncode сериализует значения для записи в журнал.
+// Если журнал выключен, возвращает nil.
+func (s *memoryStorage[T]) walEncode(values ...T) ([][]byte, error) {
+	if s.wal == nil {
+		return nil, nil
+	}
+	data := make([][]byte, len(values))
+	for i, value := range values {
+		raw, err := s.opts.codec.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("marshal failed: %w", err)
+		}
+		data[i] = raw
+	}
+	return data, nil
+}
+
+// walAppend записывает изменения очередей в журнал, если он включен.
+// Должен вызываться под блокировкой queueMu на запись до изменения очереди:
+// при ошибке очередь не изменяется.
+func (s *memoryStorage[T]) walAppend(records ...walRecord) error {
+	if s.wal == nil {
+		return nil
+	}
+	return s.wal.append(records...)
+}
+
+// walPush записывает в журнал добавление значений в конец очереди.
+// Должен вызываться под блокировкой queueMu на запись.
+func (s *memoryStorage[T]) walPush(queueName string, values ...T) error {
+	data, err := s.walEncode(values...)
+	if err != nil || data == nil {
+		return err
+	}
+	return s.walAppend(walRecord{Op: walPush, Queue: queueName, V: data})
+}
+
+// walLog записывает в журнал извлечение или перенос элементов. Такие изменения
+// не отменяются из-за ошибки журнала: она логируется, а после перезапуска
+// элементы могут быть доставлены повторно.
+// Должен вызываться под блокировкой queueMu на запись.
+func (s *memoryStorage[T]) walLog(record walRecord) {
+	if err := s.walAppend(record); err != nil {
+		s.opts.logger.Warn("storage: queue wal write failed",
+			slog.String("path", s.wal.path), slog.String("queue", record.Queue), slog.Any("error", err))
+	}
+}
+
+// openWAL открывает журнал очередей, воспроизводит его и сразу сжимает.
+// Оборванная последняя запись (падение во время записи) отбрасывается.
+// Вызывается до запуска фоновых горутин.
+func (s *memoryStorage[T]) openWAL(path string) error {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return fmt.Errorf("wal open failed: %w", err)
+	}
+	err = s.replayWAL(file)
+	_ = file.Close()
+	if err != nil {
+		return err
+	}
+
+	s.wal = &queueWAL{path: path}
+	return s.rewriteWAL()
+}
+
+// replayWAL применяет записи журнала к очередям.
+// Зарезервированные при падении элементы возвращаются в начало своих очередей
+// и доставляются повторно, как при восстановлении из снимка.
+func (s *memoryStorage[T]) replayWAL(r io.Reader) error {
+	type reserved struct {
+		queue string
+		items []T
+	}
+	reservations := make(map[string]reserved)
+	var order []string // Токены в порядке резервирования
+
+	decode := func(raw [][]byte) ([]T, error) {
+		values := make([]T, len(raw))
+		for i, data := range raw {
+			if err := s.opts.codec.Unmarshal(data, &values[i]); err != nil {
+				return nil, err
+			}
+		}
+		return values, nil
+	}
+
+	br := bufio.NewReader(r)
+	for n := 1; ; n++ {
+		line, err := br.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			break // Последняя запись без перевода строки оборвана
+		}
+		if err != nil {
+			return fmt.Errorf("wal read failed: %w", err)
+		}
+
+		var rec walRecord
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return fmt.Errorf("wal decode line %d failed: %w", n, err)
+		}
+		values, err := decode(rec.V)
+		if err != nil {
+			return fmt.Errorf("wal unmarshal queue %q failed: %w", rec.Queue, err)
+		}
+
+		switch rec.Op {
+		case walPush:
+			queue := s.queue(rec.Queue)
+			for _, value := range values {
+				queue.PushBack(value)
+			}
+		case walDrop:
+			s.consumed[rec.Queue] = struct{}{} // Очередь могла опустеть до записи состояния
+			s.dropFront(rec.Queue, rec.N)
+		case walRemove:
+			if queue := s.queues[rec.Queue]; queue != nil {
+				queue.RemoveAt(rec.N)
+				s.consumed[rec.Queue] = struct{}{}
+				s.dropIfEmpty(rec.Queue, queue)
+			}
+		case walHead:
+			s.queues[rec.Queue].SetFront(values[0])
+		case walReplace:
+			delete(s.queues, rec.Queue)
+			s.consumed[rec.Queue] = struct{}{}
+			for _, value := range values {
+				s.queue(rec.Queue).PushBack(value)
+			}
+		case walDelay:
+			pending := s.delayed[rec.Queue]
+			i := sort.Search(len(pending), func(i int) bool { return pending[i].ready > rec.R })
+			s.delayed[rec.Queue] = slices.Insert(pending, i, delayedItem[T]{value: values[0], ready: rec.R})
+		case walPromote:
+			pending := s.delayed[rec.Queue]
+			k := min(rec.N, len(pending))
+			for _, d := range pending[:k] {
+				s.queue(rec.Queue).PushBack(d.value)
+			}
+			if k == len(pending) {
+				delete(s.delayed, rec.Queue)
+			} else {
+				s.delayed[rec.Queue] = pending[k:]
+			}
+		case walReserve:
+			var items []T
+			if queue := s.queues[rec.Queue]; queue != nil {
+				items = queue.PopFrontN(rec.N)
+				s.consumed[rec.Queue] = struct{}{}
+				s.dropIfEmpty(rec.Queue, queue)
+			}
+			reservations[rec.T[0]] = reserved{queue: rec.Queue, items: items}
+			order = append(order, rec.T[0])
+		case walAck:
+			delete(reservations, rec.T[0])
+		case walRequeue:
+			var head []T
+			for _, token := range rec.T {
+				head = append(head, reservations[token].items...)
+				delete(reservations, token)
+			}
+			s.queue(rec.Queue).PushFront(head...)
+		default:
+			return fmt.Errorf("wal line %d: unknown operation %q", n, rec.Op)
+		}
+	}
+
+	// Раньше зарезервированные пачки оказываются ближе к началу очереди
+	for _, token := range slices.Backward(order) {
+		if r, ok := reservations[token]; ok {
+			s.queue(r.queue).PushFront(r.items...)
+		}
+	}
+	return nil
+}
+
+// walState возвращает записи, воспроизводящие текущее состояние очередей.
+// Должен вызываться под блокировкой queueMu.
+func (s *memoryStorage[T]) walState() ([]walRecord, error) {
+	var records []walRecord
+	for name, reserved := range s.reservations {
+		for token, r := range reserved {
+			data, err := s.walEncode(r.items...)
+			if err != nil {
+				return nil, err
+			}
+			// Резервирование забирает элементы из пустой очереди: содержимое очереди записывается позже
+			records = append(records,
+				walRecord{Op: walPush, Queue: name, V: data},
+				walRecord{Op: walReserve, Queue: name, N: len(data), T: []string{token}})
+		}
+	}
+	for name, queue := range s.queues {
+		data, err := s.walEncode(queue.Range(0, queue.Len())...)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, walRecord{Op: walPush, Queue: name, V: data})
+	}
+	for name := range s.consumed {
+		// Пустое удаление восстанавливает признак разобранной очереди (см. ErrQueueConsumed)
+		records = append(records, walRecord{Op: walDrop, Queue: name})
+	}
+	for name, pending := range s.delayed {
+		for _, d := range pending {
+			data, err := s.walEncode(d.value)
+			if err != nil {
+				return nil, err
+			}
+			records = append(records, walRecord{Op: walDelay, Queue: name, V: data, R: d.ready})
+		}
+	}
+	return records, nil
+}
+
+// rewriteWAL атомарно заменяет журнал записями текущего состояния очередей:
+// записи пишутся во временный файл в том же каталоге, который затем
+// переименовывается в журнал и используется для дальнейшей записи.
+// После Close не выполняется, чтобы не открыть новый файл, который никто не закроет.
+// Должен вызываться под блокировкой queueMu на запись.
+func (s *memoryStorage[T]) rewriteWAL() error {
+	if s.closing.Load() {
+		return ErrClosed
+	}
+	records, err := s.walState()
+	if err != nil {
+		return err
+	}
+
+	path := s.wal.path
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("wal create failed: %w", err)
+	}
+	next := &queueWAL{path: path, file: tmp}
+	if err := next.append(records...); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("wal sync failed: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("wal rename failed: %w", err)
+	}
+
+	if s.wal.file != nil {
+		_ = s.wal.file.Close() // Старый файл уже заменен
+	}
+	s.wal = next
+	return nil
+}
+
+// compactWAL сжимает журнал, если записей в нем много больше, чем элементов в очередях:
+// извлеченные элементы удаляются из журнала вместе с историей изменений.
+func (s *memoryStorage[T]) compactWAL() {
+	s.queueMu.Lock()         // Блокируем на запись
+	defer s.queueMu.Unlock() // Гарантируем разблокировку
+
+	if s.wal == nil || s.closing.Load() {
+		return
+	}
+
+	// Количество записей, которое останется после сжатия
+	live := len(s.queues)
+	for _, pending := range s.delayed {
+		live += len(pending)
+	}
+	for _, reserved := range s.reservations {
+		live += 2 * len(reserved)
+	}
+	if s.wal.records < max(walCompactMin, 2*live) {
+		return
+	}
+	if err := s.rewriteWAL(); err != nil {
+		s.opts.logger.Warn("storage: queue wal compaction failed",
+			slog.String("path", s.wal.path), slog.Any("error", err))
+	}
+}